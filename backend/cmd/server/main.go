@@ -5,11 +5,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"rbac-system/backend/internal/config"
 	"rbac-system/backend/internal/database"
 	"rbac-system/backend/internal/handlers"
+	"rbac-system/backend/internal/mailer"
 	"rbac-system/backend/internal/middleware"
+	"rbac-system/backend/internal/oidc"
 	"rbac-system/backend/internal/services"
 	"rbac-system/backend/internal/utils"
 
@@ -22,6 +25,18 @@ import (
 func main() {
 	cfg := config.Load()
 
+	passwordHasher, err := utils.NewPasswordHasher(cfg.Password)
+	if err != nil {
+		log.Fatal("Invalid password hashing configuration:", err)
+	}
+	utils.SetPasswordHasher(passwordHasher)
+
+	passwordPolicy, err := utils.NewPasswordPolicy(cfg.Policy)
+	if err != nil {
+		log.Fatal("Invalid password policy configuration:", err)
+	}
+	utils.SetPasswordPolicy(passwordPolicy)
+
 	if err := database.Connect(cfg); err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
@@ -48,18 +63,121 @@ func main() {
 		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
 		AllowCredentials: true,
 	}))
+	app.Use(middleware.ActivityLogger())
+
+	var mailTransport mailer.Mailer = mailer.NewLogMailer(cfg.Mail.LogDir, cfg.Mail.From)
+	if cfg.Mail.Driver == "smtp" {
+		mailTransport = mailer.NewSMTPMailer(cfg.SMTP, cfg.Mail.From)
+	}
+	asyncMailer := mailer.NewAsyncMailer(mailTransport, cfg.Mail.QueueSize, cfg.Mail.MaxRetries, cfg.Mail.RetryBackoff)
+	notifier := mailer.NewNotifier(asyncMailer, cfg.Mail.AppName, cfg.Mail.AppURL)
 
 	jwtService := utils.NewJWTService(cfg)
-	authService := services.NewAuthService(jwtService)
-	userService := services.NewUserService(services.NewRBACService(database.DB))
+
+	var signingKeyService *services.SigningKeyService
+	stopKeyRotation := make(chan struct{})
+	if utils.IsAsymmetricAlgorithm(cfg.JWT.Algorithm) {
+		signingKeyService = services.NewSigningKeyService(cfg)
+		if err := signingKeyService.Initialize(); err != nil {
+			log.Fatal("Failed to initialize JWT signing keys:", err)
+		}
+		jwtService.WithKeyProvider(signingKeyService)
+		go signingKeyService.StartRotation(time.Minute, stopKeyRotation)
+	} else if cfg.JWT.Algorithm != "HS256" {
+		log.Fatalf("Unsupported JWT_ALGORITHM %q", cfg.JWT.Algorithm)
+	}
+
+	mfaService := services.NewMFAService(cfg.MFA.Issuer)
+	lockoutService := services.NewLockoutService(cfg.Lockout, notifier)
+	emailVerificationService := services.NewEmailVerificationService(jwtService, cfg.Email, notifier)
+	passwordService := services.NewPasswordService(notifier, cfg.Policy.HistoryCount)
+	authService := services.NewAuthService(jwtService, mfaService, lockoutService, emailVerificationService, passwordService, notifier)
+	authService.WithRegistration(cfg.Register)
+	if len(cfg.LDAP.Directories) > 0 {
+		ldapService, err := services.NewLDAPService(cfg.LDAP)
+		if err != nil {
+			log.Fatal("Invalid LDAP configuration:", err)
+		}
+		authService.WithDirectory(ldapService)
+	}
+	rbacService := services.NewRBACService(database.DB)
+	if cfg.Email.Mode == "restrict" {
+		rbacService.RestrictUnverified(cfg.Email.UnverifiedPermissions)
+	}
+	userService := services.NewUserService(rbacService, emailVerificationService, notifier, passwordService)
 	roleService := services.NewRoleService()
 	dashboardService := services.NewDashboardService()
-	rbacService := services.NewRBACService(database.DB)
+	sessionService := services.NewSessionService()
+	tokenService := services.NewPersonalAccessTokenService(rbacService)
+	serviceAccountService := services.NewServiceAccountService(jwtService)
+	oauthService := services.NewOAuthService(cfg, jwtService, authService, serviceAccountService, rbacService)
+	invitationService := services.NewInvitationService(cfg.Register, jwtService, authService, notifier)
+	registrationService := services.NewRegistrationService()
+	magicLinkService := services.NewMagicLinkService(cfg.MagicLink, authService, notifier)
+	impersonationService := services.NewImpersonationService(jwtService, rbacService)
+
+	var oidcService *services.OIDCService
+	if cfg.OIDC.Enabled {
+		if cfg.OIDC.IssuerURL == "" || cfg.OIDC.ClientID == "" || cfg.OIDC.RedirectURL == "" {
+			log.Fatal("OIDC_ISSUER_URL, OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC is enabled")
+		}
+		oidcService = services.NewOIDCService(cfg.OIDC, oidc.NewProvider(cfg.OIDC, nil), authService)
+	}
+
+	var scimService *services.SCIMService
+	if cfg.SCIM.Enabled {
+		if len(cfg.SCIM.Token) < 32 {
+			log.Fatal("SCIM_TOKEN must be at least 32 characters when SCIM is enabled")
+		}
+		scimService = services.NewSCIMService(cfg.SCIM, passwordService)
+	}
 
-	authHandler := handlers.NewAuthHandler(*authService)
-	userHandler := handlers.NewUserHandler(userService, rbacService)
+	authHandler := handlers.NewAuthHandler(*authService, passwordService)
+	userHandler := handlers.NewUserHandler(userService, rbacService, lockoutService)
 	roleHandler := handlers.NewRoleHandler(roleService)
 	dashboardHandler := handlers.NewDashboardHandler(dashboardService)
+	sessionHandler := handlers.NewSessionHandler(sessionService, rbacService)
+	mfaHandler := handlers.NewMFAHandler(mfaService, rbacService)
+	keyHandler := handlers.NewKeyHandler(signingKeyService)
+	tokenHandler := handlers.NewTokenHandler(tokenService, rbacService)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(serviceAccountService, rbacService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService)
+	scimHandler := handlers.NewSCIMHandler(scimService)
+	invitationHandler := handlers.NewInvitationHandler(invitationService)
+	settingsHandler := handlers.NewSettingsHandler(registrationService)
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService)
+	impersonationHandler := handlers.NewImpersonationHandler(impersonationService)
+
+	authMiddleware := middleware.AuthMiddleware(authService, jwtService, tokenService, serviceAccountService)
+	requireRecentAuth := middleware.RequireRecentAuth(cfg.StepUp.MaxAge)
+
+	app.Get("/.well-known/jwks.json", keyHandler.JWKS)
+
+	oauth := app.Group("/oauth")
+	oauth.Post("/token", oauthHandler.Token)
+	oauth.Post("/introspect", oauthHandler.Introspect)
+
+	if scimService != nil {
+		scimAPI := app.Group("/scim/v2", middleware.SCIMAuth(cfg.SCIM.Token))
+		scimAPI.Get("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+		scimAPI.Get("/ResourceTypes", scimHandler.GetResourceTypes)
+		scimAPI.Get("/ResourceTypes/:id", scimHandler.GetResourceType)
+		scimAPI.Get("/Schemas", scimHandler.GetSchemas)
+		scimAPI.Get("/Schemas/:id", scimHandler.GetSchema)
+		scimAPI.Get("/Users", scimHandler.GetUsers)
+		scimAPI.Post("/Users", scimHandler.CreateUser)
+		scimAPI.Get("/Users/:id", scimHandler.GetUser)
+		scimAPI.Put("/Users/:id", scimHandler.ReplaceUser)
+		scimAPI.Patch("/Users/:id", scimHandler.PatchUser)
+		scimAPI.Delete("/Users/:id", scimHandler.DeleteUser)
+		scimAPI.Get("/Groups", scimHandler.GetGroups)
+		scimAPI.Post("/Groups", scimHandler.CreateGroup)
+		scimAPI.Get("/Groups/:id", scimHandler.GetGroup)
+		scimAPI.Put("/Groups/:id", scimHandler.ReplaceGroup)
+		scimAPI.Patch("/Groups/:id", scimHandler.PatchGroup)
+		scimAPI.Delete("/Groups/:id", scimHandler.DeleteGroup)
+	}
 
 	api := app.Group("/api")
 
@@ -67,53 +185,121 @@ func main() {
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
 	auth.Post("/refresh", authHandler.RefreshToken)
-	auth.Post("/logout", middleware.AuthMiddleware(authService, jwtService), authHandler.Logout)
+	auth.Post("/mfa/verify", authHandler.VerifyMFA)
+	auth.Post("/mfa/enroll", authHandler.BeginMFAEnrollment)
+	auth.Post("/mfa/enroll/verify", authHandler.CompleteMFAEnrollment)
+	auth.Post("/logout", authMiddleware, authHandler.Logout)
+	auth.Post("/logout-all", authMiddleware, middleware.RejectImpersonation(), authHandler.LogoutAll)
+	auth.Post("/step-up", authMiddleware, middleware.RejectServiceAccounts(), middleware.RejectPersonalAccessTokens(), middleware.RejectImpersonation(), authHandler.StepUp)
+	auth.Post("/verify-email", authHandler.VerifyEmail)
+	auth.Post("/resend-verification", authHandler.ResendVerification)
+	auth.Post("/magic-link", magicLinkHandler.RequestLink)
+	auth.Post("/magic-link/verify", magicLinkHandler.Login)
 	auth.Post("/forgot-password", authHandler.ForgotPassword)
 	auth.Post("/reset-password", authHandler.ResetPassword)
+	auth.Post("/password/expired", authHandler.ChangeExpiredPassword)
+	auth.Post("/service-token", serviceAccountHandler.IssueToken)
+	auth.Post("/invitations/accept", invitationHandler.AcceptInvitation)
+	if oidcService != nil {
+		auth.Get("/oidc/authorize", oidcHandler.Authorize)
+		auth.Post("/oidc/callback", oidcHandler.Callback)
+	}
 
 	profile := api.Group("/profile")
-	profile.Use(middleware.AuthMiddleware(authService, jwtService))
+	profile.Use(authMiddleware, middleware.RejectServiceAccounts(), middleware.RejectPersonalAccessTokens())
 	profile.Get("/", authHandler.Profile)
-	profile.Put("/", authHandler.UpdateProfile)
-	profile.Put("/password", authHandler.UpdatePassword)
+	profile.Put("/", middleware.RejectImpersonation(), authHandler.UpdateProfile)
+	profile.Put("/password", middleware.RejectImpersonation(), authHandler.UpdatePassword)
+	profile.Get("/sessions", sessionHandler.GetMySessions)
+	profile.Delete("/sessions/:id", middleware.RejectImpersonation(), sessionHandler.RevokeMySession)
+	profile.Get("/tokens", tokenHandler.GetMyTokens)
+	profile.Post("/tokens", middleware.RejectImpersonation(), tokenHandler.CreateToken)
+	profile.Delete("/tokens/:id", middleware.RejectImpersonation(), tokenHandler.RevokeMyToken)
+	profile.Post("/mfa/enroll", middleware.RejectImpersonation(), mfaHandler.Enroll)
+	profile.Post("/mfa/verify", middleware.RejectImpersonation(), mfaHandler.Verify)
+	profile.Post("/mfa/recovery-codes", middleware.RejectImpersonation(), mfaHandler.RegenerateRecoveryCodes)
+	profile.Delete("/mfa", middleware.RejectImpersonation(), mfaHandler.Disable)
 
 	users := api.Group("/users")
-	users.Use(middleware.AuthMiddleware(authService, jwtService))
+	users.Use(authMiddleware)
 	users.Get("/", middleware.RequirePermission(rbacService, "users", "read"), userHandler.GetUsers)
 	users.Post("/", middleware.RequirePermission(rbacService, "users", "create"), userHandler.CreateUser)
 	users.Get("/:id", middleware.SelfOrPermission(rbacService, "users", "read"), userHandler.GetUser)
-	users.Put("/:id", middleware.SelfOrPermission(rbacService, "users", "update"), userHandler.UpdateUser)
-	users.Delete("/:id", middleware.RequirePermission(rbacService, "users", "delete"), userHandler.DeleteUser)
+	users.Put("/:id", middleware.RejectImpersonation(), middleware.SelfOrPermission(rbacService, "users", "update"), userHandler.UpdateUser)
+	users.Delete("/:id", middleware.RequirePermission(rbacService, "users", "delete"), requireRecentAuth, userHandler.DeleteUser)
 	users.Put("/:id/activate", middleware.RequirePermission(rbacService, "users", "update"), userHandler.ActivateUser)
 	users.Put("/:id/deactivate", middleware.RequirePermission(rbacService, "users", "update"), userHandler.DeactivateUser)
-	users.Put("/:id/password", middleware.SelfOrPermission(rbacService, "users", "update"), userHandler.UpdatePassword)
+	users.Put("/:id/unlock", middleware.RequirePermission(rbacService, "users", "update"), userHandler.UnlockUser)
+	users.Put("/:id/password", middleware.RejectImpersonation(), middleware.SelfOrPermission(rbacService, "users", "update"), userHandler.UpdatePassword)
+	users.Delete("/:id/mfa", middleware.RequirePermission(rbacService, "users", "update"), mfaHandler.ResetUserMFA)
 	users.Get("/:id/activity", middleware.SelfOrPermission(rbacService, "activity_logs", "read"), userHandler.GetUserActivity)
-	users.Post("/bulk-actions", middleware.RequireRole(rbacService, "Super Admin"), userHandler.BulkActions)
+	users.Get("/:id/sessions", middleware.RequirePermission(rbacService, "sessions", "read"), sessionHandler.GetUserSessions)
+	users.Delete("/:id/sessions", middleware.RequirePermission(rbacService, "sessions", "revoke"), sessionHandler.RevokeUserSessions)
+	users.Delete("/:id/sessions/:sessionId", middleware.RequirePermission(rbacService, "sessions", "revoke"), sessionHandler.RevokeUserSession)
+	users.Get("/:id/tokens", middleware.RequirePermission(rbacService, "access_tokens", "read"), tokenHandler.GetUserTokens)
+	users.Delete("/:id/tokens/:tokenId", middleware.RequirePermission(rbacService, "access_tokens", "revoke"), tokenHandler.RevokeUserToken)
+	users.Post("/:id/impersonate", middleware.RejectServiceAccounts(), middleware.RejectPersonalAccessTokens(), middleware.RejectImpersonation(), middleware.RequirePermission(rbacService, "users", "impersonate"), impersonationHandler.Impersonate)
+	users.Post("/bulk-actions", middleware.RequireRole(rbacService, "Super Admin"), requireRecentAuth, userHandler.BulkActions)
+
+	invitations := api.Group("/invitations")
+	invitations.Use(authMiddleware, middleware.RejectServiceAccounts())
+	invitations.Get("/", middleware.RequirePermission(rbacService, "invitations", "read"), invitationHandler.GetInvitations)
+	invitations.Post("/", middleware.RequirePermission(rbacService, "invitations", "create"), invitationHandler.CreateInvitation)
+	invitations.Post("/:id/resend", middleware.RequirePermission(rbacService, "invitations", "create"), invitationHandler.ResendInvitation)
+	invitations.Delete("/:id", middleware.RequirePermission(rbacService, "invitations", "revoke"), invitationHandler.RevokeInvitation)
+
+	serviceAccounts := api.Group("/service-accounts")
+	serviceAccounts.Use(authMiddleware)
+	serviceAccounts.Get("/", middleware.RequirePermission(rbacService, "service_accounts", "read"), serviceAccountHandler.GetServiceAccounts)
+	serviceAccounts.Post("/", middleware.RequirePermission(rbacService, "service_accounts", "create"), serviceAccountHandler.CreateServiceAccount)
+	serviceAccounts.Get("/:id", middleware.RequirePermission(rbacService, "service_accounts", "read"), serviceAccountHandler.GetServiceAccount)
+	serviceAccounts.Put("/:id", middleware.RequirePermission(rbacService, "service_accounts", "update"), serviceAccountHandler.UpdateServiceAccount)
+	serviceAccounts.Delete("/:id", middleware.RequirePermission(rbacService, "service_accounts", "delete"), serviceAccountHandler.DeleteServiceAccount)
+	serviceAccounts.Post("/:id/secret", middleware.RequirePermission(rbacService, "service_accounts", "update"), serviceAccountHandler.RotateSecret)
+	serviceAccounts.Get("/:id/keys", middleware.RequirePermission(rbacService, "service_accounts", "read"), serviceAccountHandler.GetAPIKeys)
+	serviceAccounts.Post("/:id/keys", middleware.RequirePermission(rbacService, "service_accounts", "update"), serviceAccountHandler.CreateAPIKey)
+	serviceAccounts.Delete("/:id/keys/:keyId", middleware.RequirePermission(rbacService, "service_accounts", "update"), serviceAccountHandler.RevokeAPIKey)
+	serviceAccounts.Get("/:id/activity", middleware.RequirePermission(rbacService, "activity_logs", "read"), serviceAccountHandler.GetActivity)
+
+	oauthClients := api.Group("/oauth-clients")
+	oauthClients.Use(authMiddleware)
+	oauthClients.Get("/", middleware.RequirePermission(rbacService, "oauth_clients", "read"), oauthHandler.GetClients)
+	oauthClients.Post("/", middleware.RequirePermission(rbacService, "oauth_clients", "create"), oauthHandler.CreateClient)
+	oauthClients.Put("/:id", middleware.RequirePermission(rbacService, "oauth_clients", "update"), oauthHandler.UpdateClient)
+	oauthClients.Delete("/:id", middleware.RequirePermission(rbacService, "oauth_clients", "delete"), oauthHandler.DeleteClient)
 
 	roles := api.Group("/roles")
-	roles.Use(middleware.AuthMiddleware(authService, jwtService))
+	roles.Use(authMiddleware)
 	roles.Get("/", middleware.RequirePermission(rbacService, "roles", "read"), roleHandler.GetRoles)
-	roles.Post("/", middleware.RequireRole(rbacService, "Super Admin"), roleHandler.CreateRole)
+	roles.Post("/", middleware.RequireRole(rbacService, "Super Admin"), requireRecentAuth, roleHandler.CreateRole)
+	roles.Get("/hierarchy", middleware.RequirePermission(rbacService, "roles", "read"), roleHandler.GetRoleHierarchy)
 	roles.Get("/:id", middleware.RequirePermission(rbacService, "roles", "read"), roleHandler.GetRole)
-	roles.Put("/:id", middleware.RequireRole(rbacService, "Super Admin"), roleHandler.UpdateRole)
-	roles.Delete("/:id", middleware.RequireRole(rbacService, "Super Admin"), roleHandler.DeleteRole)
-	roles.Put("/:id/permissions", middleware.RequireRole(rbacService, "Super Admin"), roleHandler.AssignPermissions)
+	roles.Put("/:id", middleware.RequireRole(rbacService, "Super Admin"), requireRecentAuth, roleHandler.UpdateRole)
+	roles.Delete("/:id", middleware.RequireRole(rbacService, "Super Admin"), requireRecentAuth, roleHandler.DeleteRole)
+	roles.Put("/:id/permissions", middleware.RequireRole(rbacService, "Super Admin"), requireRecentAuth, roleHandler.AssignPermissions)
+	roles.Put("/:id/hierarchy", middleware.RejectServiceAccounts(), middleware.RequireRole(rbacService, "Super Admin"), requireRecentAuth, roleHandler.SetRoleHierarchy)
 	roles.Get("/:id/permissions", middleware.RequirePermission(rbacService, "permissions", "read"), roleHandler.GetRolePermissions)
 
 	permissions := api.Group("/permissions")
-	permissions.Use(middleware.AuthMiddleware(authService, jwtService))
+	permissions.Use(authMiddleware)
 	permissions.Get("/", middleware.RequirePermission(rbacService, "permissions", "read"), roleHandler.GetPermissions)
 
+	settings := api.Group("/settings")
+	settings.Use(authMiddleware, middleware.RejectServiceAccounts())
+	settings.Get("/registration", middleware.RequirePermission(rbacService, "settings", "read"), settingsHandler.GetRegistrationSettings)
+	settings.Put("/registration/default-role", middleware.RequirePermission(rbacService, "settings", "update"), settingsHandler.UpdateDefaultRole)
+	settings.Post("/registration/rules", middleware.RequirePermission(rbacService, "settings", "update"), settingsHandler.CreateRegistrationRule)
+	settings.Put("/registration/rules/:id", middleware.RequirePermission(rbacService, "settings", "update"), settingsHandler.UpdateRegistrationRule)
+	settings.Delete("/registration/rules/:id", middleware.RequirePermission(rbacService, "settings", "update"), settingsHandler.DeleteRegistrationRule)
+
 	dashboard := api.Group("/dashboard")
-	dashboard.Use(middleware.AuthMiddleware(authService, jwtService))
+	dashboard.Use(authMiddleware)
 	dashboard.Get("/stats", middleware.RequirePermission(rbacService, "dashboard", "read"), dashboardHandler.GetStats)
 	dashboard.Get("/role-distribution", middleware.RequirePermission(rbacService, "dashboard", "read"), dashboardHandler.GetRoleDistribution)
 	dashboard.Get("/recent-activity", middleware.RequirePermission(rbacService, "activity_logs", "read"), dashboardHandler.GetRecentActivity)
 	dashboard.Get("/user-analytics", middleware.RequirePermission(rbacService, "dashboard", "read"), dashboardHandler.GetUserAnalytics)
 	dashboard.Get("/system-health", middleware.RequireRoleAny(rbacService, "Super Admin", "Admin"), dashboardHandler.GetSystemHealth)
 
-	app.Use(middleware.ActivityLogger())
-
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return utils.SendSuccess(c, fiber.StatusOK, "Server is healthy", map[string]string{
 			"status": "ok",
@@ -138,5 +324,7 @@ func main() {
 	if err := app.Shutdown(); err != nil {
 		log.Fatal("Failed to shutdown server:", err)
 	}
+	close(stopKeyRotation)
+	asyncMailer.Close()
 	log.Println("Server shutdown complete")
 }
\ No newline at end of file
@@ -9,12 +9,25 @@ import (
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
-	JWT      JWTConfig
-	CORS     CORSConfig
-	SMTP     SMTPConfig
-	System   SystemConfig
+	Database      DatabaseConfig
+	Server        ServerConfig
+	JWT           JWTConfig
+	CORS          CORSConfig
+	SMTP          SMTPConfig
+	Mail          MailConfig
+	Password      PasswordHashConfig
+	Policy        PasswordPolicyConfig
+	MFA           MFAConfig
+	Lockout       LockoutConfig
+	Email         EmailVerificationConfig
+	MagicLink     MagicLinkConfig
+	Impersonation ImpersonationConfig
+	StepUp        StepUpConfig
+	OIDC          OIDCConfig
+	LDAP          LDAPConfig
+	SCIM          SCIMConfig
+	Register      RegistrationConfig
+	System        SystemConfig
 }
 
 type DatabaseConfig struct {
@@ -32,11 +45,21 @@ type ServerConfig struct {
 	Env  string
 }
 
+// JWTConfig selects how tokens are signed. Algorithm "HS256" uses Secret and
+// RefreshSecret; "RS256", "ES256" and "EdDSA" use rotating key pairs stored in
+// the database and published at /.well-known/jwks.json. Issued tokens name
+// Issuer and every Audience; validation requires the issuer, at least one of
+// the audiences and tolerates Leeway of clock skew.
 type JWTConfig struct {
-	Secret               string
-	RefreshSecret        string
-	AccessTokenExpiry    time.Duration
-	RefreshTokenExpiry   time.Duration
+	Secret              string
+	RefreshSecret       string
+	AccessTokenExpiry   time.Duration
+	RefreshTokenExpiry  time.Duration
+	Algorithm           string
+	KeyRotationInterval time.Duration
+	Issuer              string
+	Audience            []string
+	Leeway              time.Duration
 }
 
 type CORSConfig struct {
@@ -50,6 +73,179 @@ type SMTPConfig struct {
 	Password string
 }
 
+// MailConfig selects how outgoing email is delivered. Driver is "smtp" or
+// "log"; the log driver writes messages to LogDir (or the application log)
+// for local development.
+type MailConfig struct {
+	Driver       string
+	From         string
+	AppName      string
+	AppURL       string
+	LogDir       string
+	QueueSize    int
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+// PasswordHashConfig selects the algorithm for new password hashes
+// ("bcrypt" or "argon2id"). Argon2Memory is in KiB.
+type PasswordHashConfig struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// PasswordPolicyConfig holds the rules every new password must satisfy.
+// CommonPasswordFile replaces the bundled common-password list when set.
+type PasswordPolicyConfig struct {
+	MinLength            int
+	MaxLength            int
+	RequireUppercase     bool
+	RequireLowercase     bool
+	RequireDigit         bool
+	RequireSymbol        bool
+	DisallowUserInfo     bool
+	CheckCommonPasswords bool
+	CommonPasswordFile   string
+	// HistoryCount is how many recent passwords, including the current one,
+	// may not be reused. 0 disables the check.
+	HistoryCount int
+}
+
+type MFAConfig struct {
+	Issuer             string
+	PendingTokenExpiry time.Duration
+}
+
+type LockoutConfig struct {
+	MaxFailedAttempts   int
+	BaseDuration        time.Duration
+	MaxDuration         time.Duration
+	IPMaxFailedAttempts int
+	IPWindow            time.Duration
+}
+
+// EmailVerificationConfig controls what unverified users may do. Mode is
+// "off" (no restriction), "block" (login refused) or "restrict" (only
+// UnverifiedPermissions are granted).
+type EmailVerificationConfig struct {
+	Mode                  string
+	TokenExpiry           time.Duration
+	UnverifiedPermissions []string
+}
+
+// MagicLinkConfig configures passwordless login by emailed one-time links,
+// which roles opt into with AllowMagicLink. Links are valid for TokenExpiry.
+type MagicLinkConfig struct {
+	TokenExpiry time.Duration
+}
+
+// ImpersonationConfig limits how long an impersonation token is valid. It
+// cannot be refreshed, so support staff impersonate again once it expires.
+type ImpersonationConfig struct {
+	TokenExpiry time.Duration
+}
+
+// StepUpConfig sets how recently the user must have entered their password or
+// MFA code for routes that require step-up re-authentication.
+type StepUpConfig struct {
+	MaxAge time.Duration
+}
+
+// OIDCConfig configures login through an external OpenID Connect provider.
+// RoleRules are checked in order and the first match picks the role of a
+// provisioned user; DefaultRole applies when none match, and an empty
+// DefaultRole refuses such users. With SyncRoles the mapping is re-applied on
+// every login.
+type OIDCConfig struct {
+	Enabled       bool
+	IssuerURL     string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	AutoProvision bool
+	DefaultRole   string
+	RoleRules     []OIDCRoleRule
+	SyncRoles     bool
+}
+
+// OIDCRoleRule grants Role to users whose ID token claim Claim equals Value,
+// or contains it when the claim is a list such as groups.
+type OIDCRoleRule struct {
+	Claim string
+	Value string
+	Role  string
+}
+
+// LDAPConfig lists the directories AuthService.Login tries before the local
+// password hash. A directory with Domains only handles logins whose email
+// domain matches one of them; the rest are tried in order for every other
+// login.
+type LDAPConfig struct {
+	Directories []LDAPDirectoryConfig
+}
+
+// LDAPDirectoryConfig describes one directory. URL is ldap:// (optionally
+// upgraded with StartTLS) or ldaps://. Users are found with UserFilter, where
+// {email} and {username} stand for the login email and its local part, while
+// bound as BindDN, and then authenticated by binding as themselves. Group
+// DNs come from GroupAttribute and, when GroupBaseDN is set, from a search
+// with GroupFilter, where {dn} is the user's DN.
+type LDAPDirectoryConfig struct {
+	Name               string
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	CACertFile         string
+	Timeout            time.Duration
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string
+	Domains            []string
+	UsernameAttribute  string
+	EmailAttribute     string
+	FirstNameAttribute string
+	LastNameAttribute  string
+	GroupAttribute     string
+	GroupBaseDN        string
+	GroupFilter        string
+	GroupRoles         []LDAPGroupRole
+	DefaultRole        string
+	AutoProvision      bool
+	SyncRoles          bool
+}
+
+// LDAPGroupRole grants Role to members of the group GroupDN.
+type LDAPGroupRole struct {
+	GroupDN string
+	Role    string
+}
+
+// SCIMConfig enables the SCIM 2.0 provisioning API at /scim/v2, which accepts
+// Token as its bearer token. Users provisioned without a group, and members
+// removed from their last group, get DefaultRole.
+type SCIMConfig struct {
+	Enabled     bool
+	Token       string
+	DefaultRole string
+}
+
+// RegistrationConfig controls who may create an account. Mode is "open"
+// (anyone may register), "invite_only" (accounts are only created by
+// accepting an invitation) or "closed" (neither; administrators create
+// accounts). AllowedDomains, when set, limits open registration to those
+// email domains. Invitations expire after InvitationExpiry unless the
+// administrator picks another lifetime.
+type RegistrationConfig struct {
+	Mode             string
+	AllowedDomains   []string
+	InvitationExpiry time.Duration
+}
+
 type SystemConfig struct {
 	DefaultAdminEmail    string
 	DefaultAdminPassword string
@@ -72,16 +268,112 @@ func Load() *Config {
 	viper.SetDefault("DB_SSL_MODE", "disable")
 	viper.SetDefault("JWT_ACCESS_TOKEN_EXPIRY", "15m")
 	viper.SetDefault("JWT_REFRESH_TOKEN_EXPIRY", "168h")
+	viper.SetDefault("JWT_ALGORITHM", "HS256")
+	viper.SetDefault("JWT_KEY_ROTATION_INTERVAL", "720h")
+	viper.SetDefault("JWT_ISSUER", "rbac-system")
+	viper.SetDefault("JWT_AUDIENCE", "rbac-system-api")
+	viper.SetDefault("JWT_LEEWAY", "30s")
 	viper.SetDefault("CORS_ALLOWED_ORIGINS", "http://localhost:3000,http://localhost:5173")
+	viper.SetDefault("MAIL_DRIVER", "log")
+	viper.SetDefault("MAIL_FROM", "RBAC System <no-reply@localhost>")
+	viper.SetDefault("MAIL_APP_NAME", "RBAC System")
+	viper.SetDefault("APP_URL", "http://localhost:3000")
+	viper.SetDefault("MAIL_QUEUE_SIZE", 100)
+	viper.SetDefault("MAIL_MAX_RETRIES", 3)
+	viper.SetDefault("MAIL_RETRY_BACKOFF", "2s")
+	viper.SetDefault("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	viper.SetDefault("PASSWORD_BCRYPT_COST", 10)
+	viper.SetDefault("PASSWORD_ARGON2_MEMORY", 65536)
+	viper.SetDefault("PASSWORD_ARGON2_ITERATIONS", 3)
+	viper.SetDefault("PASSWORD_ARGON2_PARALLELISM", 2)
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 8)
+	viper.SetDefault("PASSWORD_MAX_LENGTH", 100)
+	viper.SetDefault("PASSWORD_REQUIRE_UPPERCASE", false)
+	viper.SetDefault("PASSWORD_REQUIRE_LOWERCASE", false)
+	viper.SetDefault("PASSWORD_REQUIRE_DIGIT", false)
+	viper.SetDefault("PASSWORD_REQUIRE_SYMBOL", false)
+	viper.SetDefault("PASSWORD_DISALLOW_USER_INFO", true)
+	viper.SetDefault("PASSWORD_CHECK_COMMON", true)
+	viper.SetDefault("PASSWORD_HISTORY_COUNT", 5)
+	viper.SetDefault("MFA_ISSUER", "RBAC System")
+	viper.SetDefault("MFA_PENDING_TOKEN_EXPIRY", "5m")
+	viper.SetDefault("LOGIN_MAX_FAILED_ATTEMPTS", 5)
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", "5m")
+	viper.SetDefault("LOGIN_LOCKOUT_MAX_DURATION", "24h")
+	viper.SetDefault("LOGIN_IP_MAX_FAILED_ATTEMPTS", 20)
+	viper.SetDefault("LOGIN_IP_WINDOW", "15m")
+	viper.SetDefault("EMAIL_VERIFICATION_MODE", "off")
+	viper.SetDefault("EMAIL_VERIFICATION_TOKEN_EXPIRY", "24h")
+	viper.SetDefault("EMAIL_VERIFICATION_UNVERIFIED_PERMISSIONS", "profile.read,profile.update")
+	viper.SetDefault("MAGIC_LINK_TOKEN_EXPIRY", "15m")
+	viper.SetDefault("IMPERSONATION_TOKEN_EXPIRY", "30m")
+	viper.SetDefault("STEP_UP_MAX_AGE", "5m")
+	viper.SetDefault("OIDC_ENABLED", false)
+	viper.SetDefault("OIDC_REDIRECT_URL", "http://localhost:3000/auth/oidc/callback")
+	viper.SetDefault("OIDC_SCOPES", "openid,email,profile")
+	viper.SetDefault("OIDC_AUTO_PROVISION", true)
+	viper.SetDefault("OIDC_DEFAULT_ROLE", "User")
+	viper.SetDefault("OIDC_SYNC_ROLES", false)
+	viper.SetDefault("SCIM_ENABLED", false)
+	viper.SetDefault("SCIM_DEFAULT_ROLE", "User")
+	viper.SetDefault("REGISTRATION_MODE", "open")
+	viper.SetDefault("INVITATION_EXPIRY", "168h")
 
 	accessTokenExpiry, _ := time.ParseDuration(viper.GetString("JWT_ACCESS_TOKEN_EXPIRY"))
 	refreshTokenExpiry, _ := time.ParseDuration(viper.GetString("JWT_REFRESH_TOKEN_EXPIRY"))
+	keyRotationInterval, _ := time.ParseDuration(viper.GetString("JWT_KEY_ROTATION_INTERVAL"))
+	jwtLeeway, _ := time.ParseDuration(viper.GetString("JWT_LEEWAY"))
+	mailRetryBackoff, _ := time.ParseDuration(viper.GetString("MAIL_RETRY_BACKOFF"))
+	mfaPendingTokenExpiry, _ := time.ParseDuration(viper.GetString("MFA_PENDING_TOKEN_EXPIRY"))
+	lockoutDuration, _ := time.ParseDuration(viper.GetString("LOGIN_LOCKOUT_DURATION"))
+	lockoutMaxDuration, _ := time.ParseDuration(viper.GetString("LOGIN_LOCKOUT_MAX_DURATION"))
+	ipWindow, _ := time.ParseDuration(viper.GetString("LOGIN_IP_WINDOW"))
+	emailVerificationExpiry, _ := time.ParseDuration(viper.GetString("EMAIL_VERIFICATION_TOKEN_EXPIRY"))
+	magicLinkExpiry, _ := time.ParseDuration(viper.GetString("MAGIC_LINK_TOKEN_EXPIRY"))
+	impersonationExpiry, _ := time.ParseDuration(viper.GetString("IMPERSONATION_TOKEN_EXPIRY"))
+	stepUpMaxAge, _ := time.ParseDuration(viper.GetString("STEP_UP_MAX_AGE"))
+	invitationExpiry, _ := time.ParseDuration(viper.GetString("INVITATION_EXPIRY"))
 
 	allowedOrigins := strings.Split(viper.GetString("CORS_ALLOWED_ORIGINS"), ",")
 	for i := range allowedOrigins {
 		allowedOrigins[i] = strings.TrimSpace(allowedOrigins[i])
 	}
 
+	audience := []string{}
+	for _, aud := range strings.Split(viper.GetString("JWT_AUDIENCE"), ",") {
+		if aud = strings.TrimSpace(aud); aud != "" {
+			audience = append(audience, aud)
+		}
+	}
+
+	unverifiedPermissions := []string{}
+	for _, permission := range strings.Split(viper.GetString("EMAIL_VERIFICATION_UNVERIFIED_PERMISSIONS"), ",") {
+		if permission = strings.TrimSpace(permission); permission != "" {
+			unverifiedPermissions = append(unverifiedPermissions, permission)
+		}
+	}
+
+	oidcScopes := []string{}
+	for _, scope := range strings.Split(viper.GetString("OIDC_SCOPES"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			oidcScopes = append(oidcScopes, scope)
+		}
+	}
+
+	registrationDomains := []string{}
+	for _, domain := range strings.Split(viper.GetString("REGISTRATION_ALLOWED_DOMAINS"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			registrationDomains = append(registrationDomains, strings.ToLower(domain))
+		}
+	}
+
+	ldapDirectories := []LDAPDirectoryConfig{}
+	for _, name := range strings.Split(viper.GetString("LDAP_DIRECTORIES"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			ldapDirectories = append(ldapDirectories, loadLDAPDirectory(name))
+		}
+	}
+
 	return &Config{
 		Database: DatabaseConfig{
 			Host:     viper.GetString("DB_HOST"),
@@ -97,10 +389,15 @@ func Load() *Config {
 			Env:  viper.GetString("ENV"),
 		},
 		JWT: JWTConfig{
-			Secret:               viper.GetString("JWT_SECRET"),
-			RefreshSecret:        viper.GetString("JWT_REFRESH_SECRET"),
-			AccessTokenExpiry:    accessTokenExpiry,
-			RefreshTokenExpiry:   refreshTokenExpiry,
+			Secret:              viper.GetString("JWT_SECRET"),
+			RefreshSecret:       viper.GetString("JWT_REFRESH_SECRET"),
+			AccessTokenExpiry:   accessTokenExpiry,
+			RefreshTokenExpiry:  refreshTokenExpiry,
+			Algorithm:           viper.GetString("JWT_ALGORITHM"),
+			KeyRotationInterval: keyRotationInterval,
+			Issuer:              viper.GetString("JWT_ISSUER"),
+			Audience:            audience,
+			Leeway:              jwtLeeway,
 		},
 		CORS: CORSConfig{
 			AllowedOrigins: allowedOrigins,
@@ -111,9 +408,201 @@ func Load() *Config {
 			Username: viper.GetString("SMTP_USERNAME"),
 			Password: viper.GetString("SMTP_PASSWORD"),
 		},
+		Mail: MailConfig{
+			Driver:       viper.GetString("MAIL_DRIVER"),
+			From:         viper.GetString("MAIL_FROM"),
+			AppName:      viper.GetString("MAIL_APP_NAME"),
+			AppURL:       viper.GetString("APP_URL"),
+			LogDir:       viper.GetString("MAIL_LOG_DIR"),
+			QueueSize:    viper.GetInt("MAIL_QUEUE_SIZE"),
+			MaxRetries:   viper.GetInt("MAIL_MAX_RETRIES"),
+			RetryBackoff: mailRetryBackoff,
+		},
+		Password: PasswordHashConfig{
+			Algorithm:         viper.GetString("PASSWORD_HASH_ALGORITHM"),
+			BcryptCost:        viper.GetInt("PASSWORD_BCRYPT_COST"),
+			Argon2Memory:      viper.GetUint32("PASSWORD_ARGON2_MEMORY"),
+			Argon2Iterations:  viper.GetUint32("PASSWORD_ARGON2_ITERATIONS"),
+			Argon2Parallelism: uint8(viper.GetUint("PASSWORD_ARGON2_PARALLELISM")),
+		},
+		Policy: PasswordPolicyConfig{
+			MinLength:            viper.GetInt("PASSWORD_MIN_LENGTH"),
+			MaxLength:            viper.GetInt("PASSWORD_MAX_LENGTH"),
+			RequireUppercase:     viper.GetBool("PASSWORD_REQUIRE_UPPERCASE"),
+			RequireLowercase:     viper.GetBool("PASSWORD_REQUIRE_LOWERCASE"),
+			RequireDigit:         viper.GetBool("PASSWORD_REQUIRE_DIGIT"),
+			RequireSymbol:        viper.GetBool("PASSWORD_REQUIRE_SYMBOL"),
+			DisallowUserInfo:     viper.GetBool("PASSWORD_DISALLOW_USER_INFO"),
+			CheckCommonPasswords: viper.GetBool("PASSWORD_CHECK_COMMON"),
+			CommonPasswordFile:   viper.GetString("PASSWORD_COMMON_LIST_FILE"),
+			HistoryCount:         viper.GetInt("PASSWORD_HISTORY_COUNT"),
+		},
+		MFA: MFAConfig{
+			Issuer:             viper.GetString("MFA_ISSUER"),
+			PendingTokenExpiry: mfaPendingTokenExpiry,
+		},
+		Lockout: LockoutConfig{
+			MaxFailedAttempts:   viper.GetInt("LOGIN_MAX_FAILED_ATTEMPTS"),
+			BaseDuration:        lockoutDuration,
+			MaxDuration:         lockoutMaxDuration,
+			IPMaxFailedAttempts: viper.GetInt("LOGIN_IP_MAX_FAILED_ATTEMPTS"),
+			IPWindow:            ipWindow,
+		},
+		Email: EmailVerificationConfig{
+			Mode:                  viper.GetString("EMAIL_VERIFICATION_MODE"),
+			TokenExpiry:           emailVerificationExpiry,
+			UnverifiedPermissions: unverifiedPermissions,
+		},
+		MagicLink: MagicLinkConfig{
+			TokenExpiry: magicLinkExpiry,
+		},
+		Impersonation: ImpersonationConfig{
+			TokenExpiry: impersonationExpiry,
+		},
+		StepUp: StepUpConfig{
+			MaxAge: stepUpMaxAge,
+		},
+		OIDC: OIDCConfig{
+			Enabled:       viper.GetBool("OIDC_ENABLED"),
+			IssuerURL:     viper.GetString("OIDC_ISSUER_URL"),
+			ClientID:      viper.GetString("OIDC_CLIENT_ID"),
+			ClientSecret:  viper.GetString("OIDC_CLIENT_SECRET"),
+			RedirectURL:   viper.GetString("OIDC_REDIRECT_URL"),
+			Scopes:        oidcScopes,
+			AutoProvision: viper.GetBool("OIDC_AUTO_PROVISION"),
+			DefaultRole:   viper.GetString("OIDC_DEFAULT_ROLE"),
+			RoleRules:     parseOIDCRoleRules(viper.GetString("OIDC_ROLE_RULES")),
+			SyncRoles:     viper.GetBool("OIDC_SYNC_ROLES"),
+		},
+		LDAP: LDAPConfig{
+			Directories: ldapDirectories,
+		},
+		SCIM: SCIMConfig{
+			Enabled:     viper.GetBool("SCIM_ENABLED"),
+			Token:       viper.GetString("SCIM_TOKEN"),
+			DefaultRole: viper.GetString("SCIM_DEFAULT_ROLE"),
+		},
+		Register: RegistrationConfig{
+			Mode:             viper.GetString("REGISTRATION_MODE"),
+			AllowedDomains:   registrationDomains,
+			InvitationExpiry: invitationExpiry,
+		},
 		System: SystemConfig{
 			DefaultAdminEmail:    viper.GetString("DEFAULT_ADMIN_EMAIL"),
 			DefaultAdminPassword: viper.GetString("DEFAULT_ADMIN_PASSWORD"),
 		},
 	}
-}
\ No newline at end of file
+}
+
+// parseOIDCRoleRules reads comma-separated "claim:value=Role" rules, e.g.
+// "groups:rbac-admins=Admin,hd:example.com=User". Malformed rules are skipped.
+func parseOIDCRoleRules(raw string) []OIDCRoleRule {
+	rules := []OIDCRoleRule{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		claim, rest, ok := strings.Cut(entry, ":")
+		separator := strings.LastIndex(rest, "=")
+		if !ok || separator < 0 {
+			log.Printf("Warning: ignoring malformed OIDC role rule %q", entry)
+			continue
+		}
+
+		rule := OIDCRoleRule{
+			Claim: strings.TrimSpace(claim),
+			Value: strings.TrimSpace(rest[:separator]),
+			Role:  strings.TrimSpace(rest[separator+1:]),
+		}
+		if rule.Claim == "" || rule.Value == "" || rule.Role == "" {
+			log.Printf("Warning: ignoring malformed OIDC role rule %q", entry)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// loadLDAPDirectory reads the LDAP_<NAME>_* settings of one directory listed
+// in LDAP_DIRECTORIES.
+func loadLDAPDirectory(name string) LDAPDirectoryConfig {
+	prefix := "LDAP_" + strings.ToUpper(name) + "_"
+
+	viper.SetDefault(prefix+"TIMEOUT", "10s")
+	viper.SetDefault(prefix+"USER_FILTER", "(mail={email})")
+	viper.SetDefault(prefix+"USERNAME_ATTRIBUTE", "uid")
+	viper.SetDefault(prefix+"EMAIL_ATTRIBUTE", "mail")
+	viper.SetDefault(prefix+"FIRST_NAME_ATTRIBUTE", "givenName")
+	viper.SetDefault(prefix+"LAST_NAME_ATTRIBUTE", "sn")
+	viper.SetDefault(prefix+"GROUP_ATTRIBUTE", "memberOf")
+	viper.SetDefault(prefix+"GROUP_FILTER", "(|(member={dn})(uniqueMember={dn}))")
+	viper.SetDefault(prefix+"DEFAULT_ROLE", "User")
+	viper.SetDefault(prefix+"AUTO_PROVISION", true)
+	viper.SetDefault(prefix+"SYNC_ROLES", true)
+
+	timeout, _ := time.ParseDuration(viper.GetString(prefix + "TIMEOUT"))
+
+	domains := []string{}
+	for _, domain := range strings.Split(viper.GetString(prefix+"DOMAINS"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, strings.ToLower(domain))
+		}
+	}
+
+	return LDAPDirectoryConfig{
+		Name:               name,
+		URL:                viper.GetString(prefix + "URL"),
+		StartTLS:           viper.GetBool(prefix + "START_TLS"),
+		InsecureSkipVerify: viper.GetBool(prefix + "INSECURE_SKIP_VERIFY"),
+		CACertFile:         viper.GetString(prefix + "CA_CERT_FILE"),
+		Timeout:            timeout,
+		BindDN:             viper.GetString(prefix + "BIND_DN"),
+		BindPassword:       viper.GetString(prefix + "BIND_PASSWORD"),
+		BaseDN:             viper.GetString(prefix + "BASE_DN"),
+		UserFilter:         viper.GetString(prefix + "USER_FILTER"),
+		Domains:            domains,
+		UsernameAttribute:  viper.GetString(prefix + "USERNAME_ATTRIBUTE"),
+		EmailAttribute:     viper.GetString(prefix + "EMAIL_ATTRIBUTE"),
+		FirstNameAttribute: viper.GetString(prefix + "FIRST_NAME_ATTRIBUTE"),
+		LastNameAttribute:  viper.GetString(prefix + "LAST_NAME_ATTRIBUTE"),
+		GroupAttribute:     viper.GetString(prefix + "GROUP_ATTRIBUTE"),
+		GroupBaseDN:        viper.GetString(prefix + "GROUP_BASE_DN"),
+		GroupFilter:        viper.GetString(prefix + "GROUP_FILTER"),
+		GroupRoles:         parseLDAPGroupRoles(viper.GetString(prefix + "GROUP_ROLES")),
+		DefaultRole:        viper.GetString(prefix + "DEFAULT_ROLE"),
+		AutoProvision:      viper.GetBool(prefix + "AUTO_PROVISION"),
+		SyncRoles:          viper.GetBool(prefix + "SYNC_ROLES"),
+	}
+}
+
+// parseLDAPGroupRoles reads semicolon-separated "groupDN=Role" rules; the
+// role follows the last "=", e.g.
+// "cn=admins,ou=groups,dc=example,dc=com=Admin;cn=staff,ou=groups,dc=example,dc=com=User".
+func parseLDAPGroupRoles(raw string) []LDAPGroupRole {
+	rules := []LDAPGroupRole{}
+	for _, entry := range strings.Split(raw, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		separator := strings.LastIndex(entry, "=")
+		if separator < 0 {
+			log.Printf("Warning: ignoring malformed LDAP group role rule %q", entry)
+			continue
+		}
+
+		rule := LDAPGroupRole{
+			GroupDN: strings.TrimSpace(entry[:separator]),
+			Role:    strings.TrimSpace(entry[separator+1:]),
+		}
+		if rule.GroupDN == "" || rule.Role == "" {
+			log.Printf("Warning: ignoring malformed LDAP group role rule %q", entry)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
@@ -43,16 +43,56 @@ func Migrate() error {
 		&models.Permission{},
 		&models.ActivityLog{},
 		&models.PasswordResetToken{},
+		&models.MagicLinkToken{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.Session{},
+		&models.MFARecoveryCode{},
+		&models.LoginAttempt{},
+		&models.PasswordHistory{},
+		&models.SigningKey{},
+		&models.PersonalAccessToken{},
+		&models.ServiceAccount{},
+		&models.ServiceAccountAPIKey{},
+		&models.OAuthClient{},
+		&models.UserIdentity{},
+		&models.OIDCLoginState{},
+		&models.Invitation{},
+		&models.SystemSetting{},
+		&models.RegistrationRoleRule{},
 		&models.SeedTracker{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if err := migrateUserRoles(); err != nil {
+		return fmt.Errorf("failed to migrate user roles: %w", err)
+	}
+
 	log.Println("Database migration completed successfully")
 	return nil
 }
 
+// migrateUserRoles copies role_id into user_roles for users created before
+// users could hold several roles. It only adds missing rows, so it is safe to
+// run on every start.
+func migrateUserRoles() error {
+	result := DB.Exec(`INSERT INTO user_roles (user_id, role_id)
+		SELECT users.id, users.role_id FROM users
+		WHERE users.role_id <> 0 AND NOT EXISTS (
+			SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id AND user_roles.role_id = users.role_id
+		)`)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		log.Printf("Assigned the primary role of %d users as their first role", result.RowsAffected)
+	}
+	return nil
+}
+
 func GetDB() *gorm.DB {
 	return DB
-}
\ No newline at end of file
+}
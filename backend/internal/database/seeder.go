@@ -15,7 +15,7 @@ func SeedDatabase(cfg *config.Config) error {
 	var seedTracker models.SeedTracker
 	if err := DB.Where("seed_name = ? AND is_completed = ?", "initial_seed", true).First(&seedTracker).Error; err == nil {
 		log.Println("Database already seeded, skipping seeding process")
-		return nil
+		return seedUpdates()
 	}
 
 	log.Println("Starting database seeding process...")
@@ -28,6 +28,10 @@ func SeedDatabase(cfg *config.Config) error {
 		return err
 	}
 
+	if err := seedRoleHierarchy(); err != nil {
+		return err
+	}
+
 	if err := seedDefaultAdmin(cfg); err != nil {
 		return err
 	}
@@ -42,7 +46,15 @@ func SeedDatabase(cfg *config.Config) error {
 	}
 
 	log.Println("Database seeding completed successfully")
-	return nil
+	return seedUpdates()
+}
+
+// seedUpdates applies the seeds added after the initial seed.
+func seedUpdates() error {
+	if err := seedPermissionSets(); err != nil {
+		return err
+	}
+	return seedRoleLevels()
 }
 
 func seedPermissions() error {
@@ -128,6 +140,205 @@ func seedRoles() error {
 	return nil
 }
 
+// permissionSet is a group of permissions introduced after the initial seed.
+// Each set is applied once, tracked by name, so existing databases pick up
+// new permissions without re-running the initial seed.
+type permissionSet struct {
+	name        string
+	permissions []models.Permission
+	roles       map[string][]string
+}
+
+var permissionSets = []permissionSet{
+	{
+		name: "sessions_permissions",
+		permissions: []models.Permission{
+			{Name: "sessions.read", Resource: "sessions", Action: "read", Description: "View other users' sessions"},
+			{Name: "sessions.revoke", Resource: "sessions", Action: "revoke", Description: "Revoke other users' sessions"},
+		},
+		roles: map[string][]string{
+			"Super Admin": {"sessions.read", "sessions.revoke"},
+			"Admin":       {"sessions.read", "sessions.revoke"},
+		},
+	},
+	{
+		name: "access_tokens_permissions",
+		permissions: []models.Permission{
+			{Name: "access_tokens.read", Resource: "access_tokens", Action: "read", Description: "View other users' personal access tokens"},
+			{Name: "access_tokens.revoke", Resource: "access_tokens", Action: "revoke", Description: "Revoke other users' personal access tokens"},
+		},
+		roles: map[string][]string{
+			"Super Admin": {"access_tokens.read", "access_tokens.revoke"},
+			"Admin":       {"access_tokens.read", "access_tokens.revoke"},
+		},
+	},
+	{
+		name: "service_accounts_permissions",
+		permissions: []models.Permission{
+			{Name: "service_accounts.create", Resource: "service_accounts", Action: "create", Description: "Create service accounts"},
+			{Name: "service_accounts.read", Resource: "service_accounts", Action: "read", Description: "View service accounts and their API keys"},
+			{Name: "service_accounts.update", Resource: "service_accounts", Action: "update", Description: "Update service accounts and manage their credentials"},
+			{Name: "service_accounts.delete", Resource: "service_accounts", Action: "delete", Description: "Delete service accounts"},
+		},
+		roles: map[string][]string{
+			"Super Admin": {"service_accounts.create", "service_accounts.read", "service_accounts.update", "service_accounts.delete"},
+			"Admin":       {"service_accounts.read"},
+		},
+	},
+	{
+		name: "oauth_clients_permissions",
+		permissions: []models.Permission{
+			{Name: "oauth_clients.create", Resource: "oauth_clients", Action: "create", Description: "Register OAuth clients"},
+			{Name: "oauth_clients.read", Resource: "oauth_clients", Action: "read", Description: "View OAuth clients"},
+			{Name: "oauth_clients.update", Resource: "oauth_clients", Action: "update", Description: "Update OAuth clients and their scopes"},
+			{Name: "oauth_clients.delete", Resource: "oauth_clients", Action: "delete", Description: "Delete OAuth clients"},
+		},
+		roles: map[string][]string{
+			"Super Admin": {"oauth_clients.create", "oauth_clients.read", "oauth_clients.update", "oauth_clients.delete"},
+			"Admin":       {"oauth_clients.read"},
+		},
+	},
+	{
+		name: "invitations_permissions",
+		permissions: []models.Permission{
+			{Name: "invitations.create", Resource: "invitations", Action: "create", Description: "Invite users and resend invitations"},
+			{Name: "invitations.read", Resource: "invitations", Action: "read", Description: "View pending invitations"},
+			{Name: "invitations.revoke", Resource: "invitations", Action: "revoke", Description: "Revoke pending invitations"},
+		},
+		roles: map[string][]string{
+			"Super Admin": {"invitations.create", "invitations.read", "invitations.revoke"},
+			"Admin":       {"invitations.create", "invitations.read", "invitations.revoke"},
+		},
+	},
+	{
+		name: "settings_permissions",
+		permissions: []models.Permission{
+			{Name: "settings.read", Resource: "settings", Action: "read", Description: "View system settings"},
+			{Name: "settings.update", Resource: "settings", Action: "update", Description: "Change system settings such as the default role"},
+		},
+		roles: map[string][]string{
+			"Super Admin": {"settings.read", "settings.update"},
+			"Admin":       {"settings.read"},
+		},
+	},
+	{
+		name: "impersonation_permissions",
+		permissions: []models.Permission{
+			{Name: "users.impersonate", Resource: "users", Action: "impersonate", Description: "Sign in as users you can manage to see what they see"},
+		},
+		roles: map[string][]string{
+			"Super Admin": {"users.impersonate"},
+			"Admin":       {"users.impersonate"},
+		},
+	},
+}
+
+func seedPermissionSets() error {
+	for _, set := range permissionSets {
+		var tracker models.SeedTracker
+		if err := DB.Where("seed_name = ? AND is_completed = ?", set.name, true).First(&tracker).Error; err == nil {
+			continue
+		}
+
+		for _, permission := range set.permissions {
+			var existingPermission models.Permission
+			if err := DB.Where("name = ?", permission.Name).First(&existingPermission).Error; err != nil {
+				if err := DB.Create(&permission).Error; err != nil {
+					return err
+				}
+				log.Printf("Created permission: %s", permission.Name)
+			}
+		}
+
+		for roleName, permissionNames := range set.roles {
+			var role models.Role
+			if err := DB.Where("name = ?", roleName).First(&role).Error; err != nil {
+				log.Printf("Warning: role %s not found, skipping %s permissions", roleName, set.name)
+				continue
+			}
+
+			var permissions []models.Permission
+			if err := DB.Where("name IN ?", permissionNames).Find(&permissions).Error; err != nil {
+				return err
+			}
+
+			if err := DB.Model(&role).Association("Permissions").Append(&permissions); err != nil {
+				return err
+			}
+		}
+
+		if err := DB.Where(models.SeedTracker{SeedName: set.name}).Assign(models.SeedTracker{IsCompleted: true}).FirstOrCreate(&models.SeedTracker{}).Error; err != nil {
+			log.Printf("Warning: Failed to mark %s as completed: %v", set.name, err)
+		}
+
+		log.Printf("Applied permission set: %s", set.name)
+	}
+
+	return nil
+}
+
+// systemRoleLevels ranks the system roles. The gaps leave room for custom
+// roles in between.
+var systemRoleLevels = map[string]int{
+	"Super Admin": 40,
+	"Admin":       30,
+	"Manager":     20,
+	"User":        10,
+}
+
+// systemRoleParents lets each system role inherit from the one ranked below it.
+var systemRoleParents = [][2]string{
+	{"Super Admin", "Admin"},
+	{"Admin", "Manager"},
+	{"Manager", "User"},
+}
+
+// seedRoleHierarchy links the system roles as part of the initial seed only,
+// so existing databases don't suddenly gain inherited permissions. Roles that
+// already have a parent are left alone.
+func seedRoleHierarchy() error {
+	for _, link := range systemRoleParents {
+		var role, parent models.Role
+		if err := DB.Where("name = ?", link[0]).First(&role).Error; err != nil {
+			log.Printf("Warning: role %s not found, skipping its parent", link[0])
+			continue
+		}
+		if err := DB.Where("name = ?", link[1]).First(&parent).Error; err != nil {
+			log.Printf("Warning: role %s not found, skipping it as parent of %s", link[1], link[0])
+			continue
+		}
+
+		if err := DB.Model(&models.Role{}).Where("id = ? AND parent_id IS NULL", role.ID).Update("parent_id", parent.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	log.Println("Applied role hierarchy")
+	return nil
+}
+
+// seedRoleLevels ranks the system roles once. Roles that already have a
+// level are left alone.
+func seedRoleLevels() error {
+	var tracker models.SeedTracker
+	if err := DB.Where("seed_name = ? AND is_completed = ?", "role_levels", true).First(&tracker).Error; err == nil {
+		return nil
+	}
+
+	for name, level := range systemRoleLevels {
+		if err := DB.Model(&models.Role{}).Where("name = ? AND level = 0", name).Update("level", level).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := DB.Where(models.SeedTracker{SeedName: "role_levels"}).Assign(models.SeedTracker{IsCompleted: true}).FirstOrCreate(&models.SeedTracker{}).Error; err != nil {
+		log.Printf("Warning: Failed to mark role_levels as completed: %v", err)
+	}
+
+	log.Println("Applied role levels")
+	return nil
+}
+
 func seedDefaultAdmin(cfg *config.Config) error {
 	if cfg.System.DefaultAdminEmail == "" || cfg.System.DefaultAdminPassword == "" {
 		log.Println("Skipping default admin creation - credentials not provided")
@@ -158,11 +369,12 @@ func seedDefaultAdmin(cfg *config.Config) error {
 		FirstName:       "System",
 		LastName:        "Administrator",
 		RoleID:          superAdminRole.ID,
+		Roles:           []models.Role{superAdminRole},
 		IsActive:        true,
 		EmailVerifiedAt: &now,
 	}
 
-	if err := DB.Create(&admin).Error; err != nil {
+	if err := DB.Omit("Roles.*").Create(&admin).Error; err != nil {
 		return err
 	}
 
@@ -191,6 +403,10 @@ func ForceSeedDatabase(cfg *config.Config) error {
 		return err
 	}
 
+	if err := seedRoleHierarchy(); err != nil {
+		return err
+	}
+
 	if err := seedDefaultAdmin(cfg); err != nil {
 		return err
 	}
@@ -215,5 +431,5 @@ func ForceSeedDatabase(cfg *config.Config) error {
 	}
 
 	log.Println("Force seeding completed successfully")
-	return nil
+	return seedUpdates()
 }
\ No newline at end of file
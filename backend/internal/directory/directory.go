@@ -0,0 +1,232 @@
+// Package directory authenticates users against an LDAP directory such as
+// OpenLDAP or Active Directory: it looks the user up with a service account,
+// verifies the password by binding as the user and reads their attributes
+// and group DNs.
+package directory
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"rbac-system/backend/internal/config"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found in directory")
+	ErrInvalidCredentials = errors.New("invalid directory credentials")
+)
+
+// Entry is a user as read from the directory.
+type Entry struct {
+	DN        string
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+	Groups    []string
+}
+
+// MemberOf reports whether the entry belongs to groupDN, comparing DNs
+// case-insensitively.
+func (e *Entry) MemberOf(groupDN string) bool {
+	want, err := ldap.ParseDN(groupDN)
+	for _, group := range e.Groups {
+		have, haveErr := ldap.ParseDN(group)
+		if err == nil && haveErr == nil {
+			if have.EqualFold(want) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(group, groupDN) {
+			return true
+		}
+	}
+	return false
+}
+
+// Client talks to one directory. Every call opens its own connection.
+type Client struct {
+	config    config.LDAPDirectoryConfig
+	tlsConfig *tls.Config
+}
+
+func NewClient(cfg config.LDAPDirectoryConfig) (*Client, error) {
+	serverURL, err := url.Parse(cfg.URL)
+	if err != nil || (serverURL.Scheme != "ldap" && serverURL.Scheme != "ldaps") {
+		return nil, fmt.Errorf("invalid LDAP URL %q", cfg.URL)
+	}
+	if serverURL.Scheme == "ldaps" && cfg.StartTLS {
+		return nil, errors.New("StartTLS cannot be combined with an ldaps:// URL")
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         serverURL.Hostname(),
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if cfg.CACertFile != "" {
+		caPEM, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &Client{config: cfg, tlsConfig: tlsConfig}, nil
+}
+
+func (c *Client) Name() string {
+	return c.config.Name
+}
+
+// Authenticate finds the user for email and verifies password by binding as
+// them. It returns ErrUserNotFound when the directory has no such user and
+// ErrInvalidCredentials when the bind is refused.
+func (c *Client) Authenticate(email, password string) (*Entry, error) {
+	// An empty password would be an unauthenticated bind, which many
+	// servers accept without checking anything.
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := c.bindServiceAccount(conn); err != nil {
+		return nil, err
+	}
+
+	entry, err := c.findUser(conn, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if c.config.GroupBaseDN != "" {
+		// Users often may not search groups themselves.
+		if err := c.bindServiceAccount(conn); err != nil {
+			return nil, err
+		}
+		groups, err := c.findGroups(conn, entry.DN)
+		if err != nil {
+			return nil, err
+		}
+		entry.Groups = append(entry.Groups, groups...)
+	}
+
+	return entry, nil
+}
+
+func (c *Client) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(c.config.URL, ldap.DialWithTLSConfig(c.tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	if c.config.Timeout > 0 {
+		conn.SetTimeout(c.config.Timeout)
+	}
+
+	if c.config.StartTLS {
+		if err := conn.StartTLS(c.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS failed: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *Client) bindServiceAccount(conn *ldap.Conn) error {
+	if c.config.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(c.config.BindDN, c.config.BindPassword); err != nil {
+		return fmt.Errorf("service account bind failed: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) findUser(conn *ldap.Conn, email string) (*Entry, error) {
+	username, _, _ := strings.Cut(email, "@")
+	filter := strings.NewReplacer(
+		"{email}", ldap.EscapeFilter(email),
+		"{username}", ldap.EscapeFilter(username),
+	).Replace(c.config.UserFilter)
+
+	attributes := []string{c.config.UsernameAttribute, c.config.EmailAttribute, c.config.FirstNameAttribute, c.config.LastNameAttribute}
+	if c.config.GroupAttribute != "" {
+		attributes = append(attributes, c.config.GroupAttribute)
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		c.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		filter, attributes, nil,
+	))
+	// Hitting the size limit of 2 still returns the entries found so far.
+	if err != nil && (result == nil || !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded)) {
+		return nil, err
+	}
+
+	switch {
+	case len(result.Entries) == 0:
+		return nil, ErrUserNotFound
+	case len(result.Entries) > 1:
+		return nil, errors.New("directory search matched more than one user")
+	}
+
+	found := result.Entries[0]
+	entry := &Entry{
+		DN:        found.DN,
+		Username:  found.GetAttributeValue(c.config.UsernameAttribute),
+		Email:     found.GetAttributeValue(c.config.EmailAttribute),
+		FirstName: found.GetAttributeValue(c.config.FirstNameAttribute),
+		LastName:  found.GetAttributeValue(c.config.LastNameAttribute),
+	}
+	if c.config.GroupAttribute != "" {
+		entry.Groups = found.GetAttributeValues(c.config.GroupAttribute)
+	}
+	if entry.Email == "" {
+		entry.Email = email
+	}
+
+	return entry, nil
+}
+
+func (c *Client) findGroups(conn *ldap.Conn, userDN string) ([]string, error) {
+	filter := strings.ReplaceAll(c.config.GroupFilter, "{dn}", ldap.EscapeFilter(userDN))
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		c.config.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, []string{"1.1"}, nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, group := range result.Entries {
+		groups = append(groups, group.DN)
+	}
+	return groups, nil
+}
@@ -0,0 +1,125 @@
+package directory_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/directory"
+	"rbac-system/backend/internal/directory/ldaptest"
+)
+
+const aliceDN = "uid=alice,ou=people,dc=example,dc=com"
+
+func setupDirectory(t *testing.T) (*ldaptest.Server, config.LDAPDirectoryConfig) {
+	server, err := ldaptest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	server.AddEntry("cn=service,dc=example,dc=com", map[string][]string{"userPassword": {"service-secret"}})
+	server.AddEntry(aliceDN, map[string][]string{
+		"uid":          {"alice"},
+		"mail":         {"alice@example.com"},
+		"givenName":    {"Alice"},
+		"sn":           {"Doe"},
+		"memberOf":     {"cn=admins,ou=groups,dc=example,dc=com"},
+		"userPassword": {"alice-secret"},
+	})
+	server.AddEntry("cn=staff,ou=groups,dc=example,dc=com", map[string][]string{"member": {aliceDN}})
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, server.CACertPEM, 0o600))
+
+	cfg := config.LDAPDirectoryConfig{
+		Name:               "corp",
+		URL:                server.URL,
+		StartTLS:           true,
+		CACertFile:         caFile,
+		Timeout:            5 * time.Second,
+		BindDN:             "cn=service,dc=example,dc=com",
+		BindPassword:       "service-secret",
+		BaseDN:             "dc=example,dc=com",
+		UserFilter:         "(mail={email})",
+		UsernameAttribute:  "uid",
+		EmailAttribute:     "mail",
+		FirstNameAttribute: "givenName",
+		LastNameAttribute:  "sn",
+		GroupAttribute:     "memberOf",
+		GroupFilter:        "(|(member={dn})(uniqueMember={dn}))",
+	}
+	return server, cfg
+}
+
+func TestClient_AuthenticateOverStartTLS(t *testing.T) {
+	server, cfg := setupDirectory(t)
+	server.RequireTLS = true
+
+	client, err := directory.NewClient(cfg)
+	require.NoError(t, err)
+
+	entry, err := client.Authenticate("alice@example.com", "alice-secret")
+	require.NoError(t, err)
+	assert.Equal(t, aliceDN, entry.DN)
+	assert.Equal(t, "alice", entry.Username)
+	assert.Equal(t, "alice@example.com", entry.Email)
+	assert.Equal(t, "Alice", entry.FirstName)
+	assert.Equal(t, "Doe", entry.LastName)
+	assert.True(t, entry.MemberOf("CN=Admins,OU=Groups,DC=Example,DC=Com"))
+	assert.Contains(t, server.Binds(), aliceDN)
+}
+
+func TestClient_RefusesPlaintextWhenServerRequiresTLS(t *testing.T) {
+	server, cfg := setupDirectory(t)
+	server.RequireTLS = true
+	cfg.StartTLS = false
+
+	client, err := directory.NewClient(cfg)
+	require.NoError(t, err)
+
+	_, err = client.Authenticate("alice@example.com", "alice-secret")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, directory.ErrInvalidCredentials)
+	assert.Empty(t, server.Binds())
+}
+
+func TestClient_InvalidCredentialsAndUnknownUser(t *testing.T) {
+	_, cfg := setupDirectory(t)
+	client, err := directory.NewClient(cfg)
+	require.NoError(t, err)
+
+	_, err = client.Authenticate("alice@example.com", "wrong")
+	assert.ErrorIs(t, err, directory.ErrInvalidCredentials)
+
+	_, err = client.Authenticate("alice@example.com", "")
+	assert.ErrorIs(t, err, directory.ErrInvalidCredentials)
+
+	_, err = client.Authenticate("bob@example.com", "alice-secret")
+	assert.ErrorIs(t, err, directory.ErrUserNotFound)
+}
+
+func TestClient_GroupSearch(t *testing.T) {
+	_, cfg := setupDirectory(t)
+	cfg.GroupBaseDN = "ou=groups,dc=example,dc=com"
+
+	client, err := directory.NewClient(cfg)
+	require.NoError(t, err)
+
+	entry, err := client.Authenticate("alice@example.com", "alice-secret")
+	require.NoError(t, err)
+	assert.True(t, entry.MemberOf("cn=admins,ou=groups,dc=example,dc=com"))
+	assert.True(t, entry.MemberOf("cn=staff,ou=groups,dc=example,dc=com"))
+	assert.False(t, entry.MemberOf("cn=finance,ou=groups,dc=example,dc=com"))
+}
+
+func TestNewClient_RejectsInvalidSettings(t *testing.T) {
+	_, err := directory.NewClient(config.LDAPDirectoryConfig{URL: "http://ldap.example.com"})
+	assert.Error(t, err)
+
+	_, err = directory.NewClient(config.LDAPDirectoryConfig{URL: "ldaps://ldap.example.com", StartTLS: true})
+	assert.Error(t, err)
+}
@@ -0,0 +1,349 @@
+// Package ldaptest runs an in-process LDAP server for tests. It understands
+// simple binds, StartTLS and searches with and, or, not, equality and
+// presence filters over an in-memory set of entries, which is enough to
+// stand in for a directory during authentication.
+package ldaptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// Server is an LDAP server listening on a random local port.
+type Server struct {
+	// URL is the ldap:// URL of the server.
+	URL string
+	// CACertPEM is the self-signed certificate served after StartTLS.
+	CACertPEM []byte
+	// RequireTLS refuses binds on connections that have not used StartTLS.
+	RequireTLS bool
+
+	listener  net.Listener
+	tlsConfig *tls.Config
+
+	mu      sync.Mutex
+	entries []*entry
+	binds   []string
+}
+
+type entry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+// NewServer starts a server. Call Close when done.
+func NewServer() (*Server, error) {
+	certPEM, tlsConfig, err := selfSignedCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		URL:       "ldap://" + listener.Addr().String(),
+		CACertPEM: certPEM,
+		listener:  listener,
+		tlsConfig: tlsConfig,
+	}
+	go s.accept()
+
+	return s, nil
+}
+
+func (s *Server) Close() {
+	s.listener.Close()
+}
+
+// AddEntry adds an entry. A "userPassword" attribute lets it bind; it is
+// never returned by searches.
+func (s *Server) AddEntry(dn string, attributes map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, &entry{dn: dn, attributes: attributes})
+}
+
+// RemoveEntry deletes the entry with the DN, as if the user left the
+// directory.
+func (s *Server) RemoveEntry(dn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if e.dn == dn {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Binds returns the DNs of successful binds so far.
+func (s *Server) Binds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+
+	secure := false
+	bound := false
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID, _ := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			code := s.bind(request, secure)
+			bound = code == ldap.LDAPResultSuccess && request.Children[1].Data.Len() > 0
+			conn.Write(result(messageID, ldap.ApplicationBindResponse, code).Bytes())
+		case ldap.ApplicationUnbindRequest:
+			return
+		case ldap.ApplicationSearchRequest:
+			if !bound {
+				conn.Write(result(messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights).Bytes())
+				continue
+			}
+			for _, found := range s.search(request) {
+				conn.Write(found.packet(messageID, request.Children[7]).Bytes())
+			}
+			conn.Write(result(messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		case ldap.ApplicationExtendedRequest:
+			if secure || len(request.Children) == 0 || request.Children[0].Data.String() != startTLSOID {
+				conn.Write(result(messageID, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError).Bytes())
+				continue
+			}
+			conn.Write(result(messageID, ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess).Bytes())
+
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+		default:
+			conn.Write(result(messageID, request.Tag+1, ldap.LDAPResultUnwillingToPerform).Bytes())
+		}
+	}
+}
+
+func (s *Server) bind(request *ber.Packet, secure bool) uint16 {
+	if len(request.Children) < 3 {
+		return ldap.LDAPResultProtocolError
+	}
+	dn := request.Children[1].Data.String()
+	password := request.Children[2].Data.String()
+
+	if dn == "" && password == "" {
+		return ldap.LDAPResultSuccess
+	}
+	if s.RequireTLS && !secure {
+		return ldap.LDAPResultConfidentialityRequired
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if strings.EqualFold(e.dn, dn) {
+			for _, stored := range e.attributes["userPassword"] {
+				if stored == password {
+					s.binds = append(s.binds, e.dn)
+					return ldap.LDAPResultSuccess
+				}
+			}
+		}
+	}
+	return ldap.LDAPResultInvalidCredentials
+}
+
+func (s *Server) search(request *ber.Packet) []*entry {
+	if len(request.Children) < 8 {
+		return nil
+	}
+	baseDN := strings.ToLower(request.Children[0].Data.String())
+	scope, _ := request.Children[1].Value.(int64)
+	filter := request.Children[6]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found []*entry
+	for _, e := range s.entries {
+		dn := strings.ToLower(e.dn)
+		switch scope {
+		case ldap.ScopeBaseObject:
+			if dn != baseDN {
+				continue
+			}
+		case ldap.ScopeSingleLevel:
+			if _, parent, _ := strings.Cut(dn, ","); parent != baseDN {
+				continue
+			}
+		default:
+			if dn != baseDN && !strings.HasSuffix(dn, ","+baseDN) {
+				continue
+			}
+		}
+		if e.matches(filter) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func (e *entry) values(attribute string) []string {
+	for name, values := range e.attributes {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+	return nil
+}
+
+func (e *entry) matches(filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !e.matches(child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if e.matches(child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return len(filter.Children) == 1 && !e.matches(filter.Children[0])
+	case ldap.FilterEqualityMatch:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		want := filter.Children[1].Data.String()
+		for _, value := range e.values(filter.Children[0].Data.String()) {
+			if strings.EqualFold(value, want) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		attribute := filter.Data.String()
+		return strings.EqualFold(attribute, "objectClass") || len(e.values(attribute)) > 0
+	}
+	return false
+}
+
+// packet encodes the entry as a SearchResultEntry with the requested
+// attributes, or all of them when none are named.
+func (e *entry) packet(messageID int64, requested *ber.Packet) *ber.Packet {
+	names := []string{}
+	for _, child := range requested.Children {
+		names = append(names, child.Data.String())
+	}
+	if len(names) == 0 || (len(names) == 1 && names[0] == "*") {
+		names = names[:0]
+		for name := range e.attributes {
+			names = append(names, name)
+		}
+	}
+
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "Object Name"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, name := range names {
+		values := e.values(name)
+		if strings.EqualFold(name, "userPassword") || len(values) == 0 {
+			continue
+		}
+
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	response.AppendChild(attributes)
+
+	return envelope(messageID, response)
+}
+
+func result(messageID int64, tag ber.Tag, code uint16) *ber.Packet {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ldap.LDAPResultCodeMap[code], "Diagnostic Message"))
+	return envelope(messageID, response)
+}
+
+func envelope(messageID int64, response *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	packet.AppendChild(response)
+	return packet
+}
+
+func selfSignedCertificate() ([]byte, *tls.Config, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldaptest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+
+	return certPEM, tlsConfig, nil
+}
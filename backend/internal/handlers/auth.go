@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+
 	"rbac-system/backend/internal/database"
 	"rbac-system/backend/internal/middleware"
 	"rbac-system/backend/internal/models"
@@ -15,10 +17,10 @@ type AuthHandler struct {
 	passwordService *services.PasswordService
 }
 
-func NewAuthHandler(authService services.AuthService) *AuthHandler {
+func NewAuthHandler(authService services.AuthService, passwordService *services.PasswordService) *AuthHandler {
 	return &AuthHandler{
 		authService:     authService,
-		passwordService: services.NewPasswordService(),
+		passwordService: passwordService,
 	}
 }
 
@@ -32,7 +34,20 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		return utils.SendValidationError(c, err)
 	}
 
-	response, err := h.authService.Register(&req)
+	response, err := h.authService.Register(&req, c.IP(), c.Get("User-Agent"))
+	switch {
+	case errors.Is(err, services.ErrRegistrationClosed), errors.Is(err, services.ErrInvitationRequired):
+		return utils.SendError(c, fiber.StatusForbidden, "registration_closed", err.Error())
+	case errors.Is(err, services.ErrEmailDomainNotAllowed):
+		return utils.SendError(c, fiber.StatusForbidden, "email_domain_not_allowed", err.Error())
+	}
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		return utils.SendSuccess(c, fiber.StatusCreated, "Registration successful, please verify your email before logging in", nil)
+	}
+	var policyErr *utils.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return utils.SendValidationError(c, err)
+	}
 	if err != nil {
 		return utils.SendError(c, fiber.StatusBadRequest, "registration_failed", err.Error())
 	}
@@ -53,14 +68,110 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	ipAddress := c.IP()
 	userAgent := c.Get("User-Agent")
 
-	response, err := h.authService.Login(&req, ipAddress, userAgent)
+	response, challenge, err := h.authService.Login(&req, ipAddress, userAgent)
 	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTooManyLoginAttempts):
+			return utils.SendError(c, fiber.StatusTooManyRequests, "too_many_attempts", err.Error())
+		case errors.Is(err, services.ErrAccountLocked):
+			return utils.SendError(c, fiber.StatusLocked, "account_locked", err.Error())
+		case errors.Is(err, services.ErrEmailNotVerified):
+			return utils.SendError(c, fiber.StatusForbidden, "email_not_verified", err.Error())
+		case errors.Is(err, services.ErrLDAPNoAccount), errors.Is(err, services.ErrLDAPNoRole):
+			return utils.SendError(c, fiber.StatusForbidden, "account_not_provisioned", err.Error())
+		}
 		return utils.SendError(c, fiber.StatusUnauthorized, "login_failed", err.Error())
 	}
 
+	if challenge != nil {
+		return utils.SendSuccess(c, fiber.StatusOK, "Additional verification required", challenge)
+	}
+
 	return utils.SendSuccess(c, fiber.StatusOK, "Login successful", response)
 }
 
+// ChangeExpiredPassword sets a new password with the token from a
+// "password_expired" login challenge and continues the login.
+func (h *AuthHandler) ChangeExpiredPassword(c *fiber.Ctx) error {
+	var req models.ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	response, challenge, err := h.authService.ChangeExpiredPassword(req.Token, req.NewPassword, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		var policyErr *utils.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return utils.SendValidationError(c, err)
+		}
+		return utils.SendError(c, fiber.StatusUnauthorized, "password_change_failed", err.Error())
+	}
+
+	if challenge != nil {
+		return utils.SendSuccess(c, fiber.StatusOK, "Password changed, additional verification required", challenge)
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Password changed, login successful", response)
+}
+
+func (h *AuthHandler) VerifyMFA(c *fiber.Ctx) error {
+	var req models.MFAChallengeCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	response, err := h.authService.VerifyMFA(req.Token, req.Code, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, "mfa_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Login successful", response)
+}
+
+func (h *AuthHandler) BeginMFAEnrollment(c *fiber.Ctx) error {
+	var req models.MFAChallengeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	enrollment, err := h.authService.BeginMFAEnrollment(req.Token)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, "mfa_enrollment_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "MFA enrollment started", enrollment)
+}
+
+func (h *AuthHandler) CompleteMFAEnrollment(c *fiber.Ctx) error {
+	var req models.MFAChallengeCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	response, err := h.authService.CompleteMFAEnrollment(req.Token, req.Code, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, "mfa_enrollment_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "MFA enabled and login successful", response)
+}
+
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 	var req models.RefreshTokenRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -71,7 +182,10 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 		return utils.SendValidationError(c, err)
 	}
 
-	response, err := h.authService.RefreshToken(req.RefreshToken)
+	response, err := h.authService.RefreshToken(req.RefreshToken, c.IP(), c.Get("User-Agent"))
+	if errors.Is(err, services.ErrPasswordExpired) {
+		return utils.SendError(c, fiber.StatusUnauthorized, "password_expired", err.Error())
+	}
 	if err != nil {
 		return utils.SendError(c, fiber.StatusUnauthorized, "token_refresh_failed", err.Error())
 	}
@@ -80,9 +194,62 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 }
 
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	claims := middleware.GetClaimsFromContext(c)
+	if claims == nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "User not authenticated")
+	}
+
+	if err := h.authService.Logout(claims, c.IP(), c.Get("User-Agent")); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "logout_failed", "Failed to log out")
+	}
+
 	return utils.SendSuccess(c, fiber.StatusOK, "Logout successful", nil)
 }
 
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	claims := middleware.GetClaimsFromContext(c)
+	if claims == nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "User not authenticated")
+	}
+
+	if err := h.authService.LogoutAll(claims, c.IP(), c.Get("User-Agent")); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "logout_failed", "Failed to log out of all sessions")
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Logged out of all sessions", nil)
+}
+
+func (h *AuthHandler) StepUp(c *fiber.Ctx) error {
+	claims := middleware.GetClaimsFromContext(c)
+	if claims == nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "User not authenticated")
+	}
+
+	var req models.StepUpRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	response, err := h.authService.StepUp(claims, &req, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrStepUpFailed):
+			return utils.SendError(c, fiber.StatusUnauthorized, "step_up_failed", err.Error())
+		case errors.Is(err, services.ErrStepUpUnavailable):
+			return utils.SendError(c, fiber.StatusForbidden, "step_up_unavailable", err.Error())
+		case errors.Is(err, services.ErrAccountLocked):
+			return utils.SendError(c, fiber.StatusLocked, "account_locked", err.Error())
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Re-authentication successful", response)
+}
+
 func (h *AuthHandler) Profile(c *fiber.Ctx) error {
 	user := middleware.GetUserFromContext(c)
 	if user == nil {
@@ -107,8 +274,10 @@ func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
 		return utils.SendValidationError(c, err)
 	}
 
-	if req.Email != "" {
+	emailChanged := false
+	if req.Email != "" && req.Email != user.Email {
 		user.Email = req.Email
+		emailChanged = true
 	}
 	if req.Username != "" {
 		user.Username = req.Username
@@ -125,6 +294,12 @@ func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
 		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Failed to update profile")
 	}
 
+	if emailChanged {
+		if err := h.authService.ResetEmailVerification(user); err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Failed to send verification email")
+		}
+	}
+
 	return utils.SendSuccess(c, fiber.StatusOK, "Profile updated successfully", user.ToResponse())
 }
 
@@ -147,19 +322,49 @@ func (h *AuthHandler) UpdatePassword(c *fiber.Ctx) error {
 		return utils.SendError(c, fiber.StatusBadRequest, "invalid_password", "Current password is incorrect")
 	}
 
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
-	if err != nil {
-		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Failed to hash password")
+	if err := h.passwordService.SetPassword(user, req.NewPassword); err != nil {
+		var policyErr *utils.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return utils.SendValidationError(c, err)
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Failed to update password")
 	}
 
-	user.PasswordHash = hashedPassword
+	return utils.SendSuccess(c, fiber.StatusOK, "Password updated successfully", nil)
+}
 
-	// Save changes to database
-	if err := database.DB.Save(user).Error; err != nil {
-		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Failed to update password")
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req models.VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
 	}
 
-	return utils.SendSuccess(c, fiber.StatusOK, "Password updated successfully", nil)
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	if err := h.authService.VerifyEmail(req.Token, c.IP(), c.Get("User-Agent")); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "verification_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Email verified successfully", nil)
+}
+
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	var req models.ResendVerificationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	if err := h.authService.ResendVerification(req.Email); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Failed to send verification email")
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "If the account exists and is unverified, a verification email has been sent.", nil)
 }
 
 // ForgotPassword initiates the password reset process.
@@ -214,6 +419,10 @@ func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
 	}
 
 	if err := h.passwordService.ResetPassword(input.Token, input.NewPassword); err != nil {
+		var policyErr *utils.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return utils.SendValidationError(c, err)
+		}
 		return utils.SendError(c, fiber.StatusBadRequest, "reset_failed", err.Error())
 	}
 
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"rbac-system/backend/internal/middleware"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ImpersonationHandler struct {
+	impersonationService *services.ImpersonationService
+}
+
+func NewImpersonationHandler(impersonationService *services.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{impersonationService: impersonationService}
+}
+
+func (h *ImpersonationHandler) Impersonate(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid user ID")
+	}
+
+	response, err := h.impersonationService.Impersonate(middleware.GetUserIDFromContext(c), uint(id), c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCannotImpersonateSelf):
+			return utils.SendError(c, fiber.StatusBadRequest, "cannot_impersonate_self", err.Error())
+		case errors.Is(err, services.ErrImpersonationNotAllowed):
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", err.Error())
+		case errors.Is(err, services.ErrImpersonatedUserNotFound):
+			return utils.SendError(c, fiber.StatusNotFound, "user_not_found", err.Error())
+		case errors.Is(err, services.ErrImpersonatedUserInactive):
+			return utils.SendError(c, fiber.StatusBadRequest, "user_inactive", err.Error())
+		}
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Impersonation started successfully", response)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"rbac-system/backend/internal/middleware"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type InvitationHandler struct {
+	invitationService *services.InvitationService
+}
+
+func NewInvitationHandler(invitationService *services.InvitationService) *InvitationHandler {
+	return &InvitationHandler{invitationService: invitationService}
+}
+
+func (h *InvitationHandler) GetInvitations(c *fiber.Ctx) error {
+	invitations, err := h.invitationService.GetPendingInvitations()
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Invitations retrieved successfully", invitations)
+}
+
+func (h *InvitationHandler) CreateInvitation(c *fiber.Ctx) error {
+	var req models.InvitationInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	invitation, err := h.invitationService.CreateInvitation(middleware.GetUserIDFromContext(c), &req, c.IP(), c.Get("User-Agent"))
+	if errors.Is(err, services.ErrRegistrationClosed) {
+		return utils.SendError(c, fiber.StatusForbidden, "registration_closed", err.Error())
+	}
+	if errors.Is(err, services.ErrInvitationRole) {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", err.Error())
+	}
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "create_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusCreated, "Invitation sent successfully", invitation)
+}
+
+func (h *InvitationHandler) ResendInvitation(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid invitation ID")
+	}
+
+	invitation, err := h.invitationService.ResendInvitation(uint(id), middleware.GetUserIDFromContext(c), c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return sendInvitationError(c, "resend_failed", err)
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Invitation resent successfully", invitation)
+}
+
+func (h *InvitationHandler) RevokeInvitation(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid invitation ID")
+	}
+
+	if err := h.invitationService.RevokeInvitation(uint(id), middleware.GetUserIDFromContext(c), c.IP(), c.Get("User-Agent")); err != nil {
+		return sendInvitationError(c, "revoke_failed", err)
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Invitation revoked successfully", nil)
+}
+
+// AcceptInvitation is public: the signed token from the invitation email is
+// the credential.
+func (h *InvitationHandler) AcceptInvitation(c *fiber.Ctx) error {
+	var req models.AcceptInvitationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	response, challenge, err := h.invitationService.AcceptInvitation(&req, c.IP(), c.Get("User-Agent"))
+	var policyErr *utils.PasswordPolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		return utils.SendValidationError(c, err)
+	case errors.Is(err, services.ErrRegistrationClosed):
+		return utils.SendError(c, fiber.StatusForbidden, "registration_closed", err.Error())
+	case errors.Is(err, services.ErrInvitationInvalid):
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_invitation", err.Error())
+	case errors.Is(err, services.ErrTooManyLoginAttempts):
+		return utils.SendError(c, fiber.StatusTooManyRequests, "too_many_attempts", err.Error())
+	case err != nil:
+		return utils.SendError(c, fiber.StatusBadRequest, "registration_failed", err.Error())
+	}
+
+	if challenge != nil {
+		return utils.SendSuccess(c, fiber.StatusCreated, "Invitation accepted, additional verification required", challenge)
+	}
+
+	return utils.SendSuccess(c, fiber.StatusCreated, "Invitation accepted successfully", response)
+}
+
+func sendInvitationError(c *fiber.Ctx, code string, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvitationNotFound):
+		return utils.SendError(c, fiber.StatusNotFound, "invitation_not_found", err.Error())
+	case errors.Is(err, services.ErrInvitationNotPending):
+		return utils.SendError(c, fiber.StatusConflict, "invitation_not_pending", err.Error())
+	case errors.Is(err, services.ErrInvitationRole):
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", err.Error())
+	}
+	return utils.SendError(c, fiber.StatusBadRequest, code, err.Error())
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type KeyHandler struct {
+	signingKeyService *services.SigningKeyService
+}
+
+// NewKeyHandler takes a nil service when tokens are signed with HS256, in
+// which case the published key set is empty.
+func NewKeyHandler(signingKeyService *services.SigningKeyService) *KeyHandler {
+	return &KeyHandler{
+		signingKeyService: signingKeyService,
+	}
+}
+
+// JWKS serves the public signing keys as a plain RFC 7517 key set, not
+// wrapped in the usual response envelope, so standard JWT libraries can
+// consume it directly.
+func (h *KeyHandler) JWKS(c *fiber.Ctx) error {
+	jwks := &models.JWKS{Keys: []models.JWK{}}
+	if h.signingKeyService != nil {
+		var err error
+		if jwks, err = h.signingKeyService.JWKS(); err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Failed to load signing keys")
+		}
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(jwks)
+}
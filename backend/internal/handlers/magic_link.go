@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MagicLinkHandler struct {
+	magicLinkService *services.MagicLinkService
+}
+
+func NewMagicLinkHandler(magicLinkService *services.MagicLinkService) *MagicLinkHandler {
+	return &MagicLinkHandler{magicLinkService: magicLinkService}
+}
+
+func (h *MagicLinkHandler) RequestLink(c *fiber.Ctx) error {
+	var req models.MagicLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	if err := h.magicLinkService.RequestLink(req.Email, c.IP(), c.Get("User-Agent")); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Failed to send login link")
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "If the account exists and allows passwordless login, a login link has been sent.", nil)
+}
+
+func (h *MagicLinkHandler) Login(c *fiber.Ctx) error {
+	var req models.MagicLinkLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	response, challenge, err := h.magicLinkService.Login(req.Token, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTooManyLoginAttempts):
+			return utils.SendError(c, fiber.StatusTooManyRequests, "too_many_attempts", err.Error())
+		case errors.Is(err, services.ErrAccountLocked):
+			return utils.SendError(c, fiber.StatusLocked, "account_locked", err.Error())
+		case errors.Is(err, services.ErrEmailNotVerified):
+			return utils.SendError(c, fiber.StatusForbidden, "email_not_verified", err.Error())
+		}
+		return utils.SendError(c, fiber.StatusUnauthorized, "login_failed", err.Error())
+	}
+
+	if challenge != nil {
+		return utils.SendSuccess(c, fiber.StatusOK, "Additional verification required", challenge)
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Login successful", response)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"strconv"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/middleware"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MFAHandler struct {
+	mfaService  *services.MFAService
+	rbacService *services.RBACService
+}
+
+func NewMFAHandler(mfaService *services.MFAService, rbacService *services.RBACService) *MFAHandler {
+	return &MFAHandler{
+		mfaService:  mfaService,
+		rbacService: rbacService,
+	}
+}
+
+func (h *MFAHandler) Enroll(c *fiber.Ctx) error {
+	userID := middleware.GetUserIDFromContext(c)
+
+	enrollment, err := h.mfaService.BeginEnrollment(userID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "mfa_enrollment_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "MFA enrollment started", enrollment)
+}
+
+func (h *MFAHandler) Verify(c *fiber.Ctx) error {
+	var req models.MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	userID := middleware.GetUserIDFromContext(c)
+	recoveryCodes, err := h.mfaService.ConfirmEnrollment(userID, req.Code)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "mfa_verification_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "MFA enabled successfully", models.MFARecoveryCodesResponse{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var req models.MFACodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	userID := middleware.GetUserIDFromContext(c)
+	if err := h.mfaService.VerifyCode(userID, req.Code); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "mfa_verification_failed", err.Error())
+	}
+
+	recoveryCodes, err := h.mfaService.RegenerateRecoveryCodes(userID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Failed to generate recovery codes")
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Recovery codes regenerated successfully", models.MFARecoveryCodesResponse{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (h *MFAHandler) Disable(c *fiber.Ctx) error {
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "User not found")
+	}
+
+	var req models.MFADisableRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	if user.RequiresMFA() {
+		return utils.SendError(c, fiber.StatusForbidden, "mfa_required", "One of your roles requires MFA to stay enabled")
+	}
+
+	if err := utils.CheckPassword(req.Password, user.PasswordHash); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_password", "Password is incorrect")
+	}
+
+	if err := h.mfaService.VerifyCode(user.ID, req.Code); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "mfa_verification_failed", err.Error())
+	}
+
+	if err := h.mfaService.ResetMFA(user.ID); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Failed to disable MFA")
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "MFA disabled successfully", nil)
+}
+
+func (h *MFAHandler) ResetUserMFA(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid user ID")
+	}
+
+	canManage, err := middleware.CanManageUser(c, h.rbacService, uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+	}
+	if !canManage {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot reset MFA for this user")
+	}
+
+	if err := h.mfaService.ResetMFA(uint(id)); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "mfa_reset_failed", err.Error())
+	}
+
+	activityLog := middleware.NewActivityLog(c, "mfa_reset", "users", "Reset MFA for user "+c.Params("id"))
+	database.DB.Create(&activityLog)
+
+	return utils.SendSuccess(c, fiber.StatusOK, "MFA reset successfully", nil)
+}
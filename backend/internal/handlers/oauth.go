@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OAuthHandler serves the OAuth2 token and introspection endpoints, which
+// speak RFC 6749/7662 JSON rather than the API's success/error envelope, and
+// the admin endpoints for registered clients.
+type OAuthHandler struct {
+	oauthService *services.OAuthService
+}
+
+func NewOAuthHandler(oauthService *services.OAuthService) *OAuthHandler {
+	return &OAuthHandler{oauthService: oauthService}
+}
+
+func (h *OAuthHandler) Token(c *fiber.Ctx) error {
+	var req models.OAuthTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendOAuthError(c, services.ErrOAuthInvalidRequest)
+	}
+	req.ClientID, req.ClientSecret = clientCredentials(c, req.ClientID, req.ClientSecret)
+
+	response, err := h.oauthService.Token(&req, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return sendOAuthError(c, err)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	c.Set("Pragma", "no-cache")
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+func (h *OAuthHandler) Introspect(c *fiber.Ctx) error {
+	var req models.OAuthIntrospectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return sendOAuthError(c, services.ErrOAuthInvalidRequest)
+	}
+	req.ClientID, req.ClientSecret = clientCredentials(c, req.ClientID, req.ClientSecret)
+
+	response, err := h.oauthService.Introspect(&req)
+	if err != nil {
+		return sendOAuthError(c, err)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// clientCredentials prefers HTTP Basic client authentication and falls back
+// to credentials sent in the request body.
+func clientCredentials(c *fiber.Ctx, clientID, clientSecret string) (string, string) {
+	authHeader := c.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Basic ") {
+		return clientID, clientSecret
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(authHeader, "Basic "))
+	if err != nil {
+		return "", ""
+	}
+
+	id, secret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", ""
+	}
+
+	// RFC 6749 2.3.1: both parts are form-encoded before being joined.
+	if unescaped, err := url.QueryUnescape(id); err == nil {
+		id = unescaped
+	}
+	if unescaped, err := url.QueryUnescape(secret); err == nil {
+		secret = unescaped
+	}
+	return id, secret
+}
+
+func sendOAuthError(c *fiber.Ctx, err error) error {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.OAuthError{Error: "server_error"})
+	}
+
+	status := fiber.StatusBadRequest
+	if oauthErr.Code == "invalid_client" {
+		status = fiber.StatusUnauthorized
+		c.Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	c.Set("Cache-Control", "no-store")
+	return c.Status(status).JSON(models.OAuthError{Error: oauthErr.Code, ErrorDescription: oauthErr.Description})
+}
+
+func (h *OAuthHandler) GetClients(c *fiber.Ctx) error {
+	clients, err := h.oauthService.GetClients()
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "OAuth clients retrieved successfully", clients)
+}
+
+func (h *OAuthHandler) CreateClient(c *fiber.Ctx) error {
+	var req models.OAuthClientInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	client, err := h.oauthService.CreateClient(&req)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "create_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusCreated, "OAuth client created successfully. Copy the client secret now, it won't be shown again", client)
+}
+
+func (h *OAuthHandler) UpdateClient(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid client ID")
+	}
+
+	var req models.OAuthClientUpdateInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	client, err := h.oauthService.UpdateClient(uint(id), &req)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "update_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "OAuth client updated successfully", client)
+}
+
+func (h *OAuthHandler) DeleteClient(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid client ID")
+	}
+
+	if err := h.oauthService.DeleteClient(uint(id)); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "delete_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "OAuth client deleted successfully", nil)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OIDCHandler serves single sign-on through the configured OpenID Connect
+// provider. The frontend sends the browser to the authorization URL and
+// posts the code and state from the redirect back to Callback.
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{oidcService: oidcService}
+}
+
+func (h *OIDCHandler) Authorize(c *fiber.Ctx) error {
+	authorization, err := h.oidcService.BeginLogin()
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadGateway, "oidc_unavailable", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Authorization URL created", authorization)
+}
+
+func (h *OIDCHandler) Callback(c *fiber.Ctx) error {
+	var req models.OIDCCallbackRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	response, challenge, err := h.oidcService.CompleteLogin(&req, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTooManyLoginAttempts):
+			return utils.SendError(c, fiber.StatusTooManyRequests, "too_many_attempts", err.Error())
+		case errors.Is(err, services.ErrAccountLocked):
+			return utils.SendError(c, fiber.StatusLocked, "account_locked", err.Error())
+		case errors.Is(err, services.ErrEmailNotVerified), errors.Is(err, services.ErrOIDCEmailNotVerified):
+			return utils.SendError(c, fiber.StatusForbidden, "email_not_verified", err.Error())
+		case errors.Is(err, services.ErrOIDCNoAccount), errors.Is(err, services.ErrOIDCNoRole):
+			return utils.SendError(c, fiber.StatusForbidden, "account_not_provisioned", err.Error())
+		}
+		return utils.SendError(c, fiber.StatusUnauthorized, "login_failed", err.Error())
+	}
+
+	if challenge != nil {
+		return utils.SendSuccess(c, fiber.StatusOK, "Additional verification required", challenge)
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Login successful", response)
+}
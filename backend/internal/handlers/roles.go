@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
 
+	"rbac-system/backend/internal/middleware"
 	"rbac-system/backend/internal/models"
 	"rbac-system/backend/internal/services"
 	"rbac-system/backend/internal/utils"
@@ -142,4 +144,45 @@ func (h *RoleHandler) GetRolePermissions(c *fiber.Ctx) error {
 	}
 
 	return utils.SendSuccess(c, fiber.StatusOK, "Role permissions retrieved successfully", permissions)
-}
\ No newline at end of file
+}
+
+func (h *RoleHandler) GetRoleHierarchy(c *fiber.Ctx) error {
+	hierarchy, err := h.roleService.GetRoleHierarchy()
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Role hierarchy retrieved successfully", hierarchy)
+}
+
+func (h *RoleHandler) SetRoleHierarchy(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid role ID")
+	}
+
+	var req models.RoleHierarchyInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	role, err := h.roleService.SetRoleHierarchy(uint(id), req.ParentID, *req.Level, middleware.GetUserIDFromContext(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSystemRoleHierarchy), errors.Is(err, services.ErrRoleHierarchyNotAllowed):
+			return utils.SendError(c, fiber.StatusForbidden, "hierarchy_not_allowed", err.Error())
+		case errors.Is(err, services.ErrRoleHierarchyCycle):
+			return utils.SendError(c, fiber.StatusConflict, "hierarchy_cycle", err.Error())
+		case errors.Is(err, services.ErrRoleHierarchyRank):
+			return utils.SendError(c, fiber.StatusConflict, "hierarchy_rank", err.Error())
+		default:
+			return utils.SendError(c, fiber.StatusBadRequest, "update_failed", err.Error())
+		}
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Role hierarchy updated successfully", role)
+}
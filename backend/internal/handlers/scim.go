@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"rbac-system/backend/internal/scim"
+	"rbac-system/backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SCIMHandler serves the SCIM 2.0 API under /scim/v2. Unlike the rest of the
+// API it answers in application/scim+json with SCIM error bodies, as
+// provisioning clients expect.
+type SCIMHandler struct {
+	scimService *services.SCIMService
+}
+
+func NewSCIMHandler(scimService *services.SCIMService) *SCIMHandler {
+	return &SCIMHandler{scimService: scimService}
+}
+
+func (h *SCIMHandler) GetUsers(c *fiber.Ctx) error {
+	page := scim.ParsePage(c.Query("startIndex"), c.Query("count"))
+	list, err := h.scimService.ListUsers(c.Query("filter"), page)
+	if err != nil {
+		return sendSCIMError(c, err)
+	}
+
+	for _, resource := range list.Resources {
+		locate(c, resource)
+	}
+	return sendSCIM(c, fiber.StatusOK, list)
+}
+
+func (h *SCIMHandler) GetUser(c *fiber.Ctx) error {
+	user, err := h.scimService.GetUser(c.Params("id"))
+	if err != nil {
+		return sendSCIMError(c, err)
+	}
+	return sendSCIM(c, fiber.StatusOK, locate(c, user))
+}
+
+func (h *SCIMHandler) CreateUser(c *fiber.Ctx) error {
+	var resource scim.User
+	if err := json.Unmarshal(c.Body(), &resource); err != nil {
+		return sendSCIMError(c, scim.BadRequest(scim.ErrorTypeInvalidSyntax, "Invalid request body"))
+	}
+
+	user, err := h.scimService.CreateUser(&resource, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return sendSCIMError(c, err)
+	}
+
+	locate(c, user)
+	c.Location(user.Meta.Location)
+	return sendSCIM(c, fiber.StatusCreated, user)
+}
+
+func (h *SCIMHandler) ReplaceUser(c *fiber.Ctx) error {
+	var resource scim.User
+	if err := json.Unmarshal(c.Body(), &resource); err != nil {
+		return sendSCIMError(c, scim.BadRequest(scim.ErrorTypeInvalidSyntax, "Invalid request body"))
+	}
+
+	user, err := h.scimService.ReplaceUser(c.Params("id"), &resource, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return sendSCIMError(c, err)
+	}
+	return sendSCIM(c, fiber.StatusOK, locate(c, user))
+}
+
+func (h *SCIMHandler) PatchUser(c *fiber.Ctx) error {
+	var req scim.PatchRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return sendSCIMError(c, scim.BadRequest(scim.ErrorTypeInvalidSyntax, "Invalid request body"))
+	}
+
+	user, err := h.scimService.PatchUser(c.Params("id"), &req, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return sendSCIMError(c, err)
+	}
+	return sendSCIM(c, fiber.StatusOK, locate(c, user))
+}
+
+func (h *SCIMHandler) DeleteUser(c *fiber.Ctx) error {
+	if err := h.scimService.DeleteUser(c.Params("id"), c.IP(), c.Get("User-Agent")); err != nil {
+		return sendSCIMError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *SCIMHandler) GetGroups(c *fiber.Ctx) error {
+	page := scim.ParsePage(c.Query("startIndex"), c.Query("count"))
+	list, err := h.scimService.ListGroups(c.Query("filter"), page, includeMembers(c))
+	if err != nil {
+		return sendSCIMError(c, err)
+	}
+
+	for _, resource := range list.Resources {
+		locate(c, resource)
+	}
+	return sendSCIM(c, fiber.StatusOK, list)
+}
+
+func (h *SCIMHandler) GetGroup(c *fiber.Ctx) error {
+	group, err := h.scimService.GetGroup(c.Params("id"), includeMembers(c))
+	if err != nil {
+		return sendSCIMError(c, err)
+	}
+	return sendSCIM(c, fiber.StatusOK, locate(c, group))
+}
+
+func (h *SCIMHandler) CreateGroup(c *fiber.Ctx) error {
+	var resource scim.Group
+	if err := json.Unmarshal(c.Body(), &resource); err != nil {
+		return sendSCIMError(c, scim.BadRequest(scim.ErrorTypeInvalidSyntax, "Invalid request body"))
+	}
+
+	group, err := h.scimService.CreateGroup(&resource, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return sendSCIMError(c, err)
+	}
+
+	locate(c, group)
+	c.Location(group.Meta.Location)
+	return sendSCIM(c, fiber.StatusCreated, group)
+}
+
+func (h *SCIMHandler) ReplaceGroup(c *fiber.Ctx) error {
+	var resource scim.Group
+	if err := json.Unmarshal(c.Body(), &resource); err != nil {
+		return sendSCIMError(c, scim.BadRequest(scim.ErrorTypeInvalidSyntax, "Invalid request body"))
+	}
+
+	group, err := h.scimService.ReplaceGroup(c.Params("id"), &resource, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return sendSCIMError(c, err)
+	}
+	return sendSCIM(c, fiber.StatusOK, locate(c, group))
+}
+
+func (h *SCIMHandler) PatchGroup(c *fiber.Ctx) error {
+	var req scim.PatchRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return sendSCIMError(c, scim.BadRequest(scim.ErrorTypeInvalidSyntax, "Invalid request body"))
+	}
+
+	group, err := h.scimService.PatchGroup(c.Params("id"), &req, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return sendSCIMError(c, err)
+	}
+	return sendSCIM(c, fiber.StatusOK, locate(c, group))
+}
+
+func (h *SCIMHandler) DeleteGroup(c *fiber.Ctx) error {
+	if err := h.scimService.DeleteGroup(c.Params("id"), c.IP(), c.Get("User-Agent")); err != nil {
+		return sendSCIMError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *SCIMHandler) ServiceProviderConfig(c *fiber.Ctx) error {
+	return sendSCIM(c, fiber.StatusOK, scim.NewServiceProviderConfig(scimBaseURL(c)))
+}
+
+func (h *SCIMHandler) GetResourceTypes(c *fiber.Ctx) error {
+	resourceTypes := scim.NewResourceTypes(scimBaseURL(c))
+	resources := make([]interface{}, len(resourceTypes))
+	for i, resourceType := range resourceTypes {
+		resources[i] = resourceType
+	}
+	return sendSCIM(c, fiber.StatusOK, scim.NewListResponse(int64(len(resources)), 1, resources))
+}
+
+func (h *SCIMHandler) GetResourceType(c *fiber.Ctx) error {
+	for _, resourceType := range scim.NewResourceTypes(scimBaseURL(c)) {
+		if resourceType.ID == c.Params("id") {
+			return sendSCIM(c, fiber.StatusOK, resourceType)
+		}
+	}
+	return sendSCIMError(c, scim.NotFound("ResourceType", c.Params("id")))
+}
+
+func (h *SCIMHandler) GetSchemas(c *fiber.Ctx) error {
+	schemas := scim.NewSchemas(scimBaseURL(c))
+	resources := make([]interface{}, len(schemas))
+	for i, schema := range schemas {
+		resources[i] = schema
+	}
+	return sendSCIM(c, fiber.StatusOK, scim.NewListResponse(int64(len(resources)), 1, resources))
+}
+
+func (h *SCIMHandler) GetSchema(c *fiber.Ctx) error {
+	for _, schema := range scim.NewSchemas(scimBaseURL(c)) {
+		if schema.ID == c.Params("id") {
+			return sendSCIM(c, fiber.StatusOK, schema)
+		}
+	}
+	return sendSCIMError(c, scim.NotFound("Schema", c.Params("id")))
+}
+
+func scimBaseURL(c *fiber.Ctx) string {
+	return c.BaseURL() + "/scim/v2"
+}
+
+// locate fills in the meta.location of a user or group and the $ref of its
+// groups or members.
+func locate(c *fiber.Ctx, resource interface{}) interface{} {
+	baseURL := scimBaseURL(c)
+	switch resource := resource.(type) {
+	case *scim.User:
+		resource.Meta.Location = baseURL + "/Users/" + resource.ID
+		for i := range resource.Groups {
+			resource.Groups[i].Ref = baseURL + "/Groups/" + resource.Groups[i].Value
+		}
+	case *scim.Group:
+		resource.Meta.Location = baseURL + "/Groups/" + resource.ID
+		for i := range resource.Members {
+			resource.Members[i].Ref = baseURL + "/Users/" + resource.Members[i].Value
+		}
+	}
+	return resource
+}
+
+// includeMembers honours attributes and excludedAttributes for members, the
+// one attribute expensive enough for clients to leave out.
+func includeMembers(c *fiber.Ctx) bool {
+	if attributes := c.Query("attributes"); attributes != "" {
+		return containsAttribute(attributes, "members")
+	}
+	return !containsAttribute(c.Query("excludedAttributes"), "members")
+}
+
+func containsAttribute(list, attribute string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), attribute) {
+			return true
+		}
+	}
+	return false
+}
+
+func sendSCIM(c *fiber.Ctx, status int, body interface{}) error {
+	return c.Status(status).JSON(body, scim.ContentType)
+}
+
+func sendSCIMError(c *fiber.Ctx, err error) error {
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) {
+		scimErr = scim.NewError(fiber.StatusInternalServerError, "", err.Error())
+	}
+	return sendSCIM(c, scimErr.StatusCode(), scimErr)
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"strconv"
+
+	"rbac-system/backend/internal/middleware"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccountService *services.ServiceAccountService
+	rbacService           *services.RBACService
+}
+
+func NewServiceAccountHandler(serviceAccountService *services.ServiceAccountService, rbacService *services.RBACService) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccountService: serviceAccountService,
+		rbacService:           rbacService,
+	}
+}
+
+// canManageAccount reports whether the caller ranks above the service
+// account's role, which it takes to change the account or hand out its
+// credentials.
+func (h *ServiceAccountHandler) canManageAccount(c *fiber.Ctx, id uint) (bool, error) {
+	account, err := h.serviceAccountService.GetServiceAccount(id)
+	if err != nil {
+		return false, err
+	}
+	return middleware.CanAssignRoles(c, h.rbacService, []uint{account.RoleID})
+}
+
+func (h *ServiceAccountHandler) IssueToken(c *fiber.Ctx) error {
+	var req models.ServiceAccountTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	token, err := h.serviceAccountService.IssueToken(&req, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, "invalid_client", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Token issued successfully", token)
+}
+
+func (h *ServiceAccountHandler) GetServiceAccounts(c *fiber.Ctx) error {
+	accounts, err := h.serviceAccountService.GetServiceAccounts()
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Service accounts retrieved successfully", accounts)
+}
+
+func (h *ServiceAccountHandler) GetServiceAccount(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid service account ID")
+	}
+
+	account, err := h.serviceAccountService.GetServiceAccount(uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "service_account_not_found", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Service account retrieved successfully", account)
+}
+
+func (h *ServiceAccountHandler) CreateServiceAccount(c *fiber.Ctx) error {
+	var req models.ServiceAccountInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	canAssign, err := middleware.CanAssignRoles(c, h.rbacService, []uint{req.RoleID})
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+	}
+	if !canAssign {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot assign roles at or above your own rank")
+	}
+
+	credentials, err := h.serviceAccountService.CreateServiceAccount(&req)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "create_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusCreated, "Service account created successfully. Copy the client secret now, it won't be shown again", credentials)
+}
+
+func (h *ServiceAccountHandler) UpdateServiceAccount(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid service account ID")
+	}
+
+	var req models.ServiceAccountUpdateInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	canManage, err := h.canManageAccount(c, uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "service_account_not_found", err.Error())
+	}
+	if !canManage {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot manage service accounts at or above your own rank")
+	}
+
+	if req.RoleID != 0 {
+		canAssign, err := middleware.CanAssignRoles(c, h.rbacService, []uint{req.RoleID})
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+		}
+		if !canAssign {
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot assign roles at or above your own rank")
+		}
+	}
+
+	account, err := h.serviceAccountService.UpdateServiceAccount(uint(id), &req)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "update_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Service account updated successfully", account)
+}
+
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid service account ID")
+	}
+
+	canManage, err := h.canManageAccount(c, uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "service_account_not_found", err.Error())
+	}
+	if !canManage {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot manage service accounts at or above your own rank")
+	}
+
+	if err := h.serviceAccountService.DeleteServiceAccount(uint(id)); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "delete_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Service account deleted successfully", nil)
+}
+
+func (h *ServiceAccountHandler) RotateSecret(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid service account ID")
+	}
+
+	canManage, err := h.canManageAccount(c, uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "service_account_not_found", err.Error())
+	}
+	if !canManage {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot manage service accounts at or above your own rank")
+	}
+
+	credentials, err := h.serviceAccountService.RotateClientSecret(uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "rotate_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Client secret rotated successfully. Copy it now, it won't be shown again", credentials)
+}
+
+func (h *ServiceAccountHandler) GetAPIKeys(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid service account ID")
+	}
+
+	keys, err := h.serviceAccountService.GetAPIKeys(uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "API keys retrieved successfully", keys)
+}
+
+func (h *ServiceAccountHandler) CreateAPIKey(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid service account ID")
+	}
+
+	var req models.ServiceAccountAPIKeyInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	canManage, err := h.canManageAccount(c, uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "service_account_not_found", err.Error())
+	}
+	if !canManage {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot manage service accounts at or above your own rank")
+	}
+
+	key, err := h.serviceAccountService.CreateAPIKey(uint(id), &req)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "create_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusCreated, "API key created successfully. Copy it now, it won't be shown again", key)
+}
+
+func (h *ServiceAccountHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid service account ID")
+	}
+
+	keyID, err := strconv.ParseUint(c.Params("keyId"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid API key ID")
+	}
+
+	canManage, err := h.canManageAccount(c, uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "service_account_not_found", err.Error())
+	}
+	if !canManage {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot manage service accounts at or above your own rank")
+	}
+
+	if err := h.serviceAccountService.RevokeAPIKey(uint(id), uint(keyID)); err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "revoke_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "API key revoked successfully", nil)
+}
+
+func (h *ServiceAccountHandler) GetActivity(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid service account ID")
+	}
+
+	page := parseIntOrDefault(c.Query("page"), 1)
+	limit := parseIntOrDefault(c.Query("limit"), 20)
+
+	if limit > 100 {
+		limit = 100
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	activities, err := h.serviceAccountService.GetActivityLogs(uint(id), page, limit)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Service account activity retrieved successfully", activities)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"strconv"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/middleware"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+	rbacService    *services.RBACService
+}
+
+func NewSessionHandler(sessionService *services.SessionService, rbacService *services.RBACService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+		rbacService:    rbacService,
+	}
+}
+
+func (h *SessionHandler) GetMySessions(c *fiber.Ctx) error {
+	claims := middleware.GetClaimsFromContext(c)
+	if claims == nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "User not authenticated")
+	}
+
+	sessions, err := h.sessionService.GetActiveSessions(claims.UserID, claims.SessionID)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Sessions retrieved successfully", sessions)
+}
+
+func (h *SessionHandler) RevokeMySession(c *fiber.Ctx) error {
+	sessionID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid session ID")
+	}
+
+	userID := middleware.GetUserIDFromContext(c)
+	if err := h.sessionService.RevokeSession(userID, uint(sessionID)); err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "revoke_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Session revoked successfully", nil)
+}
+
+func (h *SessionHandler) GetUserSessions(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid user ID")
+	}
+
+	currentUserID := middleware.GetUserIDFromContext(c)
+	if currentUserID != uint(id) {
+		canManage, err := middleware.CanManageUser(c, h.rbacService, uint(id))
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+		}
+		if !canManage {
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot manage this user's sessions")
+		}
+	}
+
+	sessions, err := h.sessionService.GetActiveSessions(uint(id), 0)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "User sessions retrieved successfully", sessions)
+}
+
+func (h *SessionHandler) RevokeUserSession(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid user ID")
+	}
+
+	sessionID, err := strconv.ParseUint(c.Params("sessionId"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid session ID")
+	}
+
+	currentUserID := middleware.GetUserIDFromContext(c)
+	if currentUserID != uint(id) {
+		canManage, err := middleware.CanManageUser(c, h.rbacService, uint(id))
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+		}
+		if !canManage {
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot manage this user's sessions")
+		}
+	}
+
+	if err := h.sessionService.RevokeSession(uint(id), uint(sessionID)); err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "revoke_failed", err.Error())
+	}
+
+	activityLog := middleware.NewActivityLog(c, "session_revoked", "sessions", "Revoked session "+c.Params("sessionId")+" of user "+c.Params("id"))
+	database.DB.Create(&activityLog)
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Session revoked successfully", nil)
+}
+
+func (h *SessionHandler) RevokeUserSessions(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid user ID")
+	}
+
+	currentUserID := middleware.GetUserIDFromContext(c)
+	if currentUserID != uint(id) {
+		canManage, err := middleware.CanManageUser(c, h.rbacService, uint(id))
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+		}
+		if !canManage {
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot manage this user's sessions")
+		}
+	}
+
+	if err := h.sessionService.RevokeAllSessions(uint(id)); err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "revoke_failed", err.Error())
+	}
+
+	activityLog := middleware.NewActivityLog(c, "sessions_revoked", "sessions", "Revoked all sessions of user "+c.Params("id"))
+	database.DB.Create(&activityLog)
+
+	return utils.SendSuccess(c, fiber.StatusOK, "All sessions revoked successfully", nil)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"rbac-system/backend/internal/middleware"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SettingsHandler struct {
+	registrationService *services.RegistrationService
+}
+
+func NewSettingsHandler(registrationService *services.RegistrationService) *SettingsHandler {
+	return &SettingsHandler{registrationService: registrationService}
+}
+
+func (h *SettingsHandler) GetRegistrationSettings(c *fiber.Ctx) error {
+	settings, err := h.registrationService.GetSettings()
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Registration settings retrieved successfully", settings)
+}
+
+func (h *SettingsHandler) UpdateDefaultRole(c *fiber.Ctx) error {
+	var req models.DefaultRoleInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	role, err := h.registrationService.SetDefaultRole(req.RoleID, middleware.GetUserIDFromContext(c), c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "update_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Default role updated successfully", role)
+}
+
+func (h *SettingsHandler) CreateRegistrationRule(c *fiber.Ctx) error {
+	var req models.RegistrationRoleRuleInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	rule, err := h.registrationService.CreateRule(&req, middleware.GetUserIDFromContext(c), c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "create_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusCreated, "Registration rule created successfully", rule)
+}
+
+func (h *SettingsHandler) UpdateRegistrationRule(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid rule ID")
+	}
+
+	var req models.RegistrationRoleRuleInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	rule, err := h.registrationService.UpdateRule(uint(id), &req, middleware.GetUserIDFromContext(c), c.IP(), c.Get("User-Agent"))
+	if errors.Is(err, services.ErrRegistrationRuleNotFound) {
+		return utils.SendError(c, fiber.StatusNotFound, "rule_not_found", err.Error())
+	}
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "update_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Registration rule updated successfully", rule)
+}
+
+func (h *SettingsHandler) DeleteRegistrationRule(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid rule ID")
+	}
+
+	err = h.registrationService.DeleteRule(uint(id), middleware.GetUserIDFromContext(c), c.IP(), c.Get("User-Agent"))
+	if errors.Is(err, services.ErrRegistrationRuleNotFound) {
+		return utils.SendError(c, fiber.StatusNotFound, "rule_not_found", err.Error())
+	}
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "delete_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Registration rule deleted successfully", nil)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"strconv"
+
+	"rbac-system/backend/internal/middleware"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TokenHandler struct {
+	tokenService *services.PersonalAccessTokenService
+	rbacService  *services.RBACService
+}
+
+func NewTokenHandler(tokenService *services.PersonalAccessTokenService, rbacService *services.RBACService) *TokenHandler {
+	return &TokenHandler{
+		tokenService: tokenService,
+		rbacService:  rbacService,
+	}
+}
+
+func (h *TokenHandler) GetMyTokens(c *fiber.Ctx) error {
+	tokens, err := h.tokenService.GetTokens(middleware.GetUserIDFromContext(c))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Tokens retrieved successfully", tokens)
+}
+
+func (h *TokenHandler) CreateToken(c *fiber.Ctx) error {
+	var req models.PersonalAccessTokenInput
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	if err := utils.ValidateStruct(&req); err != nil {
+		return utils.SendValidationError(c, err)
+	}
+
+	token, err := h.tokenService.CreateToken(middleware.GetUserIDFromContext(c), &req)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "create_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusCreated, "Token created successfully. Copy it now, it won't be shown again", token)
+}
+
+func (h *TokenHandler) RevokeMyToken(c *fiber.Ctx) error {
+	tokenID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid token ID")
+	}
+
+	userID := middleware.GetUserIDFromContext(c)
+	if err := h.tokenService.RevokeToken(userID, uint(tokenID)); err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "revoke_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Token revoked successfully", nil)
+}
+
+func (h *TokenHandler) GetUserTokens(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid user ID")
+	}
+
+	currentUserID := middleware.GetUserIDFromContext(c)
+	if currentUserID != uint(id) {
+		canManage, err := middleware.CanManageUser(c, h.rbacService, uint(id))
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+		}
+		if !canManage {
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot view this user's tokens")
+		}
+	}
+
+	tokens, err := h.tokenService.GetTokens(uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "User tokens retrieved successfully", tokens)
+}
+
+func (h *TokenHandler) RevokeUserToken(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid user ID")
+	}
+
+	tokenID, err := strconv.ParseUint(c.Params("tokenId"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid token ID")
+	}
+
+	currentUserID := middleware.GetUserIDFromContext(c)
+	if currentUserID != uint(id) {
+		canManage, err := middleware.CanManageUser(c, h.rbacService, uint(id))
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+		}
+		if !canManage {
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot manage this user's tokens")
+		}
+	}
+
+	if err := h.tokenService.RevokeToken(uint(id), uint(tokenID)); err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "revoke_failed", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "Token revoked successfully", nil)
+}
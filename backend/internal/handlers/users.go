@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
 
+	"rbac-system/backend/internal/database"
 	"rbac-system/backend/internal/middleware"
 	"rbac-system/backend/internal/models"
 	"rbac-system/backend/internal/services"
@@ -12,14 +14,16 @@ import (
 )
 
 type UserHandler struct {
-	userService *services.UserService
-	rbacService *services.RBACService
+	userService    *services.UserService
+	rbacService    *services.RBACService
+	lockoutService *services.LockoutService
 }
 
-func NewUserHandler(userService *services.UserService, rbacService *services.RBACService) *UserHandler {
+func NewUserHandler(userService *services.UserService, rbacService *services.RBACService, lockoutService *services.LockoutService) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		rbacService: rbacService,
+		userService:    userService,
+		rbacService:    rbacService,
+		lockoutService: lockoutService,
 	}
 }
 
@@ -69,7 +73,19 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		return utils.SendValidationError(c, err)
 	}
 
+	canAssign, err := middleware.CanAssignRoles(c, h.rbacService, append([]uint{req.RoleID}, req.RoleIDs...))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+	}
+	if !canAssign {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot assign roles at or above your own rank")
+	}
+
 	user, err := h.userService.CreateUser(&req)
+	var policyErr *utils.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return utils.SendValidationError(c, err)
+	}
 	if err != nil {
 		return utils.SendError(c, fiber.StatusBadRequest, "create_failed", err.Error())
 	}
@@ -86,7 +102,7 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	currentUserID := middleware.GetUserIDFromContext(c)
 	
 	if currentUserID != uint(id) {
-		canManage, err := h.rbacService.CanManageUser(currentUserID, uint(id))
+		canManage, err := middleware.CanManageUser(c, h.rbacService, uint(id))
 		if err != nil {
 			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
 		}
@@ -104,6 +120,24 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		return utils.SendValidationError(c, err)
 	}
 
+	if currentUserID == uint(id) && (req.RoleID != 0 || req.RoleIDs != nil) {
+		roles, err := h.rbacService.GetUserRoles(uint(id))
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+		}
+		if changesRoles(&req, roles) {
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot change your own roles")
+		}
+	} else if req.RoleID != 0 || req.RoleIDs != nil {
+		canAssign, err := middleware.CanAssignRoles(c, h.rbacService, append([]uint{req.RoleID}, req.RoleIDs...))
+		if err != nil {
+			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+		}
+		if !canAssign {
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot assign roles at or above your own rank")
+		}
+	}
+
 	user, err := h.userService.UpdateUser(uint(id), &req)
 	if err != nil {
 		return utils.SendError(c, fiber.StatusBadRequest, "update_failed", err.Error())
@@ -123,7 +157,7 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 		return utils.SendError(c, fiber.StatusBadRequest, "cannot_delete_self", "Cannot delete your own account")
 	}
 
-	canManage, err := h.rbacService.CanManageUser(currentUserID, uint(id))
+	canManage, err := middleware.CanManageUser(c, h.rbacService, uint(id))
 	if err != nil {
 		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
 	}
@@ -172,6 +206,35 @@ func (h *UserHandler) DeactivateUser(c *fiber.Ctx) error {
 	return utils.SendSuccess(c, fiber.StatusOK, "User deactivated successfully", user.ToResponse())
 }
 
+func (h *UserHandler) UnlockUser(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "invalid_id", "Invalid user ID")
+	}
+
+	canManage, err := middleware.CanManageUser(c, h.rbacService, uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+	}
+	if !canManage {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Cannot unlock this user")
+	}
+
+	if err := h.lockoutService.Unlock(uint(id)); err != nil {
+		return utils.SendError(c, fiber.StatusBadRequest, "unlock_failed", err.Error())
+	}
+
+	activityLog := middleware.NewActivityLog(c, "account_unlocked", "auth", "Unlocked user "+c.Params("id"))
+	database.DB.Create(&activityLog)
+
+	user, err := h.userService.GetUserByID(uint(id))
+	if err != nil {
+		return utils.SendError(c, fiber.StatusNotFound, "user_not_found", err.Error())
+	}
+
+	return utils.SendSuccess(c, fiber.StatusOK, "User unlocked successfully", user.ToResponse())
+}
+
 func (h *UserHandler) GetUserActivity(c *fiber.Ctx) error {
 	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
 	if err != nil {
@@ -224,7 +287,7 @@ func (h *UserHandler) UpdatePassword(c *fiber.Ctx) error {
 	
 	// Only allow users to update their own password or admin can update any user's password
 	if currentUserID != uint(id) {
-		canManage, err := h.rbacService.CanManageUser(currentUserID, uint(id))
+		canManage, err := middleware.CanManageUser(c, h.rbacService, uint(id))
 		if err != nil {
 			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
 		}
@@ -243,6 +306,10 @@ func (h *UserHandler) UpdatePassword(c *fiber.Ctx) error {
 	}
 
 	err = h.userService.UpdatePassword(uint(id), &req)
+	var policyErr *utils.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return utils.SendValidationError(c, err)
+	}
 	if err != nil {
 		return utils.SendError(c, fiber.StatusBadRequest, "update_password_failed", err.Error())
 	}
@@ -255,4 +322,35 @@ func parseIntOrDefault(s string, defaultVal int) int {
 		return val
 	}
 	return defaultVal
-}
\ No newline at end of file
+}
+
+// changesRoles reports whether the update would leave the user with other
+// roles than current, which lists the primary role first. Forms send the
+// current role along with every edit, so that alone is not a change.
+func changesRoles(req *models.UserUpdateInput, current []models.Role) bool {
+	if len(current) == 0 {
+		return true
+	}
+	if req.RoleID != 0 && req.RoleID != current[0].ID {
+		return true
+	}
+	if req.RoleIDs == nil {
+		return false
+	}
+
+	requested := map[uint]bool{}
+	for _, id := range append([]uint{req.RoleID}, req.RoleIDs...) {
+		if id != 0 {
+			requested[id] = true
+		}
+	}
+	if len(requested) != len(current) {
+		return true
+	}
+	for _, role := range current {
+		if !requested[role.ID] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AsyncMailer queues messages and delivers them from a background worker so
+// request handlers never wait on the mail server. Failed sends are retried
+// with exponential backoff before the message is dropped and logged.
+type AsyncMailer struct {
+	next       Mailer
+	maxRetries int
+	backoff    time.Duration
+	queue      chan *Message
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+}
+
+func NewAsyncMailer(next Mailer, queueSize, maxRetries int, backoff time.Duration) *AsyncMailer {
+	m := &AsyncMailer{
+		next:       next,
+		maxRetries: maxRetries,
+		backoff:    backoff,
+		queue:      make(chan *Message, queueSize),
+	}
+
+	m.wg.Add(1)
+	go m.run()
+
+	return m
+}
+
+// Send enqueues the message and returns immediately. It never reports
+// delivery errors; those are logged by the worker.
+func (m *AsyncMailer) Send(msg *Message) error {
+	m.queue <- msg
+	return nil
+}
+
+// Close stops accepting messages and waits for the queue to drain.
+func (m *AsyncMailer) Close() {
+	m.closeOnce.Do(func() {
+		close(m.queue)
+	})
+	m.wg.Wait()
+}
+
+func (m *AsyncMailer) run() {
+	defer m.wg.Done()
+
+	for msg := range m.queue {
+		m.deliver(msg)
+	}
+}
+
+func (m *AsyncMailer) deliver(msg *Message) {
+	delay := m.backoff
+	for attempt := 0; ; attempt++ {
+		err := m.next.Send(msg)
+		if err == nil {
+			return
+		}
+
+		if attempt >= m.maxRetries {
+			log.Printf("Failed to send %q to %s after %d attempts: %v", msg.Subject, strings.Join(msg.To, ", "), attempt+1, err)
+			return
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogMailer is the development sink. It writes each message to Dir as an
+// .eml file, or to the application log when Dir is empty, instead of
+// delivering it.
+type LogMailer struct {
+	dir  string
+	from string
+}
+
+func NewLogMailer(dir, from string) *LogMailer {
+	return &LogMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m *LogMailer) Send(msg *Message) error {
+	if m.dir == "" {
+		log.Printf("Mail to %s: %s\n%s", strings.Join(msg.To, ", "), msg.Subject, msg.Text)
+		return nil
+	}
+
+	body, err := msg.Bytes(m.from)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), sanitizeFilename(msg.To[0]))
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0o644)
+}
+
+func sanitizeFilename(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
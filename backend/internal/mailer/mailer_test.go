@@ -0,0 +1,232 @@
+package mailer_test
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/mailer"
+	"rbac-system/backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer runs a minimal SMTP stand-in on a random local port and
+// returns every message it accepts on the channel.
+func startSMTPServer(t *testing.T) (string, string, <-chan receivedMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan receivedMail, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, received)
+		}
+	}()
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	return host, port, received
+}
+
+func serveSMTP(conn net.Conn, received chan<- receivedMail) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var mail receivedMail
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			mail.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			mail.to = append(mail.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			mail.data = data.String()
+			received <- mail
+			mail = receivedMail{}
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	host, port, received := startSMTPServer(t)
+	smtpMailer := mailer.NewSMTPMailer(config.SMTPConfig{Host: host, Port: port}, "RBAC System <no-reply@example.com>")
+
+	msg, err := mailer.Render(mailer.TemplatePasswordReset, "user@example.com", mailer.TemplateData{
+		AppName:   "RBAC System",
+		Name:      "Jane",
+		Link:      "http://localhost:3000/reset-password?token=abc123",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.NoError(t, smtpMailer.Send(msg))
+
+	select {
+	case mail := <-received:
+		assert.Equal(t, "no-reply@example.com", mail.from)
+		assert.Equal(t, []string{"user@example.com"}, mail.to)
+		assert.Contains(t, mail.data, "Subject: Reset your RBAC System password")
+		assert.Contains(t, mail.data, "multipart/alternative")
+		assert.Contains(t, mail.data, "token=3Dabc123")
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP server did not receive the message")
+	}
+}
+
+func TestRender_AllTemplates(t *testing.T) {
+	data := mailer.TemplateData{
+		AppName:   "RBAC System",
+		Name:      "Jane <script>",
+		Link:      "http://localhost:3000/verify-email?token=abc",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Event:     "Account locked",
+		Details:   "Too many failed login attempts",
+		IPAddress: "10.0.0.1",
+		Time:      time.Now(),
+	}
+
+	for _, name := range []string{
+		mailer.TemplatePasswordReset,
+		mailer.TemplateWelcome,
+		mailer.TemplateEmailVerification,
+		mailer.TemplateSecurityAlert,
+		mailer.TemplateMagicLink,
+	} {
+		msg, err := mailer.Render(name, "user@example.com", data)
+		require.NoError(t, err, name)
+		assert.NotEmpty(t, msg.Subject, name)
+		assert.NotContains(t, msg.Subject, "\n", name)
+		assert.Contains(t, msg.Text, "Hi Jane", name)
+		assert.Contains(t, msg.HTML, "Jane &lt;script&gt;", name)
+	}
+}
+
+func TestRender_Invitation(t *testing.T) {
+	msg, err := mailer.Render(mailer.TemplateInvitation, "new@example.com", mailer.TemplateData{
+		AppName:   "RBAC System",
+		Link:      "http://localhost:3000/accept-invitation?token=abc",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Inviter:   "Jane Admin",
+		Role:      "Editor",
+		Message:   "See you <b>Monday</b>",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "You're invited to RBAC System", msg.Subject)
+	assert.Contains(t, msg.Text, "Jane Admin has invited you to join RBAC System as Editor.")
+	assert.Contains(t, msg.HTML, "See you &lt;b&gt;Monday&lt;/b&gt;")
+}
+
+type flakyMailer struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	sent     []*mailer.Message
+}
+
+func (m *flakyMailer) Send(msg *mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attempts++
+	if m.attempts <= m.failures {
+		return errors.New("temporary failure")
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestAsyncMailer_RetriesFailedSends(t *testing.T) {
+	flaky := &flakyMailer{failures: 2}
+	asyncMailer := mailer.NewAsyncMailer(flaky, 10, 3, time.Millisecond)
+
+	require.NoError(t, asyncMailer.Send(&mailer.Message{To: []string{"user@example.com"}, Subject: "Hello"}))
+	asyncMailer.Close()
+
+	assert.Equal(t, 3, flaky.attempts)
+	require.Len(t, flaky.sent, 1)
+	assert.Equal(t, "Hello", flaky.sent[0].Subject)
+}
+
+func TestAsyncMailer_GivesUpAfterMaxRetries(t *testing.T) {
+	flaky := &flakyMailer{failures: 10}
+	asyncMailer := mailer.NewAsyncMailer(flaky, 10, 2, time.Millisecond)
+
+	require.NoError(t, asyncMailer.Send(&mailer.Message{To: []string{"user@example.com"}, Subject: "Hello"}))
+	asyncMailer.Close()
+
+	assert.Equal(t, 3, flaky.attempts)
+	assert.Empty(t, flaky.sent)
+}
+
+func TestNotifier_LogMailerWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	notifier := mailer.NewNotifier(mailer.NewLogMailer(dir, "no-reply@example.com"), "RBAC System", "http://localhost:3000/")
+
+	user := &models.User{Email: "user@example.com", FirstName: "Jane"}
+	require.NoError(t, notifier.SendEmailVerification(user, "tok", time.Now().Add(time.Hour)))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: user@example.com")
+	assert.Contains(t, string(content), "http://localhost:3000/verify-email?token=3Dtok")
+}
+
+func TestNotifier_NilIsNoop(t *testing.T) {
+	var notifier *mailer.Notifier
+	assert.NoError(t, notifier.SendWelcome(&models.User{Email: "user@example.com"}))
+}
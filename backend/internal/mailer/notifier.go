@@ -0,0 +1,107 @@
+package mailer
+
+import (
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"rbac-system/backend/internal/models"
+)
+
+// Notifier renders the application's transactional emails and hands them to
+// a Mailer. A nil Notifier is valid and sends nothing, which keeps services
+// usable in tests and tools that don't configure mail.
+type Notifier struct {
+	mailer  Mailer
+	appName string
+	appURL  string
+}
+
+func NewNotifier(mailer Mailer, appName, appURL string) *Notifier {
+	return &Notifier{
+		mailer:  mailer,
+		appName: appName,
+		appURL:  strings.TrimRight(appURL, "/"),
+	}
+}
+
+func (n *Notifier) SendPasswordReset(user *models.User, token string, expiresAt time.Time) error {
+	return n.send(TemplatePasswordReset, user, TemplateData{
+		Link:      n.link("/reset-password", token),
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (n *Notifier) SendMagicLink(user *models.User, token string, expiresAt time.Time) error {
+	return n.send(TemplateMagicLink, user, TemplateData{
+		Link:      n.link("/magic-link", token),
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (n *Notifier) SendWelcome(user *models.User) error {
+	return n.send(TemplateWelcome, user, TemplateData{
+		Link: n.link("/login", ""),
+	})
+}
+
+func (n *Notifier) SendEmailVerification(user *models.User, token string, expiresAt time.Time) error {
+	return n.send(TemplateEmailVerification, user, TemplateData{
+		Link:      n.link("/verify-email", token),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// SendSecurityAlert tells the user about a security-relevant event on their
+// account, such as a lockout or a detected refresh token reuse.
+func (n *Notifier) SendSecurityAlert(user *models.User, event, details, ipAddress string) error {
+	return n.send(TemplateSecurityAlert, user, TemplateData{
+		Event:     event,
+		Details:   details,
+		IPAddress: ipAddress,
+		Time:      time.Now(),
+	})
+}
+
+// SendInvitation invites someone without an account yet, so the message is
+// addressed to the invitation's email rather than to a user.
+func (n *Notifier) SendInvitation(invitation *models.Invitation, inviter, token string) error {
+	return n.send(TemplateInvitation, &models.User{Email: invitation.Email}, TemplateData{
+		Link:      n.link("/accept-invitation", token),
+		ExpiresAt: invitation.ExpiresAt,
+		Inviter:   inviter,
+		Role:      invitation.Role.Name,
+		Message:   invitation.Message,
+	})
+}
+
+func (n *Notifier) send(template string, user *models.User, data TemplateData) error {
+	if n == nil || n.mailer == nil {
+		return nil
+	}
+
+	data.AppName = n.appName
+	data.Name = user.FirstName
+	if data.Name == "" {
+		data.Name = user.Username
+	}
+
+	msg, err := Render(template, user.Email, data)
+	if err != nil {
+		log.Printf("Failed to render %s email: %v", template, err)
+		return err
+	}
+
+	return n.mailer.Send(msg)
+}
+
+func (n *Notifier) link(path, token string) string {
+	if n == nil {
+		return ""
+	}
+	if token == "" {
+		return n.appURL + path
+	}
+	return n.appURL + path + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,93 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+
+	"rbac-system/backend/internal/config"
+)
+
+const smtpDialTimeout = 10 * time.Second
+
+type SMTPMailer struct {
+	config config.SMTPConfig
+	from   string
+}
+
+func NewSMTPMailer(cfg config.SMTPConfig, from string) *SMTPMailer {
+	return &SMTPMailer{
+		config: cfg,
+		from:   from,
+	}
+}
+
+// Send delivers the message over SMTP, upgrading to TLS with STARTTLS and
+// authenticating whenever the server offers it.
+func (m *SMTPMailer) Send(msg *Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("message has no recipients")
+	}
+
+	body, err := msg.Bytes(m.from)
+	if err != nil {
+		return err
+	}
+
+	sender, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(m.config.Host, m.config.Port), smtpDialTimeout)
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.config.Host}); err != nil {
+			return err
+		}
+	}
+
+	if m.config.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			auth := smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+			if err := client.Auth(auth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := client.Mail(sender.Address); err != nil {
+		return err
+	}
+	for _, recipient := range msg.To {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
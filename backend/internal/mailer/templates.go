@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+const (
+	TemplatePasswordReset     = "password_reset"
+	TemplateWelcome           = "welcome"
+	TemplateEmailVerification = "email_verification"
+	TemplateSecurityAlert     = "security_alert"
+	TemplateInvitation        = "invitation"
+	TemplateMagicLink         = "magic_link"
+)
+
+// TemplateData holds the values available to every email template.
+type TemplateData struct {
+	AppName   string
+	Name      string
+	Link      string
+	ExpiresAt time.Time
+	Event     string
+	Details   string
+	IPAddress string
+	Time      time.Time
+	Inviter   string
+	Role      string
+	Message   string
+}
+
+// Render builds a message from templates/<name>.txt, which must define a
+// "subject" block, and templates/<name>.html rendered inside layout.html.
+func Render(name string, to string, data TemplateData) (*Message, error) {
+	textTmpl, err := texttemplate.ParseFS(templateFS, "templates/"+name+".txt")
+	if err != nil {
+		return nil, err
+	}
+
+	var subject, text bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return nil, err
+	}
+
+	htmlTmpl, err := htmltemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html")
+	if err != nil {
+		return nil, err
+	}
+
+	var html bytes.Buffer
+	if err := htmlTmpl.ExecuteTemplate(&html, "layout", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		To:      []string{to},
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
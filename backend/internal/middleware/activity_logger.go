@@ -7,27 +7,22 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+// ActivityLogger records every successful authenticated request. It has to
+// run ahead of the routes, and the principal is only known once the route's
+// auth middleware has run, so it is checked after the request is handled.
 func ActivityLogger() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		userID := GetUserIDFromContext(c)
-		if userID == 0 {
-			return c.Next()
-		}
-
 		err := c.Next()
 
+		if GetUserIDFromContext(c) == 0 && GetServiceAccountFromContext(c) == nil {
+			return err
+		}
+
 		if c.Response().StatusCode() >= 200 && c.Response().StatusCode() < 300 {
 			action := getActionFromMethod(c.Method())
 			resource := getResourceFromPath(c.Path())
 			
-			activityLog := models.ActivityLog{
-				UserID:    userID,
-				Action:    action,
-				Resource:  resource,
-				Details:   getDetailsFromContext(c),
-				IPAddress: c.IP(),
-				UserAgent: c.Get("User-Agent"),
-			}
+			activityLog := NewActivityLog(c, action, resource, getDetailsFromContext(c))
 			
 			go func() {
 				database.DB.Create(&activityLog)
@@ -38,6 +33,31 @@ func ActivityLogger() fiber.Handler {
 	}
 }
 
+// NewActivityLog builds an activity log entry for the current request,
+// attributed to the authenticated user or service account, and to the
+// impersonator when the user is being impersonated.
+func NewActivityLog(c *fiber.Ctx, action, resource, details string) models.ActivityLog {
+	activityLog := models.ActivityLog{
+		Action:    action,
+		Resource:  resource,
+		Details:   details,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}
+
+	if account := GetServiceAccountFromContext(c); account != nil {
+		activityLog.ServiceAccountID = &account.ID
+	} else if userID := GetUserIDFromContext(c); userID != 0 {
+		activityLog.UserID = &userID
+	}
+
+	if impersonator := GetImpersonatorFromContext(c); impersonator != nil {
+		activityLog.ImpersonatorID = &impersonator.ID
+	}
+
+	return activityLog
+}
+
 func getActionFromMethod(method string) string {
 	switch method {
 	case "GET":
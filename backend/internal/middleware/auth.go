@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"rbac-system/backend/internal/models"
 	"rbac-system/backend/internal/services"
@@ -10,7 +12,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func AuthMiddleware(authService *services.AuthService, jwtService *utils.JWTService) fiber.Handler {
+func AuthMiddleware(authService *services.AuthService, jwtService *utils.JWTService, tokenService *services.PersonalAccessTokenService, serviceAccountService *services.ServiceAccountService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -23,9 +25,33 @@ func AuthMiddleware(authService *services.AuthService, jwtService *utils.JWTServ
 		}
 
 		tokenString := tokenParts[1]
+		if services.IsPersonalAccessToken(tokenString) {
+			return authenticatePersonalAccessToken(c, tokenService, tokenString)
+		}
+
+		if services.IsServiceAccountKey(tokenString) {
+			account, err := serviceAccountService.AuthenticateAPIKey(tokenString, c.IP())
+			if err != nil {
+				return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "Invalid or expired API key")
+			}
+			return authenticateServiceAccount(c, account)
+		}
+
 		claims, err := jwtService.ValidateAccessToken(tokenString)
 		if err != nil {
-			return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "Invalid or expired token")
+			serviceClaims, serviceErr := jwtService.ValidateServiceAccountToken(tokenString)
+			if serviceErr != nil {
+				return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "Invalid or expired token")
+			}
+
+			account, err := serviceAccountService.AuthenticateToken(serviceClaims)
+			if err != nil {
+				return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", err.Error())
+			}
+			if serviceClaims.Scope != "" {
+				c.Locals("token_scopes", strings.Fields(serviceClaims.Scope))
+			}
+			return authenticateServiceAccount(c, account)
 		}
 
 		user, err := authService.GetUserByID(claims.UserID)
@@ -37,10 +63,134 @@ func AuthMiddleware(authService *services.AuthService, jwtService *utils.JWTServ
 			return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "Account is deactivated")
 		}
 
+		if authService.IsAccessTokenRevoked(claims, user) {
+			return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "Token has been revoked")
+		}
+
+		// An impersonation ends with the impersonator's account or when
+		// they log out everywhere.
+		if claims.ImpersonatorID != 0 {
+			impersonator, err := authService.GetUserByID(claims.ImpersonatorID)
+			if err != nil || !impersonator.IsActive || authService.IsAccessTokenRevoked(claims, impersonator) {
+				return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "Impersonation has ended")
+			}
+			c.Locals("impersonator", impersonator)
+		}
+
+		authService.TouchSession(claims)
+
 		c.Locals("user", user)
+		c.Locals("claims", claims)
 		c.Locals("user_id", user.ID)
 		c.Locals("role_id", user.RoleID)
-		
+		if claims.Scope != "" {
+			c.Locals("token_scopes", strings.Fields(claims.Scope))
+		}
+
+		return c.Next()
+	}
+}
+
+// authenticatePersonalAccessToken accepts a personal access token in place of
+// a JWT. No claims are set; the token's permissions are stored as its scopes
+// so that the RBAC middleware can narrow the owner's permissions to them.
+func authenticatePersonalAccessToken(c *fiber.Ctx, tokenService *services.PersonalAccessTokenService, tokenString string) error {
+	token, err := tokenService.Authenticate(tokenString, c.IP())
+	if err != nil {
+		return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "Invalid or expired token")
+	}
+
+	user := &token.User
+	if !user.IsActive {
+		return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "Account is deactivated")
+	}
+
+	c.Locals("user", user)
+	c.Locals("user_id", user.ID)
+	c.Locals("role_id", user.RoleID)
+	c.Locals("personal_access_token", token)
+	c.Locals("token_scopes", token.PermissionNames())
+
+	return c.Next()
+}
+
+// authenticateServiceAccount sets up the request for a service account. It has
+// no user, so only the service account and its role are stored.
+func authenticateServiceAccount(c *fiber.Ctx, account *models.ServiceAccount) error {
+	c.Locals("service_account", account)
+	c.Locals("role_id", account.RoleID)
+
+	return c.Next()
+}
+
+// RejectServiceAccounts guards routes that only make sense for a person, such
+// as the profile endpoints.
+func RejectServiceAccounts() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if GetServiceAccountFromContext(c) != nil {
+			return utils.SendError(c, fiber.StatusForbidden, "service_account_not_allowed", "Service accounts cannot use this endpoint")
+		}
+		return c.Next()
+	}
+}
+
+// CanManageUser reports whether the authenticated principal may manage the
+// target user. Service accounts are ranked by their role.
+func CanManageUser(c *fiber.Ctx, rbacService *services.RBACService, targetUserID uint) (bool, error) {
+	if account := GetServiceAccountFromContext(c); account != nil {
+		return rbacService.CanRoleManageUser(account.RoleID, targetUserID)
+	}
+	return rbacService.CanManageUser(GetUserIDFromContext(c), targetUserID)
+}
+
+// CanAssignRoles reports whether the authenticated principal ranks above every
+// one of the roles, so that it may give them to a user.
+func CanAssignRoles(c *fiber.Ctx, rbacService *services.RBACService, roleIDs []uint) (bool, error) {
+	if account := GetServiceAccountFromContext(c); account != nil {
+		return rbacService.CanRoleAssignRoles(account.RoleID, roleIDs)
+	}
+	return rbacService.CanAssignRoles(GetUserIDFromContext(c), roleIDs)
+}
+
+// RejectPersonalAccessTokens restricts routes to interactive sessions, so a
+// token, or an OAuth client acting for the user, can't be used to change
+// credentials or mint further tokens.
+func RejectPersonalAccessTokens() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if GetPersonalAccessTokenFromContext(c) != nil {
+			return utils.SendError(c, fiber.StatusForbidden, "token_not_allowed", "Personal access tokens cannot be used for this endpoint")
+		}
+		if claims := GetClaimsFromContext(c); claims != nil && claims.ClientID != "" {
+			return utils.SendError(c, fiber.StatusForbidden, "token_not_allowed", "OAuth client tokens cannot be used for this endpoint")
+		}
+		return c.Next()
+	}
+}
+
+// RequireRecentAuth guards sensitive routes: the access token's auth_time
+// must be within maxAge. Otherwise the request fails with step_up_required
+// and the client should re-authenticate through /api/auth/step-up. Personal
+// access tokens, service accounts and impersonators have no auth_time and
+// never pass.
+func RequireRecentAuth(maxAge time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := GetClaimsFromContext(c)
+		if claims == nil || claims.AuthTime.IsZero() || time.Since(claims.AuthTime) > maxAge {
+			c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error="insufficient_user_authentication", max_age=%d`, int(maxAge.Seconds())))
+			return utils.SendError(c, fiber.StatusUnauthorized, "step_up_required", "Please confirm your password or MFA code to continue")
+		}
+		return c.Next()
+	}
+}
+
+// RejectImpersonation keeps an impersonator from acting as the user where
+// it would change their credentials or outlast the impersonation, such as
+// passwords, MFA, tokens and sessions, and from impersonating anyone else.
+func RejectImpersonation() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if GetImpersonatorFromContext(c) != nil {
+			return utils.SendError(c, fiber.StatusForbidden, "impersonation_not_allowed", "This action is not available while impersonating a user")
+		}
 		return c.Next()
 	}
 }
@@ -53,6 +203,14 @@ func GetUserFromContext(c *fiber.Ctx) *models.User {
 	return user
 }
 
+func GetClaimsFromContext(c *fiber.Ctx) *models.JWTClaims {
+	claims, ok := c.Locals("claims").(*models.JWTClaims)
+	if !ok {
+		return nil
+	}
+	return claims
+}
+
 func GetUserIDFromContext(c *fiber.Ctx) uint {
 	userID, ok := c.Locals("user_id").(uint)
 	if !ok {
@@ -67,4 +225,30 @@ func GetRoleIDFromContext(c *fiber.Ctx) uint {
 		return 0
 	}
 	return roleID
-}
\ No newline at end of file
+}
+
+func GetPersonalAccessTokenFromContext(c *fiber.Ctx) *models.PersonalAccessToken {
+	token, ok := c.Locals("personal_access_token").(*models.PersonalAccessToken)
+	if !ok {
+		return nil
+	}
+	return token
+}
+
+func GetServiceAccountFromContext(c *fiber.Ctx) *models.ServiceAccount {
+	account, ok := c.Locals("service_account").(*models.ServiceAccount)
+	if !ok {
+		return nil
+	}
+	return account
+}
+
+// GetImpersonatorFromContext returns the user acting through an impersonation
+// token, or nil when the authenticated user is acting for themselves.
+func GetImpersonatorFromContext(c *fiber.Ctx) *models.User {
+	impersonator, ok := c.Locals("impersonator").(*models.User)
+	if !ok {
+		return nil
+	}
+	return impersonator
+}
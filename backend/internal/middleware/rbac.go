@@ -11,17 +11,21 @@ import (
 
 func RequirePermission(rbacService *services.RBACService, resource, action string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if account := GetServiceAccountFromContext(c); account != nil {
+			return serviceAccountPermission(c, rbacService, account.ID, resource, action)
+		}
+
 		userID := GetUserIDFromContext(c)
 		if userID == 0 {
 			return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "User not authenticated")
 		}
 
-		hasPermission, err := rbacService.CheckPermission(userID, resource, action)
+		hasPermission, err := userHasPermission(c, rbacService, userID, resource, action)
 		if err != nil {
 			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
 		}
 
-		if !hasPermission {
+		if !hasPermission || !tokenAllows(c, resource, action) {
 			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Insufficient permissions")
 		}
 
@@ -31,6 +35,22 @@ func RequirePermission(rbacService *services.RBACService, resource, action strin
 
 func RequireRole(rbacService *services.RBACService, roleName string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// Role checks guard whole areas, so scoped tokens never pass them.
+		if isScopedToken(c) {
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Insufficient role privileges")
+		}
+
+		if account := GetServiceAccountFromContext(c); account != nil {
+			hasRole, err := rbacService.ServiceAccountHasRole(account.ID, roleName)
+			if err != nil {
+				return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking role")
+			}
+			if !hasRole {
+				return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Insufficient role privileges")
+			}
+			return c.Next()
+		}
+
 		userID := GetUserIDFromContext(c)
 		if userID == 0 {
 			return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "User not authenticated")
@@ -51,19 +71,35 @@ func RequireRole(rbacService *services.RBACService, roleName string) fiber.Handl
 
 func RequireRoleAny(rbacService *services.RBACService, roleNames ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// Role checks guard whole areas, so scoped tokens never pass them.
+		if isScopedToken(c) {
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Insufficient role privileges")
+		}
+
+		if account := GetServiceAccountFromContext(c); account != nil {
+			for _, roleName := range roleNames {
+				if account.Role.Name == roleName {
+					return c.Next()
+				}
+			}
+			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Insufficient role privileges")
+		}
+
 		userID := GetUserIDFromContext(c)
 		if userID == 0 {
 			return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "User not authenticated")
 		}
 
-		userRole, err := rbacService.GetUserRole(userID)
+		userRoles, err := rbacService.GetUserRoles(userID)
 		if err != nil {
 			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error getting user role")
 		}
 
-		for _, roleName := range roleNames {
-			if userRole.Name == roleName {
-				return c.Next()
+		for _, userRole := range userRoles {
+			for _, roleName := range roleNames {
+				if userRole.Name == roleName {
+					return c.Next()
+				}
 			}
 		}
 
@@ -73,6 +109,10 @@ func RequireRoleAny(rbacService *services.RBACService, roleNames ...string) fibe
 
 func SelfOrPermission(rbacService *services.RBACService, resource, action string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if account := GetServiceAccountFromContext(c); account != nil {
+			return serviceAccountPermission(c, rbacService, account.ID, resource, action)
+		}
+
 		userID := GetUserIDFromContext(c)
 		if userID == 0 {
 			return utils.SendError(c, fiber.StatusUnauthorized, "unauthorized", "User not authenticated")
@@ -83,19 +123,68 @@ func SelfOrPermission(rbacService *services.RBACService, resource, action string
 			return utils.SendError(c, fiber.StatusBadRequest, "bad_request", "User ID parameter is required")
 		}
 
-		if targetUserID == fmt.Sprintf("%d", userID) {
+		if targetUserID == fmt.Sprintf("%d", userID) && tokenAllows(c, resource, action) {
 			return c.Next()
 		}
 
-		hasPermission, err := rbacService.CheckPermission(userID, resource, action)
+		hasPermission, err := userHasPermission(c, rbacService, userID, resource, action)
 		if err != nil {
 			return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
 		}
 
-		if !hasPermission {
+		if !hasPermission || !tokenAllows(c, resource, action) {
 			return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Insufficient permissions")
 		}
 
 		return c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// serviceAccountPermission continues the request if the service account's role
+// grants the permission. Service accounts have no "self", so SelfOrPermission
+// always needs the permission too.
+func serviceAccountPermission(c *fiber.Ctx, rbacService *services.RBACService, accountID uint, resource, action string) error {
+	hasPermission, err := rbacService.CheckServiceAccountPermission(accountID, resource, action)
+	if err != nil {
+		return utils.SendError(c, fiber.StatusInternalServerError, "internal_error", "Error checking permissions")
+	}
+
+	if !hasPermission || !tokenAllows(c, resource, action) {
+		return utils.SendError(c, fiber.StatusForbidden, "forbidden", "Insufficient permissions")
+	}
+
+	return c.Next()
+}
+
+// userHasPermission checks the authenticated user's permissions. The auth
+// middleware stores the user with their permissions loaded, so the check only
+// queries them when there is no such user.
+func userHasPermission(c *fiber.Ctx, rbacService *services.RBACService, userID uint, resource, action string) (bool, error) {
+	if user := GetUserFromContext(c); user != nil {
+		return rbacService.UserHasPermission(user, resource, action), nil
+	}
+	return rbacService.CheckPermission(userID, resource, action)
+}
+
+func isScopedToken(c *fiber.Ctx) bool {
+	_, ok := c.Locals("token_scopes").([]string)
+	return ok
+}
+
+// tokenAllows reports whether the request is within the scope of its token.
+// Personal access tokens and scoped OAuth tokens carry a list of permission
+// names; other tokens are unrestricted.
+func tokenAllows(c *fiber.Ctx, resource, action string) bool {
+	if !isScopedToken(c) {
+		return true
+	}
+
+	scopes := c.Locals("token_scopes").([]string)
+	requiredPermission := fmt.Sprintf("%s.%s", resource, action)
+	for _, scope := range scopes {
+		if scope == requiredPermission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+
+	"rbac-system/backend/internal/scim"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SCIMAuth only lets through requests bearing the SCIM token. The token is
+// separate from user credentials since it grants full user and role
+// provisioning; it is compared through its SHA-256 digest in constant time.
+func SCIMAuth(token string) fiber.Handler {
+	expected := sha256.Sum256([]byte(token))
+
+	return func(c *fiber.Ctx) error {
+		scheme, presented, ok := strings.Cut(c.Get("Authorization"), " ")
+		digest := sha256.Sum256([]byte(strings.TrimSpace(presented)))
+
+		if !ok || !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare(expected[:], digest[:]) != 1 {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="scim"`)
+			return c.Status(fiber.StatusUnauthorized).JSON(scim.NewError(fiber.StatusUnauthorized, "", "Invalid or missing SCIM token"), scim.ContentType)
+		}
+
+		return c.Next()
+	}
+}
@@ -6,19 +6,26 @@ import (
 	"gorm.io/gorm"
 )
 
+// ActivityLog records an action by either a user or a service account; exactly
+// one of UserID and ServiceAccountID is set. While a user is impersonated,
+// UserID is the impersonated user and ImpersonatorID the one acting.
 type ActivityLog struct {
-	ID        uint           `json:"id" gorm:"primarykey"`
-	UserID    uint           `json:"user_id" gorm:"not null"`
-	Action    string         `json:"action" gorm:"not null" validate:"required,max=100"`
-	Resource  string         `json:"resource" gorm:"not null" validate:"required,max=100"`
-	Details   string         `json:"details" gorm:"type:text"`
-	IPAddress string         `json:"ip_address" gorm:"max=45"`
-	UserAgent string         `json:"user_agent" gorm:"type:text"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID               uint           `json:"id" gorm:"primarykey"`
+	UserID           *uint          `json:"user_id" gorm:"index"`
+	ServiceAccountID *uint          `json:"service_account_id" gorm:"index"`
+	ImpersonatorID   *uint          `json:"impersonator_id" gorm:"index"`
+	Action           string         `json:"action" gorm:"not null" validate:"required,max=100"`
+	Resource         string         `json:"resource" gorm:"not null" validate:"required,max=100"`
+	Details          string         `json:"details" gorm:"type:text"`
+	IPAddress        string         `json:"ip_address" gorm:"max=45"`
+	UserAgent        string         `json:"user_agent" gorm:"type:text"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 
-	User User `json:"user" gorm:"foreignKey:UserID"`
+	User           User           `json:"user" gorm:"foreignKey:UserID"`
+	ServiceAccount ServiceAccount `json:"service_account" gorm:"foreignKey:ServiceAccountID"`
+	Impersonator   User           `json:"impersonator" gorm:"foreignKey:ImpersonatorID"`
 }
 
 type ActivityLogInput struct {
@@ -31,20 +38,32 @@ type ActivityLogInput struct {
 }
 
 type ActivityLogResponse struct {
-	ID        uint      `json:"id"`
-	UserID    uint      `json:"user_id"`
-	Action    string    `json:"action"`
-	Resource  string    `json:"resource"`
-	Details   string    `json:"details"`
-	IPAddress string    `json:"ip_address"`
-	UserAgent string    `json:"user_agent"`
-	CreatedAt time.Time `json:"created_at"`
-	User      struct {
+	ID               uint      `json:"id"`
+	UserID           uint      `json:"user_id"`
+	ServiceAccountID *uint     `json:"service_account_id,omitempty"`
+	ImpersonatorID   *uint     `json:"impersonator_id,omitempty"`
+	Action           string    `json:"action"`
+	Resource         string    `json:"resource"`
+	Details          string    `json:"details"`
+	IPAddress        string    `json:"ip_address"`
+	UserAgent        string    `json:"user_agent"`
+	CreatedAt        time.Time `json:"created_at"`
+	User             struct {
 		ID        uint   `json:"id"`
 		Username  string `json:"username"`
 		FirstName string `json:"first_name"`
 		LastName  string `json:"last_name"`
 	} `json:"user"`
+	ServiceAccount *ServiceAccountSummary `json:"service_account,omitempty"`
+	Impersonator   *ImpersonatorSummary   `json:"impersonator,omitempty"`
+}
+
+// ImpersonatorSummary names the user who acted on another user's behalf.
+type ImpersonatorSummary struct {
+	ID        uint   `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
 }
 
 type ActivityLogListResponse struct {
@@ -61,14 +80,18 @@ func (ActivityLog) TableName() string {
 
 func (a *ActivityLog) ToResponse() *ActivityLogResponse {
 	response := &ActivityLogResponse{
-		ID:        a.ID,
-		UserID:    a.UserID,
-		Action:    a.Action,
-		Resource:  a.Resource,
-		Details:   a.Details,
-		IPAddress: a.IPAddress,
-		UserAgent: a.UserAgent,
-		CreatedAt: a.CreatedAt,
+		ID:               a.ID,
+		ServiceAccountID: a.ServiceAccountID,
+		ImpersonatorID:   a.ImpersonatorID,
+		Action:           a.Action,
+		Resource:         a.Resource,
+		Details:          a.Details,
+		IPAddress:        a.IPAddress,
+		UserAgent:        a.UserAgent,
+		CreatedAt:        a.CreatedAt,
+	}
+	if a.UserID != nil {
+		response.UserID = *a.UserID
 	}
 	
 	response.User.ID = a.User.ID
@@ -76,5 +99,18 @@ func (a *ActivityLog) ToResponse() *ActivityLogResponse {
 	response.User.FirstName = a.User.FirstName
 	response.User.LastName = a.User.LastName
 
+	if a.ServiceAccountID != nil {
+		response.ServiceAccount = &ServiceAccountSummary{ID: a.ServiceAccount.ID, Name: a.ServiceAccount.Name}
+	}
+
+	if a.ImpersonatorID != nil {
+		response.Impersonator = &ImpersonatorSummary{
+			ID:        *a.ImpersonatorID,
+			Username:  a.Impersonator.Username,
+			FirstName: a.Impersonator.FirstName,
+			LastName:  a.Impersonator.LastName,
+		}
+	}
+
 	return response
 }
\ No newline at end of file
@@ -10,7 +10,7 @@ type LoginRequest struct {
 type RegisterRequest struct {
 	Email     string `json:"email" validate:"required,email"`
 	Username  string `json:"username" validate:"required,min=3,max=50"`
-	Password  string `json:"password" validate:"required,min=8,max=100"`
+	Password  string `json:"password" validate:"required"`
 	FirstName string `json:"first_name" validate:"required,min=1,max=50"`
 	LastName  string `json:"last_name" validate:"required,min=1,max=50"`
 }
@@ -20,27 +20,108 @@ type TokenResponse struct {
 	RefreshToken string    `json:"refresh_token"`
 	ExpiresAt    time.Time `json:"expires_at"`
 	User         UserResponse `json:"user"`
+	// RecoveryCodes is only set when the login also completed MFA enrollment.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// ImpersonationResponse hands support staff an access token for the
+// impersonated user. It cannot be refreshed and expires at ExpiresAt.
+type ImpersonationResponse struct {
+	AccessToken  string       `json:"access_token"`
+	ExpiresAt    time.Time    `json:"expires_at"`
+	User         UserResponse `json:"user"`
+	Impersonator UserResponse `json:"impersonator"`
+}
+
+// StepUpRequest re-authenticates the current session with either the
+// account password or, for users with MFA, a TOTP or recovery code.
+type StepUpRequest struct {
+	Password string `json:"password" validate:"required_without=Code"`
+	Code     string `json:"code" validate:"required_without=Password"`
+}
+
+// StepUpResponse carries a fresh access token whose auth_time satisfies the
+// step-up check until AuthTime plus the configured maximum age.
+type StepUpResponse struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	AuthTime    time.Time `json:"auth_time"`
 }
 
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
 type ForgotPasswordRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type MagicLinkLoginRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
 type ResetPasswordRequest struct {
 	Token           string `json:"token" validate:"required"`
-	NewPassword     string `json:"new_password" validate:"required,min=8,max=100"`
+	NewPassword     string `json:"new_password" validate:"required"`
 	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=NewPassword"`
 }
 
 type JWTClaims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	RoleID uint   `json:"role_id"`
-	Type   string `json:"type"` // "access" or "refresh"
+	ID               string    `json:"jti"`
+	SessionID        uint      `json:"sid"`
+	UserID           uint      `json:"user_id"`
+	ServiceAccountID uint      `json:"service_account_id,omitempty"`
+	InvitationID     uint      `json:"invitation_id,omitempty"`
+	ImpersonatorID   uint      `json:"impersonator_id,omitempty"` // user acting through an impersonation token
+	Email            string    `json:"email"`
+	RoleID           uint      `json:"role_id"`
+	Type             string    `json:"type"`              // "access", "refresh", "mfa_pending", "service_access" or "invitation"
+	Purpose          string    `json:"purpose,omitempty"` // what an mfa_pending token may be used for
+	Subject          string    `json:"sub"`
+	ClientID         string    `json:"client_id,omitempty"` // OAuth client a service_access token was issued to
+	Scope            string    `json:"scope,omitempty"`     // space-separated permission names the token is limited to
+	IssuedAt         time.Time `json:"iat"`
+	AuthTime         time.Time `json:"auth_time"` // last password or MFA check in the session; zero when unknown
+	ExpiresAt        time.Time `json:"exp"`
+}
+
+// LoginAttempt records every password login so failures can be throttled
+// per source IP, including attempts against emails that don't exist.
+type LoginAttempt struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	UserID    *uint     `json:"user_id" gorm:"index"`
+	Email     string    `json:"email" gorm:"type:varchar(255)"`
+	IPAddress string    `json:"ip_address" gorm:"type:varchar(45);index"`
+	Success   bool      `json:"success"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// PasswordHistory keeps previous password hashes so they can't be reused.
+type PasswordHistory struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	UserID       uint      `json:"user_id" gorm:"not null;index"`
+	PasswordHash string    `json:"-" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_histories"
 }
 
 type PasswordResetToken struct {
@@ -56,4 +137,57 @@ type PasswordResetToken struct {
 
 func (PasswordResetToken) TableName() string {
 	return "password_reset_tokens"
-}
\ No newline at end of file
+}
+
+// MagicLinkToken is a one-time passwordless login link. Like a password reset
+// token it belongs to a user and expires, but only its SHA-256 hash is stored
+// and it is bound to the IP address that requested it.
+type MagicLinkToken struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	IPAddress string     `json:"ip_address" gorm:"type:varchar(45);not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
+func (MagicLinkToken) TableName() string {
+	return "magic_link_tokens"
+}
+
+type RefreshToken struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	FamilyID      string     `json:"family_id" gorm:"type:varchar(64);not null;index"`
+	TokenHash     string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+	RevokedReason string     `json:"revoked_reason" gorm:"type:varchar(32)"` // "rotated", "logout" or "reuse"
+	ReplacedByID  *uint      `json:"replaced_by_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// RevokedToken is a deny-list entry for an access token that must stop working
+// before it expires. Entries are only meaningful until ExpiresAt, after which
+// the token would be rejected anyway and the row can be purged.
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	JTI       string    `json:"jti" gorm:"type:varchar(64);not null;uniqueIndex"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to their account at an external identity
+// provider, identified by the provider's issuer and the stable subject it
+// assigns.
+type UserIdentity struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Issuer      string     `json:"issuer" gorm:"type:varchar(255);uniqueIndex:idx_user_identities_issuer_subject;not null"`
+	Subject     string     `json:"subject" gorm:"type:varchar(255);uniqueIndex:idx_user_identities_issuer_subject;not null"`
+	Email       string     `json:"email" gorm:"type:varchar(255)"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// OIDCLoginState remembers an authorization request until the provider
+// redirects back, so the callback can check its state and nonce and prove
+// the PKCE code verifier.
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primarykey"`
+	StateHash    string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Nonce        string    `gorm:"type:varchar(64);not null"`
+	CodeVerifier string    `gorm:"type:varchar(128);not null"`
+	ExpiresAt    time.Time `gorm:"index;not null"`
+	CreatedAt    time.Time
+}
+
+type OIDCAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
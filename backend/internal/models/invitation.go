@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Invitation lets an administrator onboard someone by email with a
+// pre-selected role. The invitee proves control of the address with the
+// signed token from the invitation email; TokenID is the jti of the latest
+// token, so resending an invitation invalidates the links sent before.
+type Invitation struct {
+	ID             uint       `json:"id" gorm:"primarykey"`
+	Email          string     `json:"email" gorm:"type:varchar(255);index;not null"`
+	RoleID         uint       `json:"role_id" gorm:"not null"`
+	InvitedByID    *uint      `json:"invited_by_id" gorm:"index"`
+	Message        string     `json:"message" gorm:"type:text"`
+	TokenID        string     `json:"-" gorm:"type:varchar(64);not null"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null"`
+	LastSentAt     time.Time  `json:"last_sent_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	AcceptedUserID *uint      `json:"accepted_user_id"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Role Role `json:"role" gorm:"foreignKey:RoleID"`
+}
+
+type InvitationInput struct {
+	Email   string `json:"email" validate:"required,email"`
+	RoleID  uint   `json:"role_id" validate:"required,min=1"`
+	Message string `json:"message" validate:"max=1000"`
+	// ExpiresInHours is optional; without it INVITATION_EXPIRY applies.
+	// Either way invitations last 30 days at most.
+	ExpiresInHours int `json:"expires_in_hours" validate:"omitempty,min=1,max=720"`
+}
+
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" validate:"required"`
+	Username  string `json:"username" validate:"required,min=3,max=50"`
+	Password  string `json:"password" validate:"required"`
+	FirstName string `json:"first_name" validate:"required,min=1,max=50"`
+	LastName  string `json:"last_name" validate:"required,min=1,max=50"`
+}
+
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+// Pending reports whether the invitation can still be accepted.
+func (i *Invitation) Pending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && time.Now().Before(i.ExpiresAt)
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+type MFARecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// AuthChallenge is returned by login instead of a TokenResponse when the
+// user has to complete another step before receiving real tokens.
+type AuthChallenge struct {
+	Type      string    `json:"type"` // "mfa_required", "mfa_enrollment_required" or "password_expired"
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,min=6,max=20"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required,min=6,max=20"`
+}
+
+type MFAChallengeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type MFAChallengeCodeRequest struct {
+	Token string `json:"token" validate:"required"`
+	Code  string `json:"code" validate:"required,min=6,max=20"`
+}
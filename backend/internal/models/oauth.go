@@ -0,0 +1,143 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// OAuthClient is a registered client of the OAuth2 token and introspection
+// endpoints. Its scopes are permission names; client_credentials tokens act
+// as the linked service account, limited to the requested scopes.
+type OAuthClient struct {
+	ID               uint          `json:"id" gorm:"primarykey"`
+	ClientID         string        `json:"client_id" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ClientSecretHash string        `json:"-" gorm:"type:varchar(64);not null"`
+	Name             string        `json:"name" gorm:"type:varchar(100);not null"`
+	GrantTypes       string        `json:"-" gorm:"type:varchar(255)"`
+	ServiceAccountID *uint         `json:"service_account_id" gorm:"index"`
+	IsActive         bool          `json:"is_active" gorm:"default:true"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+	Scopes           []*Permission `json:"-" gorm:"many2many:oauth_client_scopes;"`
+
+	ServiceAccount *ServiceAccount `json:"-" gorm:"foreignKey:ServiceAccountID"`
+}
+
+type OAuthClientInput struct {
+	Name             string   `json:"name" validate:"required,min=3,max=100"`
+	GrantTypes       []string `json:"grant_types" validate:"required,min=1,dive,oneof=client_credentials refresh_token"`
+	Scopes           []string `json:"scopes"`
+	ServiceAccountID *uint    `json:"service_account_id"`
+}
+
+type OAuthClientUpdateInput struct {
+	Name             string   `json:"name" validate:"omitempty,min=3,max=100"`
+	GrantTypes       []string `json:"grant_types" validate:"omitempty,dive,oneof=client_credentials refresh_token"`
+	Scopes           []string `json:"scopes"`
+	ServiceAccountID *uint    `json:"service_account_id"`
+	IsActive         *bool    `json:"is_active"`
+}
+
+type OAuthClientResponse struct {
+	ID               uint      `json:"id"`
+	ClientID         string    `json:"client_id"`
+	Name             string    `json:"name"`
+	GrantTypes       []string  `json:"grant_types"`
+	Scopes           []string  `json:"scopes"`
+	ServiceAccountID *uint     `json:"service_account_id"`
+	IsActive         bool      `json:"is_active"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// CreatedOAuthClientResponse carries the client secret, which is only
+// returned when the client is registered.
+type CreatedOAuthClientResponse struct {
+	OAuthClientResponse
+	ClientSecret string `json:"client_secret"`
+}
+
+// OAuthTokenRequest is the RFC 6749 token request. Client credentials may be
+// sent in the body or with HTTP Basic authentication.
+type OAuthTokenRequest struct {
+	GrantType    string `json:"grant_type" form:"grant_type"`
+	Scope        string `json:"scope" form:"scope"`
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OAuthIntrospectionRequest is the RFC 7662 introspection request.
+type OAuthIntrospectionRequest struct {
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+	ClientID      string `json:"client_id" form:"client_id"`
+	ClientSecret  string `json:"client_secret" form:"client_secret"`
+}
+
+// OAuthIntrospectionResponse is the RFC 7662 introspection response. Inactive
+// tokens only report active=false.
+type OAuthIntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+}
+
+// OAuthError is the RFC 6749 error response.
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+func (c *OAuthClient) GrantTypeList() []string {
+	return strings.Fields(c.GrantTypes)
+}
+
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	for _, allowed := range c.GrantTypeList() {
+		if allowed == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *OAuthClient) ScopeNames() []string {
+	names := make([]string, 0, len(c.Scopes))
+	for _, p := range c.Scopes {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func (c *OAuthClient) ToResponse() *OAuthClientResponse {
+	return &OAuthClientResponse{
+		ID:               c.ID,
+		ClientID:         c.ClientID,
+		Name:             c.Name,
+		GrantTypes:       c.GrantTypeList(),
+		Scopes:           c.ScopeNames(),
+		ServiceAccountID: c.ServiceAccountID,
+		IsActive:         c.IsActive,
+		CreatedAt:        c.CreatedAt,
+	}
+}
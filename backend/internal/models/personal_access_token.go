@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// PersonalAccessToken is a long-lived bearer token a user creates for API
+// automation. It is limited to a subset of the owner's permissions and only
+// its SHA-256 hash is stored.
+type PersonalAccessToken struct {
+	ID          uint          `json:"id" gorm:"primarykey"`
+	UserID      uint          `json:"user_id" gorm:"not null;index"`
+	Name        string        `json:"name" gorm:"type:varchar(100);not null"`
+	TokenHash   string        `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Prefix      string        `json:"prefix" gorm:"type:varchar(16);not null"`
+	ExpiresAt   time.Time     `json:"expires_at" gorm:"not null;index"`
+	LastUsedAt  *time.Time    `json:"last_used_at"`
+	LastUsedIP  string        `json:"last_used_ip" gorm:"type:varchar(45)"`
+	RevokedAt   *time.Time    `json:"revoked_at"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	Permissions []*Permission `json:"permissions" gorm:"many2many:personal_access_token_permissions;"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+type PersonalAccessTokenInput struct {
+	Name          string   `json:"name" validate:"required,min=1,max=100"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"required,min=1,max=365"`
+	Permissions   []string `json:"permissions" validate:"required,min=1"`
+}
+
+type PersonalAccessTokenResponse struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  string     `json:"last_used_ip"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CreatedPersonalAccessTokenResponse is returned once, when the token is
+// created. The plain token cannot be retrieved afterwards.
+type CreatedPersonalAccessTokenResponse struct {
+	PersonalAccessTokenResponse
+	Token string `json:"token"`
+}
+
+func (PersonalAccessToken) TableName() string {
+	return "personal_access_tokens"
+}
+
+func (t *PersonalAccessToken) ToResponse() *PersonalAccessTokenResponse {
+	return &PersonalAccessTokenResponse{
+		ID:          t.ID,
+		Name:        t.Name,
+		Prefix:      t.Prefix,
+		Permissions: t.PermissionNames(),
+		ExpiresAt:   t.ExpiresAt,
+		LastUsedAt:  t.LastUsedAt,
+		LastUsedIP:  t.LastUsedIP,
+		RevokedAt:   t.RevokedAt,
+		CreatedAt:   t.CreatedAt,
+	}
+}
+
+func (t *PersonalAccessToken) PermissionNames() []string {
+	names := make([]string, 0, len(t.Permissions))
+	for _, p := range t.Permissions {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func (t *PersonalAccessToken) Active() bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return time.Now().Before(t.ExpiresAt)
+}
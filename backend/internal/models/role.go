@@ -7,23 +7,42 @@ import (
 )
 
 type Role struct {
-	ID           uint           `json:"id" gorm:"primarykey"`
-	Name         string         `json:"name" gorm:"type:varchar(50);uniqueIndex;not null" validate:"required,min=2,max=50"`
-	Description  string         `json:"description" gorm:"type:text"`
-	IsSystemRole bool           `json:"is_system_role" gorm:"default:false"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
-
-	Users       []User        `json:"users,omitempty" gorm:"foreignKey:RoleID"`
+	ID           uint   `json:"id" gorm:"primarykey"`
+	Name         string `json:"name" gorm:"type:varchar(50);uniqueIndex;not null" validate:"required,min=2,max=50"`
+	Description  string `json:"description" gorm:"type:text"`
+	IsSystemRole bool   `json:"is_system_role" gorm:"default:false"`
+	RequireMFA   bool   `json:"require_mfa" gorm:"default:false"`
+	// AllowMagicLink lets members sign in with a one-time link sent to their
+	// email instead of their password.
+	AllowMagicLink bool `json:"allow_magic_link" gorm:"default:false"`
+	// PasswordMaxAgeDays is how long members' passwords stay valid; 0 means
+	// they never expire.
+	PasswordMaxAgeDays int `json:"password_max_age_days" gorm:"default:0"`
+	// Level ranks the role: members can manage users whose roles all have a
+	// lower level. Custom roles start at 0, below every system role.
+	Level int `json:"level" gorm:"default:0"`
+	// ParentID is the role this one inherits permissions from, which always
+	// has a lower level.
+	ParentID  *uint          `json:"parent_id" gorm:"index"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Users       []User        `json:"users,omitempty" gorm:"many2many:user_roles;"`
 	Permissions []*Permission `json:"permissions,omitempty" gorm:"many2many:role_permissions;"`
+	// InheritedPermissions are the permissions of the ancestors, filled in
+	// by the services that need them.
+	InheritedPermissions []*Permission `json:"inherited_permissions,omitempty" gorm:"-"`
 }
 
 type RoleInput struct {
-	Name          string  `json:"name" validate:"required,min=2,max=50"`
-	Description   string  `json:"description" validate:"max=500"`
-	IsSystemRole  bool    `json:"is_system_role"`
-	PermissionIDs []uint  `json:"permission_ids"`
+	Name               string `json:"name" validate:"required,min=2,max=50"`
+	Description        string `json:"description" validate:"max=500"`
+	IsSystemRole       bool   `json:"is_system_role"`
+	RequireMFA         *bool  `json:"require_mfa"`
+	AllowMagicLink     *bool  `json:"allow_magic_link"`
+	PasswordMaxAgeDays *int   `json:"password_max_age_days" validate:"omitempty,min=0"`
+	PermissionIDs      []uint `json:"permission_ids"`
 }
 
 type RoleWithPermissions struct {
@@ -31,10 +50,27 @@ type RoleWithPermissions struct {
 	PermissionIDs []uint `json:"permission_ids"`
 }
 
+// RoleHierarchyInput moves a role in the hierarchy; a null ParentID makes it
+// a root role.
+type RoleHierarchyInput struct {
+	ParentID *uint `json:"parent_id" validate:"omitempty,min=1"`
+	Level    *int  `json:"level" validate:"required,min=0"`
+}
+
+// RoleHierarchyNode is a role with the roles that inherit from it.
+type RoleHierarchyNode struct {
+	ID           uint                 `json:"id"`
+	Name         string               `json:"name"`
+	IsSystemRole bool                 `json:"is_system_role"`
+	Level        int                  `json:"level"`
+	ParentID     *uint                `json:"parent_id"`
+	Children     []*RoleHierarchyNode `json:"children"`
+}
+
 type RolePermissionInput struct {
 	PermissionIDs []uint `json:"permission_ids" validate:"required"`
 }
 
 func (Role) TableName() string {
 	return "roles"
-}
\ No newline at end of file
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ServiceAccount is a non-human principal for automation. It has a role but
+// no password or profile, and authenticates with its client credentials or
+// with API keys.
+type ServiceAccount struct {
+	ID                   uint           `json:"id" gorm:"primarykey"`
+	Name                 string         `json:"name" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Description          string         `json:"description" gorm:"type:text"`
+	RoleID               uint           `json:"role_id" gorm:"not null"`
+	IsActive             bool           `json:"is_active" gorm:"default:true"`
+	ClientID             string         `json:"client_id" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ClientSecretHash     string         `json:"-" gorm:"type:varchar(64);not null"`
+	CredentialsRotatedAt *time.Time     `json:"-"`
+	LastUsedAt           *time.Time     `json:"last_used_at"`
+	CreatedAt            time.Time      `json:"created_at"`
+	UpdatedAt            time.Time      `json:"updated_at"`
+	DeletedAt            gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Role Role `json:"role" gorm:"foreignKey:RoleID"`
+}
+
+// ServiceAccountAPIKey is a static bearer key for a service account. Only its
+// SHA-256 hash is stored.
+type ServiceAccountAPIKey struct {
+	ID               uint       `json:"id" gorm:"primarykey"`
+	ServiceAccountID uint       `json:"service_account_id" gorm:"not null;index"`
+	Name             string     `json:"name" gorm:"type:varchar(100);not null"`
+	KeyHash          string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Prefix           string     `json:"prefix" gorm:"type:varchar(16);not null"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	LastUsedIP       string     `json:"last_used_ip" gorm:"type:varchar(45)"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+
+	ServiceAccount ServiceAccount `json:"-" gorm:"foreignKey:ServiceAccountID"`
+}
+
+type ServiceAccountInput struct {
+	Name        string `json:"name" validate:"required,min=3,max=100"`
+	Description string `json:"description" validate:"max=500"`
+	RoleID      uint   `json:"role_id" validate:"required,min=1"`
+}
+
+type ServiceAccountUpdateInput struct {
+	Name        string `json:"name" validate:"omitempty,min=3,max=100"`
+	Description string `json:"description" validate:"max=500"`
+	RoleID      uint   `json:"role_id" validate:"omitempty,min=1"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+type ServiceAccountAPIKeyInput struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+	// ExpiresInDays is optional; keys without it stay valid until revoked.
+	ExpiresInDays int `json:"expires_in_days" validate:"omitempty,min=1,max=730"`
+}
+
+type ServiceAccountTokenRequest struct {
+	ClientID     string `json:"client_id" validate:"required"`
+	ClientSecret string `json:"client_secret" validate:"required"`
+}
+
+type ServiceAccountTokenResponse struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// ServiceAccountCredentials carries a newly issued client secret, which is
+// only ever returned once.
+type ServiceAccountCredentials struct {
+	ServiceAccount *ServiceAccount `json:"service_account"`
+	ClientID       string          `json:"client_id"`
+	ClientSecret   string          `json:"client_secret"`
+}
+
+type CreatedServiceAccountAPIKey struct {
+	*ServiceAccountAPIKey
+	Key string `json:"key"`
+}
+
+type ServiceAccountSummary struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func (ServiceAccount) TableName() string {
+	return "service_accounts"
+}
+
+func (ServiceAccountAPIKey) TableName() string {
+	return "service_account_api_keys"
+}
+
+func (k *ServiceAccountAPIKey) Active() bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt)
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Session represents one signed-in device. It is created at login and follows
+// its refresh token family through every rotation. AuthenticatedAt is the
+// last time the user entered their password or MFA code in it, at login or
+// by stepping up, and becomes the auth_time of its access tokens.
+// OAuthClientID is set on sessions started for an OAuth client through the
+// refresh_token grant; only that client may refresh them.
+type Session struct {
+	ID              uint       `json:"id" gorm:"primarykey"`
+	UserID          uint       `json:"user_id" gorm:"not null;index"`
+	FamilyID        string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	RefreshTokenID  *uint      `json:"-"`
+	IPAddress       string     `json:"ip_address" gorm:"type:varchar(45)"`
+	UserAgent       string     `json:"user_agent" gorm:"type:text"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+	AuthenticatedAt time.Time  `json:"authenticated_at"`
+	ExpiresAt       time.Time  `json:"expires_at" gorm:"not null;index"`
+	RevokedAt       *time.Time `json:"revoked_at"`
+	OAuthClientID   *uint      `json:"-" gorm:"column:oauth_client_id;index"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+type SessionResponse struct {
+	ID         uint      `json:"id"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+func (s *Session) ToResponse(currentSessionID uint) *SessionResponse {
+	return &SessionResponse{
+		ID:         s.ID,
+		IPAddress:  s.IPAddress,
+		UserAgent:  s.UserAgent,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.ID == currentSessionID,
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// SettingDefaultRoleID holds the ID of the role self-registered users get when
+// no registration role rule matches their email domain.
+const SettingDefaultRoleID = "registration.default_role_id"
+
+// SystemSetting is a runtime setting administrators change through the API.
+// Settings are read on every use, so changes apply without a restart.
+type SystemSetting struct {
+	Key         string    `json:"key" gorm:"type:varchar(100);primaryKey"`
+	Value       string    `json:"value" gorm:"type:text"`
+	UpdatedByID *uint     `json:"updated_by_id"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RegistrationRoleRule gives users registering with an email address at
+// Domain the role RoleID instead of the default role.
+type RegistrationRoleRule struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Domain    string    `json:"domain" gorm:"type:varchar(255);uniqueIndex;not null"`
+	RoleID    uint      `json:"role_id" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Role Role `json:"role" gorm:"foreignKey:RoleID"`
+}
+
+type RegistrationSettings struct {
+	DefaultRole *Role                  `json:"default_role"`
+	Rules       []RegistrationRoleRule `json:"rules"`
+}
+
+type DefaultRoleInput struct {
+	RoleID uint `json:"role_id" validate:"required,min=1"`
+}
+
+type RegistrationRoleRuleInput struct {
+	Domain string `json:"domain" validate:"required,fqdn"`
+	RoleID uint   `json:"role_id" validate:"required,min=1"`
+}
+
+func (SystemSetting) TableName() string {
+	return "system_settings"
+}
+
+func (RegistrationRoleRule) TableName() string {
+	return "registration_role_rules"
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// SigningKey is an asymmetric key pair used to sign JWTs. The newest
+// unretired key signs new tokens; retired keys stay published in the JWKS
+// until VerifyUntil so tokens they signed can still be checked.
+type SigningKey struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	KID         string     `json:"kid" gorm:"column:kid;type:varchar(64);uniqueIndex;not null"`
+	Algorithm   string     `json:"algorithm" gorm:"type:varchar(16);not null"`
+	PrivateKey  string     `json:"-" gorm:"type:text;not null"`
+	PublicKey   string     `json:"public_key" gorm:"type:text;not null"`
+	RetiredAt   *time.Time `json:"retired_at"`
+	VerifyUntil *time.Time `json:"verify_until" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
@@ -6,32 +6,46 @@ import (
 	"gorm.io/gorm"
 )
 
+// User holds one or more roles. RoleID is the primary role, shown wherever a
+// single role is expected such as the role_id token claim, and is kept among
+// Roles. Permissions are the union over all of the roles.
 type User struct {
-	ID              uint           `json:"id" gorm:"primarykey"`
-	Email           string         `json:"email" gorm:"type:varchar(255);uniqueIndex;not null" validate:"required,email"`
-	Username        string         `json:"username" gorm:"type:varchar(255);uniqueIndex;not null" validate:"required,min=3,max=50"`
-	PasswordHash    string         `json:"-" gorm:"not null"`
-	FirstName       string         `json:"first_name" gorm:"not null" validate:"required,min=1,max=50"`
-	LastName        string         `json:"last_name" gorm:"not null" validate:"required,min=1,max=50"`
-	RoleID          uint           `json:"role_id" gorm:"not null"`
-	IsActive        bool           `json:"is_active" gorm:"default:true"`
-	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
-	LastLoginAt     *time.Time     `json:"last_login_at"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
-	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                uint           `json:"id" gorm:"primarykey"`
+	Email             string         `json:"email" gorm:"type:varchar(255);uniqueIndex;not null" validate:"required,email"`
+	Username          string         `json:"username" gorm:"type:varchar(255);uniqueIndex;not null" validate:"required,min=3,max=50"`
+	PasswordHash      string         `json:"-" gorm:"not null"`
+	FirstName         string         `json:"first_name" gorm:"not null" validate:"required,min=1,max=50"`
+	LastName          string         `json:"last_name" gorm:"not null" validate:"required,min=1,max=50"`
+	RoleID            uint           `json:"role_id" gorm:"not null"`
+	IsActive          bool           `json:"is_active" gorm:"default:true"`
+	EmailVerifiedAt   *time.Time     `json:"email_verified_at"`
+	LastLoginAt       *time.Time     `json:"last_login_at"`
+	TokensRevokedAt   *time.Time     `json:"-"`
+	MFASecret         string         `json:"-" gorm:"type:varchar(64)"`
+	MFAEnabledAt      *time.Time     `json:"mfa_enabled_at"`
+	MFALastStep       int64          `json:"-"`
+	FailedLogins      int            `json:"-" gorm:"default:0"`
+	LockoutCount      int            `json:"-" gorm:"default:0"`
+	LockedUntil       *time.Time     `json:"locked_until"`
+	PasswordChangedAt *time.Time     `json:"password_changed_at"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
 
 	Role         Role          `json:"role" gorm:"foreignKey:RoleID"`
+	Roles        []Role        `json:"roles,omitempty" gorm:"many2many:user_roles;"`
 	ActivityLogs []ActivityLog `json:"activity_logs,omitempty" gorm:"foreignKey:UserID"`
 }
 
 type UserInput struct {
 	Email     string `json:"email" validate:"required,email"`
 	Username  string `json:"username" validate:"required,min=3,max=50"`
-	Password  string `json:"password" validate:"required,min=8,max=100"`
+	Password  string `json:"password" validate:"required"`
 	FirstName string `json:"first_name" validate:"required,min=1,max=50"`
 	LastName  string `json:"last_name" validate:"required,min=1,max=50"`
 	RoleID    uint   `json:"role_id" validate:"required,min=1"`
+	// RoleIDs are further roles on top of the primary RoleID.
+	RoleIDs []uint `json:"role_ids" validate:"omitempty,dive,min=1"`
 }
 
 type UserUpdateInput struct {
@@ -40,29 +54,37 @@ type UserUpdateInput struct {
 	FirstName string `json:"first_name" validate:"omitempty,min=1,max=50"`
 	LastName  string `json:"last_name" validate:"omitempty,min=1,max=50"`
 	RoleID    uint   `json:"role_id" validate:"omitempty,min=1"`
-	IsActive  *bool  `json:"is_active"`
+	// RoleIDs, when given, replaces all of the user's roles; the primary role
+	// is kept among them.
+	RoleIDs  []uint `json:"role_ids" validate:"omitempty,dive,min=1"`
+	IsActive *bool  `json:"is_active"`
 }
 
 type PasswordUpdateInput struct {
 	CurrentPassword string `json:"current_password" validate:"required,min=8"`
-	NewPassword     string `json:"new_password" validate:"required,min=8,max=100"`
+	NewPassword     string `json:"new_password" validate:"required"`
 	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=NewPassword"`
 }
 
 type UserResponse struct {
-	ID              uint       `json:"id"`
-	Email           string     `json:"email"`
-	Username        string     `json:"username"`
-	FirstName       string     `json:"first_name"`
-	LastName        string     `json:"last_name"`
-	RoleID          uint       `json:"role_id"`
-	IsActive        bool       `json:"is_active"`
-	EmailVerifiedAt *time.Time `json:"email_verified_at"`
-	LastLoginAt     *time.Time `json:"last_login_at"`
-	CreatedAt       time.Time  `json:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at"`
-	Role            Role       `json:"role"`
-	Permissions     []string   `json:"permissions"`
+	ID                uint       `json:"id"`
+	Email             string     `json:"email"`
+	Username          string     `json:"username"`
+	FirstName         string     `json:"first_name"`
+	LastName          string     `json:"last_name"`
+	RoleID            uint       `json:"role_id"`
+	IsActive          bool       `json:"is_active"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at"`
+	LastLoginAt       *time.Time `json:"last_login_at"`
+	MFAEnabled        bool       `json:"mfa_enabled"`
+	LockedUntil       *time.Time `json:"locked_until"`
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+	PasswordExpiresAt *time.Time `json:"password_expires_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	Role              Role       `json:"role"`
+	Roles             []Role     `json:"roles"`
+	Permissions       []string   `json:"permissions"`
 }
 
 type UserListResponse struct {
@@ -77,28 +99,116 @@ func (User) TableName() string {
 	return "users"
 }
 
+// AllRoles returns the primary role followed by the user's other roles. Role
+// and Roles must be loaded; a primary role missing from Roles still counts.
+func (u *User) AllRoles() []Role {
+	roles := []Role{}
+	if u.Role.ID != 0 {
+		roles = append(roles, u.Role)
+	}
+	for _, role := range u.Roles {
+		if role.ID != u.Role.ID {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// HasRole reports whether any of the user's roles has the name.
+func (u *User) HasRole(name string) bool {
+	for _, role := range u.AllRoles() {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiresMFA reports whether any of the user's roles requires MFA.
+func (u *User) RequiresMFA() bool {
+	for _, role := range u.AllRoles() {
+		if role.RequireMFA {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsMagicLink reports whether every one of the user's roles allows
+// passwordless login, so a link never grants a role that doesn't.
+func (u *User) AllowsMagicLink() bool {
+	roles := u.AllRoles()
+	for _, role := range roles {
+		if !role.AllowMagicLink {
+			return false
+		}
+	}
+	return len(roles) > 0
+}
+
 func (u *User) ToResponse() *UserResponse {
 	permissions := []string{}
-	// Ensure Role and Permissions are preloaded
-	if u.Role.Permissions != nil {
-		for _, p := range u.Role.Permissions {
-			permissions = append(permissions, p.Name)
+	seen := map[string]bool{}
+	// Permissions are only listed when the roles' permissions are preloaded,
+	// and inherited ones once they are filled in.
+	for _, role := range u.AllRoles() {
+		for _, set := range [][]*Permission{role.Permissions, role.InheritedPermissions} {
+			for _, p := range set {
+				if !seen[p.Name] {
+					seen[p.Name] = true
+					permissions = append(permissions, p.Name)
+				}
+			}
 		}
 	}
 
 	return &UserResponse{
-		ID:              u.ID,
-		Email:           u.Email,
-		Username:        u.Username,
-		FirstName:       u.FirstName,
-		LastName:        u.LastName,
-		RoleID:          u.RoleID,
-		IsActive:        u.IsActive,
-		EmailVerifiedAt: u.EmailVerifiedAt,
-		LastLoginAt:     u.LastLoginAt,
-		CreatedAt:       u.CreatedAt,
-		UpdatedAt:       u.UpdatedAt,
-		Role:            u.Role,
-		Permissions:     permissions,
+		ID:                u.ID,
+		Email:             u.Email,
+		Username:          u.Username,
+		FirstName:         u.FirstName,
+		LastName:          u.LastName,
+		RoleID:            u.RoleID,
+		IsActive:          u.IsActive,
+		EmailVerifiedAt:   u.EmailVerifiedAt,
+		LastLoginAt:       u.LastLoginAt,
+		MFAEnabled:        u.MFAEnabledAt != nil,
+		LockedUntil:       u.LockedUntil,
+		PasswordChangedAt: u.PasswordChangedAt,
+		PasswordExpiresAt: u.PasswordExpiresAt(),
+		CreatedAt:         u.CreatedAt,
+		UpdatedAt:         u.UpdatedAt,
+		Role:              u.Role,
+		Roles:             u.AllRoles(),
+		Permissions:       permissions,
+	}
+}
+
+// PasswordExpiresAt returns when the password stops being accepted under the
+// strictest maximum age of the user's roles, or nil if it never expires. The
+// roles must be loaded. Users whose password predates change tracking are
+// measured from account creation.
+func (u *User) PasswordExpiresAt() *time.Time {
+	maxAgeDays := 0
+	for _, role := range u.AllRoles() {
+		if role.PasswordMaxAgeDays > 0 && (maxAgeDays == 0 || role.PasswordMaxAgeDays < maxAgeDays) {
+			maxAgeDays = role.PasswordMaxAgeDays
+		}
 	}
-}
\ No newline at end of file
+	if maxAgeDays == 0 {
+		return nil
+	}
+
+	changedAt := u.CreatedAt
+	if u.PasswordChangedAt != nil {
+		changedAt = *u.PasswordChangedAt
+	}
+
+	expiresAt := changedAt.AddDate(0, 0, maxAgeDays)
+	return &expiresAt
+}
+
+func (u *User) PasswordExpired() bool {
+	expiresAt := u.PasswordExpiresAt()
+	return expiresAt != nil && !time.Now().Before(*expiresAt)
+}
@@ -0,0 +1,357 @@
+// Package oidc is a relying-party client for the OpenID Connect
+// authorization code flow with PKCE: provider discovery, the authorization
+// URL, the code exchange and ID token verification against the provider's
+// JWKS.
+package oidc
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// clockSkew is tolerated when checking ID token lifetimes.
+	clockSkew = time.Minute
+	// jwksRefreshInterval limits how often an unknown kid triggers a JWKS
+	// refetch.
+	jwksRefreshInterval = time.Minute
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	ErrNonceMismatch  = errors.New("ID token nonce does not match")
+)
+
+// Discovery is the subset of the provider metadata document the client uses.
+type Discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// TokenResponse is the provider's answer to the authorization code exchange.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// IDToken holds the verified claims of an ID token. Claims keeps every claim
+// so role rules can match provider-specific ones such as groups.
+type IDToken struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	GivenName         string
+	FamilyName        string
+	Name              string
+	Claims            jwt.MapClaims
+}
+
+// Provider talks to one OpenID Connect provider. Discovery and keys are
+// fetched lazily and cached, so the application starts even while the
+// provider is unreachable.
+type Provider struct {
+	config     config.OIDCConfig
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	discovery     *Discovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+func NewProvider(cfg config.OIDCConfig, httpClient *http.Client) *Provider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{config: cfg, httpClient: httpClient}
+}
+
+// Issuer is the configured issuer URL, which identifies the provider.
+func (p *Provider) Issuer() string {
+	return p.config.IssuerURL
+}
+
+// Discover fetches and caches the provider metadata. The advertised issuer
+// must match the configured one exactly.
+func (p *Provider) Discover() (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery Discovery
+	wellKnown := strings.TrimSuffix(p.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(wellKnown, &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+
+	if discovery.Issuer != p.config.IssuerURL {
+		return nil, fmt.Errorf("oidc discovery returned issuer %q, expected %q", discovery.Issuer, p.config.IssuerURL)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery document is missing required endpoints")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// AuthCodeURL builds the authorization request URL for the code flow with an
+// S256 PKCE challenge derived from codeVerifier.
+func (p *Provider) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.scopes(), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+// Exchange redeems an authorization code, authenticating with the client
+// secret when one is configured and proving possession of codeVerifier.
+func (p *Provider) Exchange(code, codeVerifier string) (*TokenResponse, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr models.OAuthError
+		json.NewDecoder(resp.Body).Decode(&oauthErr)
+		if oauthErr.Error != "" {
+			return nil, fmt.Errorf("oidc token request rejected: %s", oauthErr.Error)
+		}
+		return nil, fmt.Errorf("oidc token request returned status %d", resp.StatusCode)
+	}
+
+	var token TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("oidc token response has no ID token")
+	}
+
+	return &token, nil
+}
+
+// VerifyIDToken checks the ID token's signature against the provider's JWKS,
+// its issuer, audience, lifetime and nonce.
+func (p *Provider) VerifyIDToken(rawIDToken, nonce string) (*IDToken, error) {
+	discovery, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, p.keyFunc,
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	// With several audiences the authorized party must be this client.
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.config.ClientID {
+			return nil, fmt.Errorf("%w: azp does not match client", ErrInvalidIDToken)
+		}
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	idToken := &IDToken{Claims: claims}
+	idToken.Subject, _ = claims.GetSubject()
+	if idToken.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	idToken.Email, _ = claims["email"].(string)
+	idToken.PreferredUsername, _ = claims["preferred_username"].(string)
+	idToken.GivenName, _ = claims["given_name"].(string)
+	idToken.FamilyName, _ = claims["family_name"].(string)
+	idToken.Name, _ = claims["name"].(string)
+
+	// Some providers send email_verified as a string.
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		idToken.EmailVerified = verified
+	case string:
+		idToken.EmailVerified = verified == "true"
+	}
+
+	return idToken, nil
+}
+
+func (p *Provider) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := p.findKey(kid); key != nil {
+		return key, nil
+	}
+
+	// Unknown keys usually mean the provider rotated; refetch, but not on
+	// every request carrying a bogus kid.
+	if time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := p.fetchKeys(); err != nil {
+		return nil, err
+	}
+
+	if key := p.findKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// findKey returns the key with kid, or the only key when the token names
+// none. Callers hold p.mu.
+func (p *Provider) findKey(kid string) crypto.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+// fetchKeys reloads the JWKS. Callers hold p.mu.
+func (p *Provider) fetchKeys() error {
+	p.keysFetchedAt = time.Now()
+
+	var jwks models.JWKS
+	if err := p.getJSON(p.discovery.JWKSURI, &jwks); err != nil {
+		return fmt.Errorf("oidc jwks request failed: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := utils.ParseJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+
+	return nil
+}
+
+func (p *Provider) getJSON(target string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (p *Provider) scopes() []string {
+	scopes := p.config.Scopes
+	for _, scope := range scopes {
+		if scope == "openid" {
+			return scopes
+		}
+	}
+	return append([]string{"openid"}, scopes...)
+}
+
+// NewCodeVerifier returns a random PKCE code verifier (RFC 7636).
+func NewCodeVerifier() (string, error) {
+	return utils.GenerateRandomToken(32)
+}
+
+// CodeChallenge derives the S256 PKCE challenge for a code verifier.
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,116 @@
+package oidc_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/oidc"
+	"rbac-system/backend/internal/oidc/oidctest"
+)
+
+func setupProvider(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	mock, err := oidctest.NewProvider("rbac-app", "app-secret")
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+
+	provider := oidc.NewProvider(config.OIDCConfig{
+		IssuerURL:    mock.Issuer(),
+		ClientID:     "rbac-app",
+		ClientSecret: "app-secret",
+		RedirectURL:  "http://localhost:3000/auth/oidc/callback",
+		Scopes:       []string{"email", "profile"},
+	}, nil)
+
+	return mock, provider
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	mock, provider := setupProvider(t)
+	mock.SetClaims(map[string]interface{}{"sub": "alice-1", "email": "alice@corp.example", "email_verified": true})
+
+	verifier, err := oidc.NewCodeVerifier()
+	require.NoError(t, err)
+
+	authURL, err := provider.AuthCodeURL("state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+	assert.Equal(t, oidc.CodeChallenge(verifier), parsed.Query().Get("code_challenge"))
+
+	code, state, err := mock.Authorize(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "state-1", state)
+
+	_, err = provider.Exchange(code, "wrong-verifier")
+	assert.Error(t, err, "the token endpoint enforces PKCE")
+
+	code, _, err = mock.Authorize(authURL)
+	require.NoError(t, err)
+	token, err := provider.Exchange(code, verifier)
+	require.NoError(t, err)
+
+	idToken, err := provider.VerifyIDToken(token.IDToken, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice-1", idToken.Subject)
+	assert.Equal(t, "alice@corp.example", idToken.Email)
+	assert.True(t, idToken.EmailVerified)
+
+	_, err = provider.VerifyIDToken(token.IDToken, "other-nonce")
+	assert.ErrorIs(t, err, oidc.ErrNonceMismatch)
+}
+
+func TestProvider_VerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	mock, provider := setupProvider(t)
+	now := time.Now()
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   mock.Issuer(),
+			"aud":   "rbac-app",
+			"sub":   "alice-1",
+			"iat":   now.Unix(),
+			"exp":   now.Add(5 * time.Minute).Unix(),
+			"nonce": "nonce-1",
+		}
+	}
+
+	tests := map[string]func(jwt.MapClaims){
+		"wrong issuer":    func(c jwt.MapClaims) { c["iss"] = "https://evil.example" },
+		"wrong audience":  func(c jwt.MapClaims) { c["aud"] = "other-app" },
+		"expired":         func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() },
+		"missing subject": func(c jwt.MapClaims) { delete(c, "sub") },
+		"foreign authorized party": func(c jwt.MapClaims) {
+			c["aud"] = []string{"rbac-app", "other-app"}
+			c["azp"] = "other-app"
+		},
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims := valid()
+			mutate(claims)
+			raw, err := mock.SignIDToken(claims)
+			require.NoError(t, err)
+
+			_, err = provider.VerifyIDToken(raw, "nonce-1")
+			assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+		})
+	}
+
+	raw, err := mock.SignIDToken(valid())
+	require.NoError(t, err)
+	_, err = provider.VerifyIDToken(raw, "nonce-1")
+	assert.NoError(t, err)
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodHS256, valid()).SignedString([]byte("app-secret"))
+	require.NoError(t, err)
+	_, err = provider.VerifyIDToken(unsigned, "nonce-1")
+	assert.ErrorIs(t, err, oidc.ErrInvalidIDToken, "symmetric algorithms are not accepted")
+}
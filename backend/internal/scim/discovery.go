@@ -0,0 +1,209 @@
+package scim
+
+// DocumentMeta is the meta attribute of discovery documents, which have no
+// timestamps.
+type DocumentMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+type Supported struct {
+	Supported bool `json:"supported"`
+}
+
+type BulkSupport struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type FilterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type AuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary"`
+}
+
+type ServiceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 Supported              `json:"patch"`
+	Bulk                  BulkSupport            `json:"bulk"`
+	Filter                FilterSupport          `json:"filter"`
+	ChangePassword        Supported              `json:"changePassword"`
+	Sort                  Supported              `json:"sort"`
+	ETag                  Supported              `json:"etag"`
+	AuthenticationSchemes []AuthenticationScheme `json:"authenticationSchemes"`
+	Meta                  DocumentMeta           `json:"meta"`
+}
+
+// NewServiceProviderConfig describes what this server supports; baseURL is
+// the /scim/v2 URL.
+func NewServiceProviderConfig(baseURL string) *ServiceProviderConfig {
+	return &ServiceProviderConfig{
+		Schemas:        []string{ServiceProviderConfigSchema},
+		Patch:          Supported{Supported: true},
+		Bulk:           BulkSupport{Supported: false},
+		Filter:         FilterSupport{Supported: true, MaxResults: MaxResults},
+		ChangePassword: Supported{Supported: true},
+		Sort:           Supported{Supported: false},
+		ETag:           Supported{Supported: false},
+		AuthenticationSchemes: []AuthenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "Bearer Token",
+			Description: "The SCIM token configured with SCIM_TOKEN, sent as an Authorization: Bearer header",
+			Primary:     true,
+		}},
+		Meta: DocumentMeta{ResourceType: "ServiceProviderConfig", Location: baseURL + "/ServiceProviderConfig"},
+	}
+}
+
+type ResourceType struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Endpoint    string       `json:"endpoint"`
+	Description string       `json:"description"`
+	Schema      string       `json:"schema"`
+	Meta        DocumentMeta `json:"meta"`
+}
+
+func NewResourceTypes(baseURL string) []*ResourceType {
+	return []*ResourceType{
+		{
+			Schemas:     []string{ResourceTypeSchema},
+			ID:          "User",
+			Name:        "User",
+			Endpoint:    "/Users",
+			Description: "User accounts",
+			Schema:      UserSchema,
+			Meta:        DocumentMeta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/User"},
+		},
+		{
+			Schemas:     []string{ResourceTypeSchema},
+			ID:          "Group",
+			Name:        "Group",
+			Endpoint:    "/Groups",
+			Description: "Roles, with their members",
+			Schema:      GroupSchema,
+			Meta:        DocumentMeta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+type SchemaAttribute struct {
+	Name          string            `json:"name"`
+	Type          string            `json:"type"`
+	MultiValued   bool              `json:"multiValued"`
+	Description   string            `json:"description,omitempty"`
+	Required      bool              `json:"required"`
+	CaseExact     bool              `json:"caseExact"`
+	Mutability    string            `json:"mutability"`
+	Returned      string            `json:"returned"`
+	Uniqueness    string            `json:"uniqueness"`
+	SubAttributes []SchemaAttribute `json:"subAttributes,omitempty"`
+}
+
+type Schema struct {
+	Schemas     []string          `json:"schemas"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Attributes  []SchemaAttribute `json:"attributes"`
+	Meta        DocumentMeta      `json:"meta"`
+}
+
+func attribute(name, attributeType, description string) SchemaAttribute {
+	return SchemaAttribute{
+		Name:        name,
+		Type:        attributeType,
+		Description: description,
+		Mutability:  "readWrite",
+		Returned:    "default",
+		Uniqueness:  "none",
+	}
+}
+
+// NewSchemas returns the User and Group schemas, limited to the attributes
+// this server stores.
+func NewSchemas(baseURL string) []*Schema {
+	userName := attribute("userName", "string", "Unique username")
+	userName.Required = true
+	userName.Uniqueness = "server"
+
+	name := attribute("name", "complex", "The user's name")
+	name.SubAttributes = []SchemaAttribute{
+		attribute("formatted", "string", "Full name"),
+		attribute("givenName", "string", "First name"),
+		attribute("familyName", "string", "Last name"),
+	}
+	name.SubAttributes[0].Mutability = "readOnly"
+
+	emails := attribute("emails", "complex", "Email address; the primary one is stored and must be unique")
+	emails.MultiValued = true
+	emails.SubAttributes = []SchemaAttribute{
+		attribute("value", "string", "Email address"),
+		attribute("type", "string", "Label such as work"),
+		attribute("primary", "boolean", "Whether this is the address stored"),
+	}
+
+	displayName := attribute("displayName", "string", "Full name")
+	displayName.Mutability = "readOnly"
+
+	password := attribute("password", "string", "Sets the local password; checked against the password policy")
+	password.Mutability = "writeOnly"
+	password.Returned = "never"
+
+	userGroups := attribute("groups", "complex", "The user's role")
+	userGroups.MultiValued = true
+	userGroups.Mutability = "readOnly"
+	userGroups.SubAttributes = []SchemaAttribute{
+		attribute("value", "string", "Group id"),
+		attribute("display", "string", "Role name"),
+		attribute("$ref", "reference", "Group URI"),
+	}
+
+	members := attribute("members", "complex", "Users holding the role")
+	members.MultiValued = true
+	members.SubAttributes = []SchemaAttribute{
+		attribute("value", "string", "User id"),
+		attribute("display", "string", "Username"),
+		attribute("$ref", "reference", "User URI"),
+	}
+
+	groupName := attribute("displayName", "string", "Role name")
+	groupName.Required = true
+	groupName.Uniqueness = "server"
+
+	return []*Schema{
+		{
+			Schemas:     []string{SchemaSchema},
+			ID:          UserSchema,
+			Name:        "User",
+			Description: "User account",
+			Attributes: []SchemaAttribute{
+				userName,
+				name,
+				displayName,
+				emails,
+				attribute("active", "boolean", "Whether the user can sign in"),
+				attribute("externalId", "string", "Identifier assigned by the provisioning client"),
+				password,
+				userGroups,
+			},
+			Meta: DocumentMeta{ResourceType: "Schema", Location: baseURL + "/Schemas/" + UserSchema},
+		},
+		{
+			Schemas:     []string{SchemaSchema},
+			ID:          GroupSchema,
+			Name:        "Group",
+			Description: "Role",
+			Attributes:  []SchemaAttribute{groupName, members},
+			Meta:        DocumentMeta{ResourceType: "Schema", Location: baseURL + "/Schemas/" + GroupSchema},
+		},
+	}
+}
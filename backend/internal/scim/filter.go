@@ -0,0 +1,480 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter is a parsed filter expression (RFC 7644 section 3.4.2.2). Attribute
+// names are lower-cased and stripped of their schema URN, so "userName" and
+// "urn:ietf:params:scim:schemas:core:2.0:User:username" both read "username".
+type Filter interface {
+	filter()
+}
+
+// AttributeExpression compares an attribute with a value, or tests its
+// presence when Operator is "pr".
+type AttributeExpression struct {
+	Attribute string
+	Operator  string
+	// Value is a string, bool, float64 or nil.
+	Value interface{}
+}
+
+type LogicalExpression struct {
+	// Operator is "and" or "or".
+	Operator    string
+	Left, Right Filter
+}
+
+type NotExpression struct {
+	Filter Filter
+}
+
+// ValuePathExpression filters the values of a multi-valued attribute, as in
+// emails[type eq "work"].
+type ValuePathExpression struct {
+	Attribute string
+	Filter    Filter
+}
+
+func (*AttributeExpression) filter() {}
+func (*LogicalExpression) filter()   {}
+func (*NotExpression) filter()       {}
+func (*ValuePathExpression) filter() {}
+
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// ParseFilter parses a filter query parameter.
+func ParseFilter(input string) (Filter, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, BadRequest(ErrorTypeInvalidFilter, "unexpected %q in filter", p.peek().text)
+	}
+	return filter, nil
+}
+
+// Path is a parsed PATCH path (RFC 7644 section 3.5.2) such as "active",
+// "name.givenName" or `members[value eq "2"]`. Names are lower-cased.
+type Path struct {
+	Attribute    string
+	Filter       Filter
+	SubAttribute string
+}
+
+func ParsePath(input string) (*Path, error) {
+	input = strings.TrimSpace(input)
+	attribute, rest, hasFilter := strings.Cut(input, "[")
+
+	path := &Path{}
+	if hasFilter {
+		end := strings.LastIndex(rest, "]")
+		if end < 0 {
+			return nil, BadRequest(ErrorTypeInvalidPath, "unterminated value filter in path %q", input)
+		}
+		filter, err := ParseFilter(rest[:end])
+		if err != nil {
+			return nil, BadRequest(ErrorTypeInvalidPath, "invalid value filter in path %q: %v", input, err)
+		}
+		path.Filter = filter
+
+		sub := rest[end+1:]
+		if sub != "" {
+			if !strings.HasPrefix(sub, ".") || len(sub) == 1 {
+				return nil, BadRequest(ErrorTypeInvalidPath, "invalid path %q", input)
+			}
+			path.SubAttribute = strings.ToLower(sub[1:])
+		}
+	}
+
+	attribute = normalizeAttribute(attribute)
+	if !hasFilter {
+		attribute, path.SubAttribute, _ = strings.Cut(attribute, ".")
+	}
+	if attribute == "" || strings.ContainsAny(attribute, " \"()") {
+		return nil, BadRequest(ErrorTypeInvalidPath, "invalid path %q", input)
+	}
+	path.Attribute = attribute
+
+	return path, nil
+}
+
+// normalizeAttribute lower-cases an attribute path and drops a leading
+// schema URN.
+func normalizeAttribute(attribute string) string {
+	attribute = strings.ToLower(strings.TrimSpace(attribute))
+	if strings.HasPrefix(attribute, "urn:") {
+		attribute = attribute[strings.LastIndex(attribute, ":")+1:]
+	}
+	return attribute
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		switch c := input[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(input) && input[end] != '"' {
+				if input[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(input) {
+				return nil, BadRequest(ErrorTypeInvalidFilter, "unterminated string in filter")
+			}
+			var text string
+			if err := json.Unmarshal([]byte(input[i:end+1]), &text); err != nil {
+				return nil, BadRequest(ErrorTypeInvalidFilter, "invalid string %s in filter", input[i:end+1])
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(input) && !strings.ContainsRune(" \t\n\r()[]\"", rune(input[end])) {
+				end++
+			}
+			tokens = append(tokens, token{text: input[i:end]})
+			i = end
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, BadRequest(ErrorTypeInvalidFilter, "empty filter")
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	return !t.quoted && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) expect(text string) error {
+	if t := p.next(); t.quoted || t.text != text {
+		return BadRequest(ErrorTypeInvalidFilter, "expected %q in filter", text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpression{Operator: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Filter, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpression{Operator: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseFactor() (Filter, error) {
+	if p.keyword("not") {
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &NotExpression{Filter: inner}, nil
+	}
+
+	if t := p.peek(); !t.quoted && t.text == "(" {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	attribute := p.next()
+	if attribute.quoted || attribute.text == "" || strings.ContainsAny(attribute.text, "()[]") {
+		return nil, BadRequest(ErrorTypeInvalidFilter, "expected an attribute in filter")
+	}
+	name := normalizeAttribute(attribute.text)
+
+	if t := p.peek(); !t.quoted && t.text == "[" {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return &ValuePathExpression{Attribute: name, Filter: inner}, nil
+	}
+
+	operator := p.next()
+	op := strings.ToLower(operator.text)
+	if operator.quoted {
+		return nil, BadRequest(ErrorTypeInvalidFilter, "expected an operator after %q", attribute.text)
+	}
+	if op == "pr" {
+		return &AttributeExpression{Attribute: name, Operator: op}, nil
+	}
+	if !comparisonOperators[op] {
+		return nil, BadRequest(ErrorTypeInvalidFilter, "unsupported filter operator %q", operator.text)
+	}
+
+	if p.done() {
+		return nil, BadRequest(ErrorTypeInvalidFilter, "missing value after %q", operator.text)
+	}
+	value, err := parseValue(p.next())
+	if err != nil {
+		return nil, err
+	}
+	return &AttributeExpression{Attribute: name, Operator: op, Value: value}, nil
+}
+
+func parseValue(t token) (interface{}, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	number, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, BadRequest(ErrorTypeInvalidFilter, "invalid value %q in filter", t.text)
+	}
+	return number, nil
+}
+
+// AttributeType is the type of a filterable column.
+type AttributeType int
+
+const (
+	StringAttribute AttributeType = iota
+	BooleanAttribute
+	IntegerAttribute
+	DateTimeAttribute
+)
+
+// Column maps a filterable attribute onto a SQL expression.
+type Column struct {
+	Expression string
+	Type       AttributeType
+	// CaseExact compares strings as is; otherwise both sides are lower-cased.
+	CaseExact bool
+}
+
+// ToSQL translates a filter into a WHERE clause over columns, which is keyed
+// by lower-cased attribute path such as "name.givenname". Filters on other
+// attributes are rejected.
+func ToSQL(filter Filter, columns map[string]Column) (string, []interface{}, error) {
+	return toSQL(filter, columns, "")
+}
+
+func toSQL(filter Filter, columns map[string]Column, prefix string) (string, []interface{}, error) {
+	switch f := filter.(type) {
+	case *LogicalExpression:
+		left, leftArgs, err := toSQL(f.Left, columns, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := toSQL(f.Right, columns, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(f.Operator), right), append(leftArgs, rightArgs...), nil
+	case *NotExpression:
+		inner, args, err := toSQL(f.Filter, columns, prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + inner + ")", args, nil
+	case *ValuePathExpression:
+		if prefix != "" {
+			return "", nil, BadRequest(ErrorTypeInvalidFilter, "nested value filters are not supported")
+		}
+		return toSQL(f.Filter, columns, f.Attribute+".")
+	case *AttributeExpression:
+		return comparisonSQL(f, columns, prefix)
+	}
+	return "", nil, BadRequest(ErrorTypeInvalidFilter, "unsupported filter")
+}
+
+func comparisonSQL(f *AttributeExpression, columns map[string]Column, prefix string) (string, []interface{}, error) {
+	attribute := prefix + f.Attribute
+	column, ok := columns[attribute]
+	if !ok {
+		return "", nil, BadRequest(ErrorTypeInvalidFilter, "filtering on %q is not supported", attribute)
+	}
+	expression := column.Expression
+
+	if f.Operator == "pr" {
+		if column.Type == StringAttribute {
+			return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", expression, expression), nil, nil
+		}
+		return expression + " IS NOT NULL", nil, nil
+	}
+
+	if f.Value == nil {
+		switch f.Operator {
+		case "eq":
+			return expression + " IS NULL", nil, nil
+		case "ne":
+			return expression + " IS NOT NULL", nil, nil
+		}
+		return "", nil, BadRequest(ErrorTypeInvalidFilter, "null can only be compared with eq or ne")
+	}
+
+	sqlOperator := map[string]string{"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<="}[f.Operator]
+
+	switch column.Type {
+	case BooleanAttribute:
+		value, ok := f.Value.(bool)
+		if !ok || (f.Operator != "eq" && f.Operator != "ne") {
+			return "", nil, BadRequest(ErrorTypeInvalidFilter, "%q can only be compared with true or false using eq or ne", attribute)
+		}
+		return fmt.Sprintf("%s %s ?", expression, sqlOperator), []interface{}{value}, nil
+
+	case IntegerAttribute:
+		if sqlOperator == "" {
+			return "", nil, BadRequest(ErrorTypeInvalidFilter, "operator %q does not apply to %q", f.Operator, attribute)
+		}
+		value, ok := integerValue(f.Value)
+		if !ok {
+			// Identifiers that cannot exist match nothing rather than fail.
+			if f.Operator == "eq" {
+				return "1 = 0", nil, nil
+			}
+			if f.Operator == "ne" {
+				return "1 = 1", nil, nil
+			}
+			return "", nil, BadRequest(ErrorTypeInvalidFilter, "%q must be compared with an integer", attribute)
+		}
+		return fmt.Sprintf("%s %s ?", expression, sqlOperator), []interface{}{value}, nil
+
+	case DateTimeAttribute:
+		text, _ := f.Value.(string)
+		value, err := time.Parse(time.RFC3339, text)
+		if err != nil || sqlOperator == "" {
+			return "", nil, BadRequest(ErrorTypeInvalidFilter, "%q must be compared with an RFC 3339 date-time using eq, ne, gt, ge, lt or le", attribute)
+		}
+		return fmt.Sprintf("%s %s ?", expression, sqlOperator), []interface{}{value}, nil
+	}
+
+	value, ok := f.Value.(string)
+	if !ok {
+		return "", nil, BadRequest(ErrorTypeInvalidFilter, "%q must be compared with a string", attribute)
+	}
+	if !column.CaseExact {
+		expression = "LOWER(" + expression + ")"
+		value = strings.ToLower(value)
+	}
+
+	switch f.Operator {
+	case "co", "sw", "ew":
+		pattern := escapeLike(value)
+		switch f.Operator {
+		case "co":
+			pattern = "%" + pattern + "%"
+		case "sw":
+			pattern = pattern + "%"
+		case "ew":
+			pattern = "%" + pattern
+		}
+		return expression + " LIKE ? ESCAPE '!'", []interface{}{pattern}, nil
+	}
+	return fmt.Sprintf("%s %s ?", expression, sqlOperator), []interface{}{value}, nil
+}
+
+func integerValue(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), v == float64(int64(v))
+	case string:
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+// escapeLike escapes LIKE wildcards with "!", which works as the ESCAPE
+// character on both MySQL and SQLite.
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
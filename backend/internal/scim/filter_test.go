@@ -0,0 +1,102 @@
+package scim_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/scim"
+)
+
+var testColumns = map[string]scim.Column{
+	"id":           {Expression: "id", Type: scim.IntegerAttribute},
+	"username":     {Expression: "username"},
+	"emails.value": {Expression: "email"},
+	"active":       {Expression: "is_active", Type: scim.BooleanAttribute},
+	"externalid":   {Expression: "external_id", CaseExact: true},
+	"meta.created": {Expression: "created_at", Type: scim.DateTimeAttribute},
+}
+
+func TestToSQL(t *testing.T) {
+	tests := []struct {
+		filter string
+		where  string
+		args   []interface{}
+	}{
+		{`userName eq "Alice"`, "LOWER(username) = ?", []interface{}{"alice"}},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "bob"`, "LOWER(username) = ?", []interface{}{"bob"}},
+		{`externalId eq "Ab-1"`, "external_id = ?", []interface{}{"Ab-1"}},
+		{`userName sw "a_b"`, "LOWER(username) LIKE ? ESCAPE '!'", []interface{}{"a!_b%"}},
+		{`emails[value co "@Example.com"]`, "LOWER(email) LIKE ? ESCAPE '!'", []interface{}{"%@example.com%"}},
+		{`active eq true and not (userName ew "x")`, "(is_active = ? AND NOT (LOWER(username) LIKE ? ESCAPE '!'))", []interface{}{true, "%x"}},
+		{`userName eq "a" or userName eq "b" and active eq false`, "(LOWER(username) = ? OR (LOWER(username) = ? AND is_active = ?))", []interface{}{"a", "b", false}},
+		{`externalId pr`, "(external_id IS NOT NULL AND external_id <> '')", nil},
+		{`id eq "42"`, "id = ?", []interface{}{int64(42)}},
+		{`id eq "not-a-number"`, "1 = 0", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, err := scim.ParseFilter(tt.filter)
+			require.NoError(t, err)
+			where, args, err := scim.ToSQL(filter, testColumns)
+			require.NoError(t, err)
+			assert.Equal(t, tt.where, where)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func TestToSQL_Invalid(t *testing.T) {
+	filters := []string{
+		``,
+		`userName`,
+		`userName zz "a"`,
+		`userName eq "unterminated`,
+		`(userName eq "a"`,
+		`title eq "Engineer"`,
+		`active eq "yes"`,
+		`active gt true`,
+		`meta.created gt "yesterday"`,
+		`userName eq "a" extra`,
+	}
+
+	for _, input := range filters {
+		t.Run(input, func(t *testing.T) {
+			filter, err := scim.ParseFilter(input)
+			if err == nil {
+				_, _, err = scim.ToSQL(filter, testColumns)
+			}
+			var scimErr *scim.Error
+			require.ErrorAs(t, err, &scimErr)
+			assert.Equal(t, "400", scimErr.Status)
+			assert.Equal(t, scim.ErrorTypeInvalidFilter, scimErr.ScimType)
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	path, err := scim.ParsePath("name.givenName")
+	require.NoError(t, err)
+	assert.Equal(t, &scim.Path{Attribute: "name", SubAttribute: "givenname"}, path)
+
+	path, err = scim.ParsePath(`emails[type eq "work"].value`)
+	require.NoError(t, err)
+	assert.Equal(t, "emails", path.Attribute)
+	assert.Equal(t, "value", path.SubAttribute)
+	assert.Equal(t, &scim.AttributeExpression{Attribute: "type", Operator: "eq", Value: "work"}, path.Filter)
+
+	path, err = scim.ParsePath("urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department")
+	require.NoError(t, err)
+	assert.Equal(t, "department", path.Attribute)
+
+	_, err = scim.ParsePath(`members[value eq "1"`)
+	assert.Error(t, err)
+}
+
+func TestParsePage(t *testing.T) {
+	assert.Equal(t, scim.Page{StartIndex: 1, Count: scim.DefaultCount}, scim.ParsePage("", ""))
+	assert.Equal(t, scim.Page{StartIndex: 1, Count: 0}, scim.ParsePage("-3", "-1"))
+	assert.Equal(t, scim.Page{StartIndex: 11, Count: scim.MaxResults}, scim.ParsePage("11", "5000"))
+}
@@ -0,0 +1,224 @@
+// Package scim holds the SCIM 2.0 (RFC 7643 and RFC 7644) wire types, the
+// filter and attribute path grammar and the discovery documents served at
+// /scim/v2.
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	UserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	ServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	ResourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+
+	// ContentType is the media type of every SCIM request and response.
+	ContentType = "application/scim+json"
+
+	// DefaultCount and MaxResults bound the page size of list responses.
+	DefaultCount = 100
+	MaxResults   = 200
+)
+
+// SCIM error types (RFC 7644 section 3.12).
+const (
+	ErrorTypeInvalidFilter = "invalidFilter"
+	ErrorTypeInvalidPath   = "invalidPath"
+	ErrorTypeInvalidValue  = "invalidValue"
+	ErrorTypeInvalidSyntax = "invalidSyntax"
+	ErrorTypeMutability    = "mutability"
+	ErrorTypeNoTarget      = "noTarget"
+	ErrorTypeTooMany       = "tooMany"
+	ErrorTypeUniqueness    = "uniqueness"
+)
+
+// Error is a SCIM error response. It is also returned as a Go error so the
+// handler can send it as is.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+func NewError(status int, scimType, detail string) *Error {
+	return &Error{
+		Schemas:  []string{ErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+// BadRequest is a 400 error of the given SCIM type.
+func BadRequest(scimType, format string, args ...interface{}) *Error {
+	return NewError(http.StatusBadRequest, scimType, fmt.Sprintf(format, args...))
+}
+
+func NotFound(resourceType, id string) *Error {
+	return NewError(http.StatusNotFound, "", fmt.Sprintf("%s %s not found", resourceType, id))
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// StatusCode returns the HTTP status of the error.
+func (e *Error) StatusCode() int {
+	status, err := strconv.Atoi(e.Status)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// MultiValue is one value of a multi-valued attribute such as emails,
+// groups or members.
+type MultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type User struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *Name        `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Emails      []MultiValue `json:"emails,omitempty"`
+	Active      *bool        `json:"active,omitempty"`
+	Password    string       `json:"password,omitempty"`
+	Groups      []MultiValue `json:"groups,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+// PrimaryEmail returns the primary email, or the first one.
+func (u *User) PrimaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+type Group struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []MultiValue `json:"members,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int64         `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+func NewListResponse(total int64, startIndex int, resources []interface{}) *ListResponse {
+	if resources == nil {
+		resources = []interface{}{}
+	}
+	return &ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Page is the startIndex and count of a list request, already clamped to
+// valid values.
+type Page struct {
+	StartIndex int
+	Count      int
+}
+
+// ParsePage reads the startIndex and count query parameters. A startIndex
+// below 1 is treated as 1 and count is capped at MaxResults.
+func ParsePage(startIndex, count string) Page {
+	page := Page{StartIndex: 1, Count: DefaultCount}
+	if value, err := strconv.Atoi(startIndex); err == nil && value > 1 {
+		page.StartIndex = value
+	}
+	if value, err := strconv.Atoi(count); err == nil {
+		page.Count = value
+	}
+	if page.Count < 0 {
+		page.Count = 0
+	}
+	if page.Count > MaxResults {
+		page.Count = MaxResults
+	}
+	return page
+}
+
+// ParseBool reads a boolean PATCH value. Some clients, Azure AD among them,
+// send booleans as the strings "True" and "False".
+func ParseBool(raw json.RawMessage) (bool, error) {
+	var value bool
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		if parsed, err := strconv.ParseBool(strings.TrimSpace(text)); err == nil {
+			return parsed, nil
+		}
+	}
+	return false, BadRequest(ErrorTypeInvalidValue, "expected a boolean, got %s", string(raw))
+}
+
+// ParseString reads a string PATCH value.
+func ParseString(raw json.RawMessage) (string, error) {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", BadRequest(ErrorTypeInvalidValue, "expected a string, got %s", string(raw))
+	}
+	return value, nil
+}
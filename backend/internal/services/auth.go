@@ -2,9 +2,12 @@ package services
 
 import (
 	"errors"
+	"strings"
 	"time"
 
+	"rbac-system/backend/internal/config"
 	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/mailer"
 	"rbac-system/backend/internal/models"
 	"rbac-system/backend/internal/utils"
 
@@ -12,75 +15,412 @@ import (
 )
 
 type AuthService struct {
-	jwtService *utils.JWTService
+	jwtService      *utils.JWTService
+	sessionService  *SessionService
+	mfaService      *MFAService
+	lockoutService  *LockoutService
+	emailService    *EmailVerificationService
+	passwordService *PasswordService
+	notifier        *mailer.Notifier
+	directory       DirectoryAuthenticator
+	registration    config.RegistrationConfig
 }
 
-func NewAuthService(jwtService *utils.JWTService) *AuthService {
+var (
+	ErrPasswordExpired = errors.New("password has expired and must be changed")
+	// ErrRefreshTokenClient rejects a refresh token presented by another
+	// OAuth client than the one its family belongs to.
+	ErrRefreshTokenClient = errors.New("refresh token was issued to another client")
+	// ErrDirectoryUserNotFound tells Login that no directory knows the user,
+	// so the local password hash is checked instead.
+	ErrDirectoryUserNotFound = errors.New("user not found in any directory")
+	// ErrDirectoryInvalidCredentials is a failed login against a directory
+	// that knows the user.
+	ErrDirectoryInvalidCredentials = errors.New("invalid directory credentials")
+	ErrRegistrationClosed          = errors.New("registration is closed")
+	ErrInvitationRequired          = errors.New("registration requires an invitation")
+	ErrEmailDomainNotAllowed       = errors.New("registration is not open to this email domain")
+	ErrStepUpFailed                = errors.New("invalid password or code")
+	ErrStepUpUnavailable           = errors.New("re-authentication requires a signed-in session")
+)
+
+// DirectoryAuthenticator checks credentials against external directories and
+// returns the matching local user, provisioning or updating it as needed.
+// Verify only re-checks the password of a user already linked to a directory.
+type DirectoryAuthenticator interface {
+	Authenticate(email, password, ipAddress, userAgent string) (*models.User, error)
+	Verify(user *models.User, password string) error
+}
+
+func NewAuthService(jwtService *utils.JWTService, mfaService *MFAService, lockoutService *LockoutService, emailService *EmailVerificationService, passwordService *PasswordService, notifier *mailer.Notifier) *AuthService {
 	return &AuthService{
-		jwtService: jwtService,
+		jwtService:      jwtService,
+		sessionService:  NewSessionService(),
+		mfaService:      mfaService,
+		lockoutService:  lockoutService,
+		emailService:    emailService,
+		passwordService: passwordService,
+		notifier:        notifier,
 	}
 }
 
-func (s *AuthService) Register(req *models.RegisterRequest) (*models.TokenResponse, error) {
+// WithDirectory makes Login try the directory before the local password hash.
+func (s *AuthService) WithDirectory(directory DirectoryAuthenticator) *AuthService {
+	s.directory = directory
+	return s
+}
+
+// WithRegistration sets who may register; without it registration is open to
+// everyone.
+func (s *AuthService) WithRegistration(registration config.RegistrationConfig) *AuthService {
+	s.registration = registration
+	return s
+}
+
+func (s *AuthService) Register(req *models.RegisterRequest, ipAddress, userAgent string) (*models.TokenResponse, error) {
+	if err := s.checkRegistrationAllowed(req.Email); err != nil {
+		return nil, err
+	}
+
 	var existingUser models.User
 	if err := database.DB.Where("email = ? OR username = ?", req.Email, req.Username).First(&existingUser).Error; err == nil {
 		return nil, errors.New("user with this email or username already exists")
 	}
 
+	if err := utils.ValidatePassword(req.Password, req.Username, req.Email); err != nil {
+		return nil, err
+	}
+
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
 		return nil, err
 	}
 
-	var defaultRole models.Role
-	if err := database.DB.Where("name = ?", "User").First(&defaultRole).Error; err != nil {
-		return nil, errors.New("default role not found")
+	role, err := registrationRole(req.Email)
+	if err != nil {
+		return nil, err
 	}
 
+	now := time.Now()
 	user := models.User{
-		Email:        req.Email,
-		Username:     req.Username,
-		PasswordHash: hashedPassword,
-		FirstName:    req.FirstName,
-		LastName:     req.LastName,
-		RoleID:       defaultRole.ID,
-		IsActive:     true,
+		Email:             req.Email,
+		Username:          req.Username,
+		PasswordHash:      hashedPassword,
+		PasswordChangedAt: &now,
+		FirstName:         req.FirstName,
+		LastName:          req.LastName,
+		RoleID:            role.ID,
+		Roles:             []models.Role{*role},
+		IsActive:          true,
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	if err := database.DB.Omit("Roles.*").Create(&user).Error; err != nil {
 		return nil, err
 	}
 
-	if err := database.DB.Preload("Role.Permissions").First(&user, user.ID).Error; err != nil {
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&user, user.ID).Error; err != nil {
 		return nil, err
 	}
 
-	return s.generateTokenResponse(&user)
+	s.emailService.trySendVerification(&user)
+
+	s.notifier.SendWelcome(&user)
+
+	if s.emailService.BlocksLogin(&user) {
+		return nil, ErrEmailNotVerified
+	}
+
+	return s.generateTokenResponse(&user, ipAddress, userAgent)
+}
+
+// checkRegistrationAllowed applies the registration mode and allowed email
+// domains to self-service registration.
+func (s *AuthService) checkRegistrationAllowed(email string) error {
+	switch s.registration.Mode {
+	case "closed":
+		return ErrRegistrationClosed
+	case "invite_only":
+		return ErrInvitationRequired
+	}
+
+	if len(s.registration.AllowedDomains) == 0 {
+		return nil
+	}
+
+	_, domain, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, allowed := range s.registration.AllowedDomains {
+		if domain == allowed {
+			return nil
+		}
+	}
+	return ErrEmailDomainNotAllowed
 }
 
-func (s *AuthService) Login(req *models.LoginRequest, ipAddress, userAgent string) (*models.TokenResponse, error) {
+// Login checks the user's password, first against the directory when one is
+// configured and otherwise against the local hash. When a second factor is
+// still needed it returns an AuthChallenge carrying an mfa_pending token
+// instead of tokens.
+func (s *AuthService) Login(req *models.LoginRequest, ipAddress, userAgent string) (*models.TokenResponse, *models.AuthChallenge, error) {
+	if err := s.lockoutService.CheckIP(req.Email, ipAddress, userAgent); err != nil {
+		return nil, nil, err
+	}
+
 	var user models.User
-	if err := database.DB.Preload("Role.Permissions").Where("email = ?", req.Email).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid credentials")
+	err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").Where("email = ?", req.Email).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+	found := err == nil
+
+	if found && s.lockoutService.IsLocked(&user) {
+		s.lockoutService.RecordFailure(&user, req.Email, "Login attempted while account is locked", ipAddress, userAgent)
+		return nil, nil, ErrAccountLocked
+	}
+
+	if found && !user.IsActive {
+		return nil, nil, errors.New("account is deactivated")
+	}
+
+	if s.directory != nil {
+		directoryUser, err := s.directory.Authenticate(req.Email, req.Password, ipAddress, userAgent)
+		switch {
+		case err == nil:
+			return s.LoginWithIdentity(directoryUser, ipAddress, userAgent)
+		case errors.Is(err, ErrDirectoryInvalidCredentials):
+			var failedUser *models.User
+			if found {
+				failedUser = &user
+			}
+			s.lockoutService.RecordFailure(failedUser, req.Email, "Invalid directory password", ipAddress, userAgent)
+			if found && s.lockoutService.IsLocked(&user) {
+				return nil, nil, ErrAccountLocked
+			}
+			return nil, nil, errors.New("invalid credentials")
+		case !errors.Is(err, ErrDirectoryUserNotFound):
+			return nil, nil, err
+		}
+	}
+
+	if !found {
+		s.lockoutService.RecordFailure(nil, req.Email, "Unknown email", ipAddress, userAgent)
+		return nil, nil, errors.New("invalid credentials")
+	}
+
+	linked, err := hasDirectoryIdentity(user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if linked {
+		s.lockoutService.RecordFailure(&user, req.Email, "Directory no longer knows the user", ipAddress, userAgent)
+		return nil, nil, errors.New("invalid credentials")
+	}
+
+	if err := utils.CheckPassword(req.Password, user.PasswordHash); err != nil {
+		s.lockoutService.RecordFailure(&user, req.Email, "Invalid password", ipAddress, userAgent)
+		if s.lockoutService.IsLocked(&user) {
+			return nil, nil, ErrAccountLocked
 		}
+		return nil, nil, errors.New("invalid credentials")
+	}
+
+	s.upgradePasswordHash(&user, req.Password)
+
+	if s.emailService.BlocksLogin(&user) {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	// An expired password can only be rotated; nothing else is issued until
+	// then.
+	if user.PasswordExpired() {
+		challenge, err := s.newChallenge(&user, "password_expired", "password_change")
+		return nil, challenge, err
+	}
+
+	return s.continueLogin(&user, ipAddress, userAgent)
+}
+
+// LoginWithIdentity logs in a user authenticated by an external identity
+// provider, a directory or a login link. It replaces the password check, so password expiry
+// does not apply, but lockout, deactivation, email verification and MFA do.
+func (s *AuthService) LoginWithIdentity(user *models.User, ipAddress, userAgent string) (*models.TokenResponse, *models.AuthChallenge, error) {
+	if err := s.lockoutService.CheckIP(user.Email, ipAddress, userAgent); err != nil {
+		return nil, nil, err
+	}
+
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(user, user.ID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if s.lockoutService.IsLocked(user) {
+		return nil, nil, ErrAccountLocked
+	}
+
+	if !user.IsActive {
+		return nil, nil, errors.New("account is deactivated")
+	}
+
+	if s.emailService.BlocksLogin(user) {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	return s.continueLogin(user, ipAddress, userAgent)
+}
+
+// continueLogin runs the steps after the password was accepted: an MFA
+// challenge when the user has or needs a second factor, otherwise tokens.
+func (s *AuthService) continueLogin(user *models.User, ipAddress, userAgent string) (*models.TokenResponse, *models.AuthChallenge, error) {
+	if user.MFAEnabledAt != nil {
+		challenge, err := s.newChallenge(user, "mfa_required", "verify")
+		return nil, challenge, err
+	}
+
+	if user.RequiresMFA() {
+		challenge, err := s.newChallenge(user, "mfa_enrollment_required", "enroll")
+		return nil, challenge, err
+	}
+
+	response, err := s.completeLogin(user, ipAddress, userAgent)
+	return response, nil, err
+}
+
+// ChangeExpiredPassword rotates an expired password using the challenge token
+// from Login and then continues the login, so MFA still applies.
+func (s *AuthService) ChangeExpiredPassword(challengeToken, newPassword, ipAddress, userAgent string) (*models.TokenResponse, *models.AuthChallenge, error) {
+	user, claims, err := s.userFromChallenge(challengeToken, "password_change")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.passwordService.SetPassword(user, newPassword); err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.revokeAccessToken(claims); err != nil {
+		return nil, nil, err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "password_changed",
+		Resource:  "auth",
+		Details:   "Expired password was changed",
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return s.continueLogin(user, ipAddress, userAgent)
+}
+
+// VerifyMFA exchanges an mfa_pending token and a TOTP or recovery code for
+// real tokens.
+func (s *AuthService) VerifyMFA(mfaToken, code, ipAddress, userAgent string) (*models.TokenResponse, error) {
+	user, claims, err := s.userFromChallenge(mfaToken, "verify")
+	if err != nil {
 		return nil, err
 	}
 
+	if err := s.mfaService.VerifyCode(user.ID, code); err != nil {
+		s.lockoutService.RecordFailure(user, user.Email, "Invalid MFA code", ipAddress, userAgent)
+		return nil, err
+	}
+
+	if err := s.revokeAccessToken(claims); err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(user, ipAddress, userAgent)
+}
+
+// BeginMFAEnrollment starts enrollment for a user whose role requires MFA but
+// who has not set it up yet, authenticated only by their mfa_pending token.
+func (s *AuthService) BeginMFAEnrollment(mfaToken string) (*models.MFAEnrollmentResponse, error) {
+	user, _, err := s.userFromChallenge(mfaToken, "enroll")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.mfaService.BeginEnrollment(user.ID)
+}
+
+// CompleteMFAEnrollment confirms the enrollment started with
+// BeginMFAEnrollment and finishes the login, returning the recovery codes
+// alongside the tokens.
+func (s *AuthService) CompleteMFAEnrollment(mfaToken, code, ipAddress, userAgent string) (*models.TokenResponse, error) {
+	user, claims, err := s.userFromChallenge(mfaToken, "enroll")
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := s.mfaService.ConfirmEnrollment(user.ID, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.revokeAccessToken(claims); err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(user, user.ID).Error; err != nil {
+		return nil, err
+	}
+
+	response, err := s.completeLogin(user, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	response.RecoveryCodes = recoveryCodes
+
+	return response, nil
+}
+
+func (s *AuthService) newChallenge(user *models.User, challengeType, purpose string) (*models.AuthChallenge, error) {
+	token, expiresAt, err := s.jwtService.GenerateMFAToken(user, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthChallenge{
+		Type:      challengeType,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// userFromChallenge validates an mfa_pending token, which is single use, and
+// loads the user it was issued to.
+func (s *AuthService) userFromChallenge(mfaToken, purpose string) (*models.User, *models.JWTClaims, error) {
+	claims, err := s.jwtService.ValidateMFAToken(mfaToken, purpose)
+	if err != nil || s.isJTIRevoked(claims.ID) {
+		return nil, nil, errors.New("invalid or expired challenge token")
+	}
+
+	var user models.User
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&user, claims.UserID).Error; err != nil {
+		return nil, nil, errors.New("user not found")
+	}
+
 	if !user.IsActive {
-		return nil, errors.New("account is deactivated")
+		return nil, nil, errors.New("account is deactivated")
 	}
 
-	if err := utils.CheckPassword(req.Password, user.PasswordHash); err != nil {
-		return nil, errors.New("invalid credentials")
+	if s.lockoutService.IsLocked(&user) {
+		return nil, nil, ErrAccountLocked
 	}
 
+	return &user, claims, nil
+}
+
+// completeLogin records a successful login and starts a session for it.
+func (s *AuthService) completeLogin(user *models.User, ipAddress, userAgent string) (*models.TokenResponse, error) {
+	s.lockoutService.RecordSuccess(user, ipAddress)
+
 	now := time.Now()
 	user.LastLoginAt = &now
-	database.DB.Save(&user)
+	database.DB.Model(user).Update("last_login_at", now)
 
 	activityLog := models.ActivityLog{
-		UserID:    user.ID,
+		UserID:    &user.ID,
 		Action:    "login",
 		Resource:  "auth",
 		Details:   "User logged in successfully",
@@ -89,17 +429,111 @@ func (s *AuthService) Login(req *models.LoginRequest, ipAddress, userAgent strin
 	}
 	database.DB.Create(&activityLog)
 
-	return s.generateTokenResponse(&user)
+	return s.generateTokenResponse(user, ipAddress, userAgent)
 }
 
-func (s *AuthService) RefreshToken(refreshToken string) (*models.TokenResponse, error) {
+func (s *AuthService) RefreshToken(refreshToken, ipAddress, userAgent string) (*models.TokenResponse, error) {
+	return s.refreshToken(refreshToken, nil, "", ipAddress, userAgent)
+}
+
+// RefreshTokenForClient is RefreshToken for the OAuth refresh_token grant.
+// The client never gets hold of the user's own session: a refresh token from
+// it starts a separate session for the client and leaves the user's family
+// as it was, while a token of the client's session is rotated as usual. The
+// client's access tokens are limited to scope, and only the client may
+// refresh its session.
+func (s *AuthService) RefreshTokenForClient(refreshToken string, client *models.OAuthClient, scope, ipAddress, userAgent string) (*models.TokenResponse, error) {
+	return s.refreshToken(refreshToken, client, scope, ipAddress, userAgent)
+}
+
+func (s *AuthService) refreshToken(refreshToken string, client *models.OAuthClient, scope, ipAddress, userAgent string) (*models.TokenResponse, error) {
 	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
 	if err != nil {
 		return nil, errors.New("invalid refresh token")
 	}
 
+	var stored models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(refreshToken)).First(&stored).Error; err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	if stored.UserID != claims.UserID {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	if stored.RevokedAt != nil {
+		if stored.RevokedReason == "rotated" {
+			s.revokeTokenFamily(&stored, ipAddress, userAgent)
+			return nil, errors.New("refresh token reuse detected")
+		}
+		return nil, errors.New("invalid refresh token")
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	var session models.Session
+	if err := database.DB.Where("family_id = ?", stored.FamilyID).First(&session).Error; err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	// Checked before rotating, so a client presenting someone else's token
+	// can't use it up either.
+	if session.OAuthClientID != nil && (client == nil || client.ID != *session.OAuthClientID) {
+		return nil, ErrRefreshTokenClient
+	}
+
+	if client != nil && session.OAuthClientID == nil {
+		user, err := s.refreshingUser(claims.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return s.startClientSession(user, client, scope, ipAddress, userAgent)
+	}
+
+	// Retire the presented token before issuing its successor. The guard on
+	// revoked_at makes concurrent refreshes with the same token race safely:
+	// only one of them rotates, the other is treated as a replay.
+	now := time.Now()
+	result := database.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", stored.ID).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": "rotated"})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		s.revokeTokenFamily(&stored, ipAddress, userAgent)
+		return nil, errors.New("refresh token reuse detected")
+	}
+
+	// The presented token is already rotated out, so on an expired password
+	// the session ends here and the next login goes through the change.
+	user, err := s.refreshingUser(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	response, next, err := s.issueTokens(user, &session, client, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	database.DB.Model(&stored).Update("replaced_by_id", next.ID)
+	database.DB.Model(&session).Updates(map[string]interface{}{
+		"last_seen_at": now,
+		"ip_address":   ipAddress,
+		"user_agent":   userAgent,
+	})
+
+	return response, nil
+}
+
+// refreshingUser loads the user a refresh token belongs to, as long as they
+// may still be issued tokens.
+func (s *AuthService) refreshingUser(userID uint) (*models.User, error) {
 	var user models.User
-	if err := database.DB.Preload("Role.Permissions").First(&user, claims.UserID).Error; err != nil {
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&user, userID).Error; err != nil {
 		return nil, errors.New("user not found")
 	}
 
@@ -107,32 +541,416 @@ func (s *AuthService) RefreshToken(refreshToken string) (*models.TokenResponse,
 		return nil, errors.New("account is deactivated")
 	}
 
-	return s.generateTokenResponse(&user)
+	if user.PasswordExpired() {
+		return nil, ErrPasswordExpired
+	}
+
+	return &user, nil
+}
+
+// startClientSession gives an OAuth client a session of its own for the
+// user, with its own refresh token family.
+func (s *AuthService) startClientSession(user *models.User, client *models.OAuthClient, scope, ipAddress, userAgent string) (*models.TokenResponse, error) {
+	familyID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.sessionService.CreateSession(user.ID, familyID, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	session.OAuthClientID = &client.ID
+	if err := database.DB.Model(session).Update("oauth_client_id", client.ID).Error; err != nil {
+		return nil, err
+	}
+
+	response, _, err := s.issueTokens(user, session, client, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "token_issued",
+		Resource:  "auth",
+		Details:   "OAuth client " + client.Name + " started a session with scope: " + scope,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return response, nil
+}
+
+// InspectRefreshToken reports whether a refresh token is still usable
+// without rotating it: validly signed, the live member of its family, and
+// belonging to an active user.
+func (s *AuthService) InspectRefreshToken(refreshToken string) (*models.JWTClaims, *models.User, error) {
+	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	var stored models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(refreshToken)).First(&stored).Error; err != nil {
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	if stored.UserID != claims.UserID || stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	user, err := s.GetUserByID(claims.UserID)
+	if err != nil || !user.IsActive {
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	return claims, user, nil
+}
+
+// upgradePasswordHash re-hashes the password with the configured algorithm
+// when the stored hash is outdated. The plaintext is only available at login,
+// so this is how a raised work factor reaches existing users. Failures are
+// ignored; the old hash stays valid and is retried on the next login.
+func (s *AuthService) upgradePasswordHash(user *models.User, password string) {
+	if !utils.PasswordNeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return
+	}
+
+	if err := database.DB.Model(user).UpdateColumn("password_hash", hashedPassword).Error; err != nil {
+		return
+	}
+	user.PasswordHash = hashedPassword
+}
+
+// revokeTokenFamily revokes every refresh token descended from the same login
+// and records the replay, since a rotated token being presented again means
+// either the client or an attacker holds a stale copy.
+func (s *AuthService) revokeTokenFamily(token *models.RefreshToken, ipAddress, userAgent string) {
+	now := time.Now()
+	database.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": "reuse"})
+	database.DB.Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+		Update("revoked_at", now)
+
+	activityLog := models.ActivityLog{
+		UserID:    &token.UserID,
+		Action:    "refresh_token_reuse",
+		Resource:  "auth",
+		Details:   "Rotated refresh token was presented again; token family " + token.FamilyID + " revoked",
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	var user models.User
+	if err := database.DB.First(&user, token.UserID).Error; err == nil {
+		s.notifier.SendSecurityAlert(&user, "Suspicious sign-in activity", "A refresh token was reused, so one of your sessions was signed out.", ipAddress)
+	}
+}
+
+// Logout ends the current session: the presented access token is added to the
+// revocation list and the session behind it is revoked together with its
+// refresh token family.
+func (s *AuthService) Logout(claims *models.JWTClaims, ipAddress, userAgent string) error {
+	if err := s.revokeAccessToken(claims); err != nil {
+		return err
+	}
+
+	if claims.SessionID != 0 {
+		if err := s.sessionService.RevokeSession(claims.UserID, claims.SessionID); err != nil {
+			return err
+		}
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &claims.UserID,
+		Action:    "logout",
+		Resource:  "auth",
+		Details:   "User logged out",
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	if claims.ImpersonatorID != 0 {
+		activityLog.ImpersonatorID = &claims.ImpersonatorID
+		activityLog.Action = "impersonation_ended"
+		activityLog.Details = "Impersonation ended"
+	}
+	database.DB.Create(&activityLog)
+
+	return nil
+}
+
+// LogoutAll ends every session of the user. Access tokens issued up to now
+// are rejected through User.TokensRevokedAt, which avoids having to enumerate
+// them, and all sessions with their refresh tokens are revoked.
+func (s *AuthService) LogoutAll(claims *models.JWTClaims, ipAddress, userAgent string) error {
+	now := time.Now()
+	if err := database.DB.Model(&models.User{}).Where("id = ?", claims.UserID).Update("tokens_revoked_at", now).Error; err != nil {
+		return err
+	}
+
+	if err := s.sessionService.RevokeAllSessions(claims.UserID); err != nil {
+		return err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &claims.UserID,
+		Action:    "logout_all",
+		Resource:  "auth",
+		Details:   "User logged out of all sessions",
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return nil
+}
+
+// StepUp re-authenticates the session behind the access token with the
+// user's password, checked against their directory when they have one, or an
+// MFA code. The session's auth time moves to now and a fresh access token
+// carrying it is returned. Failures count towards the account lockout.
+func (s *AuthService) StepUp(claims *models.JWTClaims, req *models.StepUpRequest, ipAddress, userAgent string) (*models.StepUpResponse, error) {
+	if claims.SessionID == 0 || claims.ImpersonatorID != 0 {
+		return nil, ErrStepUpUnavailable
+	}
+
+	user, err := s.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.lockoutService.IsLocked(user) {
+		return nil, ErrAccountLocked
+	}
+
+	if err := s.checkStepUp(user, req, ipAddress, userAgent); err != nil {
+		s.lockoutService.RecordFailure(user, user.Email, "Invalid step-up credentials", ipAddress, userAgent)
+		if s.lockoutService.IsLocked(user) {
+			return nil, ErrAccountLocked
+		}
+		return nil, err
+	}
+
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", claims.SessionID, user.ID).First(&session).Error; err != nil {
+		return nil, ErrStepUpUnavailable
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&session).Update("authenticated_at", now).Error; err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresAt, err := s.jwtService.GenerateAccessToken(user, session.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "step_up",
+		Resource:  "auth",
+		Details:   "User re-authenticated for a sensitive operation",
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return &models.StepUpResponse{
+		AccessToken: accessToken,
+		ExpiresAt:   expiresAt,
+		AuthTime:    now,
+	}, nil
 }
 
-func (s *AuthService) generateTokenResponse(user *models.User) (*models.TokenResponse, error) {
-	accessToken, expiresAt, err := s.jwtService.GenerateAccessToken(user)
+func (s *AuthService) checkStepUp(user *models.User, req *models.StepUpRequest, ipAddress, userAgent string) error {
+	if req.Code != "" {
+		if user.MFAEnabledAt == nil {
+			return ErrStepUpFailed
+		}
+		if err := s.mfaService.VerifyCode(user.ID, req.Code); err != nil {
+			return ErrStepUpFailed
+		}
+		return nil
+	}
+
+	if s.directory != nil {
+		err := s.directory.Verify(user, req.Password)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, ErrDirectoryInvalidCredentials):
+			return ErrStepUpFailed
+		case !errors.Is(err, ErrDirectoryUserNotFound):
+			return err
+		}
+	}
+
+	linked, err := hasDirectoryIdentity(user.ID)
+	if err != nil {
+		return err
+	}
+	if linked {
+		return ErrStepUpFailed
+	}
+
+	if err := utils.CheckPassword(req.Password, user.PasswordHash); err != nil {
+		return ErrStepUpFailed
+	}
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether the token was logged out, either
+// individually, through its session, or by a log-out-everywhere issued after
+// it was minted. iat only has whole seconds, so a token from the same second
+// as the log-out-everywhere counts as revoked.
+func (s *AuthService) IsAccessTokenRevoked(claims *models.JWTClaims, user *models.User) bool {
+	if user.TokensRevokedAt != nil && claims.IssuedAt.Unix() <= user.TokensRevokedAt.Unix() {
+		return true
+	}
+
+	if claims.SessionID != 0 && !s.sessionService.IsSessionActive(claims.SessionID) {
+		return true
+	}
+
+	return s.isJTIRevoked(claims.ID)
+}
+
+func (s *AuthService) isJTIRevoked(jti string) bool {
+	if jti == "" {
+		return false
+	}
+
+	var count int64
+	database.DB.Model(&models.RevokedToken{}).
+		Where("jti = ? AND expires_at > ?", jti, time.Now()).
+		Count(&count)
+	return count > 0
+}
+
+func (s *AuthService) revokeAccessToken(claims *models.JWTClaims) error {
+	if claims.ID == "" {
+		return nil
+	}
+
+	// Entries past their token's exp are dead weight; clear them out while
+	// we are writing to the table anyway.
+	database.DB.Where("expires_at <= ?", time.Now()).Delete(&models.RevokedToken{})
+
+	revoked := models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt,
+	}
+	return database.DB.Where(models.RevokedToken{JTI: claims.ID}).FirstOrCreate(&revoked).Error
+}
+
+// TouchSession records activity on the session an access token belongs to.
+func (s *AuthService) TouchSession(claims *models.JWTClaims) {
+	if claims.SessionID != 0 {
+		s.sessionService.TouchSession(claims.SessionID)
+	}
+}
+
+// generateTokenResponse starts a new session, with its own refresh token
+// family, and issues the first token pair for it.
+func (s *AuthService) generateTokenResponse(user *models.User, ipAddress, userAgent string) (*models.TokenResponse, error) {
+	familyID, err := utils.GenerateRandomToken(16)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, _, err := s.jwtService.GenerateRefreshToken(user)
+	session, err := s.sessionService.CreateSession(user.ID, familyID, ipAddress, userAgent)
 	if err != nil {
 		return nil, err
 	}
 
+	response, _, err := s.issueTokens(user, session, nil, "")
+	return response, err
+}
+
+// issueTokens mints an access/refresh pair for the session and persists the
+// hashed refresh token under the session's family. With a client the access
+// token is the client's, limited to scope.
+func (s *AuthService) issueTokens(user *models.User, session *models.Session, client *models.OAuthClient, scope string) (*models.TokenResponse, *models.RefreshToken, error) {
+	if err := withInheritedPermissions(user); err != nil {
+		return nil, nil, err
+	}
+
+	var accessToken string
+	var expiresAt time.Time
+	var err error
+	if client != nil {
+		accessToken, expiresAt, err = s.jwtService.GenerateClientAccessToken(user, session.ID, client.ClientID, scope)
+	} else {
+		accessToken, expiresAt, err = s.jwtService.GenerateAccessToken(user, session.ID, session.AuthenticatedAt)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, refreshExpiresAt, err := s.jwtService.GenerateRefreshToken(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  session.FamilyID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: refreshExpiresAt,
+	}
+	if err := database.DB.Create(&stored).Error; err != nil {
+		return nil, nil, err
+	}
+
+	session.RefreshTokenID = &stored.ID
+	session.ExpiresAt = refreshExpiresAt
+	if err := database.DB.Model(session).Updates(map[string]interface{}{
+		"refresh_token_id": stored.ID,
+		"expires_at":       refreshExpiresAt,
+	}).Error; err != nil {
+		return nil, nil, err
+	}
+
 	return &models.TokenResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		ExpiresAt:    expiresAt,
 		User:         *user.ToResponse(),
-	}, nil
+	}, &stored, nil
 }
 
 func (s *AuthService) GetUserByID(userID uint) (*models.User, error) {
 	var user models.User
-	if err := database.DB.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if err := withInheritedPermissions(&user); err != nil {
 		return nil, err
 	}
 	return &user, nil
-}
\ No newline at end of file
+}
+
+func (s *AuthService) VerifyEmail(token, ipAddress, userAgent string) error {
+	_, err := s.emailService.VerifyEmail(token, ipAddress, userAgent)
+	return err
+}
+
+func (s *AuthService) ResendVerification(email string) error {
+	return s.emailService.ResendVerification(email)
+}
+
+func (s *AuthService) ResetEmailVerification(user *models.User) error {
+	return s.emailService.ResetVerification(user)
+}
@@ -0,0 +1,457 @@
+package services_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+)
+
+var testJWTConfig = &config.Config{
+	JWT: config.JWTConfig{
+		Secret:             "test-secret",
+		RefreshSecret:      "test-refresh-secret",
+		AccessTokenExpiry:  15 * time.Minute,
+		RefreshTokenExpiry: time.Hour,
+	},
+	MFA: config.MFAConfig{
+		Issuer:             "Test",
+		PendingTokenExpiry: 5 * time.Minute,
+	},
+	Email: config.EmailVerificationConfig{
+		Mode:        "off",
+		TokenExpiry: 24 * time.Hour,
+	},
+	Lockout: config.LockoutConfig{
+		MaxFailedAttempts:   3,
+		BaseDuration:        time.Minute,
+		MaxDuration:         time.Hour,
+		IPMaxFailedAttempts: 10,
+		IPWindow:            15 * time.Minute,
+	},
+	Impersonation: config.ImpersonationConfig{
+		TokenExpiry: 30 * time.Minute,
+	},
+}
+
+func setupAuthTest(t *testing.T) (*services.AuthService, *models.User) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.ActivityLog{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.Session{}, &models.MFARecoveryCode{}, &models.LoginAttempt{}, &models.PasswordResetToken{}, &models.PasswordHistory{}, &models.SystemSetting{}, &models.RegistrationRoleRule{}, &models.UserIdentity{}))
+	database.DB = db
+
+	role := models.Role{Name: "User", Description: "User Role"}
+	db.Create(&role)
+
+	hash, err := utils.HashPassword("password123")
+	require.NoError(t, err)
+
+	user := models.User{
+		Email:        "user@example.com",
+		Username:     "regularuser",
+		PasswordHash: hash,
+		FirstName:    "Regular",
+		LastName:     "User",
+		RoleID:       role.ID,
+		IsActive:     true,
+	}
+	db.Create(&user)
+
+	return newTestAuthService(), &user
+}
+
+func newTestAuthService() *services.AuthService {
+	jwtService := utils.NewJWTService(testJWTConfig)
+	return services.NewAuthService(
+		jwtService,
+		services.NewMFAService(testJWTConfig.MFA.Issuer),
+		services.NewLockoutService(testJWTConfig.Lockout, nil),
+		services.NewEmailVerificationService(jwtService, testJWTConfig.Email, nil),
+		services.NewPasswordService(nil, 3),
+		nil,
+	)
+}
+
+func login(t *testing.T, authService *services.AuthService, user *models.User, ipAddress, userAgent string) *models.TokenResponse {
+	response, challenge, err := authService.Login(&models.LoginRequest{Email: user.Email, Password: "password123"}, ipAddress, userAgent)
+	require.NoError(t, err)
+	require.Nil(t, challenge)
+	return response
+}
+
+func TestAuthService_RefreshTokenRotation(t *testing.T) {
+	authService, user := setupAuthTest(t)
+
+	login := login(t, authService, user, "127.0.0.1", "test")
+
+	refreshed, err := authService.RefreshToken(login.RefreshToken, "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	var original models.RefreshToken
+	require.NoError(t, database.DB.Where("token_hash = ?", utils.HashToken(login.RefreshToken)).First(&original).Error)
+	assert.NotNil(t, original.RevokedAt, "Rotated token should be revoked")
+	assert.NotNil(t, original.ReplacedByID, "Rotated token should point at its successor")
+
+	_, err = authService.RefreshToken(refreshed.RefreshToken, "127.0.0.1", "test")
+	assert.NoError(t, err, "Latest token in the family should still rotate")
+}
+
+func TestAuthService_RefreshTokenReuseRevokesFamily(t *testing.T) {
+	authService, user := setupAuthTest(t)
+
+	login := login(t, authService, user, "127.0.0.1", "test")
+
+	refreshed, err := authService.RefreshToken(login.RefreshToken, "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	_, err = authService.RefreshToken(login.RefreshToken, "10.0.0.1", "attacker")
+	assert.EqualError(t, err, "refresh token reuse detected")
+
+	var count int64
+	database.DB.Model(&models.ActivityLog{}).
+		Where("user_id = ? AND action = ? AND resource = ?", user.ID, "refresh_token_reuse", "auth").
+		Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	_, err = authService.RefreshToken(refreshed.RefreshToken, "127.0.0.1", "test")
+	assert.Error(t, err, "Replay should revoke the rest of the family")
+}
+
+func TestAuthService_LogoutRevokesSession(t *testing.T) {
+	authService, user := setupAuthTest(t)
+	jwtService := utils.NewJWTService(testJWTConfig)
+
+	login := login(t, authService, user, "127.0.0.1", "test")
+
+	claims, err := jwtService.ValidateAccessToken(login.AccessToken)
+	require.NoError(t, err)
+	assert.NotEmpty(t, claims.ID, "Access tokens should carry a jti")
+	assert.False(t, authService.IsAccessTokenRevoked(claims, user))
+
+	require.NoError(t, authService.Logout(claims, "127.0.0.1", "test"))
+	assert.True(t, authService.IsAccessTokenRevoked(claims, user))
+
+	_, err = authService.RefreshToken(login.RefreshToken, "127.0.0.1", "test")
+	assert.EqualError(t, err, "invalid refresh token", "Logged out refresh tokens should not count as reuse")
+}
+
+func TestAuthService_LogoutAll(t *testing.T) {
+	authService, user := setupAuthTest(t)
+	jwtService := utils.NewJWTService(testJWTConfig)
+
+	first := login(t, authService, user, "127.0.0.1", "test")
+	second := login(t, authService, user, "127.0.0.2", "test")
+
+	claims, err := jwtService.ValidateAccessToken(first.AccessToken)
+	require.NoError(t, err)
+
+	// The second token is issued in the same second as the cutoff and must
+	// still be rejected.
+	otherClaims, err := jwtService.ValidateAccessToken(second.AccessToken)
+	require.NoError(t, err)
+
+	require.NoError(t, authService.LogoutAll(claims, "127.0.0.1", "test"))
+
+	reloaded, err := authService.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, authService.IsAccessTokenRevoked(claims, reloaded))
+	assert.True(t, authService.IsAccessTokenRevoked(otherClaims, reloaded))
+
+	_, err = authService.RefreshToken(second.RefreshToken, "127.0.0.2", "test")
+	assert.Error(t, err)
+}
+
+func TestSessionService_RevokeSession(t *testing.T) {
+	authService, user := setupAuthTest(t)
+	sessionService := services.NewSessionService()
+	jwtService := utils.NewJWTService(testJWTConfig)
+
+	first := login(t, authService, user, "127.0.0.1", "browser")
+	second := login(t, authService, user, "127.0.0.2", "cli")
+
+	firstClaims, err := jwtService.ValidateAccessToken(first.AccessToken)
+	require.NoError(t, err)
+	secondClaims, err := jwtService.ValidateAccessToken(second.AccessToken)
+	require.NoError(t, err)
+
+	sessions, err := sessionService.GetActiveSessions(user.ID, firstClaims.SessionID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	count, err := sessionService.CountActiveSessions()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	assert.Error(t, sessionService.RevokeSession(user.ID+1, secondClaims.SessionID), "Sessions of other users should not be found")
+	require.NoError(t, sessionService.RevokeSession(user.ID, secondClaims.SessionID))
+
+	assert.True(t, authService.IsAccessTokenRevoked(secondClaims, user))
+	assert.False(t, authService.IsAccessTokenRevoked(firstClaims, user))
+
+	_, err = authService.RefreshToken(second.RefreshToken, "127.0.0.2", "cli")
+	assert.Error(t, err)
+
+	sessions, err = sessionService.GetActiveSessions(user.ID, firstClaims.SessionID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Current)
+}
+
+func TestAuthService_MFALogin(t *testing.T) {
+	authService, user := setupAuthTest(t)
+	mfaService := services.NewMFAService(testJWTConfig.MFA.Issuer)
+
+	enrollment, err := mfaService.BeginEnrollment(user.ID)
+	require.NoError(t, err)
+	assert.Contains(t, enrollment.OTPAuthURI, "otpauth://totp/Test:user@example.com")
+
+	code, err := utils.GenerateTOTPCode(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	recoveryCodes, err := mfaService.ConfirmEnrollment(user.ID, code)
+	require.NoError(t, err)
+	require.Len(t, recoveryCodes, 10)
+
+	response, challenge, err := authService.Login(&models.LoginRequest{Email: user.Email, Password: "password123"}, "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Nil(t, response, "Tokens must not be issued before the second factor")
+	require.NotNil(t, challenge)
+	assert.Equal(t, "mfa_required", challenge.Type)
+
+	_, err = authService.VerifyMFA(challenge.Token, code, "127.0.0.1", "test")
+	assert.Error(t, err, "A TOTP code must not be accepted twice")
+
+	response, err = authService.VerifyMFA(challenge.Token, recoveryCodes[0], "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.AccessToken)
+
+	_, err = authService.VerifyMFA(challenge.Token, recoveryCodes[1], "127.0.0.1", "test")
+	assert.Error(t, err, "The mfa_pending token is single use")
+
+	_, challenge, err = authService.Login(&models.LoginRequest{Email: user.Email, Password: "password123"}, "127.0.0.1", "test")
+	require.NoError(t, err)
+	_, err = authService.VerifyMFA(challenge.Token, recoveryCodes[0], "127.0.0.1", "test")
+	assert.Error(t, err, "Recovery codes are single use")
+}
+
+func TestAuthService_RoleRequiresMFAEnrollment(t *testing.T) {
+	authService, user := setupAuthTest(t)
+	database.DB.Model(&models.Role{}).Where("id = ?", user.RoleID).Update("require_mfa", true)
+
+	_, challenge, err := authService.Login(&models.LoginRequest{Email: user.Email, Password: "password123"}, "127.0.0.1", "test")
+	require.NoError(t, err)
+	require.NotNil(t, challenge)
+	assert.Equal(t, "mfa_enrollment_required", challenge.Type)
+
+	_, err = authService.VerifyMFA(challenge.Token, "123456", "127.0.0.1", "test")
+	assert.Error(t, err, "Enrollment tokens cannot be used to verify")
+
+	enrollment, err := authService.BeginMFAEnrollment(challenge.Token)
+	require.NoError(t, err)
+
+	code, err := utils.GenerateTOTPCode(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	response, err := authService.CompleteMFAEnrollment(challenge.Token, code, "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.AccessToken)
+	assert.Len(t, response.RecoveryCodes, 10)
+	assert.True(t, response.User.MFAEnabled)
+}
+
+func TestAuthService_AccountLockout(t *testing.T) {
+	authService, user := setupAuthTest(t)
+	lockoutService := services.NewLockoutService(testJWTConfig.Lockout, nil)
+	wrong := &models.LoginRequest{Email: user.Email, Password: "wrongpassword"}
+
+	for i := 0; i < 2; i++ {
+		_, _, err := authService.Login(wrong, "127.0.0.1", "test")
+		assert.EqualError(t, err, "invalid credentials")
+	}
+
+	_, _, err := authService.Login(wrong, "127.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrAccountLocked, "Third failure should lock the account")
+
+	_, _, err = authService.Login(&models.LoginRequest{Email: user.Email, Password: "password123"}, "127.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrAccountLocked, "Correct password must not bypass the lock")
+
+	var locked models.User
+	require.NoError(t, database.DB.First(&locked, user.ID).Error)
+	require.NotNil(t, locked.LockedUntil)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), *locked.LockedUntil, 5*time.Second)
+
+	var count int64
+	database.DB.Model(&models.ActivityLog{}).Where("user_id = ? AND action = ? AND resource = ?", user.ID, "account_locked", "auth").Count(&count)
+	assert.Equal(t, int64(1), count)
+	database.DB.Model(&models.ActivityLog{}).Where("user_id = ? AND action = ? AND resource = ?", user.ID, "login_failed", "auth").Count(&count)
+	assert.Equal(t, int64(4), count)
+
+	// Expire the lock; the next lockout should last twice as long.
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("locked_until", time.Now().Add(-time.Second))
+	for i := 0; i < 3; i++ {
+		authService.Login(wrong, "127.0.0.1", "test")
+	}
+	require.NoError(t, database.DB.First(&locked, user.ID).Error)
+	require.NotNil(t, locked.LockedUntil)
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), *locked.LockedUntil, 5*time.Second)
+
+	require.NoError(t, lockoutService.Unlock(user.ID))
+	login(t, authService, user, "127.0.0.1", "test")
+}
+
+func TestAuthService_IPThrottling(t *testing.T) {
+	authService, user := setupAuthTest(t)
+
+	for i := 0; i < 10; i++ {
+		_, _, err := authService.Login(&models.LoginRequest{Email: "nobody@example.com", Password: "password123"}, "10.0.0.1", "test")
+		assert.EqualError(t, err, "invalid credentials")
+	}
+
+	_, _, err := authService.Login(&models.LoginRequest{Email: user.Email, Password: "password123"}, "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrTooManyLoginAttempts)
+
+	// Neither the unknown email nor the throttled attempt has a user, but
+	// both are in the activity log.
+	var count int64
+	database.DB.Model(&models.ActivityLog{}).Where("user_id IS NULL AND action = ? AND resource = ?", "login_failed", "auth").Count(&count)
+	assert.Equal(t, int64(10), count)
+	database.DB.Model(&models.ActivityLog{}).Where("user_id IS NULL AND action = ? AND resource = ?", "login_throttled", "auth").Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	login(t, authService, user, "10.0.0.2", "test")
+}
+
+func TestEmailVerificationService_VerifyEmail(t *testing.T) {
+	_, user := setupAuthTest(t)
+	jwtService := utils.NewJWTService(testJWTConfig)
+	cfg := testJWTConfig.Email
+	cfg.Mode = "block"
+	emailService := services.NewEmailVerificationService(jwtService, cfg, nil)
+
+	assert.True(t, emailService.BlocksLogin(user))
+
+	token, _, err := jwtService.GenerateEmailVerificationToken(user)
+	require.NoError(t, err)
+
+	// A token for the previous address must not verify the new one.
+	database.DB.Model(user).Update("email", "changed@example.com")
+	_, err = emailService.VerifyEmail(token, "127.0.0.1", "test")
+	assert.Error(t, err)
+
+	var reloaded models.User
+	require.NoError(t, database.DB.First(&reloaded, user.ID).Error)
+	token, _, err = jwtService.GenerateEmailVerificationToken(&reloaded)
+	require.NoError(t, err)
+
+	verified, err := emailService.VerifyEmail(token, "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.NotNil(t, verified.EmailVerifiedAt)
+	assert.False(t, emailService.BlocksLogin(verified))
+
+	require.NoError(t, emailService.ResetVerification(verified))
+	assert.Nil(t, verified.EmailVerifiedAt)
+	assert.True(t, emailService.BlocksLogin(verified))
+}
+
+func TestAuthService_LoginUpgradesPasswordHash(t *testing.T) {
+	_, user := setupAuthTest(t)
+	authService := newTestAuthService()
+
+	hasher, err := utils.NewPasswordHasher(config.PasswordHashConfig{
+		Algorithm:         "argon2id",
+		Argon2Memory:      1024,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+	})
+	require.NoError(t, err)
+	utils.SetPasswordHasher(hasher)
+	t.Cleanup(func() { utils.SetPasswordHasher(&utils.BcryptHasher{Cost: 10}) })
+
+	login(t, authService, user, "127.0.0.1", "test")
+
+	var reloaded models.User
+	require.NoError(t, database.DB.First(&reloaded, user.ID).Error)
+	assert.True(t, strings.HasPrefix(reloaded.PasswordHash, "$argon2id$"))
+	assert.False(t, utils.PasswordNeedsRehash(reloaded.PasswordHash))
+
+	// The upgraded hash keeps working for later logins.
+	login(t, authService, &reloaded, "127.0.0.1", "test")
+}
+
+func TestAuthService_ExpiredPasswordMustBeChanged(t *testing.T) {
+	authService, user := setupAuthTest(t)
+
+	database.DB.Model(&models.Role{}).Where("id = ?", user.RoleID).Update("password_max_age_days", 30)
+	database.DB.Model(user).Update("password_changed_at", time.Now().AddDate(0, 0, -31))
+
+	response, challenge, err := authService.Login(&models.LoginRequest{Email: user.Email, Password: "password123"}, "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Nil(t, response)
+	require.NotNil(t, challenge)
+	assert.Equal(t, "password_expired", challenge.Type)
+
+	// The challenge token is not an MFA token.
+	_, err = authService.VerifyMFA(challenge.Token, "000000", "127.0.0.1", "test")
+	assert.Error(t, err)
+
+	// A password rejected by the policy leaves the challenge usable.
+	_, _, err = authService.ChangeExpiredPassword(challenge.Token, "short", "127.0.0.1", "test")
+	var policyErr *utils.PasswordPolicyError
+	require.ErrorAs(t, err, &policyErr)
+
+	response, challenge, err = authService.ChangeExpiredPassword(challenge.Token, "fresh-secret-42", "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Nil(t, challenge)
+	require.NotNil(t, response)
+	assert.NotNil(t, response.User.PasswordChangedAt)
+	assert.NotNil(t, response.User.PasswordExpiresAt)
+}
+
+func TestAuthService_StepUp(t *testing.T) {
+	authService, user := setupAuthTest(t)
+	jwtService := utils.NewJWTService(testJWTConfig)
+
+	response := login(t, authService, user, "127.0.0.1", "test")
+	claims, err := jwtService.ValidateAccessToken(response.AccessToken)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), claims.AuthTime, 2*time.Second)
+
+	// Refreshing keeps the session's auth time instead of renewing it.
+	authenticatedAt := time.Now().Add(-time.Hour)
+	database.DB.Model(&models.Session{}).Where("id = ?", claims.SessionID).Update("authenticated_at", authenticatedAt)
+	refreshed, err := authService.RefreshToken(response.RefreshToken, "127.0.0.1", "test")
+	require.NoError(t, err)
+	claims, err = jwtService.ValidateAccessToken(refreshed.AccessToken)
+	require.NoError(t, err)
+	assert.WithinDuration(t, authenticatedAt, claims.AuthTime, 2*time.Second)
+
+	_, err = authService.StepUp(claims, &models.StepUpRequest{Password: "wrong-password"}, "127.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrStepUpFailed)
+
+	_, err = authService.StepUp(claims, &models.StepUpRequest{Code: "123456"}, "127.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrStepUpFailed, "Codes are only accepted from users with MFA")
+
+	stepUp, err := authService.StepUp(claims, &models.StepUpRequest{Password: "password123"}, "127.0.0.1", "test")
+	require.NoError(t, err)
+	stepped, err := jwtService.ValidateAccessToken(stepUp.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, claims.SessionID, stepped.SessionID)
+	assert.WithinDuration(t, time.Now(), stepped.AuthTime, 2*time.Second)
+
+	var session models.Session
+	require.NoError(t, database.DB.First(&session, claims.SessionID).Error)
+	assert.WithinDuration(t, time.Now(), session.AuthenticatedAt, 2*time.Second)
+
+	var failed int
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Select("failed_logins").Scan(&failed)
+	assert.Equal(t, 2, failed, "Failed step-ups count towards the lockout")
+
+	_, err = authService.StepUp(&models.JWTClaims{UserID: user.ID}, &models.StepUpRequest{Password: "password123"}, "127.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrStepUpUnavailable)
+}
@@ -68,8 +68,8 @@ func (s *DashboardService) GetRoleDistribution() ([]RoleDistribution, error) {
 	var distributions []RoleDistribution
 
 	err := database.DB.Model(&models.User{}).
-		Select("roles.name as role_name, COUNT(users.id) as user_count").
-		Joins("JOIN roles ON users.role_id = roles.id").
+		Select("roles.name as role_name, COUNT(DISTINCT users.id) as user_count").
+		Joins("JOIN roles ON roles.id = users.role_id OR roles.id IN (SELECT role_id FROM user_roles WHERE user_roles.user_id = users.id)").
 		Group("roles.id, roles.name").
 		Scan(&distributions).Error
 
@@ -83,7 +83,7 @@ func (s *DashboardService) GetRoleDistribution() ([]RoleDistribution, error) {
 func (s *DashboardService) GetRecentActivity(limit int) ([]models.ActivityLogResponse, error) {
 	var activityLogs []models.ActivityLog
 
-	err := database.DB.Preload("User").
+	err := database.DB.Preload("User").Preload("ServiceAccount").Preload("Impersonator").
 		Order("created_at DESC").
 		Limit(limit).
 		Find(&activityLogs).Error
@@ -153,9 +153,9 @@ func (s *DashboardService) GetSystemHealth() (*SystemHealth, error) {
 		health.TotalRequests = activityCount
 	}
 
-	var activeUserCount int64
-	if err := database.DB.Model(&models.User{}).Where("is_active = ? AND last_login_at > ?", true, time.Now().Add(-24*time.Hour)).Count(&activeUserCount).Error; err == nil {
-		health.ActiveSessions = activeUserCount
+	var activeSessionCount int64
+	if err := database.DB.Model(&models.Session{}).Where("revoked_at IS NULL AND expires_at > ?", time.Now()).Count(&activeSessionCount).Error; err == nil {
+		health.ActiveSessions = activeSessionCount
 	}
 
 	return health, nil
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/mailer"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+)
+
+var ErrEmailNotVerified = errors.New("email address has not been verified")
+
+type EmailVerificationService struct {
+	jwtService *utils.JWTService
+	config     config.EmailVerificationConfig
+	notifier   *mailer.Notifier
+}
+
+func NewEmailVerificationService(jwtService *utils.JWTService, cfg config.EmailVerificationConfig, notifier *mailer.Notifier) *EmailVerificationService {
+	return &EmailVerificationService{
+		jwtService: jwtService,
+		config:     cfg,
+		notifier:   notifier,
+	}
+}
+
+// BlocksLogin reports whether the user must verify their email before they
+// are allowed to log in at all.
+func (s *EmailVerificationService) BlocksLogin(user *models.User) bool {
+	return s.config.Mode == "block" && user.EmailVerifiedAt == nil
+}
+
+// SendVerification emails a verification link for the user's current address.
+func (s *EmailVerificationService) SendVerification(user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	token, expiresAt, err := s.jwtService.GenerateEmailVerificationToken(user)
+	if err != nil {
+		return err
+	}
+
+	return s.notifier.SendEmailVerification(user, token, expiresAt)
+}
+
+// ResendVerification sends a new token to an unverified account. Unknown and
+// already verified addresses are silently ignored so the endpoint can't be
+// used to probe which emails are registered.
+func (s *EmailVerificationService) ResendVerification(email string) error {
+	var user models.User
+	if err := database.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil
+	}
+
+	return s.SendVerification(&user)
+}
+
+// trySendVerification sends a verification link for an address that is
+// already saved. A failed email is only logged, since undoing the change is
+// worse than asking for another link through /api/auth/resend-verification.
+func (s *EmailVerificationService) trySendVerification(user *models.User) {
+	if err := s.SendVerification(user); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+	}
+}
+
+// ResetVerification marks the user's email as unverified, e.g. after it was
+// changed, and sends a token for the new address.
+func (s *EmailVerificationService) ResetVerification(user *models.User) error {
+	user.EmailVerifiedAt = nil
+	if err := database.DB.Model(user).Update("email_verified_at", nil).Error; err != nil {
+		return err
+	}
+
+	s.trySendVerification(user)
+	return nil
+}
+
+func (s *EmailVerificationService) VerifyEmail(token, ipAddress, userAgent string) (*models.User, error) {
+	claims, err := s.jwtService.ValidateEmailVerificationToken(token)
+	if err != nil {
+		return nil, errors.New("invalid or expired verification token")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, claims.UserID).Error; err != nil {
+		return nil, errors.New("invalid or expired verification token")
+	}
+
+	if user.Email != claims.Email {
+		return nil, errors.New("invalid or expired verification token")
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return &user, nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := database.DB.Model(&user).Update("email_verified_at", now).Error; err != nil {
+		return nil, err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "email_verified",
+		Resource:  "auth",
+		Details:   "Verified email " + user.Email,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return &user, nil
+}
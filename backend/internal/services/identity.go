@@ -0,0 +1,73 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+)
+
+// assignSyncedRole moves a user signing in through an identity provider or
+// directory to the primary role its mapping rules picked, recording the
+// change. Roles assigned on top of the primary one are kept.
+func assignSyncedRole(user *models.User, role *models.Role, source, ipAddress, userAgent string) error {
+	if role.ID == user.RoleID {
+		return nil
+	}
+
+	previous := user.Role.Name
+	if err := replacePrimaryRole(user, role); err != nil {
+		return err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "role_synced",
+		Resource:  "users",
+		Details:   fmt.Sprintf("Role changed from %s to %s by %s", previous, role.Name, source),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return nil
+}
+
+// uniqueUsername derives a username for a provisioned user from preferred,
+// or the local part of email, adding a number when it is taken.
+func uniqueUsername(preferred, email string) (string, error) {
+	base := preferred
+	if base == "" || strings.Contains(base, "@") {
+		base = email
+	}
+	base, _, _ = strings.Cut(base, "@")
+
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return -1
+	}, base)
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 2; i < 100; i++ {
+		var count int64
+		if err := database.DB.Unscoped().Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+
+	return "", fmt.Errorf("could not find a free username for %q", base)
+}
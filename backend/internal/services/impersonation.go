@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+)
+
+var (
+	ErrCannotImpersonateSelf    = errors.New("cannot impersonate yourself")
+	ErrImpersonationNotAllowed  = errors.New("cannot impersonate a user at or above your level")
+	ErrImpersonatedUserNotFound = errors.New("user not found")
+	ErrImpersonatedUserInactive = errors.New("cannot impersonate a deactivated user")
+)
+
+// ImpersonationService lets support staff act as another user to see what
+// they see. Impersonation is limited to users the actor may manage, and every
+// request made with the token is logged against both users.
+type ImpersonationService struct {
+	jwtService  *utils.JWTService
+	rbacService *RBACService
+}
+
+func NewImpersonationService(jwtService *utils.JWTService, rbacService *RBACService) *ImpersonationService {
+	return &ImpersonationService{
+		jwtService:  jwtService,
+		rbacService: rbacService,
+	}
+}
+
+// Impersonate issues a time-boxed access token for the target user that also
+// names the actor.
+func (s *ImpersonationService) Impersonate(actorID, targetID uint, ipAddress, userAgent string) (*models.ImpersonationResponse, error) {
+	if actorID == targetID {
+		return nil, ErrCannotImpersonateSelf
+	}
+
+	var actor models.User
+	if err := database.DB.Preload("Role").Preload("Roles").First(&actor, actorID).Error; err != nil {
+		return nil, err
+	}
+
+	var target models.User
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&target, targetID).Error; err != nil {
+		return nil, ErrImpersonatedUserNotFound
+	}
+
+	if err := withInheritedPermissions(&target); err != nil {
+		return nil, err
+	}
+
+	canManage, err := s.rbacService.CanManageUser(actorID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage {
+		return nil, ErrImpersonationNotAllowed
+	}
+
+	if !target.IsActive {
+		return nil, ErrImpersonatedUserInactive
+	}
+
+	accessToken, expiresAt, err := s.jwtService.GenerateImpersonationToken(&target, &actor)
+	if err != nil {
+		return nil, err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:         &target.ID,
+		ImpersonatorID: &actor.ID,
+		Action:         "impersonation_started",
+		Resource:       "users",
+		Details:        fmt.Sprintf("%s started impersonating %s until %s", actor.Username, target.Username, expiresAt.Format(time.RFC3339)),
+		IPAddress:      ipAddress,
+		UserAgent:      userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return &models.ImpersonationResponse{
+		AccessToken:  accessToken,
+		ExpiresAt:    expiresAt,
+		User:         *target.ToResponse(),
+		Impersonator: *actor.ToResponse(),
+	}, nil
+}
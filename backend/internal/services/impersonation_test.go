@@ -0,0 +1,94 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+)
+
+func setupImpersonationTest(t *testing.T) (*services.ImpersonationService, *services.UserService, *models.User, *models.User) {
+	_, user := setupAuthTest(t)
+
+	adminRole := models.Role{Name: "Admin", Level: 30}
+	database.DB.Create(&adminRole)
+	admin := models.User{Email: "admin@example.com", Username: "supportadmin", FirstName: "Support", LastName: "Admin", RoleID: adminRole.ID, IsActive: true}
+	database.DB.Create(&admin)
+
+	rbacService := services.NewRBACService(database.DB)
+	service := services.NewImpersonationService(utils.NewJWTService(testJWTConfig), rbacService)
+	return service, services.NewUserService(rbacService, nil, nil, nil), &admin, user
+}
+
+func TestImpersonation_TokenCarriesActorAndSubject(t *testing.T) {
+	service, userService, admin, user := setupImpersonationTest(t)
+
+	response, err := service.Impersonate(admin.ID, user.ID, "10.0.0.1", "support-console")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, response.User.ID)
+	assert.Equal(t, admin.ID, response.Impersonator.ID)
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), response.ExpiresAt, time.Minute)
+
+	claims, err := utils.NewJWTService(testJWTConfig).ValidateAccessToken(response.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, claims.UserID)
+	assert.Equal(t, admin.ID, claims.ImpersonatorID)
+	assert.Zero(t, claims.SessionID)
+
+	activity, err := userService.GetUserActivityLogs(user.ID, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, activity.Activities, 1)
+	assert.Equal(t, "impersonation_started", activity.Activities[0].Action)
+	require.NotNil(t, activity.Activities[0].Impersonator)
+	assert.Equal(t, "supportadmin", activity.Activities[0].Impersonator.Username)
+
+	activity, err = userService.GetUserActivityLogs(admin.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), activity.Total)
+}
+
+func TestImpersonation_RespectsHierarchy(t *testing.T) {
+	service, _, admin, user := setupImpersonationTest(t)
+
+	_, err := service.Impersonate(user.ID, admin.ID, "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrImpersonationNotAllowed)
+
+	_, err = service.Impersonate(admin.ID, admin.ID, "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrCannotImpersonateSelf)
+
+	_, err = service.Impersonate(admin.ID, 999, "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrImpersonatedUserNotFound)
+
+	database.DB.Model(user).Update("is_active", false)
+	_, err = service.Impersonate(admin.ID, user.ID, "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrImpersonatedUserInactive)
+}
+
+func TestImpersonation_LogoutEndsImpersonation(t *testing.T) {
+	authService, user := setupAuthTest(t)
+	adminRole := models.Role{Name: "Admin", Level: 30}
+	database.DB.Create(&adminRole)
+	admin := models.User{Email: "admin@example.com", Username: "supportadmin", RoleID: adminRole.ID, IsActive: true}
+	database.DB.Create(&admin)
+
+	jwtService := utils.NewJWTService(testJWTConfig)
+	service := services.NewImpersonationService(jwtService, services.NewRBACService(database.DB))
+	response, err := service.Impersonate(admin.ID, user.ID, "10.0.0.1", "test")
+	require.NoError(t, err)
+
+	claims, err := jwtService.ValidateAccessToken(response.AccessToken)
+	require.NoError(t, err)
+	require.NoError(t, authService.Logout(claims, "10.0.0.1", "test"))
+	assert.True(t, authService.IsAccessTokenRevoked(claims, user))
+
+	var ended models.ActivityLog
+	require.NoError(t, database.DB.Where("action = ?", "impersonation_ended").First(&ended).Error)
+	assert.Equal(t, user.ID, *ended.UserID)
+	assert.Equal(t, admin.ID, *ended.ImpersonatorID)
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/mailer"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+)
+
+var (
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrInvitationNotPending = errors.New("invitation has already been accepted or revoked")
+	ErrInvitationInvalid    = errors.New("invalid or expired invitation")
+	ErrInvitationRole       = errors.New("cannot invite users into roles at or above your own rank")
+)
+
+// maxInvitationExpiry bounds how long an invitation link stays valid,
+// whatever the request or INVITATION_EXPIRY ask for.
+const maxInvitationExpiry = 30 * 24 * time.Hour
+
+// InvitationService onboards users by email invitation. An administrator
+// picks the role up front; the invitee accepts with the signed token from the
+// email, which also proves control of the address.
+type InvitationService struct {
+	config      config.RegistrationConfig
+	jwtService  *utils.JWTService
+	authService *AuthService
+	notifier    *mailer.Notifier
+}
+
+func NewInvitationService(cfg config.RegistrationConfig, jwtService *utils.JWTService, authService *AuthService, notifier *mailer.Notifier) *InvitationService {
+	return &InvitationService{
+		config:      cfg,
+		jwtService:  jwtService,
+		authService: authService,
+		notifier:    notifier,
+	}
+}
+
+// GetPendingInvitations lists the invitations that have been neither
+// accepted nor revoked, including expired ones that can still be resent.
+func (s *InvitationService) GetPendingInvitations() ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := database.DB.Preload("Role").
+		Where("accepted_at IS NULL AND revoked_at IS NULL").
+		Order("created_at DESC").Find(&invitations).Error
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func (s *InvitationService) GetInvitation(id uint) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := database.DB.Preload("Role").First(&invitation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// CreateInvitation records the invitation and emails its link. Only one
+// pending invitation may exist per email; resend it instead.
+func (s *InvitationService) CreateInvitation(inviterID uint, req *models.InvitationInput, ipAddress, userAgent string) (*models.Invitation, error) {
+	if s.config.Mode == "closed" {
+		return nil, ErrRegistrationClosed
+	}
+
+	email := strings.TrimSpace(req.Email)
+
+	var count int64
+	database.DB.Model(&models.User{}).Where("email = ?", email).Count(&count)
+	if count > 0 {
+		return nil, errors.New("user with this email already exists")
+	}
+
+	database.DB.Model(&models.Invitation{}).
+		Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", email, time.Now()).
+		Count(&count)
+	if count > 0 {
+		return nil, errors.New("a pending invitation already exists for this email")
+	}
+
+	var role models.Role
+	if err := database.DB.First(&role, req.RoleID).Error; err != nil {
+		return nil, errors.New("role not found")
+	}
+	if err := checkInviterRank(inviterID, &role); err != nil {
+		return nil, err
+	}
+
+	expiry := s.config.InvitationExpiry
+	if req.ExpiresInHours > 0 {
+		expiry = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	if expiry > maxInvitationExpiry {
+		expiry = maxInvitationExpiry
+	}
+
+	invitation := models.Invitation{
+		Email:       email,
+		RoleID:      role.ID,
+		InvitedByID: &inviterID,
+		Message:     strings.TrimSpace(req.Message),
+		ExpiresAt:   time.Now().Add(expiry),
+		Role:        role,
+	}
+
+	token, err := s.issue(&invitation, func(tx *gorm.DB) error {
+		return tx.Omit("Role").Create(&invitation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logActivity(inviterID, "invitation_created", fmt.Sprintf("Invited %s as %s", invitation.Email, role.Name), ipAddress, userAgent)
+
+	if err := s.notifier.SendInvitation(&invitation, s.inviterName(inviterID), token); err != nil {
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// ResendInvitation emails a fresh link with a new expiry. The previous link
+// stops working, which also makes resending the way to recover an expired
+// invitation.
+func (s *InvitationService) ResendInvitation(id, actorID uint, ipAddress, userAgent string) (*models.Invitation, error) {
+	invitation, err := s.GetInvitation(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		return nil, ErrInvitationNotPending
+	}
+	if err := checkInviterRank(actorID, &invitation.Role); err != nil {
+		return nil, err
+	}
+
+	expiry := s.config.InvitationExpiry
+	if expiry > maxInvitationExpiry {
+		expiry = maxInvitationExpiry
+	}
+	invitation.ExpiresAt = time.Now().Add(expiry)
+
+	token, err := s.issue(invitation, func(tx *gorm.DB) error {
+		return tx.Model(invitation).Update("expires_at", invitation.ExpiresAt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logActivity(actorID, "invitation_resent", fmt.Sprintf("Resent invitation for %s", invitation.Email), ipAddress, userAgent)
+
+	if err := s.notifier.SendInvitation(invitation, s.inviterName(actorID), token); err != nil {
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+func (s *InvitationService) RevokeInvitation(id, actorID uint, ipAddress, userAgent string) error {
+	invitation, err := s.GetInvitation(id)
+	if err != nil {
+		return err
+	}
+
+	result := database.DB.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationNotPending
+	}
+
+	s.logActivity(actorID, "invitation_revoked", fmt.Sprintf("Revoked invitation for %s", invitation.Email), ipAddress, userAgent)
+	return nil
+}
+
+// AcceptInvitation creates the invited account with the pre-selected role and
+// the chosen password, then logs it in like any other login, so MFA
+// requirements of the role still apply.
+func (s *InvitationService) AcceptInvitation(req *models.AcceptInvitationRequest, ipAddress, userAgent string) (*models.TokenResponse, *models.AuthChallenge, error) {
+	if s.config.Mode == "closed" {
+		return nil, nil, ErrRegistrationClosed
+	}
+
+	claims, err := s.jwtService.ValidateInvitationToken(req.Token)
+	if err != nil {
+		return nil, nil, ErrInvitationInvalid
+	}
+
+	var invitation models.Invitation
+	if err := database.DB.First(&invitation, claims.InvitationID).Error; err != nil {
+		return nil, nil, ErrInvitationInvalid
+	}
+	if invitation.TokenID != claims.ID || !invitation.Pending() {
+		return nil, nil, ErrInvitationInvalid
+	}
+
+	var existingUser models.User
+	if err := database.DB.Where("email = ? OR username = ?", invitation.Email, req.Username).First(&existingUser).Error; err == nil {
+		return nil, nil, errors.New("user with this email or username already exists")
+	}
+
+	if err := utils.ValidatePassword(req.Password, req.Username, invitation.Email); err != nil {
+		return nil, nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	user := models.User{
+		Email:             invitation.Email,
+		Username:          req.Username,
+		PasswordHash:      hashedPassword,
+		PasswordChangedAt: &now,
+		FirstName:         req.FirstName,
+		LastName:          req.LastName,
+		RoleID:            invitation.RoleID,
+		Roles:             []models.Role{{ID: invitation.RoleID}},
+		IsActive:          true,
+		EmailVerifiedAt:   &now,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Roles.*").Create(&user).Error; err != nil {
+			return err
+		}
+
+		// The conditional update makes acceptance single-use even when the
+		// same link is submitted twice at once.
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND token_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID, claims.ID).
+			Updates(map[string]interface{}{"accepted_at": now, "accepted_user_id": user.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	details := fmt.Sprintf("Accepted invitation %d", invitation.ID)
+	if invitation.InvitedByID != nil {
+		details = fmt.Sprintf("Accepted invitation %d from user %d", invitation.ID, *invitation.InvitedByID)
+	}
+	s.logActivity(user.ID, "invitation_accepted", details, ipAddress, userAgent)
+
+	s.notifier.SendWelcome(&user)
+
+	return s.authService.LoginWithIdentity(&user, ipAddress, userAgent)
+}
+
+// issue signs a new token for the invitation and stores its jti together
+// with the change made by save, so the invitation only ever accepts the
+// latest link.
+func (s *InvitationService) issue(invitation *models.Invitation, save func(tx *gorm.DB) error) (string, error) {
+	var token string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := save(tx); err != nil {
+			return err
+		}
+
+		var tokenID string
+		var err error
+		token, tokenID, err = s.jwtService.GenerateInvitationToken(invitation)
+		if err != nil {
+			return err
+		}
+
+		invitation.TokenID = tokenID
+		invitation.LastSentAt = time.Now()
+		return tx.Model(invitation).Omit("Role").Updates(map[string]interface{}{
+			"token_id":     invitation.TokenID,
+			"last_sent_at": invitation.LastSentAt,
+		}).Error
+	})
+	return token, err
+}
+
+// checkInviterRank lets users only invite into roles they could manage, the
+// same ranking CanManageUser applies.
+func checkInviterRank(inviterID uint, role *models.Role) error {
+	var inviter models.User
+	if err := database.DB.Preload("Role").Preload("Roles").First(&inviter, inviterID).Error; err != nil {
+		return err
+	}
+	if !outranksRoles(inviter.AllRoles(), []models.Role{*role}) {
+		return ErrInvitationRole
+	}
+	return nil
+}
+
+func (s *InvitationService) inviterName(inviterID uint) string {
+	var inviter models.User
+	if err := database.DB.First(&inviter, inviterID).Error; err != nil {
+		return ""
+	}
+
+	name := strings.TrimSpace(inviter.FirstName + " " + inviter.LastName)
+	if name == "" {
+		name = inviter.Username
+	}
+	return name
+}
+
+func (s *InvitationService) logActivity(userID uint, action, details, ipAddress, userAgent string) {
+	activityLog := models.ActivityLog{
+		UserID:    &userID,
+		Action:    action,
+		Resource:  "invitations",
+		Details:   details,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+}
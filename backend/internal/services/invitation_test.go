@@ -0,0 +1,206 @@
+package services_test
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/mailer"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+)
+
+type recordingMailer struct {
+	messages []*mailer.Message
+}
+
+func (m *recordingMailer) Send(msg *mailer.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// lastLinkToken returns the token of the path link in the most recent email.
+func (m *recordingMailer) lastLinkToken(t *testing.T, path string) string {
+	require.NotEmpty(t, m.messages)
+	match := regexp.MustCompile(regexp.QuoteMeta(path) + `\?token=(\S+)`).FindStringSubmatch(m.messages[len(m.messages)-1].Text)
+	require.NotNil(t, match)
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func setupInvitationTest(t *testing.T, mode string) (*services.InvitationService, *recordingMailer, *models.User, *models.Role) {
+	authService, admin := setupAuthTest(t)
+	require.NoError(t, database.DB.AutoMigrate(&models.Invitation{}))
+	database.DB.Model(&models.Role{}).Where("id = ?", admin.RoleID).Update("level", 30)
+
+	role := models.Role{Name: "Editor"}
+	database.DB.Create(&role)
+
+	mail := &recordingMailer{}
+	service := services.NewInvitationService(
+		config.RegistrationConfig{Mode: mode, InvitationExpiry: 24 * time.Hour},
+		utils.NewJWTService(testJWTConfig),
+		authService,
+		mailer.NewNotifier(mail, "RBAC System", "http://localhost:3000"),
+	)
+	return service, mail, admin, &role
+}
+
+func acceptRequest(token string) *models.AcceptInvitationRequest {
+	return &models.AcceptInvitationRequest{
+		Token:     token,
+		Username:  "newhire",
+		Password:  "Corr3ct-Horse-Battery",
+		FirstName: "New",
+		LastName:  "Hire",
+	}
+}
+
+func TestInvitation_CreateAndAccept(t *testing.T) {
+	service, mail, admin, role := setupInvitationTest(t, "invite_only")
+
+	invitation, err := service.CreateInvitation(admin.ID, &models.InvitationInput{Email: "new@example.com", RoleID: role.ID, Message: "Welcome aboard"}, "10.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "Editor", invitation.Role.Name)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), invitation.ExpiresAt, time.Minute)
+
+	require.Len(t, mail.messages, 1)
+	assert.Equal(t, []string{"new@example.com"}, mail.messages[0].To)
+	assert.Contains(t, mail.messages[0].Text, "Regular User has invited you to join RBAC System as Editor")
+	assert.Contains(t, mail.messages[0].Text, "Welcome aboard")
+
+	_, err = service.CreateInvitation(admin.ID, &models.InvitationInput{Email: "new@example.com", RoleID: role.ID}, "10.0.0.1", "test")
+	assert.Error(t, err)
+
+	pending, err := service.GetPendingInvitations()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	token := mail.lastLinkToken(t, "/accept-invitation")
+	response, challenge, err := service.AcceptInvitation(acceptRequest(token), "10.0.0.2", "browser")
+	require.NoError(t, err)
+	require.Nil(t, challenge)
+	assert.NotEmpty(t, response.AccessToken)
+	assert.Equal(t, "new@example.com", response.User.Email)
+	assert.Equal(t, role.ID, response.User.RoleID)
+	assert.NotNil(t, response.User.EmailVerifiedAt)
+
+	_, _, err = service.AcceptInvitation(acceptRequest(token), "10.0.0.2", "browser")
+	assert.ErrorIs(t, err, services.ErrInvitationInvalid)
+
+	pending, err = service.GetPendingInvitations()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	var accepted int64
+	database.DB.Model(&models.ActivityLog{}).Where("user_id = ? AND action = ?", response.User.ID, "invitation_accepted").Count(&accepted)
+	assert.Equal(t, int64(1), accepted)
+}
+
+func TestInvitation_ResendInvalidatesPreviousLink(t *testing.T) {
+	service, mail, admin, role := setupInvitationTest(t, "open")
+
+	invitation, err := service.CreateInvitation(admin.ID, &models.InvitationInput{Email: "new@example.com", RoleID: role.ID, ExpiresInHours: 1}, "10.0.0.1", "test")
+	require.NoError(t, err)
+	firstToken := mail.lastLinkToken(t, "/accept-invitation")
+
+	resent, err := service.ResendInvitation(invitation.ID, admin.ID, "10.0.0.1", "test")
+	require.NoError(t, err)
+	assert.True(t, resent.ExpiresAt.After(invitation.ExpiresAt))
+	require.Len(t, mail.messages, 2)
+
+	_, _, err = service.AcceptInvitation(acceptRequest(firstToken), "10.0.0.2", "browser")
+	assert.ErrorIs(t, err, services.ErrInvitationInvalid)
+
+	_, _, err = service.AcceptInvitation(acceptRequest(mail.lastLinkToken(t, "/accept-invitation")), "10.0.0.2", "browser")
+	require.NoError(t, err)
+
+	_, err = service.ResendInvitation(invitation.ID, admin.ID, "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrInvitationNotPending)
+}
+
+func TestInvitation_RevokeAndExpiry(t *testing.T) {
+	service, mail, admin, role := setupInvitationTest(t, "open")
+
+	invitation, err := service.CreateInvitation(admin.ID, &models.InvitationInput{Email: "new@example.com", RoleID: role.ID}, "10.0.0.1", "test")
+	require.NoError(t, err)
+	token := mail.lastLinkToken(t, "/accept-invitation")
+
+	require.NoError(t, service.RevokeInvitation(invitation.ID, admin.ID, "10.0.0.1", "test"))
+	assert.ErrorIs(t, service.RevokeInvitation(invitation.ID, admin.ID, "10.0.0.1", "test"), services.ErrInvitationNotPending)
+
+	_, _, err = service.AcceptInvitation(acceptRequest(token), "10.0.0.2", "browser")
+	assert.ErrorIs(t, err, services.ErrInvitationInvalid)
+
+	expired, err := service.CreateInvitation(admin.ID, &models.InvitationInput{Email: "late@example.com", RoleID: role.ID}, "10.0.0.1", "test")
+	require.NoError(t, err)
+	database.DB.Model(expired).Update("expires_at", time.Now().Add(-time.Minute))
+
+	_, _, err = service.AcceptInvitation(acceptRequest(mail.lastLinkToken(t, "/accept-invitation")), "10.0.0.2", "browser")
+	assert.ErrorIs(t, err, services.ErrInvitationInvalid)
+
+	var logs int64
+	database.DB.Model(&models.ActivityLog{}).Where("user_id = ? AND resource = ?", admin.ID, "invitations").Count(&logs)
+	assert.Equal(t, int64(3), logs)
+}
+
+func TestInvitation_RoleRankAndExpiryCap(t *testing.T) {
+	service, _, admin, role := setupInvitationTest(t, "open")
+
+	superAdminRole := models.Role{Name: "Super Admin", Level: 40}
+	database.DB.Create(&superAdminRole)
+	_, err := service.CreateInvitation(admin.ID, &models.InvitationInput{Email: "boss@example.com", RoleID: superAdminRole.ID}, "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrInvitationRole)
+	_, err = service.CreateInvitation(admin.ID, &models.InvitationInput{Email: "peer@example.com", RoleID: admin.RoleID}, "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrInvitationRole)
+
+	capped := services.NewInvitationService(
+		config.RegistrationConfig{Mode: "open", InvitationExpiry: 10000 * time.Hour},
+		utils.NewJWTService(testJWTConfig),
+		nil,
+		mailer.NewNotifier(&recordingMailer{}, "RBAC System", "http://localhost:3000"),
+	)
+	invitation, err := capped.CreateInvitation(admin.ID, &models.InvitationInput{Email: "late@example.com", RoleID: role.ID}, "10.0.0.1", "test")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), invitation.ExpiresAt, time.Minute)
+}
+
+func TestInvitation_ClosedRegistration(t *testing.T) {
+	service, _, admin, role := setupInvitationTest(t, "closed")
+
+	_, err := service.CreateInvitation(admin.ID, &models.InvitationInput{Email: "new@example.com", RoleID: role.ID}, "10.0.0.1", "test")
+	assert.ErrorIs(t, err, services.ErrRegistrationClosed)
+}
+
+func TestAuthService_RegistrationMode(t *testing.T) {
+	authService, _ := setupAuthTest(t)
+
+	register := func(email string) error {
+		_, err := authService.Register(&models.RegisterRequest{
+			Email:     email,
+			Username:  "u" + email[:3],
+			Password:  "Corr3ct-Horse-Battery",
+			FirstName: "New",
+			LastName:  "User",
+		}, "10.0.0.1", "test")
+		return err
+	}
+
+	authService.WithRegistration(config.RegistrationConfig{Mode: "invite_only"})
+	assert.ErrorIs(t, register("one@example.com"), services.ErrInvitationRequired)
+
+	authService.WithRegistration(config.RegistrationConfig{Mode: "closed"})
+	assert.ErrorIs(t, register("one@example.com"), services.ErrRegistrationClosed)
+
+	authService.WithRegistration(config.RegistrationConfig{Mode: "open", AllowedDomains: []string{"corp.example"}})
+	assert.ErrorIs(t, register("one@example.com"), services.ErrEmailDomainNotAllowed)
+	assert.NoError(t, register("two@CORP.example"))
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/directory"
+	"rbac-system/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLDAPNoAccount = errors.New("no account is linked to this directory user")
+	ErrLDAPNoRole    = errors.New("no role mapping matches this directory user's groups")
+	// ErrDirectoryPassword refuses local password changes for users whose
+	// password belongs to a directory.
+	ErrDirectoryPassword = errors.New("password is managed by the user's directory")
+)
+
+// ldapIssuerPrefix starts the issuer of every directory identity, followed
+// by the directory's name.
+const ldapIssuerPrefix = "ldap:"
+
+// hasDirectoryIdentity reports whether the user is linked to a directory
+// entry. Their password is the directory's, so the local hash never stands in
+// for it, not even once the directory no longer knows them.
+func hasDirectoryIdentity(userID uint) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.UserIdentity{}).
+		Where("user_id = ? AND issuer LIKE ?", userID, ldapIssuerPrefix+"%").Count(&count).Error
+	return count > 0, err
+}
+
+type ldapDirectory struct {
+	config config.LDAPDirectoryConfig
+	client *directory.Client
+}
+
+// LDAPService is the DirectoryAuthenticator for LDAP and Active Directory.
+// Directory users are matched to local users by DN, then by email, and
+// otherwise provisioned; their names, email and (with SyncRoles) role follow
+// the directory on every login.
+type LDAPService struct {
+	directories []*ldapDirectory
+}
+
+func NewLDAPService(cfg config.LDAPConfig) (*LDAPService, error) {
+	service := &LDAPService{}
+	for _, directoryConfig := range cfg.Directories {
+		client, err := directory.NewClient(directoryConfig)
+		if err != nil {
+			return nil, fmt.Errorf("LDAP directory %s: %w", directoryConfig.Name, err)
+		}
+		service.directories = append(service.directories, &ldapDirectory{config: directoryConfig, client: client})
+	}
+	return service, nil
+}
+
+// Authenticate tries the directories responsible for the email's domain, or
+// every directory without domains in order. Unreachable directories are
+// skipped; a directory that knows the user but refuses the password ends
+// the attempt.
+func (s *LDAPService) Authenticate(email, password, ipAddress, userAgent string) (*models.User, error) {
+	for _, dir := range s.candidates(email) {
+		entry, err := dir.client.Authenticate(email, password)
+		switch {
+		case errors.Is(err, directory.ErrUserNotFound):
+			continue
+		case errors.Is(err, directory.ErrInvalidCredentials):
+			return nil, ErrDirectoryInvalidCredentials
+		case err != nil:
+			log.Printf("LDAP directory %s failed: %v", dir.config.Name, err)
+			continue
+		}
+
+		return s.syncUser(dir, entry, ipAddress, userAgent)
+	}
+
+	return nil, ErrDirectoryUserNotFound
+}
+
+// Verify checks the password of a user already linked to a directory by
+// binding as their entry. Nothing is provisioned or synced, and an entry
+// linked to another local user, or to none, is refused.
+func (s *LDAPService) Verify(user *models.User, password string) error {
+	for _, dir := range s.candidates(user.Email) {
+		entry, err := dir.client.Authenticate(user.Email, password)
+		switch {
+		case errors.Is(err, directory.ErrUserNotFound):
+			continue
+		case errors.Is(err, directory.ErrInvalidCredentials):
+			return ErrDirectoryInvalidCredentials
+		case err != nil:
+			log.Printf("LDAP directory %s failed: %v", dir.config.Name, err)
+			continue
+		}
+
+		var identity models.UserIdentity
+		err = database.DB.Where("issuer = ? AND subject = ?", ldapIssuerPrefix+dir.config.Name, entry.DN).First(&identity).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && identity.UserID != user.ID) {
+			return ErrDirectoryInvalidCredentials
+		}
+		return err
+	}
+
+	return ErrDirectoryUserNotFound
+}
+
+func (s *LDAPService) candidates(email string) []*ldapDirectory {
+	_, domain, _ := strings.Cut(strings.ToLower(email), "@")
+
+	var matched, fallback []*ldapDirectory
+	for _, dir := range s.directories {
+		if len(dir.config.Domains) == 0 {
+			fallback = append(fallback, dir)
+			continue
+		}
+		for _, directoryDomain := range dir.config.Domains {
+			if directoryDomain == domain {
+				matched = append(matched, dir)
+				break
+			}
+		}
+	}
+
+	if len(matched) > 0 {
+		return matched
+	}
+	return fallback
+}
+
+// syncUser finds or provisions the local user for a directory entry and
+// brings it up to date.
+func (s *LDAPService) syncUser(dir *ldapDirectory, entry *directory.Entry, ipAddress, userAgent string) (*models.User, error) {
+	issuer := ldapIssuerPrefix + dir.config.Name
+	now := time.Now()
+
+	var identity models.UserIdentity
+	err := database.DB.Preload("User.Role").Where("issuer = ? AND subject = ?", issuer, entry.DN).First(&identity).Error
+	if err == nil {
+		// A deleted user stays out rather than being provisioned again.
+		if identity.User.ID == 0 {
+			return nil, ErrLDAPNoAccount
+		}
+
+		database.DB.Model(&identity).Updates(map[string]interface{}{"email": entry.Email, "last_login_at": now})
+		return s.updateUser(dir, &identity.User, entry, ipAddress, userAgent)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var user models.User
+	err = database.DB.Preload("Role").Where("email = ?", entry.Email).First(&user).Error
+	if err == nil {
+		identity := models.UserIdentity{UserID: user.ID, Issuer: issuer, Subject: entry.DN, Email: entry.Email, LastLoginAt: &now}
+		if err := database.DB.Create(&identity).Error; err != nil {
+			return nil, err
+		}
+
+		activityLog := models.ActivityLog{
+			UserID:    &user.ID,
+			Action:    "identity_linked",
+			Resource:  "auth",
+			Details:   fmt.Sprintf("Linked directory entry %s from %s by email", entry.DN, dir.config.Name),
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+		}
+		database.DB.Create(&activityLog)
+
+		return s.updateUser(dir, &user, entry, ipAddress, userAgent)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if !dir.config.AutoProvision {
+		return nil, ErrLDAPNoAccount
+	}
+
+	return s.provisionUser(dir, issuer, entry, ipAddress, userAgent)
+}
+
+// updateUser copies the directory's names and email onto the user, marks
+// the email verified since the directory manages it and, with SyncRoles,
+// re-applies the group mapping.
+func (s *LDAPService) updateUser(dir *ldapDirectory, user *models.User, entry *directory.Entry, ipAddress, userAgent string) (*models.User, error) {
+	updates := map[string]interface{}{}
+	if entry.FirstName != "" && entry.FirstName != user.FirstName {
+		updates["first_name"] = entry.FirstName
+	}
+	if entry.LastName != "" && entry.LastName != user.LastName {
+		updates["last_name"] = entry.LastName
+	}
+	if entry.Email != "" && !strings.EqualFold(entry.Email, user.Email) {
+		var taken int64
+		database.DB.Unscoped().Model(&models.User{}).Where("email = ? AND id <> ?", entry.Email, user.ID).Count(&taken)
+		if taken == 0 {
+			updates["email"] = entry.Email
+		}
+	}
+	if user.EmailVerifiedAt == nil {
+		updates["email_verified_at"] = time.Now()
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(user).Omit("Role").Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if dir.config.SyncRoles {
+		role, err := s.mapRole(dir, entry)
+		if err != nil {
+			return nil, err
+		}
+		if err := assignSyncedRole(user, role, "directory groups", ipAddress, userAgent); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// provisionUser creates a local user for a directory entry. The user has no
+// local password; the directory stays responsible for it.
+func (s *LDAPService) provisionUser(dir *ldapDirectory, issuer string, entry *directory.Entry, ipAddress, userAgent string) (*models.User, error) {
+	role, err := s.mapRole(dir, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := uniqueUsername(entry.Username, entry.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	firstName := entry.FirstName
+	if firstName == "" {
+		firstName = username
+	}
+
+	now := time.Now()
+	user := models.User{
+		Email:           entry.Email,
+		Username:        username,
+		FirstName:       firstName,
+		LastName:        entry.LastName,
+		RoleID:          role.ID,
+		Roles:           []models.Role{*role},
+		IsActive:        true,
+		EmailVerifiedAt: &now,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Roles.*").Create(&user).Error; err != nil {
+			return err
+		}
+		identity := models.UserIdentity{UserID: user.ID, Issuer: issuer, Subject: entry.DN, Email: entry.Email, LastLoginAt: &now}
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "user_provisioned",
+		Resource:  "auth",
+		Details:   fmt.Sprintf("Provisioned from directory %s with role %s", dir.config.Name, role.Name),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return &user, nil
+}
+
+// mapRole returns the role of the first group rule the entry matches, or the
+// directory's default role.
+func (s *LDAPService) mapRole(dir *ldapDirectory, entry *directory.Entry) (*models.Role, error) {
+	roleName := dir.config.DefaultRole
+	for _, rule := range dir.config.GroupRoles {
+		if entry.MemberOf(rule.GroupDN) {
+			roleName = rule.Role
+			break
+		}
+	}
+
+	if roleName == "" {
+		return nil, ErrLDAPNoRole
+	}
+
+	var role models.Role
+	if err := database.DB.Where("name = ?", roleName).First(&role).Error; err != nil {
+		return nil, fmt.Errorf("role %q from LDAP group mapping not found", roleName)
+	}
+	return &role, nil
+}
@@ -0,0 +1,192 @@
+package services_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/directory/ldaptest"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+)
+
+const ldapAdminsGroup = "cn=admins,ou=groups,dc=corp,dc=example"
+
+func setupLDAPTest(t *testing.T, configure func(*config.LDAPDirectoryConfig)) (*services.AuthService, *ldaptest.Server, *models.User) {
+	authService, user := setupAuthTest(t)
+	require.NoError(t, database.DB.AutoMigrate(&models.UserIdentity{}))
+	database.DB.Create(&models.Role{Name: "Admin", Description: "Admin Role"})
+
+	server, err := ldaptest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	server.RequireTLS = true
+	server.AddEntry("cn=service,dc=corp,dc=example", map[string][]string{"userPassword": {"service-secret"}})
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, server.CACertPEM, 0o600))
+
+	cfg := config.LDAPDirectoryConfig{
+		Name:               "corp",
+		URL:                server.URL,
+		StartTLS:           true,
+		CACertFile:         caFile,
+		Timeout:            5 * time.Second,
+		BindDN:             "cn=service,dc=corp,dc=example",
+		BindPassword:       "service-secret",
+		BaseDN:             "dc=corp,dc=example",
+		UserFilter:         "(mail={email})",
+		UsernameAttribute:  "uid",
+		EmailAttribute:     "mail",
+		FirstNameAttribute: "givenName",
+		LastNameAttribute:  "sn",
+		GroupAttribute:     "memberOf",
+		GroupRoles:         []config.LDAPGroupRole{{GroupDN: ldapAdminsGroup, Role: "Admin"}},
+		DefaultRole:        "User",
+		AutoProvision:      true,
+		SyncRoles:          true,
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	ldapService, err := services.NewLDAPService(config.LDAPConfig{Directories: []config.LDAPDirectoryConfig{cfg}})
+	require.NoError(t, err)
+	authService.WithDirectory(ldapService)
+
+	return authService, server, user
+}
+
+func addLDAPUser(server *ldaptest.Server, uid, email, password string, groups ...string) string {
+	dn := "uid=" + uid + ",ou=people,dc=corp,dc=example"
+	server.AddEntry(dn, map[string][]string{
+		"uid":          {uid},
+		"mail":         {email},
+		"givenName":    {"Dir"},
+		"sn":           {"User"},
+		"memberOf":     groups,
+		"userPassword": {password},
+	})
+	return dn
+}
+
+func TestLDAP_ProvisionsUserWithGroupRole(t *testing.T) {
+	authService, server, _ := setupLDAPTest(t, nil)
+	dn := addLDAPUser(server, "carol", "carol@corp.example", "carol-secret", ldapAdminsGroup)
+
+	response, challenge, err := authService.Login(&models.LoginRequest{Email: "carol@corp.example", Password: "carol-secret"}, "10.0.0.1", "browser")
+	require.NoError(t, err)
+	require.Nil(t, challenge)
+	assert.Equal(t, "carol", response.User.Username)
+	assert.Contains(t, server.Binds(), dn)
+
+	var user models.User
+	require.NoError(t, database.DB.Preload("Role").Where("email = ?", "carol@corp.example").First(&user).Error)
+	assert.Equal(t, "Admin", user.Role.Name)
+	assert.Equal(t, "Dir", user.FirstName)
+	assert.Empty(t, user.PasswordHash)
+	assert.NotNil(t, user.EmailVerifiedAt)
+
+	var identity models.UserIdentity
+	require.NoError(t, database.DB.Where("issuer = ? AND subject = ?", "ldap:corp", dn).First(&identity).Error)
+	assert.Equal(t, user.ID, identity.UserID)
+
+	_, _, err = authService.Login(&models.LoginRequest{Email: "carol@corp.example", Password: "wrong"}, "10.0.0.1", "browser")
+	assert.Error(t, err)
+}
+
+func TestLDAP_LinksExistingUserAndSyncsRole(t *testing.T) {
+	authService, server, user := setupLDAPTest(t, nil)
+	addLDAPUser(server, "regular", user.Email, "directory-secret", ldapAdminsGroup)
+
+	_, _, err := authService.Login(&models.LoginRequest{Email: user.Email, Password: "directory-secret"}, "10.0.0.1", "browser")
+	require.NoError(t, err)
+
+	var linked models.User
+	require.NoError(t, database.DB.Preload("Role").First(&linked, user.ID).Error)
+	assert.Equal(t, "Admin", linked.Role.Name)
+	assert.Equal(t, "Dir", linked.FirstName)
+
+	var synced int64
+	database.DB.Model(&models.ActivityLog{}).Where("user_id = ? AND action = ?", user.ID, "role_synced").Count(&synced)
+	assert.Equal(t, int64(1), synced)
+
+	// The directory password now decides; the old local one is refused.
+	_, _, err = authService.Login(&models.LoginRequest{Email: user.Email, Password: "password123"}, "10.0.0.1", "browser")
+	assert.Error(t, err)
+}
+
+func TestLDAP_RemovedUserCannotUseLocalPassword(t *testing.T) {
+	authService, server, user := setupLDAPTest(t, nil)
+	dn := addLDAPUser(server, "regular", user.Email, "directory-secret")
+
+	_, _, err := authService.Login(&models.LoginRequest{Email: user.Email, Password: "directory-secret"}, "10.0.0.1", "browser")
+	require.NoError(t, err)
+
+	server.RemoveEntry(dn)
+	_, _, err = authService.Login(&models.LoginRequest{Email: user.Email, Password: "password123"}, "10.0.0.1", "browser")
+	assert.EqualError(t, err, "invalid credentials", "the local hash doesn't outlive the directory account")
+
+	passwordService := services.NewPasswordService(nil, 3)
+	_, err = passwordService.CreateResetToken(user.Email)
+	assert.ErrorIs(t, err, services.ErrDirectoryPassword)
+	assert.ErrorIs(t, passwordService.SetPassword(user, "N3w-Passw0rd!"), services.ErrDirectoryPassword)
+}
+
+func TestLDAP_StepUpOnlyChecksThePassword(t *testing.T) {
+	authService, server, user := setupLDAPTest(t, nil)
+	addLDAPUser(server, "regular", user.Email, "directory-secret")
+
+	response, _, err := authService.Login(&models.LoginRequest{Email: user.Email, Password: "directory-secret"}, "10.0.0.1", "browser")
+	require.NoError(t, err)
+	claims, err := utils.NewJWTService(testJWTConfig).ValidateAccessToken(response.AccessToken)
+	require.NoError(t, err)
+
+	// A step-up binds without syncing the user from the directory.
+	database.DB.Model(user).Update("first_name", "Local")
+	_, err = authService.StepUp(claims, &models.StepUpRequest{Password: "directory-secret"}, "10.0.0.1", "browser")
+	require.NoError(t, err)
+
+	var reloaded models.User
+	require.NoError(t, database.DB.First(&reloaded, user.ID).Error)
+	assert.Equal(t, "Local", reloaded.FirstName)
+
+	_, err = authService.StepUp(claims, &models.StepUpRequest{Password: "password123"}, "10.0.0.1", "browser")
+	assert.ErrorIs(t, err, services.ErrStepUpFailed, "the local password doesn't count for directory users")
+}
+
+func TestLDAP_FallsBackToLocalPassword(t *testing.T) {
+	authService, _, user := setupLDAPTest(t, nil)
+
+	login(t, authService, user, "10.0.0.1", "browser")
+}
+
+func TestLDAP_RoutesByDomain(t *testing.T) {
+	authService, server, user := setupLDAPTest(t, func(cfg *config.LDAPDirectoryConfig) {
+		cfg.Domains = []string{"corp.example"}
+	})
+	dn := addLDAPUser(server, "regular", user.Email, "directory-secret")
+
+	// example.com is not a directory domain, so the directory is not asked.
+	login(t, authService, user, "10.0.0.1", "browser")
+	assert.NotContains(t, server.Binds(), dn)
+	_, _, err := authService.Login(&models.LoginRequest{Email: user.Email, Password: "directory-secret"}, "10.0.0.1", "browser")
+	assert.Error(t, err)
+}
+
+func TestLDAP_NoProvisioningWithoutAutoProvision(t *testing.T) {
+	authService, server, _ := setupLDAPTest(t, func(cfg *config.LDAPDirectoryConfig) {
+		cfg.AutoProvision = false
+	})
+	addLDAPUser(server, "dave", "dave@corp.example", "dave-secret")
+
+	_, _, err := authService.Login(&models.LoginRequest{Email: "dave@corp.example", Password: "dave-secret"}, "10.0.0.1", "browser")
+	assert.ErrorIs(t, err, services.ErrLDAPNoAccount)
+}
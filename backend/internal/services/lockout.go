@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/mailer"
+	"rbac-system/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAccountLocked        = errors.New("account is temporarily locked due to too many failed login attempts")
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts, please try again later")
+)
+
+// LockoutService throttles password guessing. Accounts are locked after
+// MaxFailedAttempts consecutive failures, for a period that doubles with each
+// lockout, and source IPs are refused once they fail too often in IPWindow.
+type LockoutService struct {
+	config   config.LockoutConfig
+	notifier *mailer.Notifier
+}
+
+func NewLockoutService(cfg config.LockoutConfig, notifier *mailer.Notifier) *LockoutService {
+	return &LockoutService{
+		config:   cfg,
+		notifier: notifier,
+	}
+}
+
+// CheckIP refuses the attempt when the address has already failed too many
+// times within the window, and logs the refusal.
+func (s *LockoutService) CheckIP(email, ipAddress, userAgent string) error {
+	if s.config.IPMaxFailedAttempts <= 0 {
+		return nil
+	}
+
+	var failures int64
+	if err := database.DB.Model(&models.LoginAttempt{}).
+		Where("ip_address = ? AND success = ? AND created_at > ?", ipAddress, false, time.Now().Add(-s.config.IPWindow)).
+		Count(&failures).Error; err != nil {
+		return err
+	}
+
+	if failures >= int64(s.config.IPMaxFailedAttempts) {
+		activityLog := models.ActivityLog{
+			Action:    "login_throttled",
+			Resource:  "auth",
+			Details:   fmt.Sprintf("Login for %s refused after %d failed attempts from this address", email, failures),
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+		}
+		database.DB.Create(&activityLog)
+
+		return ErrTooManyLoginAttempts
+	}
+
+	return nil
+}
+
+func (s *LockoutService) IsLocked(user *models.User) bool {
+	return user.LockedUntil != nil && time.Now().Before(*user.LockedUntil)
+}
+
+// RecordFailure counts a failed attempt against the IP and, when the user is
+// known, against the account, locking it once the threshold is reached.
+// Failures for unknown emails are logged without a user.
+func (s *LockoutService) RecordFailure(user *models.User, email, reason, ipAddress, userAgent string) {
+	attempt := models.LoginAttempt{
+		Email:     email,
+		IPAddress: ipAddress,
+		Success:   false,
+	}
+	activityLog := models.ActivityLog{
+		Action:    "login_failed",
+		Resource:  "auth",
+		Details:   reason,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+		activityLog.UserID = &user.ID
+	} else {
+		activityLog.Details = reason + ": " + email
+	}
+	database.DB.Create(&attempt)
+	database.DB.Create(&activityLog)
+
+	if user == nil {
+		return
+	}
+
+	// A locked account is rejected before the password is checked, so only
+	// failures while unlocked count towards the next lockout.
+	if s.IsLocked(user) || s.config.MaxFailedAttempts <= 0 {
+		return
+	}
+
+	user.FailedLogins++
+	updates := map[string]interface{}{"failed_logins": gorm.Expr("failed_logins + 1")}
+
+	if user.FailedLogins >= s.config.MaxFailedAttempts {
+		user.LockoutCount++
+		lockedUntil := time.Now().Add(s.lockoutDuration(user.LockoutCount))
+		user.LockedUntil = &lockedUntil
+		user.FailedLogins = 0
+
+		updates["failed_logins"] = 0
+		updates["lockout_count"] = user.LockoutCount
+		updates["locked_until"] = lockedUntil
+
+		lockLog := models.ActivityLog{
+			UserID:    &user.ID,
+			Action:    "account_locked",
+			Resource:  "auth",
+			Details:   fmt.Sprintf("Account locked until %s after %d failed login attempts", lockedUntil.Format(time.RFC3339), s.config.MaxFailedAttempts),
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+		}
+		database.DB.Create(&lockLog)
+
+		s.notifier.SendSecurityAlert(user, "Account locked", lockLog.Details, ipAddress)
+	}
+
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(updates)
+}
+
+// RecordSuccess clears the account's failure state after a successful login.
+func (s *LockoutService) RecordSuccess(user *models.User, ipAddress string) {
+	attempt := models.LoginAttempt{
+		UserID:    &user.ID,
+		Email:     user.Email,
+		IPAddress: ipAddress,
+		Success:   true,
+	}
+	database.DB.Create(&attempt)
+
+	if user.FailedLogins == 0 && user.LockoutCount == 0 && user.LockedUntil == nil {
+		return
+	}
+
+	user.FailedLogins = 0
+	user.LockoutCount = 0
+	user.LockedUntil = nil
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_logins": 0,
+		"lockout_count": 0,
+		"locked_until":  nil,
+	})
+}
+
+// Unlock lifts a lockout early and resets the backoff.
+func (s *LockoutService) Unlock(userID uint) error {
+	result := database.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"failed_logins": 0,
+		"lockout_count": 0,
+		"locked_until":  nil,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+func (s *LockoutService) lockoutDuration(lockoutCount int) time.Duration {
+	duration := s.config.BaseDuration
+	for i := 1; i < lockoutCount; i++ {
+		duration *= 2
+		if s.config.MaxDuration > 0 && duration >= s.config.MaxDuration {
+			return s.config.MaxDuration
+		}
+	}
+	return duration
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/mailer"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+)
+
+var ErrMagicLinkInvalid = errors.New("invalid or expired login link")
+
+// MagicLinkService provides passwordless login for users whose roles all
+// have AllowMagicLink. A link is single-use, expires after the configured
+// lifetime and only works from the IP address that requested it.
+type MagicLinkService struct {
+	config      config.MagicLinkConfig
+	authService *AuthService
+	notifier    *mailer.Notifier
+}
+
+func NewMagicLinkService(cfg config.MagicLinkConfig, authService *AuthService, notifier *mailer.Notifier) *MagicLinkService {
+	return &MagicLinkService{
+		config:      cfg,
+		authService: authService,
+		notifier:    notifier,
+	}
+}
+
+// RequestLink emails a login link to the user with this email. Unknown,
+// deactivated and not allowed users get nothing, without an error, so the
+// response doesn't reveal which accounts exist. A new link replaces any
+// unused one.
+func (s *MagicLinkService) RequestLink(email, ipAddress, userAgent string) error {
+	var user models.User
+	if err := database.DB.Preload("Role").Preload("Roles").Where("email = ?", email).First(&user).Error; err != nil {
+		return nil
+	}
+
+	if !user.IsActive || !user.AllowsMagicLink() {
+		return nil
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	database.DB.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&models.MagicLinkToken{})
+
+	magicLink := models.MagicLinkToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		IPAddress: ipAddress,
+		ExpiresAt: time.Now().Add(s.config.TokenExpiry),
+	}
+	if err := database.DB.Create(&magicLink).Error; err != nil {
+		return err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "magic_link_requested",
+		Resource:  "auth",
+		Details:   "Passwordless login link requested",
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return s.notifier.SendMagicLink(&user, token, magicLink.ExpiresAt)
+}
+
+// Login exchanges a login link for tokens, or for an MFA challenge when the
+// user has a second factor. A link presented from another IP address is
+// refused but not consumed, so the user can still open it themselves.
+func (s *MagicLinkService) Login(token, ipAddress, userAgent string) (*models.TokenResponse, *models.AuthChallenge, error) {
+	var magicLink models.MagicLinkToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(token)).First(&magicLink).Error; err != nil {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+
+	if magicLink.UsedAt != nil || time.Now().After(magicLink.ExpiresAt) || magicLink.IPAddress != ipAddress {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+
+	result := database.DB.Model(&models.MagicLinkToken{}).
+		Where("id = ? AND used_at IS NULL", magicLink.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+
+	var user models.User
+	if err := database.DB.Preload("Role").Preload("Roles").First(&user, magicLink.UserID).Error; err != nil {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+
+	// A role may have dropped passwordless login since the link was sent.
+	if !user.AllowsMagicLink() {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+
+	return s.authService.LoginWithIdentity(&user, ipAddress, userAgent)
+}
@@ -0,0 +1,98 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/mailer"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+)
+
+func setupMagicLinkTest(t *testing.T) (*services.MagicLinkService, *recordingMailer, *models.User) {
+	authService, user := setupAuthTest(t)
+	require.NoError(t, database.DB.AutoMigrate(&models.MagicLinkToken{}))
+	database.DB.Model(&models.Role{}).Where("id = ?", user.RoleID).Update("allow_magic_link", true)
+
+	mail := &recordingMailer{}
+	service := services.NewMagicLinkService(
+		config.MagicLinkConfig{TokenExpiry: 15 * time.Minute},
+		authService,
+		mailer.NewNotifier(mail, "RBAC System", "http://localhost:3000"),
+	)
+	return service, mail, user
+}
+
+func TestMagicLink_LoginIsSingleUse(t *testing.T) {
+	service, mail, user := setupMagicLinkTest(t)
+
+	require.NoError(t, service.RequestLink(user.Email, "10.0.0.1", "browser"))
+	require.Len(t, mail.messages, 1)
+	token := mail.lastLinkToken(t, "/magic-link")
+
+	var stored models.MagicLinkToken
+	require.NoError(t, database.DB.First(&stored).Error)
+	assert.NotEqual(t, token, stored.TokenHash)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), stored.ExpiresAt, time.Minute)
+
+	response, challenge, err := service.Login(token, "10.0.0.1", "browser")
+	require.NoError(t, err)
+	require.Nil(t, challenge)
+	assert.NotEmpty(t, response.AccessToken)
+	assert.Equal(t, user.ID, response.User.ID)
+
+	_, _, err = service.Login(token, "10.0.0.1", "browser")
+	assert.ErrorIs(t, err, services.ErrMagicLinkInvalid)
+}
+
+func TestMagicLink_BoundToRequestingIP(t *testing.T) {
+	service, mail, user := setupMagicLinkTest(t)
+
+	require.NoError(t, service.RequestLink(user.Email, "10.0.0.1", "browser"))
+	token := mail.lastLinkToken(t, "/magic-link")
+
+	_, _, err := service.Login(token, "10.9.9.9", "attacker")
+	assert.ErrorIs(t, err, services.ErrMagicLinkInvalid)
+
+	_, _, err = service.Login(token, "10.0.0.1", "browser")
+	assert.NoError(t, err)
+}
+
+func TestMagicLink_ExpiryAndReplacement(t *testing.T) {
+	service, mail, user := setupMagicLinkTest(t)
+
+	require.NoError(t, service.RequestLink(user.Email, "10.0.0.1", "browser"))
+	first := mail.lastLinkToken(t, "/magic-link")
+	require.NoError(t, service.RequestLink(user.Email, "10.0.0.1", "browser"))
+	second := mail.lastLinkToken(t, "/magic-link")
+
+	_, _, err := service.Login(first, "10.0.0.1", "browser")
+	assert.ErrorIs(t, err, services.ErrMagicLinkInvalid)
+
+	database.DB.Model(&models.MagicLinkToken{}).Where("user_id = ?", user.ID).Update("expires_at", time.Now().Add(-time.Second))
+	_, _, err = service.Login(second, "10.0.0.1", "browser")
+	assert.ErrorIs(t, err, services.ErrMagicLinkInvalid)
+}
+
+func TestMagicLink_RolePolicy(t *testing.T) {
+	service, mail, user := setupMagicLinkTest(t)
+
+	require.NoError(t, service.RequestLink("nobody@example.com", "10.0.0.1", "browser"))
+	assert.Empty(t, mail.messages)
+
+	require.NoError(t, service.RequestLink(user.Email, "10.0.0.1", "browser"))
+	token := mail.lastLinkToken(t, "/magic-link")
+
+	database.DB.Model(&models.Role{}).Where("id = ?", user.RoleID).Update("allow_magic_link", false)
+
+	_, _, err := service.Login(token, "10.0.0.1", "browser")
+	assert.ErrorIs(t, err, services.ErrMagicLinkInvalid)
+
+	require.NoError(t, service.RequestLink(user.Email, "10.0.0.1", "browser"))
+	assert.Len(t, mail.messages, 1)
+}
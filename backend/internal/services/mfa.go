@@ -0,0 +1,176 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+type MFAService struct {
+	issuer string
+}
+
+func NewMFAService(issuer string) *MFAService {
+	return &MFAService{issuer: issuer}
+}
+
+// BeginEnrollment generates a fresh TOTP secret for the user. The secret is
+// stored but MFA stays disabled until ConfirmEnrollment proves the user's
+// authenticator produces matching codes.
+func (s *MFAService) BeginEnrollment(userID uint) (*models.MFAEnrollmentResponse, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	if user.MFAEnabledAt != nil {
+		return nil, errors.New("MFA is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Model(&user).Update("mfa_secret", secret).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPProvisioningURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables MFA once the user submits a valid code for the
+// pending secret and returns a first set of recovery codes.
+func (s *MFAService) ConfirmEnrollment(userID uint, code string) ([]string, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	if user.MFAEnabledAt != nil {
+		return nil, errors.New("MFA is already enabled")
+	}
+
+	if user.MFASecret == "" {
+		return nil, errors.New("MFA enrollment has not been started")
+	}
+
+	step, ok := utils.ValidateTOTPCode(user.MFASecret, code, time.Now())
+	if !ok {
+		return nil, errors.New("invalid MFA code")
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{
+		"mfa_enabled_at": now,
+		"mfa_last_step":  step,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return s.RegenerateRecoveryCodes(userID)
+}
+
+// VerifyCode accepts either a TOTP code or an unused recovery code. TOTP
+// codes are bound to their time step so the same code can't be replayed.
+func (s *MFAService) VerifyCode(userID uint, code string) error {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return errors.New("user not found")
+	}
+
+	if user.MFAEnabledAt == nil {
+		return errors.New("MFA is not enabled")
+	}
+
+	if step, ok := utils.ValidateTOTPCode(user.MFASecret, code, time.Now()); ok {
+		result := database.DB.Model(&models.User{}).
+			Where("id = ? AND mfa_last_step < ?", user.ID, step).
+			Update("mfa_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("MFA code has already been used")
+		}
+		return nil
+	}
+
+	result := database.DB.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("invalid MFA code")
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all of the user's recovery codes. The
+// plain codes are only ever returned here.
+func (s *MFAService) RegenerateRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.MFARecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		raw, err := utils.GenerateRandomToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = raw[:5] + "-" + raw[5:]
+		records[i] = models.MFARecoveryCode{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(codes[i]),
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// ResetMFA removes the user's second factor entirely, e.g. after they lose
+// their device. They have to enroll again before MFA applies.
+func (s *MFAService) ResetMFA(userID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"mfa_secret":     "",
+			"mfa_enabled_at": nil,
+			"mfa_last_step":  0,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("user not found")
+		}
+
+		return tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error
+	})
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return utils.HashToken(normalized)
+}
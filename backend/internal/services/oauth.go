@@ -0,0 +1,468 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+)
+
+// OAuthError is an error with an RFC 6749 error code, which the token and
+// introspection endpoints return as-is.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func newOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+var ErrOAuthInvalidRequest = newOAuthError("invalid_request", "malformed request")
+
+type OAuthService struct {
+	jwtConfig             config.JWTConfig
+	jwtService            *utils.JWTService
+	authService           *AuthService
+	serviceAccountService *ServiceAccountService
+	rbacService           *RBACService
+}
+
+func NewOAuthService(cfg *config.Config, jwtService *utils.JWTService, authService *AuthService, serviceAccountService *ServiceAccountService, rbacService *RBACService) *OAuthService {
+	return &OAuthService{
+		jwtConfig:             cfg.JWT,
+		jwtService:            jwtService,
+		authService:           authService,
+		serviceAccountService: serviceAccountService,
+		rbacService:           rbacService,
+	}
+}
+
+func (s *OAuthService) GetClients() ([]*models.OAuthClientResponse, error) {
+	var clients []models.OAuthClient
+	if err := database.DB.Preload("Scopes").Order("name").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]*models.OAuthClientResponse, 0, len(clients))
+	for i := range clients {
+		responses = append(responses, clients[i].ToResponse())
+	}
+	return responses, nil
+}
+
+func (s *OAuthService) getClient(id uint) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := database.DB.Preload("Scopes").First(&client, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("client not found")
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+// CreateClient registers a client. The client secret is only returned here.
+func (s *OAuthService) CreateClient(req *models.OAuthClientInput) (*models.CreatedOAuthClientResponse, error) {
+	scopes, err := s.resolveScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	client := models.OAuthClient{
+		Name:             req.Name,
+		GrantTypes:       strings.Join(req.GrantTypes, " "),
+		ServiceAccountID: req.ServiceAccountID,
+		IsActive:         true,
+		Scopes:           scopes,
+	}
+	if err := s.checkServiceAccount(&client); err != nil {
+		return nil, err
+	}
+
+	client.ClientID, err = utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	clientSecret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	client.ClientSecretHash = utils.HashToken(clientSecret)
+
+	if err := database.DB.Create(&client).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedOAuthClientResponse{
+		OAuthClientResponse: *client.ToResponse(),
+		ClientSecret:        clientSecret,
+	}, nil
+}
+
+func (s *OAuthService) UpdateClient(id uint, req *models.OAuthClientUpdateInput) (*models.OAuthClientResponse, error) {
+	client, err := s.getClient(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		client.Name = req.Name
+	}
+	if len(req.GrantTypes) > 0 {
+		client.GrantTypes = strings.Join(req.GrantTypes, " ")
+	}
+	if req.ServiceAccountID != nil {
+		client.ServiceAccountID = req.ServiceAccountID
+	}
+	if req.IsActive != nil {
+		client.IsActive = *req.IsActive
+	}
+	if err := s.checkServiceAccount(client); err != nil {
+		return nil, err
+	}
+
+	var scopes []*models.Permission
+	if req.Scopes != nil {
+		scopes, err = s.resolveScopes(req.Scopes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Scopes").Save(client).Error; err != nil {
+			return err
+		}
+
+		if scopes == nil {
+			return nil
+		}
+		client.Scopes = scopes
+		return tx.Model(client).Association("Scopes").Replace(scopes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return client.ToResponse(), nil
+}
+
+func (s *OAuthService) DeleteClient(id uint) error {
+	client, err := s.getClient(id)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(client).Association("Scopes").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(client).Error
+	})
+}
+
+// resolveScopes maps scope names onto permissions, rejecting unknown names.
+func (s *OAuthService) resolveScopes(names []string) ([]*models.Permission, error) {
+	if len(names) == 0 {
+		return []*models.Permission{}, nil
+	}
+
+	var permissions []*models.Permission
+	if err := database.DB.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		known[permission.Name] = true
+	}
+	for _, name := range names {
+		if !known[name] {
+			return nil, errors.New("unknown scope: " + name)
+		}
+	}
+
+	return permissions, nil
+}
+
+// checkServiceAccount requires clients using client_credentials to act as an
+// existing service account.
+func (s *OAuthService) checkServiceAccount(client *models.OAuthClient) error {
+	if client.ServiceAccountID == nil {
+		if client.AllowsGrant("client_credentials") {
+			return errors.New("client_credentials clients need a service account")
+		}
+		return nil
+	}
+
+	_, err := s.serviceAccountService.GetServiceAccount(*client.ServiceAccountID)
+	return err
+}
+
+// AuthenticateClient checks a client's credentials for the token and
+// introspection endpoints.
+func (s *OAuthService) AuthenticateClient(clientID, clientSecret string) (*models.OAuthClient, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, newOAuthError("invalid_client", "client authentication is required")
+	}
+
+	var client models.OAuthClient
+	if err := database.DB.Preload("Scopes").Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, newOAuthError("invalid_client", "client authentication failed")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(client.ClientSecretHash), []byte(utils.HashToken(clientSecret))) != 1 {
+		return nil, newOAuthError("invalid_client", "client authentication failed")
+	}
+
+	if !client.IsActive {
+		return nil, newOAuthError("invalid_client", "client is disabled")
+	}
+
+	return &client, nil
+}
+
+// Token implements the token endpoint for the client_credentials and
+// refresh_token grants.
+func (s *OAuthService) Token(req *models.OAuthTokenRequest, ipAddress, userAgent string) (*models.OAuthTokenResponse, error) {
+	client, err := s.AuthenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case "client_credentials", "refresh_token":
+	case "":
+		return nil, newOAuthError("invalid_request", "grant_type is required")
+	default:
+		return nil, newOAuthError("unsupported_grant_type", "grant type "+req.GrantType+" is not supported")
+	}
+
+	if !client.AllowsGrant(req.GrantType) {
+		return nil, newOAuthError("unauthorized_client", "client may not use the "+req.GrantType+" grant")
+	}
+
+	grantedScope, err := grantScope(client, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GrantType == "refresh_token" {
+		return s.refreshTokenGrant(client, req.RefreshToken, grantedScope, ipAddress, userAgent)
+	}
+	return s.clientCredentialsGrant(client, grantedScope, ipAddress, userAgent)
+}
+
+// grantScope checks the requested scope against the client's scopes and
+// returns what the token is limited to, all of the client's scopes when none
+// were requested.
+func grantScope(client *models.OAuthClient, scope string) (string, error) {
+	allowed := client.ScopeNames()
+	granted := strings.Fields(scope)
+	if len(granted) == 0 {
+		granted = allowed
+	}
+	if len(granted) == 0 {
+		return "", newOAuthError("invalid_scope", "client has no scopes")
+	}
+
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		allowedSet[name] = true
+	}
+	for _, name := range granted {
+		if !allowedSet[name] {
+			return "", newOAuthError("invalid_scope", "scope "+name+" is not allowed for this client")
+		}
+	}
+
+	return strings.Join(granted, " "), nil
+}
+
+func (s *OAuthService) clientCredentialsGrant(client *models.OAuthClient, grantedScope, ipAddress, userAgent string) (*models.OAuthTokenResponse, error) {
+	if client.ServiceAccountID == nil {
+		return nil, newOAuthError("unauthorized_client", "client has no service account")
+	}
+	account, err := s.serviceAccountService.GetServiceAccount(*client.ServiceAccountID)
+	if err != nil || !account.IsActive {
+		return nil, newOAuthError("unauthorized_client", "client's service account is unavailable")
+	}
+
+	accessToken, expiresAt, err := s.jwtService.GenerateServiceAccountToken(account, client.ClientID, grantedScope)
+	if err != nil {
+		return nil, err
+	}
+
+	database.DB.Model(account).Update("last_used_at", time.Now())
+
+	activityLog := models.ActivityLog{
+		ServiceAccountID: &account.ID,
+		Action:           "token_issued",
+		Resource:         "auth",
+		Details:          "OAuth client " + client.Name + " issued a token with scope: " + grantedScope,
+		IPAddress:        ipAddress,
+		UserAgent:        userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return &models.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+		Scope:       grantedScope,
+	}, nil
+}
+
+// refreshTokenGrant issues the client tokens for a user, limited to the
+// granted scope. A user's refresh token starts a session of the client's own,
+// whose refresh tokens rotate like /api/auth/refresh, including reuse
+// detection.
+func (s *OAuthService) refreshTokenGrant(client *models.OAuthClient, refreshToken, grantedScope, ipAddress, userAgent string) (*models.OAuthTokenResponse, error) {
+	if refreshToken == "" {
+		return nil, newOAuthError("invalid_request", "refresh_token is required")
+	}
+
+	tokens, err := s.authService.RefreshTokenForClient(refreshToken, client, grantedScope, ipAddress, userAgent)
+	if err != nil {
+		return nil, newOAuthError("invalid_grant", err.Error())
+	}
+
+	return &models.OAuthTokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(tokens.ExpiresAt).Seconds()),
+		RefreshToken: tokens.RefreshToken,
+		Scope:        grantedScope,
+	}, nil
+}
+
+// Introspect implements RFC 7662 for the access, service account and refresh
+// tokens this system issues. Anything that doesn't validate, or has since
+// been revoked, is reported as inactive.
+func (s *OAuthService) Introspect(req *models.OAuthIntrospectionRequest) (*models.OAuthIntrospectionResponse, error) {
+	if _, err := s.AuthenticateClient(req.ClientID, req.ClientSecret); err != nil {
+		return nil, err
+	}
+
+	if req.Token == "" {
+		return nil, newOAuthError("invalid_request", "token is required")
+	}
+
+	if req.TokenTypeHint == "refresh_token" {
+		if response := s.introspectRefreshToken(req.Token); response != nil {
+			return response, nil
+		}
+	}
+
+	if response := s.introspectAccessToken(req.Token); response != nil {
+		return response, nil
+	}
+
+	if response := s.introspectServiceAccountToken(req.Token); response != nil {
+		return response, nil
+	}
+
+	if response := s.introspectRefreshToken(req.Token); response != nil {
+		return response, nil
+	}
+
+	return &models.OAuthIntrospectionResponse{Active: false}, nil
+}
+
+func (s *OAuthService) introspectAccessToken(token string) *models.OAuthIntrospectionResponse {
+	claims, err := s.jwtService.ValidateAccessToken(token)
+	if err != nil {
+		return nil
+	}
+
+	user, err := s.authService.GetUserByID(claims.UserID)
+	if err != nil || !user.IsActive || s.authService.IsAccessTokenRevoked(claims, user) {
+		return nil
+	}
+
+	scope := claims.Scope
+	if scope == "" {
+		permissions, err := s.rbacService.GetUserPermissions(user.ID)
+		if err != nil {
+			return nil
+		}
+		scope = permissionNames(permissions)
+	}
+
+	response := s.activeResponse(claims, "access_token", scope)
+	response.Username = user.Username
+	response.ClientID = claims.ClientID
+	return response
+}
+
+func (s *OAuthService) introspectServiceAccountToken(token string) *models.OAuthIntrospectionResponse {
+	claims, err := s.jwtService.ValidateServiceAccountToken(token)
+	if err != nil {
+		return nil
+	}
+
+	account, err := s.serviceAccountService.AuthenticateToken(claims)
+	if err != nil {
+		return nil
+	}
+
+	scope := claims.Scope
+	if scope == "" {
+		permissions, err := s.rbacService.GetServiceAccountPermissions(account.ID)
+		if err != nil {
+			return nil
+		}
+		scope = permissionNames(permissions)
+	}
+
+	response := s.activeResponse(claims, "access_token", scope)
+	response.Username = account.Name
+	response.ClientID = claims.ClientID
+	return response
+}
+
+func (s *OAuthService) introspectRefreshToken(token string) *models.OAuthIntrospectionResponse {
+	claims, user, err := s.authService.InspectRefreshToken(token)
+	if err != nil {
+		return nil
+	}
+
+	response := s.activeResponse(claims, "refresh_token", "")
+	response.Username = user.Username
+	return response
+}
+
+func (s *OAuthService) activeResponse(claims *models.JWTClaims, tokenType, scope string) *models.OAuthIntrospectionResponse {
+	return &models.OAuthIntrospectionResponse{
+		Active:    true,
+		Scope:     scope,
+		TokenType: tokenType,
+		Exp:       claims.ExpiresAt.Unix(),
+		Iat:       claims.IssuedAt.Unix(),
+		Sub:       claims.Subject,
+		Aud:       s.jwtConfig.Audience,
+		Iss:       s.jwtConfig.Issuer,
+		Jti:       claims.ID,
+	}
+}
+
+func permissionNames(permissions []*models.Permission) string {
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, permission.Name)
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,198 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+)
+
+type oauthTest struct {
+	service      *services.OAuthService
+	authService  *services.AuthService
+	user         *models.User
+	account      *models.ServiceAccount
+	clientID     string
+	clientSecret string
+}
+
+func setupOAuthTest(t *testing.T) *oauthTest {
+	authService, user := setupAuthTest(t)
+	require.NoError(t, database.DB.AutoMigrate(&models.ServiceAccount{}, &models.ServiceAccountAPIKey{}, &models.OAuthClient{}))
+
+	readPerm := models.Permission{Name: "users.read", Resource: "users", Action: "read"}
+	updatePerm := models.Permission{Name: "users.update", Resource: "users", Action: "update"}
+	database.DB.Create(&readPerm)
+	database.DB.Create(&updatePerm)
+
+	role := models.Role{Name: "Sync", Description: "Directory sync"}
+	database.DB.Create(&role)
+	database.DB.Model(&role).Association("Permissions").Append(&readPerm, &updatePerm)
+
+	jwtService := utils.NewJWTService(testJWTConfig)
+	serviceAccountService := services.NewServiceAccountService(jwtService)
+	credentials, err := serviceAccountService.CreateServiceAccount(&models.ServiceAccountInput{Name: "directory-sync", RoleID: role.ID})
+	require.NoError(t, err)
+
+	service := services.NewOAuthService(testJWTConfig, jwtService, authService, serviceAccountService, services.NewRBACService(database.DB))
+	client, err := service.CreateClient(&models.OAuthClientInput{
+		Name:             "sync worker",
+		GrantTypes:       []string{"client_credentials", "refresh_token"},
+		Scopes:           []string{"users.read", "users.update"},
+		ServiceAccountID: &credentials.ServiceAccount.ID,
+	})
+	require.NoError(t, err)
+
+	return &oauthTest{
+		service:      service,
+		authService:  authService,
+		user:         user,
+		account:      credentials.ServiceAccount,
+		clientID:     client.ClientID,
+		clientSecret: client.ClientSecret,
+	}
+}
+
+func oauthErrorCode(err error) string {
+	var oauthErr *services.OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Code
+	}
+	return ""
+}
+
+func TestOAuth_ClientCredentialsGrant(t *testing.T) {
+	env := setupOAuthTest(t)
+
+	token, err := env.service.Token(&models.OAuthTokenRequest{GrantType: "client_credentials", ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.Equal(t, "users.read users.update", token.Scope)
+	assert.Positive(t, token.ExpiresIn)
+
+	token, err = env.service.Token(&models.OAuthTokenRequest{GrantType: "client_credentials", Scope: "users.read", ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	require.NoError(t, err)
+	assert.Equal(t, "users.read", token.Scope)
+
+	_, err = env.service.Token(&models.OAuthTokenRequest{GrantType: "client_credentials", Scope: "users.delete", ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	assert.Equal(t, "invalid_scope", oauthErrorCode(err))
+
+	_, err = env.service.Token(&models.OAuthTokenRequest{GrantType: "client_credentials", ClientID: env.clientID, ClientSecret: "wrong"}, "10.0.0.1", "worker")
+	assert.Equal(t, "invalid_client", oauthErrorCode(err))
+
+	_, err = env.service.Token(&models.OAuthTokenRequest{GrantType: "password", ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	assert.Equal(t, "unsupported_grant_type", oauthErrorCode(err))
+}
+
+func TestOAuth_IntrospectServiceAccountToken(t *testing.T) {
+	env := setupOAuthTest(t)
+
+	token, err := env.service.Token(&models.OAuthTokenRequest{GrantType: "client_credentials", Scope: "users.read", ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	require.NoError(t, err)
+
+	_, err = env.service.Introspect(&models.OAuthIntrospectionRequest{Token: token.AccessToken})
+	assert.Equal(t, "invalid_client", oauthErrorCode(err), "introspection requires client authentication")
+
+	result, err := env.service.Introspect(&models.OAuthIntrospectionRequest{Token: token.AccessToken, ClientID: env.clientID, ClientSecret: env.clientSecret})
+	require.NoError(t, err)
+	assert.True(t, result.Active)
+	assert.Equal(t, "users.read", result.Scope)
+	assert.Equal(t, env.clientID, result.ClientID)
+	assert.Equal(t, "directory-sync", result.Username)
+	assert.Positive(t, result.Exp)
+	assert.NotEmpty(t, result.Sub)
+
+	result, err = env.service.Introspect(&models.OAuthIntrospectionRequest{Token: "not-a-token", ClientID: env.clientID, ClientSecret: env.clientSecret})
+	require.NoError(t, err)
+	assert.False(t, result.Active)
+
+	database.DB.Model(env.account).Update("is_active", false)
+	result, err = env.service.Introspect(&models.OAuthIntrospectionRequest{Token: token.AccessToken, ClientID: env.clientID, ClientSecret: env.clientSecret})
+	require.NoError(t, err)
+	assert.False(t, result.Active, "tokens of deactivated service accounts are inactive")
+}
+
+func TestOAuth_RefreshTokenGrantAndIntrospection(t *testing.T) {
+	env := setupOAuthTest(t)
+	tokens := login(t, env.authService, env.user, "10.0.0.1", "browser")
+	creds := func(req *models.OAuthIntrospectionRequest) *models.OAuthIntrospectionRequest {
+		req.ClientID, req.ClientSecret = env.clientID, env.clientSecret
+		return req
+	}
+
+	result, err := env.service.Introspect(creds(&models.OAuthIntrospectionRequest{Token: tokens.AccessToken}))
+	require.NoError(t, err)
+	assert.True(t, result.Active)
+	assert.Equal(t, "regularuser", result.Username)
+	assert.Equal(t, "access_token", result.TokenType)
+
+	refreshed, err := env.service.Token(&models.OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: tokens.RefreshToken, ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	require.NoError(t, err)
+	assert.NotEmpty(t, refreshed.AccessToken)
+	assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+	assert.Equal(t, "users.read users.update", refreshed.Scope)
+
+	result, err = env.service.Introspect(creds(&models.OAuthIntrospectionRequest{Token: refreshed.AccessToken}))
+	require.NoError(t, err)
+	assert.True(t, result.Active)
+	assert.Equal(t, "users.read users.update", result.Scope, "the client's access token is limited to its scopes")
+	assert.Equal(t, env.clientID, result.ClientID)
+
+	clientRefresh, err := env.service.Token(&models.OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: refreshed.RefreshToken, Scope: "users.read", ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	require.NoError(t, err)
+	assert.Equal(t, "users.read", clientRefresh.Scope)
+
+	result, err = env.service.Introspect(creds(&models.OAuthIntrospectionRequest{Token: refreshed.RefreshToken, TokenTypeHint: "refresh_token"}))
+	require.NoError(t, err)
+	assert.False(t, result.Active, "rotated refresh tokens are inactive")
+
+	result, err = env.service.Introspect(creds(&models.OAuthIntrospectionRequest{Token: clientRefresh.RefreshToken}))
+	require.NoError(t, err)
+	assert.True(t, result.Active)
+	assert.Equal(t, "refresh_token", result.TokenType)
+
+	_, err = env.service.Token(&models.OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: refreshed.RefreshToken, ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	assert.Equal(t, "invalid_grant", oauthErrorCode(err))
+
+	_, err = env.service.Token(&models.OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: tokens.RefreshToken, Scope: "users.delete", ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	assert.Equal(t, "invalid_scope", oauthErrorCode(err))
+}
+
+func TestOAuth_RefreshTokenBoundToClient(t *testing.T) {
+	env := setupOAuthTest(t)
+	tokens := login(t, env.authService, env.user, "10.0.0.1", "browser")
+
+	other, err := env.service.CreateClient(&models.OAuthClientInput{
+		Name:             "other app",
+		GrantTypes:       []string{"refresh_token"},
+		Scopes:           []string{"users.read"},
+		ServiceAccountID: &env.account.ID,
+	})
+	require.NoError(t, err)
+
+	refreshed, err := env.service.Token(&models.OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: tokens.RefreshToken, ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	require.NoError(t, err)
+
+	_, err = env.service.Token(&models.OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: refreshed.RefreshToken, ClientID: other.ClientID, ClientSecret: other.ClientSecret}, "10.0.0.2", "other")
+	assert.Equal(t, "invalid_grant", oauthErrorCode(err), "the session belongs to the client it was started for")
+
+	_, err = env.authService.RefreshToken(refreshed.RefreshToken, "10.0.0.1", "browser")
+	assert.ErrorIs(t, err, services.ErrRefreshTokenClient, "the client's tokens can't be refreshed into unscoped ones")
+
+	_, err = env.service.Token(&models.OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: refreshed.RefreshToken, ClientID: env.clientID, ClientSecret: env.clientSecret}, "10.0.0.1", "worker")
+	assert.NoError(t, err, "refused attempts don't use the token up")
+
+	// The user's own session is left alone.
+	userRefresh, err := env.authService.RefreshToken(tokens.RefreshToken, "10.0.0.1", "browser")
+	require.NoError(t, err)
+	claims, err := utils.NewJWTService(testJWTConfig).ValidateAccessToken(userRefresh.AccessToken)
+	require.NoError(t, err)
+	assert.Empty(t, claims.Scope)
+	assert.Empty(t, claims.ClientID)
+}
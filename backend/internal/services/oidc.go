@@ -0,0 +1,349 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/oidc"
+	"rbac-system/backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+const oidcStateExpiry = 10 * time.Minute
+
+var (
+	ErrOIDCInvalidState     = errors.New("invalid or expired login state")
+	ErrOIDCEmailNotVerified = errors.New("an account with this email already exists but the identity provider has not verified the email")
+	ErrOIDCNoAccount        = errors.New("no account is linked to this identity")
+	ErrOIDCNoRole           = errors.New("no role mapping matches this identity")
+)
+
+// OIDCService signs users in through an external OpenID Connect provider.
+// Identities are matched by issuer and subject, then linked to an existing
+// user with the same provider-verified email, and otherwise provisioned with a
+// role picked by the configured rules.
+type OIDCService struct {
+	config      config.OIDCConfig
+	provider    *oidc.Provider
+	authService *AuthService
+}
+
+func NewOIDCService(cfg config.OIDCConfig, provider *oidc.Provider, authService *AuthService) *OIDCService {
+	return &OIDCService{
+		config:      cfg,
+		provider:    provider,
+		authService: authService,
+	}
+}
+
+// BeginLogin starts an authorization request and returns the provider URL to
+// send the browser to. The state, nonce and PKCE verifier stay server side
+// until the callback.
+func (s *OIDCService) BeginLogin() (*models.OIDCAuthorizationResponse, error) {
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	authorizationURL, err := s.provider.AuthCodeURL(state, nonce, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	database.DB.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+
+	loginState := models.OIDCLoginState{
+		StateHash:    utils.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(oidcStateExpiry),
+	}
+	if err := database.DB.Create(&loginState).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.OIDCAuthorizationResponse{
+		AuthorizationURL: authorizationURL,
+		State:            state,
+	}, nil
+}
+
+// CompleteLogin redeems the code the provider redirected back with, verifies
+// the ID token and logs the matching user in like AuthService.Login does,
+// including any MFA challenge.
+func (s *OIDCService) CompleteLogin(req *models.OIDCCallbackRequest, ipAddress, userAgent string) (*models.TokenResponse, *models.AuthChallenge, error) {
+	loginState, err := s.consumeState(req.State)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := s.provider.Exchange(req.Code, loginState.CodeVerifier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idToken, err := s.provider.VerifyIDToken(token.IDToken, loginState.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.resolveUser(idToken, ipAddress, userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.authService.LoginWithIdentity(user, ipAddress, userAgent)
+}
+
+// consumeState loads and deletes the pending login, so each state is only
+// accepted once.
+func (s *OIDCService) consumeState(state string) (*models.OIDCLoginState, error) {
+	var loginState models.OIDCLoginState
+	if err := database.DB.Where("state_hash = ?", utils.HashToken(state)).First(&loginState).Error; err != nil {
+		return nil, ErrOIDCInvalidState
+	}
+
+	result := database.DB.Delete(&loginState)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(loginState.ExpiresAt) {
+		return nil, ErrOIDCInvalidState
+	}
+
+	return &loginState, nil
+}
+
+func (s *OIDCService) resolveUser(idToken *oidc.IDToken, ipAddress, userAgent string) (*models.User, error) {
+	now := time.Now()
+
+	var identity models.UserIdentity
+	err := database.DB.Preload("User.Role").Where("issuer = ? AND subject = ?", s.provider.Issuer(), idToken.Subject).First(&identity).Error
+	if err == nil {
+		// The linked user was deleted; deleting them is how an admin keeps
+		// them out, so they are not provisioned again.
+		if identity.User.ID == 0 {
+			return nil, ErrOIDCNoAccount
+		}
+
+		database.DB.Model(&identity).Updates(map[string]interface{}{"email": idToken.Email, "last_login_at": now})
+		if s.config.SyncRoles {
+			if err := s.syncRole(&identity.User, idToken, ipAddress, userAgent); err != nil {
+				return nil, err
+			}
+		}
+		return &identity.User, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if idToken.Email != "" {
+		var user models.User
+		err := database.DB.Preload("Role").Where("email = ?", idToken.Email).First(&user).Error
+		if err == nil {
+			return s.linkIdentity(&user, idToken, ipAddress, userAgent)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if !s.config.AutoProvision {
+		return nil, ErrOIDCNoAccount
+	}
+
+	return s.provisionUser(idToken, ipAddress, userAgent)
+}
+
+// linkIdentity attaches the identity to an existing user with the same
+// email, which the provider must have verified.
+func (s *OIDCService) linkIdentity(user *models.User, idToken *oidc.IDToken, ipAddress, userAgent string) (*models.User, error) {
+	if !idToken.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	now := time.Now()
+	identity := models.UserIdentity{
+		UserID:      user.ID,
+		Issuer:      s.provider.Issuer(),
+		Subject:     idToken.Subject,
+		Email:       idToken.Email,
+		LastLoginAt: &now,
+	}
+	if err := database.DB.Create(&identity).Error; err != nil {
+		return nil, err
+	}
+
+	if user.EmailVerifiedAt == nil {
+		user.EmailVerifiedAt = &now
+		database.DB.Model(user).Update("email_verified_at", now)
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "identity_linked",
+		Resource:  "auth",
+		Details:   fmt.Sprintf("Linked identity from %s by verified email", identity.Issuer),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	if s.config.SyncRoles {
+		if err := s.syncRole(user, idToken, ipAddress, userAgent); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// provisionUser creates a user for a first-time identity. The user has no
+// password and can only sign in through the provider until they set one
+// with a password reset.
+func (s *OIDCService) provisionUser(idToken *oidc.IDToken, ipAddress, userAgent string) (*models.User, error) {
+	if idToken.Email == "" {
+		return nil, errors.New("identity provider did not supply an email address")
+	}
+
+	role, err := s.mapRole(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := uniqueUsername(idToken.PreferredUsername, idToken.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	firstName, lastName := idToken.GivenName, idToken.FamilyName
+	if firstName == "" && lastName == "" {
+		firstName, lastName, _ = strings.Cut(strings.TrimSpace(idToken.Name), " ")
+	}
+	if firstName == "" {
+		firstName = username
+	}
+
+	now := time.Now()
+	user := models.User{
+		Email:     idToken.Email,
+		Username:  username,
+		FirstName: firstName,
+		LastName:  strings.TrimSpace(lastName),
+		RoleID:    role.ID,
+		Roles:     []models.Role{*role},
+		IsActive:  true,
+	}
+	if idToken.EmailVerified {
+		user.EmailVerifiedAt = &now
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Roles.*").Create(&user).Error; err != nil {
+			return err
+		}
+
+		identity := models.UserIdentity{
+			UserID:      user.ID,
+			Issuer:      s.provider.Issuer(),
+			Subject:     idToken.Subject,
+			Email:       idToken.Email,
+			LastLoginAt: &now,
+		}
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "user_provisioned",
+		Resource:  "auth",
+		Details:   fmt.Sprintf("Provisioned from %s with role %s", s.provider.Issuer(), role.Name),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return &user, nil
+}
+
+// syncRole re-applies the role rules to a linked user.
+func (s *OIDCService) syncRole(user *models.User, idToken *oidc.IDToken, ipAddress, userAgent string) error {
+	role, err := s.mapRole(idToken)
+	if err != nil {
+		return err
+	}
+	return assignSyncedRole(user, role, "identity provider rules", ipAddress, userAgent)
+}
+
+// mapRole returns the role of the first rule matching the ID token, or the
+// default role.
+func (s *OIDCService) mapRole(idToken *oidc.IDToken) (*models.Role, error) {
+	roleName := s.config.DefaultRole
+	for _, rule := range s.config.RoleRules {
+		if claimMatches(idToken.Claims[rule.Claim], rule.Value) || claimMatches(nestedClaim(idToken.Claims, rule.Claim), rule.Value) {
+			roleName = rule.Role
+			break
+		}
+	}
+
+	if roleName == "" {
+		return nil, ErrOIDCNoRole
+	}
+
+	var role models.Role
+	if err := database.DB.Where("name = ?", roleName).First(&role).Error; err != nil {
+		return nil, fmt.Errorf("role %q from OIDC role mapping not found", roleName)
+	}
+	return &role, nil
+}
+
+// nestedClaim resolves dotted claim names such as "realm_access.roles".
+func nestedClaim(claims map[string]interface{}, name string) interface{} {
+	var value interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+// claimMatches reports whether a claim equals value or, for list claims,
+// contains it.
+func claimMatches(claim interface{}, value string) bool {
+	switch claim := claim.(type) {
+	case nil:
+		return false
+	case string:
+		return claim == value
+	case []interface{}:
+		for _, item := range claim {
+			if fmt.Sprint(item) == value {
+				return true
+			}
+		}
+		return false
+	default:
+		return fmt.Sprint(claim) == value
+	}
+}
@@ -0,0 +1,159 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/oidc"
+	"rbac-system/backend/internal/oidc/oidctest"
+	"rbac-system/backend/internal/services"
+)
+
+type oidcTest struct {
+	service *services.OIDCService
+	mock    *oidctest.Provider
+	user    *models.User
+}
+
+func setupOIDCTest(t *testing.T, configure func(*config.OIDCConfig)) *oidcTest {
+	authService, user := setupAuthTest(t)
+	require.NoError(t, database.DB.AutoMigrate(&models.UserIdentity{}, &models.OIDCLoginState{}))
+	database.DB.Create(&models.Role{Name: "Admin", Description: "Admin Role"})
+
+	mock, err := oidctest.NewProvider("rbac-app", "app-secret")
+	require.NoError(t, err)
+	t.Cleanup(mock.Close)
+
+	cfg := config.OIDCConfig{
+		Enabled:       true,
+		IssuerURL:     mock.Issuer(),
+		ClientID:      "rbac-app",
+		ClientSecret:  "app-secret",
+		RedirectURL:   "http://localhost:3000/auth/oidc/callback",
+		Scopes:        []string{"openid", "email", "profile"},
+		AutoProvision: true,
+		DefaultRole:   "User",
+		RoleRules:     []config.OIDCRoleRule{{Claim: "groups", Value: "rbac-admins", Role: "Admin"}},
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	return &oidcTest{
+		service: services.NewOIDCService(cfg, oidc.NewProvider(cfg, nil), authService),
+		mock:    mock,
+		user:    user,
+	}
+}
+
+// oidcLogin runs the browser side of the flow: start, sign in at the mock
+// provider with claims and post the redirect's code and state back.
+func oidcLogin(t *testing.T, env *oidcTest, claims map[string]interface{}) (*models.TokenResponse, error) {
+	env.mock.SetClaims(claims)
+
+	authorization, err := env.service.BeginLogin()
+	require.NoError(t, err)
+	code, state, err := env.mock.Authorize(authorization.AuthorizationURL)
+	require.NoError(t, err)
+	assert.Equal(t, authorization.State, state)
+
+	response, challenge, err := env.service.CompleteLogin(&models.OIDCCallbackRequest{Code: code, State: state}, "10.0.0.1", "browser")
+	assert.Nil(t, challenge)
+	return response, err
+}
+
+func TestOIDC_ProvisionsUserWithMappedRole(t *testing.T) {
+	env := setupOIDCTest(t, nil)
+	claims := map[string]interface{}{
+		"sub":                "alice-1",
+		"email":              "alice@corp.example",
+		"email_verified":     true,
+		"preferred_username": "alice",
+		"given_name":         "Alice",
+		"family_name":        "Doe",
+		"groups":             []string{"engineering", "rbac-admins"},
+	}
+
+	response, err := oidcLogin(t, env, claims)
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.AccessToken)
+	assert.Equal(t, "alice", response.User.Username)
+	assert.Equal(t, "Admin", response.User.Role.Name)
+	assert.NotNil(t, response.User.EmailVerifiedAt)
+
+	again, err := oidcLogin(t, env, claims)
+	require.NoError(t, err)
+	assert.Equal(t, response.User.ID, again.User.ID, "the identity is matched on later logins")
+
+	var identities int64
+	database.DB.Model(&models.UserIdentity{}).Where("user_id = ?", response.User.ID).Count(&identities)
+	assert.Equal(t, int64(1), identities)
+
+	var provisioned int64
+	database.DB.Model(&models.ActivityLog{}).Where("user_id = ? AND action = ?", response.User.ID, "user_provisioned").Count(&provisioned)
+	assert.Equal(t, int64(1), provisioned)
+
+	response, err = oidcLogin(t, env, map[string]interface{}{"sub": "bob-1", "email": "bob@corp.example", "email_verified": true})
+	require.NoError(t, err)
+	assert.Equal(t, "User", response.User.Role.Name, "users matching no rule get the default role")
+	assert.Equal(t, "bob", response.User.Username)
+}
+
+func TestOIDC_LinksExistingUserByVerifiedEmail(t *testing.T) {
+	env := setupOIDCTest(t, nil)
+
+	_, err := oidcLogin(t, env, map[string]interface{}{"sub": "corp-42", "email": env.user.Email, "email_verified": false})
+	assert.ErrorIs(t, err, services.ErrOIDCEmailNotVerified)
+
+	response, err := oidcLogin(t, env, map[string]interface{}{"sub": "corp-42", "email": env.user.Email, "email_verified": true, "groups": []string{"rbac-admins"}})
+	require.NoError(t, err)
+	assert.Equal(t, env.user.ID, response.User.ID)
+	assert.Equal(t, "User", response.User.Role.Name, "linking keeps the local role unless roles are synced")
+
+	var identity models.UserIdentity
+	require.NoError(t, database.DB.Where("subject = ?", "corp-42").First(&identity).Error)
+	assert.Equal(t, env.user.ID, identity.UserID)
+}
+
+func TestOIDC_StateIsSingleUse(t *testing.T) {
+	env := setupOIDCTest(t, nil)
+	env.mock.SetClaims(map[string]interface{}{"sub": "alice-1", "email": "alice@corp.example", "email_verified": true})
+
+	authorization, err := env.service.BeginLogin()
+	require.NoError(t, err)
+	code, state, err := env.mock.Authorize(authorization.AuthorizationURL)
+	require.NoError(t, err)
+
+	_, _, err = env.service.CompleteLogin(&models.OIDCCallbackRequest{Code: code, State: "forged"}, "10.0.0.1", "browser")
+	assert.ErrorIs(t, err, services.ErrOIDCInvalidState)
+
+	_, _, err = env.service.CompleteLogin(&models.OIDCCallbackRequest{Code: code, State: state}, "10.0.0.1", "browser")
+	require.NoError(t, err)
+
+	_, _, err = env.service.CompleteLogin(&models.OIDCCallbackRequest{Code: code, State: state}, "10.0.0.1", "browser")
+	assert.ErrorIs(t, err, services.ErrOIDCInvalidState)
+}
+
+func TestOIDC_ProvisioningPolicy(t *testing.T) {
+	env := setupOIDCTest(t, func(cfg *config.OIDCConfig) { cfg.AutoProvision = false })
+	_, err := oidcLogin(t, env, map[string]interface{}{"sub": "alice-1", "email": "alice@corp.example", "email_verified": true})
+	assert.ErrorIs(t, err, services.ErrOIDCNoAccount)
+
+	env = setupOIDCTest(t, func(cfg *config.OIDCConfig) { cfg.DefaultRole = "" })
+	_, err = oidcLogin(t, env, map[string]interface{}{"sub": "alice-1", "email": "alice@corp.example", "email_verified": true})
+	assert.ErrorIs(t, err, services.ErrOIDCNoRole)
+
+	env = setupOIDCTest(t, func(cfg *config.OIDCConfig) { cfg.SyncRoles = true })
+	response, err := oidcLogin(t, env, map[string]interface{}{"sub": "alice-1", "email": "alice@corp.example", "email_verified": true, "groups": []string{"rbac-admins"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Admin", response.User.Role.Name)
+
+	response, err = oidcLogin(t, env, map[string]interface{}{"sub": "alice-1", "email": "alice@corp.example", "email_verified": true, "groups": []string{}})
+	require.NoError(t, err)
+	assert.Equal(t, "User", response.User.Role.Name, "synced roles follow group changes at the provider")
+}
@@ -4,18 +4,112 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
-
 	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/mailer"
 	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+
+	"gorm.io/gorm"
 )
 
-type PasswordService struct{}
+type PasswordService struct {
+	notifier     *mailer.Notifier
+	historyCount int
+}
+
+func NewPasswordService(notifier *mailer.Notifier, historyCount int) *PasswordService {
+	return &PasswordService{
+		notifier:     notifier,
+		historyCount: historyCount,
+	}
+}
+
+// SetPassword is the single place a user's password changes. It enforces the
+// password policy and history, stores the new hash with password_changed_at
+// and keeps the replaced hash in the history. Users linked to a directory
+// change their password there.
+func (s *PasswordService) SetPassword(user *models.User, newPassword string) error {
+	linked, err := hasDirectoryIdentity(user.ID)
+	if err != nil {
+		return err
+	}
+	if linked {
+		return ErrDirectoryPassword
+	}
+
+	if err := utils.ValidatePassword(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	if err := s.checkHistory(user, newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if s.historyCount > 1 && user.PasswordHash != "" {
+			history := models.PasswordHistory{UserID: user.ID, PasswordHash: user.PasswordHash}
+			if err := tx.Create(&history).Error; err != nil {
+				return err
+			}
+
+			// Only the previous historyCount-1 hashes are needed; the current
+			// one lives on the user.
+			var keep []uint
+			tx.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).
+				Order("id DESC").Limit(s.historyCount-1).Pluck("id", &keep)
+			if err := tx.Where("user_id = ? AND id NOT IN ?", user.ID, keep).Delete(&models.PasswordHistory{}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"password_hash":       hashedPassword,
+			"password_changed_at": now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = hashedPassword
+	user.PasswordChangedAt = &now
+	return nil
+}
+
+// checkHistory rejects the current password and the previous historyCount-1
+// ones.
+func (s *PasswordService) checkHistory(user *models.User, newPassword string) error {
+	if s.historyCount <= 0 {
+		return nil
+	}
+
+	reused := &utils.PasswordPolicyError{Violations: []utils.PasswordViolation{{
+		Code:    "password_reused",
+		Message: fmt.Sprintf("Password must not match any of your last %d passwords", s.historyCount),
+	}}}
+
+	if user.PasswordHash != "" && utils.CheckPasswordHash(newPassword, user.PasswordHash) {
+		return reused
+	}
+
+	var history []models.PasswordHistory
+	database.DB.Where("user_id = ?", user.ID).Order("id DESC").Limit(s.historyCount - 1).Find(&history)
+	for _, entry := range history {
+		if utils.CheckPasswordHash(newPassword, entry.PasswordHash) {
+			return reused
+		}
+	}
 
-func NewPasswordService() *PasswordService {
-	return &PasswordService{}
+	return nil
 }
 
 // CreateResetToken generates a new password reset token for a user.
@@ -25,6 +119,14 @@ func (s *PasswordService) CreateResetToken(email string) (string, error) {
 		return "", errors.New("user not found")
 	}
 
+	linked, err := hasDirectoryIdentity(user.ID)
+	if err != nil {
+		return "", err
+	}
+	if linked {
+		return "", ErrDirectoryPassword
+	}
+
 	token := make([]byte, 32)
 	if _, err := rand.Read(token); err != nil {
 		return "", err
@@ -41,9 +143,9 @@ func (s *PasswordService) CreateResetToken(email string) (string, error) {
 		return "", err
 	}
 
-	// Here you would typically send an email with the reset link.
-	// For this example, we'll just return the token.
-	// log.Printf("Password reset token for %s: %s", email, tokenStr)
+	if err := s.notifier.SendPasswordReset(&user, tokenStr, resetToken.ExpiresAt); err != nil {
+		return "", err
+	}
 
 	return tokenStr, nil
 }
@@ -60,13 +162,7 @@ func (s *PasswordService) ResetPassword(token, newPassword string) error {
 		return errors.New("user not found")
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return err
-	}
-
-	user.PasswordHash = string(hashedPassword)
-	if err := database.DB.Save(&user).Error; err != nil {
+	if err := s.SetPassword(&user, newPassword); err != nil {
 		return err
 	}
 
@@ -76,5 +172,7 @@ func (s *PasswordService) ResetPassword(token, newPassword string) error {
 		// log.Printf("Failed to delete used reset token: %v", err)
 	}
 
+	s.notifier.SendSecurityAlert(&user, "Password reset", "Your password was reset using a password reset link.", "")
+
 	return nil
 }
@@ -0,0 +1,62 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+)
+
+func TestPasswordService_ResetPasswordEnforcesPolicy(t *testing.T) {
+	_, user := setupAuthTest(t)
+	passwordService := services.NewPasswordService(nil, 3)
+
+	token, err := passwordService.CreateResetToken(user.Email)
+	require.NoError(t, err)
+
+	var policyErr *utils.PasswordPolicyError
+	err = passwordService.ResetPassword(token, "regularuser-2024")
+	require.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, "contains_user_info", policyErr.Violations[0].Code)
+
+	// A rejected password must not consume the token.
+	require.NoError(t, passwordService.ResetPassword(token, "a-much-better-secret"))
+
+	var reloaded models.User
+	require.NoError(t, database.DB.First(&reloaded, user.ID).Error)
+	assert.True(t, utils.CheckPasswordHash("a-much-better-secret", reloaded.PasswordHash))
+
+	assert.Error(t, passwordService.ResetPassword(token, "another-good-secret"))
+}
+
+func TestPasswordService_SetPasswordRejectsRecentPasswords(t *testing.T) {
+	_, user := setupAuthTest(t)
+	passwordService := services.NewPasswordService(nil, 3)
+
+	require.NoError(t, passwordService.SetPassword(user, "initial-secret"))
+	require.NoError(t, passwordService.SetPassword(user, "first-new-secret"))
+	require.NoError(t, passwordService.SetPassword(user, "second-new-secret"))
+	assert.NotNil(t, user.PasswordChangedAt)
+
+	var policyErr *utils.PasswordPolicyError
+	for _, reused := range []string{"second-new-secret", "first-new-secret", "initial-secret"} {
+		err := passwordService.SetPassword(user, reused)
+		require.True(t, errors.As(err, &policyErr), reused)
+		assert.Equal(t, "password_reused", policyErr.Violations[0].Code)
+	}
+
+	// Only the previous two hashes are kept, so the oldest one is free again
+	// after one more change.
+	require.NoError(t, passwordService.SetPassword(user, "third-new-secret"))
+	require.NoError(t, passwordService.SetPassword(user, "initial-secret"))
+
+	var count int64
+	database.DB.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+)
+
+// PersonalAccessTokenPrefix marks bearer tokens that are personal access
+// tokens rather than JWTs.
+const PersonalAccessTokenPrefix = "pat_"
+
+// tokenTouchInterval throttles last-used updates so that busy scripts don't
+// write on every request.
+const tokenTouchInterval = time.Minute
+
+type PersonalAccessTokenService struct {
+	rbacService *RBACService
+}
+
+func NewPersonalAccessTokenService(rbacService *RBACService) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{rbacService: rbacService}
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// CreateToken issues a token for the user limited to the requested
+// permissions, each of which the user must currently hold. The plain token is
+// only returned here.
+func (s *PersonalAccessTokenService) CreateToken(userID uint, input *models.PersonalAccessTokenInput) (*models.CreatedPersonalAccessTokenResponse, error) {
+	granted, err := s.rbacService.GetUserPermissions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	grantedByName := make(map[string]*models.Permission, len(granted))
+	for _, permission := range granted {
+		grantedByName[permission.Name] = permission
+	}
+
+	permissions := make([]*models.Permission, 0, len(input.Permissions))
+	seen := make(map[string]bool, len(input.Permissions))
+	for _, name := range input.Permissions {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		permission, ok := grantedByName[name]
+		if !ok {
+			return nil, errors.New("permission not granted to user: " + name)
+		}
+		permissions = append(permissions, permission)
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	plain := PersonalAccessTokenPrefix + secret
+
+	token := models.PersonalAccessToken{
+		UserID:      userID,
+		Name:        input.Name,
+		TokenHash:   utils.HashToken(plain),
+		Prefix:      plain[:len(PersonalAccessTokenPrefix)+6],
+		ExpiresAt:   time.Now().AddDate(0, 0, input.ExpiresInDays),
+		Permissions: permissions,
+	}
+	if err := database.DB.Create(&token).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedPersonalAccessTokenResponse{
+		PersonalAccessTokenResponse: *token.ToResponse(),
+		Token:                       plain,
+	}, nil
+}
+
+func (s *PersonalAccessTokenService) GetTokens(userID uint) ([]*models.PersonalAccessTokenResponse, error) {
+	var tokens []models.PersonalAccessToken
+	if err := database.DB.Preload("Permissions").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]*models.PersonalAccessTokenResponse, 0, len(tokens))
+	for i := range tokens {
+		responses = append(responses, tokens[i].ToResponse())
+	}
+	return responses, nil
+}
+
+func (s *PersonalAccessTokenService) RevokeToken(userID, tokenID uint) error {
+	var token models.PersonalAccessToken
+	if err := database.DB.Where("id = ? AND user_id = ?", tokenID, userID).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("token not found")
+		}
+		return err
+	}
+
+	if token.RevokedAt != nil {
+		return nil
+	}
+
+	return database.DB.Model(&token).Update("revoked_at", time.Now()).Error
+}
+
+// Authenticate resolves a plain token to its active record, with its
+// permissions and its owner's permissions loaded, and records where it was
+// last used from.
+func (s *PersonalAccessTokenService) Authenticate(plain, ip string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	if err := database.DB.Preload("Permissions").Preload("User.Role.Permissions").Preload("User.Roles.Permissions").
+		Where("token_hash = ?", utils.HashToken(plain)).
+		First(&token).Error; err != nil {
+		return nil, errors.New("invalid token")
+	}
+	if err := withInheritedPermissions(&token.User); err != nil {
+		return nil, err
+	}
+
+	if !token.Active() {
+		return nil, errors.New("token is revoked or expired")
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || token.LastUsedAt.Before(now.Add(-tokenTouchInterval)) || token.LastUsedIP != ip {
+		database.DB.Model(&token).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+
+	return &token, nil
+}
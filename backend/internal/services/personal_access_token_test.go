@@ -0,0 +1,95 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+)
+
+func setupTokenTest(t *testing.T) (*services.PersonalAccessTokenService, *models.User) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.PersonalAccessToken{}))
+	database.DB = db
+
+	readPerm := models.Permission{Name: "users.read", Resource: "users", Action: "read"}
+	createPerm := models.Permission{Name: "users.create", Resource: "users", Action: "create"}
+	db.Create(&readPerm)
+	db.Create(&createPerm)
+
+	role := models.Role{Name: "Manager", Description: "Manager Role"}
+	db.Create(&role)
+	db.Model(&role).Association("Permissions").Append(&readPerm)
+
+	user := models.User{Email: "manager@example.com", Username: "manager", RoleID: role.ID, IsActive: true}
+	db.Create(&user)
+
+	return services.NewPersonalAccessTokenService(services.NewRBACService(db)), &user
+}
+
+func TestPersonalAccessToken_CreateAndAuthenticate(t *testing.T) {
+	service, user := setupTokenTest(t)
+
+	created, err := service.CreateToken(user.ID, &models.PersonalAccessTokenInput{
+		Name:          "reporting script",
+		ExpiresInDays: 30,
+		Permissions:   []string{"users.read"},
+	})
+	require.NoError(t, err)
+	assert.True(t, services.IsPersonalAccessToken(created.Token))
+	assert.Equal(t, []string{"users.read"}, created.Permissions)
+
+	var stored models.PersonalAccessToken
+	require.NoError(t, database.DB.First(&stored, created.ID).Error)
+	assert.NotEqual(t, created.Token, stored.TokenHash, "only the hash should be stored")
+
+	token, err := service.Authenticate(created.Token, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, token.User.ID)
+	assert.Equal(t, []string{"users.read"}, token.PermissionNames())
+
+	require.NoError(t, database.DB.First(&stored, created.ID).Error)
+	require.NotNil(t, stored.LastUsedAt)
+	assert.Equal(t, "10.0.0.1", stored.LastUsedIP)
+
+	_, err = service.Authenticate(created.Token+"x", "10.0.0.1")
+	assert.Error(t, err)
+}
+
+func TestPersonalAccessToken_RejectsPermissionsOutsideRole(t *testing.T) {
+	service, user := setupTokenTest(t)
+
+	_, err := service.CreateToken(user.ID, &models.PersonalAccessTokenInput{
+		Name:          "too broad",
+		ExpiresInDays: 30,
+		Permissions:   []string{"users.read", "users.create"},
+	})
+	assert.Error(t, err)
+}
+
+func TestPersonalAccessToken_RevokedAndExpired(t *testing.T) {
+	service, user := setupTokenTest(t)
+	input := &models.PersonalAccessTokenInput{Name: "ci", ExpiresInDays: 1, Permissions: []string{"users.read"}}
+
+	revoked, err := service.CreateToken(user.ID, input)
+	require.NoError(t, err)
+	assert.Error(t, service.RevokeToken(user.ID+1, revoked.ID), "other users cannot revoke the token")
+	require.NoError(t, service.RevokeToken(user.ID, revoked.ID))
+	_, err = service.Authenticate(revoked.Token, "10.0.0.1")
+	assert.Error(t, err)
+
+	expired, err := service.CreateToken(user.ID, input)
+	require.NoError(t, err)
+	database.DB.Model(&models.PersonalAccessToken{}).Where("id = ?", expired.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	_, err = service.Authenticate(expired.Token, "10.0.0.1")
+	assert.Error(t, err)
+
+	tokens, err := service.GetTokens(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, tokens, 2)
+}
@@ -10,87 +10,227 @@ import (
 
 type RBACService struct {
 	DB *gorm.DB
+
+	restrictUnverified    bool
+	unverifiedPermissions map[string]bool
 }
 
 func NewRBACService(db *gorm.DB) *RBACService {
 	return &RBACService{DB: db}
 }
 
+// RestrictUnverified limits users without a verified email to the named
+// permissions, on top of whatever their role grants.
+func (s *RBACService) RestrictUnverified(permissionNames []string) *RBACService {
+	s.restrictUnverified = true
+	s.unverifiedPermissions = make(map[string]bool, len(permissionNames))
+	for _, name := range permissionNames {
+		s.unverifiedPermissions[name] = true
+	}
+	return s
+}
+
 func (s *RBACService) CheckPermission(userID uint, resource, action string) (bool, error) {
-	var user models.User
-	if err := s.DB.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
+	permissions, err := s.GetUserPermissions(userID)
+	if err != nil {
 		return false, err
 	}
 
+	return hasPermission(permissions, resource, action), nil
+}
+
+// UserHasPermission is CheckPermission for a user loaded as UserPermissions
+// expects.
+func (s *RBACService) UserHasPermission(user *models.User, resource, action string) bool {
+	return hasPermission(s.UserPermissions(user), resource, action)
+}
+
+func hasPermission(permissions []*models.Permission, resource, action string) bool {
 	requiredPermission := fmt.Sprintf("%s.%s", resource, action)
 	
-	for _, permission := range user.Role.Permissions {
+	for _, permission := range permissions {
 		if permission.Name == requiredPermission {
-			return true, nil
+			return true
 		}
 		
 		if permission.Resource == resource && permission.Action == action {
-			return true, nil
+			return true
 		}
 	}
 
-	return false, nil
+	return false
+}
+
+// GetServiceAccountPermissions returns the permissions of a service account,
+// which are exactly those of its role, inherited ones included.
+func (s *RBACService) GetServiceAccountPermissions(accountID uint) ([]*models.Permission, error) {
+	var account models.ServiceAccount
+	if err := s.DB.Preload("Role.Permissions").First(&account, accountID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := fillInheritedPermissions(s.DB, &account.Role); err != nil {
+		return nil, err
+	}
+
+	return rolePermissions([]models.Role{account.Role}), nil
 }
 
+func (s *RBACService) CheckServiceAccountPermission(accountID uint, resource, action string) (bool, error) {
+	permissions, err := s.GetServiceAccountPermissions(accountID)
+	if err != nil {
+		return false, err
+	}
+
+	return hasPermission(permissions, resource, action), nil
+}
+
+func (s *RBACService) ServiceAccountHasRole(accountID uint, roleName string) (bool, error) {
+	var account models.ServiceAccount
+	if err := s.DB.Preload("Role").First(&account, accountID).Error; err != nil {
+		return false, err
+	}
+
+	return account.Role.Name == roleName, nil
+}
+
+// GetUserPermissions returns the union of the permissions of all the user's
+// roles, including those they inherit.
 func (s *RBACService) GetUserPermissions(userID uint) ([]*models.Permission, error) {
 	var user models.User
-	if err := s.DB.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
+	if err := s.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	if err := fillUserInheritedPermissions(s.DB, &user); err != nil {
 		return nil, err
 	}
 
-	return user.Role.Permissions, nil
+	return s.UserPermissions(&user), nil
+}
+
+// UserPermissions is GetUserPermissions for a user that is already loaded with
+// their roles' own and inherited permissions, so it runs no queries.
+func (s *RBACService) UserPermissions(user *models.User) []*models.Permission {
+	permissions := []*models.Permission{}
+	for _, permission := range rolePermissions(user.AllRoles()) {
+		if s.restrictUnverified && user.EmailVerifiedAt == nil && !s.unverifiedPermissions[permission.Name] {
+			continue
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions
 }
 
+// rolePermissions returns the own and inherited permissions of the roles,
+// without duplicates.
+func rolePermissions(roles []models.Role) []*models.Permission {
+	permissions := []*models.Permission{}
+	seen := map[uint]bool{}
+	for _, role := range roles {
+		for _, set := range [][]*models.Permission{role.Permissions, role.InheritedPermissions} {
+			for _, permission := range set {
+				if !seen[permission.ID] {
+					seen[permission.ID] = true
+					permissions = append(permissions, permission)
+				}
+			}
+		}
+	}
+
+	return permissions
+}
+
+// HasRole reports whether the role is among the user's roles.
 func (s *RBACService) HasRole(userID uint, roleName string) (bool, error) {
-	var user models.User
-	if err := s.DB.Preload("Role").First(&user, userID).Error; err != nil {
+	user, err := s.loadUserRoles(userID)
+	if err != nil {
 		return false, err
 	}
 
-	return user.Role.Name == roleName, nil
+	return user.HasRole(roleName), nil
+}
+
+// GetUserRoles returns all of the user's roles, the primary one first.
+func (s *RBACService) GetUserRoles(userID uint) ([]models.Role, error) {
+	user, err := s.loadUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.AllRoles(), nil
 }
 
-func (s *RBACService) GetUserRole(userID uint) (*models.Role, error) {
+func (s *RBACService) loadUserRoles(userID uint) (*models.User, error) {
 	var user models.User
-	if err := s.DB.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
+	if err := s.DB.Preload("Role").Preload("Roles").First(&user, userID).Error; err != nil {
 		return nil, err
 	}
 
-	return &user.Role, nil
+	return &user, nil
 }
 
+// CanManageUser reports whether the manager's highest role has a higher level
+// than every one of the target's roles.
 func (s *RBACService) CanManageUser(managerID, targetUserID uint) (bool, error) {
-	managerRole, err := s.GetUserRole(managerID)
+	managerRoles, err := s.GetUserRoles(managerID)
 	if err != nil {
 		return false, err
 	}
 
-	targetRole, err := s.GetUserRole(targetUserID)
+	return s.roleCanManageUser(managerRoles, targetUserID)
+}
+
+// CanRoleManageUser applies the CanManageUser ranking to a principal that is
+// only known by its role, such as a service account.
+func (s *RBACService) CanRoleManageUser(roleID, targetUserID uint) (bool, error) {
+	var role models.Role
+	if err := s.DB.First(&role, roleID).Error; err != nil {
+		return false, err
+	}
+
+	return s.roleCanManageUser([]models.Role{role}, targetUserID)
+}
+
+func (s *RBACService) roleCanManageUser(managerRoles []models.Role, targetUserID uint) (bool, error) {
+	targetRoles, err := s.GetUserRoles(targetUserID)
 	if err != nil {
 		return false, err
 	}
 
-	roleHierarchy := map[string]int{
-		"Super Admin": 4,
-		"Admin":       3,
-		"Manager":     2,
-		"User":        1,
+	return canManageRoles(managerRoles, targetRoles), nil
+}
+
+// CanAssignRoles reports whether the manager's highest role has a higher level
+// than every one of the roles, so that they may hand them out.
+func (s *RBACService) CanAssignRoles(managerID uint, roleIDs []uint) (bool, error) {
+	managerRoles, err := s.GetUserRoles(managerID)
+	if err != nil {
+		return false, err
 	}
 
-	managerLevel, exists := roleHierarchy[managerRole.Name]
-	if !exists {
-		return false, nil
+	return s.rolesCanAssign(managerRoles, roleIDs)
+}
+
+// CanRoleAssignRoles applies CanAssignRoles to a principal that is only known
+// by its role, such as a service account.
+func (s *RBACService) CanRoleAssignRoles(roleID uint, roleIDs []uint) (bool, error) {
+	var role models.Role
+	if err := s.DB.First(&role, roleID).Error; err != nil {
+		return false, err
 	}
 
-	targetLevel, exists := roleHierarchy[targetRole.Name]
-	if !exists {
-		return false, nil
+	return s.rolesCanAssign([]models.Role{role}, roleIDs)
+}
+
+func (s *RBACService) rolesCanAssign(managerRoles []models.Role, roleIDs []uint) (bool, error) {
+	var roles []models.Role
+	if len(roleIDs) > 0 {
+		if err := s.DB.Where("id IN ?", roleIDs).Find(&roles).Error; err != nil {
+			return false, err
+		}
 	}
 
-	return managerLevel > targetLevel, nil
-}
\ No newline at end of file
+	return outranksRoles(managerRoles, roles), nil
+}
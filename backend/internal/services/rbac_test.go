@@ -2,6 +2,7 @@ package services_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
@@ -101,3 +102,230 @@ func TestRBACService_HasRole(t *testing.T) {
 	assert.NoError(t, err)
 	assert.False(t, hasRole, "Regular user should not have Admin role")
 }
+
+func TestRBACService_RestrictUnverified(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewRBACService(db).RestrictUnverified([]string{"profile.read"})
+
+	role := models.Role{Name: "Admin", Description: "Admin Role"}
+	db.Create(&role)
+
+	profileRead := models.Permission{Name: "profile.read", Resource: "profile", Action: "read"}
+	usersRead := models.Permission{Name: "users.read", Resource: "users", Action: "read"}
+	db.Create(&profileRead)
+	db.Create(&usersRead)
+	db.Model(&role).Association("Permissions").Append(&profileRead, &usersRead)
+
+	user := models.User{Email: "admin@example.com", Username: "adminuser", RoleID: role.ID}
+	db.Create(&user)
+
+	hasPerm, err := service.CheckPermission(user.ID, "users", "read")
+	assert.NoError(t, err)
+	assert.False(t, hasPerm, "Unverified users should only get the allowed permissions")
+
+	hasPerm, err = service.CheckPermission(user.ID, "profile", "read")
+	assert.NoError(t, err)
+	assert.True(t, hasPerm, "Allowed permissions still require the role to grant them")
+
+	db.Model(&user).Update("email_verified_at", time.Now())
+
+	hasPerm, err = service.CheckPermission(user.ID, "users", "read")
+	assert.NoError(t, err)
+	assert.True(t, hasPerm, "Verified users get their full role permissions")
+}
+
+func TestRBACService_MultipleRoles(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewRBACService(db)
+
+	// Create roles; managers rank above users
+	userRole := models.Role{Name: "User", Description: "User Role", Level: 10}
+	db.Create(&userRole)
+	managerRole := models.Role{Name: "Manager", Description: "Manager Role", Level: 20}
+	auditorRole := models.Role{Name: "Auditor", Description: "Auditor Role", Level: 30}
+	db.Create(&managerRole)
+	db.Create(&auditorRole)
+
+	usersRead := models.Permission{Name: "users.read", Resource: "users", Action: "read"}
+	logsRead := models.Permission{Name: "activity_logs.read", Resource: "activity_logs", Action: "read"}
+	db.Create(&usersRead)
+	db.Create(&logsRead)
+	db.Model(&managerRole).Association("Permissions").Append(&usersRead)
+	db.Model(&auditorRole).Association("Permissions").Append(&usersRead, &logsRead)
+
+	// The auditor role comes on top of the primary user role
+	multiUser := models.User{Email: "multi@example.com", Username: "multiuser", RoleID: userRole.ID, Roles: []models.Role{userRole, auditorRole}}
+	manager := models.User{Email: "manager@example.com", Username: "manager", RoleID: managerRole.ID}
+	db.Create(&multiUser)
+	db.Create(&manager)
+
+	permissions, err := service.GetUserPermissions(multiUser.ID)
+	assert.NoError(t, err)
+	assert.Len(t, permissions, 2, "Permissions should be the union of all roles")
+
+	hasPerm, err := service.CheckPermission(multiUser.ID, "activity_logs", "read")
+	assert.NoError(t, err)
+	assert.True(t, hasPerm, "Additional roles should grant their permissions")
+
+	hasRole, err := service.HasRole(multiUser.ID, "Auditor")
+	assert.NoError(t, err)
+	assert.True(t, hasRole, "HasRole should check every role of the user")
+
+	roles, err := service.GetUserRoles(multiUser.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "User", roles[0].Name, "The primary role should come first")
+
+	canManage, err := service.CanManageUser(manager.ID, multiUser.ID)
+	assert.NoError(t, err)
+	assert.False(t, canManage, "Every role of the target must rank below the manager")
+
+	db.Model(&multiUser).Association("Roles").Delete(&auditorRole)
+	canManage, err = service.CanManageUser(manager.ID, multiUser.ID)
+	assert.NoError(t, err)
+	assert.True(t, canManage, "Managers should manage users below them")
+}
+
+func TestRBACService_RoleHierarchy(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewRBACService(db)
+
+	// Editors inherit from viewers and rank above them, contractors rank
+	// alongside editors
+	viewerRole := models.Role{Name: "Viewer", Description: "Viewer Role", Level: 10}
+	db.Create(&viewerRole)
+	editorRole := models.Role{Name: "Editor", Description: "Editor Role", Level: 20, ParentID: &viewerRole.ID}
+	otherRole := models.Role{Name: "Contractor", Description: "Contractor Role", Level: 20}
+	db.Create(&editorRole)
+	db.Create(&otherRole)
+
+	usersRead := models.Permission{Name: "users.read", Resource: "users", Action: "read"}
+	usersUpdate := models.Permission{Name: "users.update", Resource: "users", Action: "update"}
+	db.Create(&usersRead)
+	db.Create(&usersUpdate)
+	db.Model(&viewerRole).Association("Permissions").Append(&usersRead)
+	db.Model(&editorRole).Association("Permissions").Append(&usersUpdate)
+
+	editor := models.User{Email: "editor@example.com", Username: "editor", RoleID: editorRole.ID}
+	viewer := models.User{Email: "viewer@example.com", Username: "viewer", RoleID: viewerRole.ID}
+	contractor := models.User{Email: "contractor@example.com", Username: "contractor", RoleID: otherRole.ID}
+	db.Create(&editor)
+	db.Create(&viewer)
+	db.Create(&contractor)
+
+	hasPerm, err := service.CheckPermission(editor.ID, "users", "read")
+	assert.NoError(t, err)
+	assert.True(t, hasPerm, "Child roles should inherit their parent's permissions")
+
+	hasPerm, err = service.CheckPermission(viewer.ID, "users", "update")
+	assert.NoError(t, err)
+	assert.False(t, hasPerm, "Parent roles should not get their children's permissions")
+
+	canManage, err := service.CanManageUser(editor.ID, viewer.ID)
+	assert.NoError(t, err)
+	assert.True(t, canManage, "Higher levels should manage lower ones")
+
+	canManage, err = service.CanManageUser(viewer.ID, editor.ID)
+	assert.NoError(t, err)
+	assert.False(t, canManage, "Lower levels should not manage higher ones")
+
+	canManage, err = service.CanManageUser(editor.ID, contractor.ID)
+	assert.NoError(t, err)
+	assert.False(t, canManage, "Roles of the same level should not manage each other")
+
+	canManage, err = service.CanRoleManageUser(editorRole.ID, viewer.ID)
+	assert.NoError(t, err)
+	assert.True(t, canManage, "Service account roles should follow the same hierarchy")
+}
+
+func TestRBACService_SuperAdminManagesCustomRoles(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewRBACService(db)
+
+	// Custom roles start at level 0, below every system role
+	superAdminRole := models.Role{Name: "Super Admin", IsSystemRole: true, Level: 40}
+	adminRole := models.Role{Name: "Admin", IsSystemRole: true, Level: 30}
+	supportRole := models.Role{Name: "Support", Description: "Support Role"}
+	db.Create(&superAdminRole)
+	db.Create(&adminRole)
+	db.Create(&supportRole)
+
+	superAdmin := models.User{Email: "super@example.com", Username: "superadmin", RoleID: superAdminRole.ID}
+	admin := models.User{Email: "admin@example.com", Username: "admin", RoleID: adminRole.ID}
+	support := models.User{Email: "support@example.com", Username: "support", RoleID: supportRole.ID}
+	db.Create(&superAdmin)
+	db.Create(&admin)
+	db.Create(&support)
+
+	canManage, err := service.CanManageUser(superAdmin.ID, support.ID)
+	assert.NoError(t, err)
+	assert.True(t, canManage, "Super Admin should manage users with custom roles")
+
+	canManage, err = service.CanManageUser(admin.ID, support.ID)
+	assert.NoError(t, err)
+	assert.True(t, canManage, "Admin should manage users with custom roles")
+
+	canManage, err = service.CanManageUser(support.ID, admin.ID)
+	assert.NoError(t, err)
+	assert.False(t, canManage, "Custom roles should not manage system roles above them")
+}
+
+func TestRBACService_CanAssignRoles(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewRBACService(db)
+
+	adminRole := models.Role{Name: "Admin", IsSystemRole: true, Level: 30}
+	superAdminRole := models.Role{Name: "Super Admin", IsSystemRole: true, Level: 40}
+	supportRole := models.Role{Name: "Support", Description: "Support Role"}
+	db.Create(&adminRole)
+	db.Create(&superAdminRole)
+	db.Create(&supportRole)
+
+	admin := models.User{Email: "admin@example.com", Username: "admin", RoleID: adminRole.ID}
+	db.Create(&admin)
+
+	canAssign, err := service.CanAssignRoles(admin.ID, []uint{supportRole.ID})
+	assert.NoError(t, err)
+	assert.True(t, canAssign, "Admins should assign roles below their own")
+
+	canAssign, err = service.CanAssignRoles(admin.ID, []uint{supportRole.ID, superAdminRole.ID})
+	assert.NoError(t, err)
+	assert.False(t, canAssign, "Every assigned role must rank below the assigner")
+
+	canAssign, err = service.CanAssignRoles(admin.ID, []uint{adminRole.ID})
+	assert.NoError(t, err)
+	assert.False(t, canAssign, "Roles of the assigner's own level should not be assignable")
+}
+
+func TestRBACService_ServiceAccountRolesNeedRank(t *testing.T) {
+	db := setupTestDB(t)
+	service := services.NewRBACService(db)
+
+	managerRole := models.Role{Name: "Manager", IsSystemRole: true, Level: 20}
+	superAdminRole := models.Role{Name: "Super Admin", IsSystemRole: true, Level: 40}
+	syncRole := models.Role{Name: "Sync", Description: "Directory sync"}
+	db.Create(&managerRole)
+	db.Create(&superAdminRole)
+	db.Create(&syncRole)
+
+	manager := models.User{Email: "manager@example.com", Username: "manager", RoleID: managerRole.ID}
+	db.Create(&manager)
+
+	// Giving a service account the Super Admin role would let a lower-ranked
+	// caller mint API keys acting as one.
+	canAssign, err := service.CanAssignRoles(manager.ID, []uint{superAdminRole.ID})
+	assert.NoError(t, err)
+	assert.False(t, canAssign, "A Manager should not give a service account the Super Admin role")
+
+	canAssign, err = service.CanAssignRoles(manager.ID, []uint{syncRole.ID})
+	assert.NoError(t, err)
+	assert.True(t, canAssign)
+
+	// A service account managing others is ranked by its own role.
+	canAssign, err = service.CanRoleAssignRoles(managerRole.ID, []uint{superAdminRole.ID})
+	assert.NoError(t, err)
+	assert.False(t, canAssign, "A Manager service account should not hand out the Super Admin role")
+
+	canAssign, err = service.CanRoleAssignRoles(managerRole.ID, []uint{managerRole.ID})
+	assert.NoError(t, err)
+	assert.False(t, canAssign, "A service account should not manage accounts of its own rank")
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+)
+
+var ErrRegistrationRuleNotFound = errors.New("registration role rule not found")
+
+// RegistrationService manages which role self-registered users start with:
+// the rule for their email domain, otherwise the default role.
+// Both are stored in the database and read on every registration.
+type RegistrationService struct{}
+
+func NewRegistrationService() *RegistrationService {
+	return &RegistrationService{}
+}
+
+func (s *RegistrationService) GetSettings() (*models.RegistrationSettings, error) {
+	defaultRole, err := defaultRegistrationRole()
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var rules []models.RegistrationRoleRule
+	if err := database.DB.Preload("Role").Order("domain").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.RegistrationSettings{DefaultRole: defaultRole, Rules: rules}, nil
+}
+
+func (s *RegistrationService) SetDefaultRole(roleID, actorID uint, ipAddress, userAgent string) (*models.Role, error) {
+	var role models.Role
+	if err := database.DB.First(&role, roleID).Error; err != nil {
+		return nil, errors.New("role not found")
+	}
+
+	setting := models.SystemSetting{
+		Key:         models.SettingDefaultRoleID,
+		Value:       strconv.FormatUint(uint64(role.ID), 10),
+		UpdatedByID: &actorID,
+	}
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by_id", "updated_at"}),
+	}).Create(&setting).Error
+	if err != nil {
+		return nil, err
+	}
+
+	logSettingsActivity(actorID, "default_role_updated", fmt.Sprintf("Default registration role set to %s", role.Name), ipAddress, userAgent)
+	return &role, nil
+}
+
+func (s *RegistrationService) CreateRule(req *models.RegistrationRoleRuleInput, actorID uint, ipAddress, userAgent string) (*models.RegistrationRoleRule, error) {
+	domain := normalizeDomain(req.Domain)
+
+	var existing models.RegistrationRoleRule
+	if err := database.DB.Where("domain = ?", domain).First(&existing).Error; err == nil {
+		return nil, errors.New("a rule for this domain already exists")
+	}
+
+	var role models.Role
+	if err := database.DB.First(&role, req.RoleID).Error; err != nil {
+		return nil, errors.New("role not found")
+	}
+
+	rule := models.RegistrationRoleRule{Domain: domain, RoleID: role.ID}
+	if err := database.DB.Create(&rule).Error; err != nil {
+		return nil, err
+	}
+	rule.Role = role
+
+	logSettingsActivity(actorID, "registration_rule_created", fmt.Sprintf("Users registering with @%s get role %s", domain, role.Name), ipAddress, userAgent)
+	return &rule, nil
+}
+
+func (s *RegistrationService) UpdateRule(id uint, req *models.RegistrationRoleRuleInput, actorID uint, ipAddress, userAgent string) (*models.RegistrationRoleRule, error) {
+	var rule models.RegistrationRoleRule
+	if err := database.DB.First(&rule, id).Error; err != nil {
+		return nil, ErrRegistrationRuleNotFound
+	}
+
+	domain := normalizeDomain(req.Domain)
+
+	var existing models.RegistrationRoleRule
+	if err := database.DB.Where("domain = ? AND id != ?", domain, id).First(&existing).Error; err == nil {
+		return nil, errors.New("a rule for this domain already exists")
+	}
+
+	var role models.Role
+	if err := database.DB.First(&role, req.RoleID).Error; err != nil {
+		return nil, errors.New("role not found")
+	}
+
+	rule.Domain = domain
+	rule.RoleID = role.ID
+	if err := database.DB.Omit("Role").Save(&rule).Error; err != nil {
+		return nil, err
+	}
+	rule.Role = role
+
+	logSettingsActivity(actorID, "registration_rule_updated", fmt.Sprintf("Users registering with @%s get role %s", domain, role.Name), ipAddress, userAgent)
+	return &rule, nil
+}
+
+func (s *RegistrationService) DeleteRule(id, actorID uint, ipAddress, userAgent string) error {
+	var rule models.RegistrationRoleRule
+	if err := database.DB.First(&rule, id).Error; err != nil {
+		return ErrRegistrationRuleNotFound
+	}
+
+	if err := database.DB.Delete(&rule).Error; err != nil {
+		return err
+	}
+
+	logSettingsActivity(actorID, "registration_rule_deleted", fmt.Sprintf("Removed the registration role rule for @%s", rule.Domain), ipAddress, userAgent)
+	return nil
+}
+
+// registrationRole picks the role of a user registering with email.
+func registrationRole(email string) (*models.Role, error) {
+	_, domain, _ := strings.Cut(strings.ToLower(email), "@")
+
+	var rule models.RegistrationRoleRule
+	err := database.DB.Preload("Role").Where("domain = ?", domain).First(&rule).Error
+	if err == nil {
+		return &rule.Role, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	role, err := defaultRegistrationRole()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("default role not found")
+	}
+	return role, err
+}
+
+// defaultRegistrationRole loads the configured default role. Databases where
+// it was never set keep using the seeded "User" role.
+func defaultRegistrationRole() (*models.Role, error) {
+	var setting models.SystemSetting
+	err := database.DB.Where(&models.SystemSetting{Key: models.SettingDefaultRoleID}).First(&setting).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var role models.Role
+	if err == nil {
+		roleID, _ := strconv.ParseUint(setting.Value, 10, 32)
+		err = database.DB.First(&role, roleID).Error
+	} else {
+		err = database.DB.Where("name = ?", "User").First(&role).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// isRegistrationRole reports whether a role is the default registration role
+// or used by a registration role rule, and so must not be deleted.
+func isRegistrationRole(roleID uint) (bool, error) {
+	var count int64
+	setting := models.SystemSetting{Key: models.SettingDefaultRoleID, Value: strconv.FormatUint(uint64(roleID), 10)}
+	if err := database.DB.Model(&models.SystemSetting{}).Where(&setting).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := database.DB.Model(&models.RegistrationRoleRule{}).Where("role_id = ?", roleID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "@")
+}
+
+func logSettingsActivity(userID uint, action, details, ipAddress, userAgent string) {
+	activityLog := models.ActivityLog{
+		UserID:    &userID,
+		Action:    action,
+		Resource:  "settings",
+		Details:   details,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+}
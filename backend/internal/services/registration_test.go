@@ -0,0 +1,142 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/mailer"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+)
+
+type failingMailer struct{}
+
+func (failingMailer) Send(*mailer.Message) error {
+	return errors.New("smtp unavailable")
+}
+
+func registerUser(t *testing.T, authService *services.AuthService, email, username string) *models.TokenResponse {
+	response, err := authService.Register(&models.RegisterRequest{
+		Email:     email,
+		Username:  username,
+		Password:  "Corr3ct-Horse-Battery",
+		FirstName: "New",
+		LastName:  "User",
+	}, "10.0.0.1", "test")
+	require.NoError(t, err)
+	return response
+}
+
+func TestRegistration_DefaultRoleSurvivesRename(t *testing.T) {
+	authService, admin := setupAuthTest(t)
+	service := services.NewRegistrationService()
+
+	settings, err := service.GetSettings()
+	require.NoError(t, err)
+	require.NotNil(t, settings.DefaultRole)
+	assert.Equal(t, "User", settings.DefaultRole.Name)
+
+	member := models.Role{Name: "Member"}
+	database.DB.Create(&member)
+
+	_, err = service.SetDefaultRole(member.ID, admin.ID, "10.0.0.1", "test")
+	require.NoError(t, err)
+	_, err = service.SetDefaultRole(member.ID, admin.ID, "10.0.0.1", "test")
+	require.NoError(t, err)
+
+	database.DB.Model(&member).Update("name", "Members")
+
+	response := registerUser(t, authService, "first@example.com", "firstuser")
+	assert.Equal(t, member.ID, response.User.RoleID)
+
+	var changes int64
+	database.DB.Model(&models.ActivityLog{}).Where("user_id = ? AND action = ?", admin.ID, "default_role_updated").Count(&changes)
+	assert.Equal(t, int64(2), changes)
+
+	roleService := services.NewRoleService()
+	database.DB.Where("role_id = ?", member.ID).Delete(&models.User{})
+	assert.EqualError(t, roleService.DeleteRole(member.ID), "cannot delete role used for new registrations")
+}
+
+func TestRegistration_DomainRules(t *testing.T) {
+	authService, admin := setupAuthTest(t)
+	service := services.NewRegistrationService()
+
+	staff := models.Role{Name: "Staff"}
+	database.DB.Create(&staff)
+
+	rule, err := service.CreateRule(&models.RegistrationRoleRuleInput{Domain: "@Corp.Example", RoleID: staff.ID}, admin.ID, "10.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "corp.example", rule.Domain)
+
+	_, err = service.CreateRule(&models.RegistrationRoleRuleInput{Domain: "corp.example", RoleID: staff.ID}, admin.ID, "10.0.0.1", "test")
+	assert.Error(t, err)
+
+	response := registerUser(t, authService, "jane@CORP.example", "janedoe")
+	assert.Equal(t, staff.ID, response.User.RoleID)
+
+	response = registerUser(t, authService, "joe@other.example", "joedoe")
+	assert.Equal(t, admin.RoleID, response.User.RoleID)
+
+	_, err = service.UpdateRule(rule.ID, &models.RegistrationRoleRuleInput{Domain: "other.example", RoleID: staff.ID}, admin.ID, "10.0.0.1", "test")
+	require.NoError(t, err)
+	response = registerUser(t, authService, "ann@other.example", "anndoe")
+	assert.Equal(t, staff.ID, response.User.RoleID)
+
+	require.NoError(t, service.DeleteRule(rule.ID, admin.ID, "10.0.0.1", "test"))
+	assert.ErrorIs(t, service.DeleteRule(rule.ID, admin.ID, "10.0.0.1", "test"), services.ErrRegistrationRuleNotFound)
+
+	var logs int64
+	database.DB.Model(&models.ActivityLog{}).Where("user_id = ? AND resource = ?", admin.ID, "settings").Count(&logs)
+	assert.Equal(t, int64(3), logs)
+}
+
+func TestRegistration_MissingDefaultRole(t *testing.T) {
+	authService, _ := setupAuthTest(t)
+	database.DB.Model(&models.Role{}).Where("name = ?", "User").Update("name", "Basic")
+
+	_, err := authService.Register(&models.RegisterRequest{
+		Email:     "first@example.com",
+		Username:  "firstuser",
+		Password:  "Corr3ct-Horse-Battery",
+		FirstName: "New",
+		LastName:  "User",
+	}, "10.0.0.1", "test")
+	assert.EqualError(t, err, "default role not found")
+}
+
+func TestRegistration_FailedVerificationEmailKeepsAccount(t *testing.T) {
+	_, user := setupAuthTest(t)
+	jwtService := utils.NewJWTService(testJWTConfig)
+	notifier := mailer.NewNotifier(failingMailer{}, "RBAC System", "http://localhost:3000")
+	emailService := services.NewEmailVerificationService(jwtService, testJWTConfig.Email, notifier)
+	authService := services.NewAuthService(
+		jwtService,
+		services.NewMFAService(testJWTConfig.MFA.Issuer),
+		services.NewLockoutService(testJWTConfig.Lockout, nil),
+		emailService,
+		services.NewPasswordService(nil, 3),
+		notifier,
+	)
+
+	// The account is created and usable; the link can be sent again later.
+	response := registerUser(t, authService, "mailless@example.com", "mailless")
+	assert.Equal(t, "mailless", response.User.Username)
+
+	userService := services.NewUserService(services.NewRBACService(database.DB), emailService, notifier, nil)
+	created, err := userService.CreateUser(&models.UserInput{
+		Email:     "created@example.com",
+		Username:  "created",
+		Password:  "Corr3ct-Horse-Battery",
+		FirstName: "Created",
+		LastName:  "User",
+		RoleID:    user.RoleID,
+	})
+	require.NoError(t, err)
+	assert.Nil(t, created.EmailVerifiedAt)
+}
@@ -20,6 +20,14 @@ func (s *RoleService) GetRoles() ([]models.Role, error) {
 	if err := database.DB.Preload("Permissions").Find(&roles).Error; err != nil {
 		return nil, err
 	}
+
+	hierarchy, err := loadRoleHierarchy(database.DB, true)
+	if err != nil {
+		return nil, err
+	}
+	for i := range roles {
+		roles[i].InheritedPermissions = hierarchy.inheritedPermissions(roles[i].ID)
+	}
 	return roles, nil
 }
 
@@ -31,6 +39,9 @@ func (s *RoleService) GetRoleByID(id uint) (*models.Role, error) {
 		}
 		return nil, err
 	}
+	if err := fillInheritedPermissions(database.DB, &role); err != nil {
+		return nil, err
+	}
 	return &role, nil
 }
 
@@ -45,6 +56,15 @@ func (s *RoleService) CreateRole(req *models.RoleInput) (*models.Role, error) {
 		Description:  req.Description,
 		IsSystemRole: false, // Only system can create system roles
 	}
+	if req.RequireMFA != nil {
+		role.RequireMFA = *req.RequireMFA
+	}
+	if req.AllowMagicLink != nil {
+		role.AllowMagicLink = *req.AllowMagicLink
+	}
+	if req.PasswordMaxAgeDays != nil {
+		role.PasswordMaxAgeDays = *req.PasswordMaxAgeDays
+	}
 
 	if err := database.DB.Create(&role).Error; err != nil {
 		return nil, err
@@ -98,6 +118,18 @@ func (s *RoleService) UpdateRole(id uint, req *models.RoleInput) (*models.Role,
 		role.Description = req.Description
 	}
 
+	if req.RequireMFA != nil {
+		role.RequireMFA = *req.RequireMFA
+	}
+
+	if req.AllowMagicLink != nil {
+		role.AllowMagicLink = *req.AllowMagicLink
+	}
+
+	if req.PasswordMaxAgeDays != nil {
+		role.PasswordMaxAgeDays = *req.PasswordMaxAgeDays
+	}
+
 	if err := database.DB.Save(&role).Error; err != nil {
 		return nil, err
 	}
@@ -137,7 +169,7 @@ func (s *RoleService) DeleteRole(id uint) error {
 	}
 
 	var userCount int64
-	if err := database.DB.Model(&models.User{}).Where("role_id = ?", id).Count(&userCount).Error; err != nil {
+	if err := usersWithRole(database.DB.Model(&models.User{}), id).Count(&userCount).Error; err != nil {
 		return err
 	}
 
@@ -145,6 +177,33 @@ func (s *RoleService) DeleteRole(id uint) error {
 		return errors.New("cannot delete role that is assigned to users")
 	}
 
+	var childCount int64
+	if err := database.DB.Model(&models.Role{}).Where("parent_id = ?", id).Count(&childCount).Error; err != nil {
+		return err
+	}
+
+	if childCount > 0 {
+		return errors.New("cannot delete role that other roles inherit from")
+	}
+
+	var serviceAccountCount int64
+	if err := database.DB.Model(&models.ServiceAccount{}).Where("role_id = ?", id).Count(&serviceAccountCount).Error; err != nil {
+		return err
+	}
+
+	if serviceAccountCount > 0 {
+		return errors.New("cannot delete role that is assigned to service accounts")
+	}
+
+	registrationRole, err := isRegistrationRole(id)
+	if err != nil {
+		return err
+	}
+
+	if registrationRole {
+		return errors.New("cannot delete role used for new registrations")
+	}
+
 	if err := database.DB.Model(&role).Association("Permissions").Clear(); err != nil {
 		return err
 	}
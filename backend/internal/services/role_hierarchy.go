@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSystemRoleHierarchy     = errors.New("cannot change the hierarchy of system roles")
+	ErrRoleHierarchyCycle      = errors.New("a role cannot inherit from itself or a role that inherits from it")
+	ErrRoleHierarchyNotAllowed = errors.New("roles cannot be placed at or above your own rank")
+	ErrRoleHierarchyRank       = errors.New("a role must rank above the roles it inherits from")
+)
+
+// roleHierarchy holds every role by ID so parent links can be followed
+// without a query per step.
+type roleHierarchy map[uint]*models.Role
+
+func loadRoleHierarchy(db *gorm.DB, withPermissions bool) (roleHierarchy, error) {
+	query := db
+	if withPermissions {
+		query = query.Preload("Permissions")
+	}
+
+	var roles []models.Role
+	if err := query.Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	hierarchy := roleHierarchy{}
+	for i := range roles {
+		hierarchy[roles[i].ID] = &roles[i]
+	}
+	return hierarchy, nil
+}
+
+// ancestors returns the IDs of the roles the role inherits from, nearest
+// first. It stops at a loop, which SetRoleHierarchy never lets happen.
+func (h roleHierarchy) ancestors(roleID uint) []uint {
+	ids := []uint{}
+	seen := map[uint]bool{roleID: true}
+	for role := h[roleID]; role != nil && role.ParentID != nil && !seen[*role.ParentID]; role = h[*role.ParentID] {
+		seen[*role.ParentID] = true
+		ids = append(ids, *role.ParentID)
+	}
+	return ids
+}
+
+// inheritsFrom reports whether other is among the ancestors of roleID.
+func (h roleHierarchy) inheritsFrom(roleID, other uint) bool {
+	for _, id := range h.ancestors(roleID) {
+		if id == other {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritedPermissions returns the permissions of the role's ancestors. The
+// hierarchy must be loaded with permissions.
+func (h roleHierarchy) inheritedPermissions(roleID uint) []*models.Permission {
+	permissions := []*models.Permission{}
+	seen := map[uint]bool{}
+	for _, id := range h.ancestors(roleID) {
+		for _, permission := range h[id].Permissions {
+			if !seen[permission.ID] {
+				seen[permission.ID] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions
+}
+
+// highestLevel returns the level of the highest-ranked role, or -1 without
+// roles.
+func highestLevel(roles []models.Role) int {
+	level := -1
+	for _, role := range roles {
+		if role.Level > level {
+			level = role.Level
+		}
+	}
+	return level
+}
+
+// outranksRoles reports whether the actor's roles rank above every one of
+// roles, which is what it takes to manage or assign them.
+func outranksRoles(actorRoles, roles []models.Role) bool {
+	return highestLevel(actorRoles) > highestLevel(roles)
+}
+
+// canManageRoles reports whether holders of managerRoles may manage a user
+// holding targetRoles. Targets without roles are never manageable.
+func canManageRoles(managerRoles, targetRoles []models.Role) bool {
+	return len(targetRoles) > 0 && outranksRoles(managerRoles, targetRoles)
+}
+
+// fillInheritedPermissions sets InheritedPermissions on the roles. The
+// hierarchy is only loaded when one of them has a parent.
+func fillInheritedPermissions(db *gorm.DB, roles ...*models.Role) error {
+	inherits := false
+	for _, role := range roles {
+		role.InheritedPermissions = []*models.Permission{}
+		inherits = inherits || role.ParentID != nil
+	}
+	if !inherits {
+		return nil
+	}
+
+	hierarchy, err := loadRoleHierarchy(db, true)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		role.InheritedPermissions = hierarchy.inheritedPermissions(role.ID)
+	}
+	return nil
+}
+
+// withInheritedPermissions fills in what each of the user's roles inherits,
+// so the user's response lists all of their permissions.
+func withInheritedPermissions(user *models.User) error {
+	return fillUserInheritedPermissions(database.DB, user)
+}
+
+func fillUserInheritedPermissions(db *gorm.DB, user *models.User) error {
+	roles := []*models.Role{&user.Role}
+	for i := range user.Roles {
+		roles = append(roles, &user.Roles[i])
+	}
+	return fillInheritedPermissions(db, roles...)
+}
+
+// GetRoleHierarchy returns the roles as trees, from the roles that inherit
+// from nothing down to the ones inheriting from them.
+func (s *RoleService) GetRoleHierarchy() ([]*models.RoleHierarchyNode, error) {
+	var roles []models.Role
+	if err := database.DB.Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	nodes := map[uint]*models.RoleHierarchyNode{}
+	for _, role := range roles {
+		nodes[role.ID] = &models.RoleHierarchyNode{
+			ID:           role.ID,
+			Name:         role.Name,
+			IsSystemRole: role.IsSystemRole,
+			Level:        role.Level,
+			ParentID:     role.ParentID,
+			Children:     []*models.RoleHierarchyNode{},
+		}
+	}
+
+	roots := []*models.RoleHierarchyNode{}
+	for _, role := range roles {
+		node := nodes[role.ID]
+		if role.ParentID != nil && nodes[*role.ParentID] != nil {
+			parent := nodes[*role.ParentID]
+			parent.Children = append(parent.Children, node)
+			continue
+		}
+		roots = append(roots, node)
+	}
+	return roots, nil
+}
+
+// SetRoleHierarchy sets the role's level and the role it inherits from, or
+// nothing when parentID is nil. System roles keep their place. The actor can
+// only move roles ranked below them and keep them there, and a role must rank
+// above its parent and below the roles inheriting from it, so nobody gains
+// permissions from a role they could not manage.
+func (s *RoleService) SetRoleHierarchy(roleID uint, parentID *uint, level int, actorID uint) (*models.Role, error) {
+	var role models.Role
+	if err := database.DB.First(&role, roleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("role not found")
+		}
+		return nil, err
+	}
+
+	if role.IsSystemRole {
+		return nil, ErrSystemRoleHierarchy
+	}
+
+	var actor models.User
+	if err := database.DB.Preload("Role").Preload("Roles").First(&actor, actorID).Error; err != nil {
+		return nil, err
+	}
+	actorLevel := highestLevel(actor.AllRoles())
+	if role.Level >= actorLevel || level >= actorLevel {
+		return nil, ErrRoleHierarchyNotAllowed
+	}
+
+	if parentID != nil {
+		hierarchy, err := loadRoleHierarchy(database.DB, false)
+		if err != nil {
+			return nil, err
+		}
+
+		parent := hierarchy[*parentID]
+		if parent == nil {
+			return nil, errors.New("parent role not found")
+		}
+		if parent.ID == role.ID || hierarchy.inheritsFrom(parent.ID, role.ID) {
+			return nil, ErrRoleHierarchyCycle
+		}
+		if parent.Level >= level {
+			return nil, ErrRoleHierarchyRank
+		}
+	}
+
+	var children int64
+	if err := database.DB.Model(&models.Role{}).Where("parent_id = ? AND level <= ?", role.ID, level).Count(&children).Error; err != nil {
+		return nil, err
+	}
+	if children > 0 {
+		return nil, ErrRoleHierarchyRank
+	}
+
+	if err := database.DB.Model(&role).Updates(map[string]interface{}{
+		"parent_id": parentID,
+		"level":     level,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Preload("Permissions").First(&role, role.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := fillInheritedPermissions(database.DB, &role); err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
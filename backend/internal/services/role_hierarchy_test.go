@@ -0,0 +1,95 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+)
+
+// setupRoleHierarchyTest returns an actor holding "Admin" (level 30), which
+// inherits from the system role "User" (level 10).
+func setupRoleHierarchyTest(t *testing.T) (*services.RoleService, *models.User, *models.Role) {
+	_, user := setupAuthTest(t)
+	database.DB.Model(&models.Role{}).Where("id = ?", user.RoleID).Updates(map[string]interface{}{"is_system_role": true, "level": 10})
+
+	adminRole := models.Role{Name: "Admin", Level: 30, ParentID: &user.RoleID}
+	database.DB.Create(&adminRole)
+	actor := models.User{Email: "admin@example.com", Username: "admin", RoleID: adminRole.ID, IsActive: true}
+	database.DB.Create(&actor)
+
+	var userRole models.Role
+	require.NoError(t, database.DB.First(&userRole, user.RoleID).Error)
+	return services.NewRoleService(), &actor, &userRole
+}
+
+func TestRoleService_SetRoleHierarchy(t *testing.T) {
+	service, actor, userRole := setupRoleHierarchyTest(t)
+
+	profileRead := models.Permission{Name: "profile.read", Resource: "profile", Action: "read"}
+	database.DB.Create(&profileRead)
+	database.DB.Model(userRole).Association("Permissions").Append(&profileRead)
+
+	team := models.Role{Name: "Team Lead"}
+	database.DB.Create(&team)
+
+	role, err := service.SetRoleHierarchy(team.ID, &userRole.ID, 20, actor.ID)
+	require.NoError(t, err)
+	require.NotNil(t, role.ParentID)
+	assert.Equal(t, userRole.ID, *role.ParentID)
+	assert.Equal(t, 20, role.Level)
+	require.Len(t, role.InheritedPermissions, 1)
+	assert.Equal(t, "profile.read", role.InheritedPermissions[0].Name)
+
+	member := models.Role{Name: "Team Member", Level: 25, ParentID: &team.ID}
+	database.DB.Create(&member)
+	_, err = service.SetRoleHierarchy(team.ID, &member.ID, 20, actor.ID)
+	assert.ErrorIs(t, err, services.ErrRoleHierarchyCycle)
+	_, err = service.SetRoleHierarchy(team.ID, &team.ID, 20, actor.ID)
+	assert.ErrorIs(t, err, services.ErrRoleHierarchyCycle)
+
+	// A role ranks above its parent and below the roles inheriting from it.
+	_, err = service.SetRoleHierarchy(team.ID, &userRole.ID, 5, actor.ID)
+	assert.ErrorIs(t, err, services.ErrRoleHierarchyRank)
+	_, err = service.SetRoleHierarchy(team.ID, &userRole.ID, 25, actor.ID)
+	assert.ErrorIs(t, err, services.ErrRoleHierarchyRank)
+
+	role, err = service.SetRoleHierarchy(team.ID, nil, 20, actor.ID)
+	require.NoError(t, err)
+	assert.Nil(t, role.ParentID)
+
+	_, err = service.SetRoleHierarchy(userRole.ID, &team.ID, 10, actor.ID)
+	assert.ErrorIs(t, err, services.ErrSystemRoleHierarchy)
+
+	assert.EqualError(t, service.DeleteRole(team.ID), "cannot delete role that other roles inherit from")
+}
+
+func TestRoleService_SetRoleHierarchyKeepsActorRank(t *testing.T) {
+	service, actor, userRole := setupRoleHierarchyTest(t)
+
+	team := models.Role{Name: "Team Lead"}
+	database.DB.Create(&team)
+
+	// Ranking a role alongside the actor's own role would let it manage them.
+	_, err := service.SetRoleHierarchy(team.ID, &userRole.ID, 30, actor.ID)
+	assert.ErrorIs(t, err, services.ErrRoleHierarchyNotAllowed)
+
+	_, err = service.SetRoleHierarchy(actor.RoleID, &userRole.ID, 20, actor.ID)
+	assert.ErrorIs(t, err, services.ErrRoleHierarchyNotAllowed)
+
+	_, err = service.SetRoleHierarchy(team.ID, &userRole.ID, 20, actor.ID)
+	require.NoError(t, err)
+
+	hierarchy, err := service.GetRoleHierarchy()
+	require.NoError(t, err)
+	require.Len(t, hierarchy, 1)
+	assert.Equal(t, "User", hierarchy[0].Name)
+	require.Len(t, hierarchy[0].Children, 2)
+	assert.Equal(t, "Admin", hierarchy[0].Children[0].Name)
+	assert.Equal(t, "Team Lead", hierarchy[0].Children[1].Name)
+	assert.Equal(t, 20, hierarchy[0].Children[1].Level)
+}
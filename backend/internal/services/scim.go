@@ -0,0 +1,1132 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/scim"
+	"rbac-system/backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// scimIssuer is the UserIdentity issuer under which a user's SCIM externalId
+// is kept.
+const scimIssuer = "scim"
+
+var scimUserColumns = map[string]scim.Column{
+	"id":                {Expression: "users.id", Type: scim.IntegerAttribute},
+	"username":          {Expression: "users.username"},
+	"emails":            {Expression: "users.email"},
+	"emails.value":      {Expression: "users.email"},
+	"name.givenname":    {Expression: "users.first_name"},
+	"name.familyname":   {Expression: "users.last_name"},
+	"active":            {Expression: "users.is_active", Type: scim.BooleanAttribute},
+	"externalid":        {Expression: "(SELECT subject FROM user_identities WHERE user_identities.user_id = users.id AND user_identities.issuer = 'scim')", CaseExact: true},
+	"meta.created":      {Expression: "users.created_at", Type: scim.DateTimeAttribute},
+	"meta.lastmodified": {Expression: "users.updated_at", Type: scim.DateTimeAttribute},
+}
+
+var scimGroupColumns = map[string]scim.Column{
+	"id":                {Expression: "roles.id", Type: scim.IntegerAttribute},
+	"displayname":       {Expression: "roles.name"},
+	"meta.created":      {Expression: "roles.created_at", Type: scim.DateTimeAttribute},
+	"meta.lastmodified": {Expression: "roles.updated_at", Type: scim.DateTimeAttribute},
+}
+
+// SCIMService implements the SCIM 2.0 Users and Groups resources for
+// provisioning clients. Users map onto models.User and groups onto roles: a
+// group's members are the users holding the role, so adding a user to a group
+// moves them into that role and removing them moves them to the default role.
+// Errors meant for the client are *scim.Error.
+type SCIMService struct {
+	config          config.SCIMConfig
+	passwordService *PasswordService
+}
+
+func NewSCIMService(cfg config.SCIMConfig, passwordService *PasswordService) *SCIMService {
+	return &SCIMService{
+		config:          cfg,
+		passwordService: passwordService,
+	}
+}
+
+// scimUserState holds the SCIM attributes a user has, or will have once a
+// request is applied.
+type scimUserState struct {
+	UserName   string
+	Email      string
+	GivenName  string
+	FamilyName string
+	Active     bool
+	ExternalID string
+	// Password is only set when the request changes it.
+	Password string
+}
+
+func scimStateFromUser(user *models.User, externalID string) scimUserState {
+	return scimUserState{
+		UserName:   user.Username,
+		Email:      user.Email,
+		GivenName:  user.FirstName,
+		FamilyName: user.LastName,
+		Active:     user.IsActive,
+		ExternalID: externalID,
+	}
+}
+
+// replacedBy returns the state after a POST or PUT of resource. Attributes
+// left out are cleared, except active, which keeps its value.
+func (state scimUserState) replacedBy(resource *scim.User) scimUserState {
+	next := scimUserState{
+		UserName:   strings.TrimSpace(resource.UserName),
+		Email:      strings.TrimSpace(resource.PrimaryEmail()),
+		Active:     state.Active,
+		ExternalID: resource.ExternalID,
+		Password:   resource.Password,
+	}
+	if next.Email == "" && strings.Contains(next.UserName, "@") {
+		next.Email = next.UserName
+	}
+	if resource.Name != nil {
+		next.GivenName = resource.Name.GivenName
+		next.FamilyName = resource.Name.FamilyName
+	}
+	if resource.Active != nil {
+		next.Active = *resource.Active
+	}
+	return next
+}
+
+func (s *SCIMService) ListUsers(filter string, page scim.Page) (*scim.ListResponse, error) {
+	query, err := scimFilter(database.DB.Model(&models.User{}), filter, scimUserColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	if page.Count > 0 {
+		if err := query.Preload("Role").Preload("Roles").Order("users.id").Offset(page.StartIndex - 1).Limit(page.Count).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	userIDs := make([]uint, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+	externalIDs, err := scimExternalIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]interface{}, len(users))
+	for i := range users {
+		resources[i] = toSCIMUser(&users[i], externalIDs[users[i].ID])
+	}
+	return scim.NewListResponse(total, page.StartIndex, resources), nil
+}
+
+func (s *SCIMService) GetUser(id string) (*scim.User, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	externalIDs, err := scimExternalIDs([]uint{user.ID})
+	if err != nil {
+		return nil, err
+	}
+	return toSCIMUser(user, externalIDs[user.ID]), nil
+}
+
+// CreateUser provisions a user in the default role. The email counts as
+// verified since the provisioning client owns it; without a password the
+// user signs in through SSO or a password reset.
+func (s *SCIMService) CreateUser(resource *scim.User, ipAddress, userAgent string) (*scim.User, error) {
+	state := scimUserState{Active: true}.replacedBy(resource)
+	if err := s.validateUser(state, 0); err != nil {
+		return nil, err
+	}
+	if state.Password != "" {
+		if err := utils.ValidatePassword(state.Password, state.UserName, state.Email); err != nil {
+			return nil, scim.BadRequest(scim.ErrorTypeInvalidValue, "%v", err)
+		}
+	}
+
+	role, err := s.defaultRole()
+	if err != nil {
+		return nil, err
+	}
+
+	firstName := state.GivenName
+	if firstName == "" {
+		firstName = state.UserName
+	}
+
+	now := time.Now()
+	user := models.User{
+		Email:           state.Email,
+		Username:        state.UserName,
+		FirstName:       firstName,
+		LastName:        state.FamilyName,
+		RoleID:          role.ID,
+		Roles:           []models.Role{*role},
+		IsActive:        state.Active,
+		EmailVerifiedAt: &now,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Roles.*").Create(&user).Error; err != nil {
+			return err
+		}
+		if state.ExternalID == "" {
+			return nil
+		}
+		return tx.Create(&models.UserIdentity{UserID: user.ID, Issuer: scimIssuer, Subject: state.ExternalID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Password != "" {
+		if err := s.passwordService.SetPassword(&user, state.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "user_provisioned",
+		Resource:  "users",
+		Details:   fmt.Sprintf("Provisioned by SCIM with role %s", role.Name),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	user.Role = *role
+	return toSCIMUser(&user, state.ExternalID), nil
+}
+
+// ReplaceUser applies a PUT of the whole user.
+func (s *SCIMService) ReplaceUser(id string, resource *scim.User, ipAddress, userAgent string) (*scim.User, error) {
+	user, current, err := s.loadUserState(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateUser(user, current, current.replacedBy(resource), ipAddress, userAgent)
+}
+
+// PatchUser applies PATCH operations. Attributes this server does not store,
+// such as enterprise extension attributes, are ignored.
+func (s *SCIMService) PatchUser(id string, req *scim.PatchRequest, ipAddress, userAgent string) (*scim.User, error) {
+	if len(req.Operations) == 0 {
+		return nil, scim.BadRequest(scim.ErrorTypeInvalidValue, "no operations given")
+	}
+
+	user, current, err := s.loadUserState(id)
+	if err != nil {
+		return nil, err
+	}
+
+	next := current
+	for _, operation := range req.Operations {
+		if err := applyUserOperation(&next, operation); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.updateUser(user, current, next, ipAddress, userAgent)
+}
+
+// DeleteUser deletes the user like UserService.DeleteUser and drops their
+// externalId so it can be provisioned again.
+func (s *SCIMService) DeleteUser(id, ipAddress, userAgent string) error {
+	user, err := s.findUser(id)
+	if err != nil {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND issuer = ?", user.ID, scimIssuer).Delete(&models.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "user_deleted",
+		Resource:  "users",
+		Details:   "Deleted by SCIM",
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return nil
+}
+
+func (s *SCIMService) findUser(id string) (*models.User, error) {
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, scim.NotFound("User", id)
+	}
+
+	var user models.User
+	if err := database.DB.Preload("Role").Preload("Roles").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scim.NotFound("User", id)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *SCIMService) loadUserState(id string) (*models.User, scimUserState, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, scimUserState{}, err
+	}
+
+	externalIDs, err := scimExternalIDs([]uint{user.ID})
+	if err != nil {
+		return nil, scimUserState{}, err
+	}
+	return user, scimStateFromUser(user, externalIDs[user.ID]), nil
+}
+
+// validateUser checks the required attributes and that userName, email and
+// externalId are not used by anyone but userID.
+func (s *SCIMService) validateUser(state scimUserState, userID uint) error {
+	if state.UserName == "" {
+		return scim.BadRequest(scim.ErrorTypeInvalidValue, "userName is required")
+	}
+	if err := utils.GetValidator().Var(state.Email, "required,email"); err != nil {
+		return scim.BadRequest(scim.ErrorTypeInvalidValue, "a valid email address is required")
+	}
+
+	var count int64
+	if err := database.DB.Unscoped().Model(&models.User{}).Where("username = ? AND id <> ?", state.UserName, userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return scim.NewError(http.StatusConflict, scim.ErrorTypeUniqueness, "userName is already in use")
+	}
+
+	if err := database.DB.Unscoped().Model(&models.User{}).Where("email = ? AND id <> ?", state.Email, userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return scim.NewError(http.StatusConflict, scim.ErrorTypeUniqueness, "email is already in use")
+	}
+
+	if state.ExternalID != "" {
+		if err := database.DB.Model(&models.UserIdentity{}).Where("issuer = ? AND subject = ? AND user_id <> ?", scimIssuer, state.ExternalID, userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return scim.NewError(http.StatusConflict, scim.ErrorTypeUniqueness, "externalId is already in use")
+		}
+	}
+
+	return nil
+}
+
+// updateUser stores the difference between current and next and records
+// it: activation changes as user_activated or user_deactivated, anything
+// else as user_updated.
+func (s *SCIMService) updateUser(user *models.User, current, next scimUserState, ipAddress, userAgent string) (*scim.User, error) {
+	if err := s.validateUser(next, user.ID); err != nil {
+		return nil, err
+	}
+
+	// The password goes first: it is the change most likely to be refused,
+	// by the policy or the history, and checked against the new names.
+	changed := []string{}
+	if next.Password != "" {
+		candidate := *user
+		candidate.Username, candidate.Email = next.UserName, next.Email
+		if err := s.passwordService.SetPassword(&candidate, next.Password); err != nil {
+			var policyErr *utils.PasswordPolicyError
+			if errors.As(err, &policyErr) {
+				return nil, scim.BadRequest(scim.ErrorTypeInvalidValue, "%v", err)
+			}
+			return nil, err
+		}
+		changed = append(changed, "password")
+	}
+
+	updates := map[string]interface{}{}
+	if next.UserName != current.UserName {
+		updates["username"] = next.UserName
+		changed = append(changed, "userName")
+	}
+	if next.Email != current.Email {
+		updates["email"] = next.Email
+		updates["email_verified_at"] = time.Now()
+		changed = append(changed, "emails")
+	}
+	if next.GivenName != current.GivenName || next.FamilyName != current.FamilyName {
+		updates["first_name"] = next.GivenName
+		updates["last_name"] = next.FamilyName
+		changed = append(changed, "name")
+	}
+	if next.Active != current.Active {
+		updates["is_active"] = next.Active
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(user).Omit("Role").Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if next.ExternalID != current.ExternalID {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ? AND issuer = ?", user.ID, scimIssuer).Delete(&models.UserIdentity{}).Error; err != nil {
+				return err
+			}
+			if next.ExternalID == "" {
+				return nil
+			}
+			return tx.Create(&models.UserIdentity{UserID: user.ID, Issuer: scimIssuer, Subject: next.ExternalID}).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, "externalId")
+	}
+
+	if next.Active != current.Active {
+		action, details := "user_activated", "Activated by SCIM"
+		if !next.Active {
+			action, details = "user_deactivated", "Deactivated by SCIM"
+		}
+		activityLog := models.ActivityLog{UserID: &user.ID, Action: action, Resource: "users", Details: details, IPAddress: ipAddress, UserAgent: userAgent}
+		database.DB.Create(&activityLog)
+	}
+	if len(changed) > 0 {
+		activityLog := models.ActivityLog{
+			UserID:    &user.ID,
+			Action:    "user_updated",
+			Resource:  "users",
+			Details:   "Updated by SCIM: " + strings.Join(changed, ", "),
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+		}
+		database.DB.Create(&activityLog)
+	}
+
+	if err := database.DB.Preload("Role").Preload("Roles").First(user, user.ID).Error; err != nil {
+		return nil, err
+	}
+	return toSCIMUser(user, next.ExternalID), nil
+}
+
+func applyUserOperation(state *scimUserState, operation scim.PatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return scim.BadRequest(scim.ErrorTypeInvalidSyntax, "unsupported operation %q", operation.Op)
+	}
+
+	if operation.Path == "" {
+		if op == "remove" {
+			return scim.BadRequest(scim.ErrorTypeNoTarget, "remove operations require a path")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return scim.BadRequest(scim.ErrorTypeInvalidValue, "operations without a path need an object value")
+		}
+		for attribute, value := range values {
+			path, err := scim.ParsePath(attribute)
+			if err != nil {
+				return err
+			}
+			if err := setUserAttribute(state, path, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := scim.ParsePath(operation.Path)
+	if err != nil {
+		return err
+	}
+	if op == "remove" {
+		return removeUserAttribute(state, path)
+	}
+	return setUserAttribute(state, path, operation.Value)
+}
+
+func setUserAttribute(state *scimUserState, path *scim.Path, value json.RawMessage) error {
+	var err error
+	switch path.Attribute {
+	case "username":
+		state.UserName, err = scim.ParseString(value)
+		state.UserName = strings.TrimSpace(state.UserName)
+	case "active":
+		state.Active, err = scim.ParseBool(value)
+	case "externalid":
+		state.ExternalID, err = scim.ParseString(value)
+	case "password":
+		state.Password, err = scim.ParseString(value)
+	case "name":
+		switch path.SubAttribute {
+		case "":
+			var name scim.Name
+			if json.Unmarshal(value, &name) != nil {
+				return scim.BadRequest(scim.ErrorTypeInvalidValue, "name must be an object")
+			}
+			state.GivenName, state.FamilyName = name.GivenName, name.FamilyName
+		case "givenname":
+			state.GivenName, err = scim.ParseString(value)
+		case "familyname":
+			state.FamilyName, err = scim.ParseString(value)
+		}
+	case "emails":
+		// Only one email is stored, so every emails path refers to it.
+		if path.SubAttribute == "value" {
+			state.Email, err = scim.ParseString(value)
+			state.Email = strings.TrimSpace(state.Email)
+			break
+		}
+		var emails []scim.MultiValue
+		if json.Unmarshal(value, &emails) != nil || len(emails) == 0 {
+			return scim.BadRequest(scim.ErrorTypeInvalidValue, "emails must be a non-empty list")
+		}
+		state.Email = strings.TrimSpace((&scim.User{Emails: emails}).PrimaryEmail())
+	case "groups":
+		return scim.BadRequest(scim.ErrorTypeMutability, "groups is read only; change membership through /Groups")
+	}
+	return err
+}
+
+func removeUserAttribute(state *scimUserState, path *scim.Path) error {
+	switch path.Attribute {
+	case "externalid":
+		state.ExternalID = ""
+	case "name":
+		if path.SubAttribute == "" || path.SubAttribute == "givenname" {
+			state.GivenName = ""
+		}
+		if path.SubAttribute == "" || path.SubAttribute == "familyname" {
+			state.FamilyName = ""
+		}
+	case "username", "emails", "active", "password":
+		return scim.BadRequest(scim.ErrorTypeMutability, "%s cannot be removed", path.Attribute)
+	case "groups":
+		return scim.BadRequest(scim.ErrorTypeMutability, "groups is read only; change membership through /Groups")
+	}
+	return nil
+}
+
+func toSCIMUser(user *models.User, externalID string) *scim.User {
+	active := user.IsActive
+	fullName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+
+	resource := &scim.User{
+		Schemas:     []string{scim.UserSchema},
+		ID:          strconv.FormatUint(uint64(user.ID), 10),
+		ExternalID:  externalID,
+		UserName:    user.Username,
+		Name:        &scim.Name{Formatted: fullName, GivenName: user.FirstName, FamilyName: user.LastName},
+		DisplayName: fullName,
+		Emails:      []scim.MultiValue{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta:        &scim.Meta{ResourceType: "User", Created: user.CreatedAt, LastModified: user.UpdatedAt},
+	}
+	for _, role := range user.AllRoles() {
+		resource.Groups = append(resource.Groups, scim.MultiValue{Value: strconv.FormatUint(uint64(role.ID), 10), Display: role.Name})
+	}
+	return resource
+}
+
+func scimExternalIDs(userIDs []uint) (map[uint]string, error) {
+	externalIDs := map[uint]string{}
+	if len(userIDs) == 0 {
+		return externalIDs, nil
+	}
+
+	var identities []models.UserIdentity
+	if err := database.DB.Where("issuer = ? AND user_id IN ?", scimIssuer, userIDs).Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		externalIDs[identity.UserID] = identity.Subject
+	}
+	return externalIDs, nil
+}
+
+// scimGroupState holds a group's name and member user IDs.
+type scimGroupState struct {
+	Name    string
+	Members []uint
+}
+
+func (state *scimGroupState) add(ids []uint) {
+	for _, id := range ids {
+		if !state.has(id) {
+			state.Members = append(state.Members, id)
+		}
+	}
+}
+
+func (state *scimGroupState) remove(ids []uint) {
+	kept := state.Members[:0]
+	for _, member := range state.Members {
+		removed := false
+		for _, id := range ids {
+			removed = removed || member == id
+		}
+		if !removed {
+			kept = append(kept, member)
+		}
+	}
+	state.Members = kept
+}
+
+func (state *scimGroupState) has(id uint) bool {
+	for _, member := range state.Members {
+		if member == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SCIMService) ListGroups(filter string, page scim.Page, includeMembers bool) (*scim.ListResponse, error) {
+	query, err := scimFilter(database.DB.Model(&models.Role{}), filter, scimGroupColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var roles []models.Role
+	if page.Count > 0 {
+		if err := query.Order("roles.id").Offset(page.StartIndex - 1).Limit(page.Count).Find(&roles).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	members := map[uint][]models.User{}
+	if includeMembers && len(roles) > 0 {
+		roleIDs := make([]uint, len(roles))
+		for i, role := range roles {
+			roleIDs[i] = role.ID
+		}
+		var users []models.User
+		if err := database.DB.Preload("Role").Preload("Roles").
+			Where("role_id IN ? OR id IN (SELECT user_id FROM user_roles WHERE role_id IN ?)", roleIDs, roleIDs).
+			Order("id").Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			for _, role := range user.AllRoles() {
+				members[role.ID] = append(members[role.ID], user)
+			}
+		}
+	}
+
+	resources := make([]interface{}, len(roles))
+	for i := range roles {
+		resources[i] = toSCIMGroup(&roles[i], members[roles[i].ID])
+	}
+	return scim.NewListResponse(total, page.StartIndex, resources), nil
+}
+
+func (s *SCIMService) GetGroup(id string, includeMembers bool) (*scim.Group, error) {
+	role, err := s.findGroup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []models.User
+	if includeMembers {
+		if err := usersWithRole(database.DB, role.ID).Order("id").Find(&members).Error; err != nil {
+			return nil, err
+		}
+	}
+	return toSCIMGroup(role, members), nil
+}
+
+// CreateGroup creates a role without permissions and gives it to the
+// members.
+func (s *SCIMService) CreateGroup(resource *scim.Group, ipAddress, userAgent string) (*scim.Group, error) {
+	name := strings.TrimSpace(resource.DisplayName)
+	if err := validateGroupName(name, 0); err != nil {
+		return nil, err
+	}
+	members, err := scimMemberIDs(resource.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.Role{Name: name, Description: "Provisioned by SCIM"}
+	if err := database.DB.Create(&role).Error; err != nil {
+		return nil, err
+	}
+
+	activityLog := models.ActivityLog{
+		Action:    "role_created",
+		Resource:  "roles",
+		Details:   fmt.Sprintf("Role %s created by SCIM", role.Name),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return s.updateGroup(&role, scimGroupState{Name: name}, scimGroupState{Name: name, Members: members}, ipAddress, userAgent)
+}
+
+// ReplaceGroup applies a PUT: the name and the complete member list.
+func (s *SCIMService) ReplaceGroup(id string, resource *scim.Group, ipAddress, userAgent string) (*scim.Group, error) {
+	role, current, err := s.loadGroupState(id)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := scimMemberIDs(resource.Members)
+	if err != nil {
+		return nil, err
+	}
+	next := scimGroupState{Name: strings.TrimSpace(resource.DisplayName), Members: members}
+
+	return s.updateGroup(role, current, next, ipAddress, userAgent)
+}
+
+// PatchGroup applies PATCH operations on displayName and members.
+func (s *SCIMService) PatchGroup(id string, req *scim.PatchRequest, ipAddress, userAgent string) (*scim.Group, error) {
+	if len(req.Operations) == 0 {
+		return nil, scim.BadRequest(scim.ErrorTypeInvalidValue, "no operations given")
+	}
+
+	role, current, err := s.loadGroupState(id)
+	if err != nil {
+		return nil, err
+	}
+
+	next := scimGroupState{Name: current.Name, Members: append([]uint(nil), current.Members...)}
+	for _, operation := range req.Operations {
+		if err := applyGroupOperation(&next, operation); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.updateGroup(role, current, next, ipAddress, userAgent)
+}
+
+// DeleteGroup deletes the role after taking it away from its members, who
+// fall back to the default role when it was their only one. System roles, the
+// default role, roles held by service accounts and roles other roles inherit
+// from cannot be deleted.
+func (s *SCIMService) DeleteGroup(id, ipAddress, userAgent string) error {
+	role, err := s.findGroup(id)
+	if err != nil {
+		return err
+	}
+	if role.IsSystemRole {
+		return scim.BadRequest(scim.ErrorTypeMutability, "system roles cannot be deleted")
+	}
+
+	defaultRole, err := s.defaultRole()
+	if err != nil {
+		return err
+	}
+	if defaultRole.ID == role.ID {
+		return scim.BadRequest(scim.ErrorTypeMutability, "the default role cannot be deleted")
+	}
+
+	var serviceAccountCount int64
+	if err := database.DB.Model(&models.ServiceAccount{}).Where("role_id = ?", role.ID).Count(&serviceAccountCount).Error; err != nil {
+		return err
+	}
+	if serviceAccountCount > 0 {
+		return scim.BadRequest(scim.ErrorTypeMutability, "the role is assigned to service accounts")
+	}
+
+	var childCount int64
+	if err := database.DB.Model(&models.Role{}).Where("parent_id = ?", role.ID).Count(&childCount).Error; err != nil {
+		return err
+	}
+	if childCount > 0 {
+		return scim.BadRequest(scim.ErrorTypeMutability, "other roles inherit from the role")
+	}
+
+	var members []models.User
+	if err := usersWithRole(database.DB, role.ID).Find(&members).Error; err != nil {
+		return err
+	}
+	for i := range members {
+		if err := removeSCIMGroupMember(&members[i], role, defaultRole, "SCIM group deletion", ipAddress, userAgent); err != nil {
+			return err
+		}
+	}
+
+	if err := database.DB.Model(role).Association("Permissions").Clear(); err != nil {
+		return err
+	}
+	if err := database.DB.Delete(role).Error; err != nil {
+		return err
+	}
+
+	activityLog := models.ActivityLog{
+		Action:    "role_deleted",
+		Resource:  "roles",
+		Details:   fmt.Sprintf("Role %s deleted by SCIM", role.Name),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return nil
+}
+
+func (s *SCIMService) findGroup(id string) (*models.Role, error) {
+	roleID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, scim.NotFound("Group", id)
+	}
+
+	var role models.Role
+	if err := database.DB.First(&role, roleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scim.NotFound("Group", id)
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (s *SCIMService) loadGroupState(id string) (*models.Role, scimGroupState, error) {
+	role, err := s.findGroup(id)
+	if err != nil {
+		return nil, scimGroupState{}, err
+	}
+
+	var members []uint
+	if err := usersWithRole(database.DB.Model(&models.User{}), role.ID).Order("id").Pluck("id", &members).Error; err != nil {
+		return nil, scimGroupState{}, err
+	}
+	return role, scimGroupState{Name: role.Name, Members: members}, nil
+}
+
+func validateGroupName(name string, roleID uint) error {
+	if name == "" {
+		return scim.BadRequest(scim.ErrorTypeInvalidValue, "displayName is required")
+	}
+	if len(name) > 50 {
+		return scim.BadRequest(scim.ErrorTypeInvalidValue, "displayName must be at most 50 characters")
+	}
+
+	// Deleted roles keep their name in the unique index.
+	var count int64
+	if err := database.DB.Unscoped().Model(&models.Role{}).Where("name = ? AND id <> ?", name, roleID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return scim.NewError(http.StatusConflict, scim.ErrorTypeUniqueness, "a role with this name exists or existed")
+	}
+	return nil
+}
+
+// updateGroup renames the role and adds it to or takes it from users so its
+// members match next. Everything is checked before anything changes.
+func (s *SCIMService) updateGroup(role *models.Role, current, next scimGroupState, ipAddress, userAgent string) (*scim.Group, error) {
+	if next.Name != current.Name {
+		if role.IsSystemRole {
+			return nil, scim.BadRequest(scim.ErrorTypeMutability, "system roles cannot be renamed")
+		}
+		if err := validateGroupName(next.Name, role.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	var added, removed []uint
+	for _, id := range next.Members {
+		if !current.has(id) {
+			added = append(added, id)
+		}
+	}
+	for _, id := range current.Members {
+		if !next.has(id) {
+			removed = append(removed, id)
+		}
+	}
+
+	defaultRole, err := s.defaultRole()
+	if err != nil {
+		return nil, err
+	}
+
+	var addedUsers, removedUsers []models.User
+	if len(added) > 0 {
+		if err := database.DB.Preload("Role").Preload("Roles").Where("id IN ?", added).Find(&addedUsers).Error; err != nil {
+			return nil, err
+		}
+		if len(addedUsers) != len(added) {
+			return nil, scim.BadRequest(scim.ErrorTypeInvalidValue, "members must be existing users")
+		}
+	}
+	if len(removed) > 0 {
+		if err := database.DB.Preload("Role").Preload("Roles").Where("id IN ?", removed).Find(&removedUsers).Error; err != nil {
+			return nil, err
+		}
+	}
+	if defaultRole.ID == role.ID {
+		for _, user := range removedUsers {
+			if len(user.AllRoles()) == 1 {
+				return nil, scim.BadRequest(scim.ErrorTypeMutability, "members cannot be removed from the default role %s while it is their only role; add them to another group instead", role.Name)
+			}
+		}
+	}
+
+	if next.Name != current.Name {
+		if err := database.DB.Model(role).Update("name", next.Name).Error; err != nil {
+			return nil, err
+		}
+
+		activityLog := models.ActivityLog{
+			Action:    "role_updated",
+			Resource:  "roles",
+			Details:   fmt.Sprintf("Role %s renamed to %s by SCIM", current.Name, next.Name),
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+		}
+		database.DB.Create(&activityLog)
+	}
+
+	for i := range addedUsers {
+		if err := addSCIMGroupMember(&addedUsers[i], role, defaultRole, ipAddress, userAgent); err != nil {
+			return nil, err
+		}
+	}
+	for i := range removedUsers {
+		if err := removeSCIMGroupMember(&removedUsers[i], role, defaultRole, "SCIM group membership", ipAddress, userAgent); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetGroup(strconv.FormatUint(uint64(role.ID), 10), true)
+}
+
+// addSCIMGroupMember gives the user the group's role. It becomes their
+// primary role when they only had the default role as primary so far.
+func addSCIMGroupMember(user *models.User, role, defaultRole *models.Role, ipAddress, userAgent string) error {
+	if err := addUserRole(user, role, user.RoleID == defaultRole.ID); err != nil {
+		return err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "role_synced",
+		Resource:  "users",
+		Details:   fmt.Sprintf("Role %s added by SCIM group membership", role.Name),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return nil
+}
+
+// removeSCIMGroupMember takes the group's role from the user, falling back to
+// the default role when it was their only one.
+func removeSCIMGroupMember(user *models.User, role, defaultRole *models.Role, source, ipAddress, userAgent string) error {
+	if err := removeUserRole(user, role, defaultRole); err != nil {
+		return err
+	}
+
+	activityLog := models.ActivityLog{
+		UserID:    &user.ID,
+		Action:    "role_synced",
+		Resource:  "users",
+		Details:   fmt.Sprintf("Role %s removed by %s", role.Name, source),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return nil
+}
+
+func applyGroupOperation(state *scimGroupState, operation scim.PatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return scim.BadRequest(scim.ErrorTypeInvalidSyntax, "unsupported operation %q", operation.Op)
+	}
+
+	if operation.Path == "" {
+		if op == "remove" {
+			return scim.BadRequest(scim.ErrorTypeNoTarget, "remove operations require a path")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return scim.BadRequest(scim.ErrorTypeInvalidValue, "operations without a path need an object value")
+		}
+		for attribute, value := range values {
+			path, err := scim.ParsePath(attribute)
+			if err != nil {
+				return err
+			}
+			if err := applyGroupAttribute(state, op, path, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := scim.ParsePath(operation.Path)
+	if err != nil {
+		return err
+	}
+	return applyGroupAttribute(state, op, path, operation.Value)
+}
+
+func applyGroupAttribute(state *scimGroupState, op string, path *scim.Path, value json.RawMessage) error {
+	switch path.Attribute {
+	case "displayname":
+		if op == "remove" {
+			return scim.BadRequest(scim.ErrorTypeMutability, "displayName cannot be removed")
+		}
+		name, err := scim.ParseString(value)
+		if err != nil {
+			return err
+		}
+		state.Name = strings.TrimSpace(name)
+
+	case "members":
+		if path.Filter != nil {
+			if op != "remove" {
+				return scim.BadRequest(scim.ErrorTypeInvalidPath, "member value filters are only supported by remove")
+			}
+			ids, err := memberFilterIDs(path.Filter)
+			if err != nil {
+				return err
+			}
+			state.remove(ids)
+			return nil
+		}
+
+		var members []scim.MultiValue
+		if len(value) > 0 && string(value) != "null" {
+			if err := json.Unmarshal(value, &members); err != nil {
+				return scim.BadRequest(scim.ErrorTypeInvalidValue, "members must be a list")
+			}
+		}
+		ids, err := scimMemberIDs(members)
+		if err != nil {
+			return err
+		}
+
+		switch op {
+		case "add":
+			state.add(ids)
+		case "replace":
+			state.Members = nil
+			state.add(ids)
+		case "remove":
+			if len(members) == 0 {
+				state.Members = nil
+			} else {
+				state.remove(ids)
+			}
+		}
+	}
+	return nil
+}
+
+// memberFilterIDs reads the user IDs out of a members[value eq "1" or value
+// eq "2"] path filter, the only form clients use.
+func memberFilterIDs(filter scim.Filter) ([]uint, error) {
+	switch f := filter.(type) {
+	case *scim.AttributeExpression:
+		if value, ok := f.Value.(string); ok && f.Attribute == "value" && f.Operator == "eq" {
+			return scimMemberIDs([]scim.MultiValue{{Value: value}})
+		}
+	case *scim.LogicalExpression:
+		if f.Operator == "or" {
+			left, err := memberFilterIDs(f.Left)
+			if err != nil {
+				return nil, err
+			}
+			right, err := memberFilterIDs(f.Right)
+			if err != nil {
+				return nil, err
+			}
+			return append(left, right...), nil
+		}
+	}
+	return nil, scim.BadRequest(scim.ErrorTypeInvalidFilter, `member filters must have the form value eq "<id>"`)
+}
+
+func scimMemberIDs(members []scim.MultiValue) ([]uint, error) {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseUint(member.Value, 10, 32)
+		if err != nil {
+			return nil, scim.BadRequest(scim.ErrorTypeInvalidValue, "member %q is not a user id", member.Value)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func toSCIMGroup(role *models.Role, members []models.User) *scim.Group {
+	group := &scim.Group{
+		Schemas:     []string{scim.GroupSchema},
+		ID:          strconv.FormatUint(uint64(role.ID), 10),
+		DisplayName: role.Name,
+		Meta:        &scim.Meta{ResourceType: "Group", Created: role.CreatedAt, LastModified: role.UpdatedAt},
+	}
+	for _, member := range members {
+		group.Members = append(group.Members, scim.MultiValue{Value: strconv.FormatUint(uint64(member.ID), 10), Display: member.Username})
+	}
+	return group
+}
+
+func (s *SCIMService) defaultRole() (*models.Role, error) {
+	var role models.Role
+	if err := database.DB.Where("name = ?", s.config.DefaultRole).First(&role).Error; err != nil {
+		return nil, fmt.Errorf("SCIM default role %q not found", s.config.DefaultRole)
+	}
+	return &role, nil
+}
+
+func scimFilter(query *gorm.DB, filter string, columns map[string]scim.Column) (*gorm.DB, error) {
+	if strings.TrimSpace(filter) == "" {
+		return query, nil
+	}
+
+	parsed, err := scim.ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := scim.ToSQL(parsed, columns)
+	if err != nil {
+		return nil, err
+	}
+	return query.Where(where, args...), nil
+}
@@ -0,0 +1,249 @@
+package services_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/scim"
+	"rbac-system/backend/internal/services"
+)
+
+func setupSCIMTest(t *testing.T) (*services.SCIMService, *models.User) {
+	_, user := setupAuthTest(t)
+	require.NoError(t, database.DB.AutoMigrate(&models.UserIdentity{}, &models.ServiceAccount{}))
+
+	service := services.NewSCIMService(config.SCIMConfig{Enabled: true, DefaultRole: "User"}, services.NewPasswordService(nil, 3))
+	return service, user
+}
+
+func scimError(t *testing.T, err error) *scim.Error {
+	var scimErr *scim.Error
+	require.ErrorAs(t, err, &scimErr)
+	return scimErr
+}
+
+func patch(operations ...scim.PatchOperation) *scim.PatchRequest {
+	return &scim.PatchRequest{Schemas: []string{scim.PatchOpSchema}, Operations: operations}
+}
+
+func TestSCIM_CreateFilterAndPageUsers(t *testing.T) {
+	service, _ := setupSCIMTest(t)
+
+	created, err := service.CreateUser(&scim.User{
+		UserName:   "jdoe@corp.example",
+		ExternalID: "hr-1001",
+		Name:       &scim.Name{GivenName: "Jane", FamilyName: "Doe"},
+		Emails:     []scim.MultiValue{{Value: "jane.doe@corp.example", Primary: true}},
+	}, "10.0.0.1", "okta")
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe@corp.example", created.UserName)
+	assert.Equal(t, "jane.doe@corp.example", created.PrimaryEmail())
+	assert.True(t, *created.Active)
+	assert.Equal(t, "User", created.Groups[0].Display)
+
+	var user models.User
+	require.NoError(t, database.DB.First(&user, created.ID).Error)
+	assert.NotNil(t, user.EmailVerifiedAt)
+	assert.Empty(t, user.PasswordHash)
+
+	for _, name := range []string{"amy", "ben"} {
+		_, err := service.CreateUser(&scim.User{UserName: name, Emails: []scim.MultiValue{{Value: name + "@corp.example"}}}, "10.0.0.1", "okta")
+		require.NoError(t, err)
+	}
+
+	list, err := service.ListUsers(`externalId eq "hr-1001"`, scim.ParsePage("", ""))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), list.TotalResults)
+	assert.Equal(t, created.ID, list.Resources[0].(*scim.User).ID)
+
+	list, err = service.ListUsers(`emails.value ew "@CORP.EXAMPLE" and active eq true`, scim.ParsePage("2", "1"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), list.TotalResults)
+	assert.Equal(t, 2, list.StartIndex)
+	require.Len(t, list.Resources, 1)
+	assert.Equal(t, "amy", list.Resources[0].(*scim.User).UserName)
+
+	_, err = service.ListUsers(`title eq "Engineer"`, scim.ParsePage("", ""))
+	assert.Equal(t, scim.ErrorTypeInvalidFilter, scimError(t, err).ScimType)
+
+	_, err = service.CreateUser(&scim.User{UserName: "JDOE@corp.example", Emails: []scim.MultiValue{{Value: "jane.doe@corp.example"}}}, "10.0.0.1", "okta")
+	assert.Equal(t, "409", scimError(t, err).Status)
+}
+
+func TestSCIM_PatchUser(t *testing.T) {
+	service, user := setupSCIMTest(t)
+	id := strconv.FormatUint(uint64(user.ID), 10)
+
+	// Azure AD sends booleans as strings and dotted names in path-less values.
+	updated, err := service.PatchUser(id, patch(
+		scim.PatchOperation{Op: "Replace", Path: "active", Value: json.RawMessage(`"False"`)},
+		scim.PatchOperation{Op: "replace", Value: json.RawMessage(`{"name.givenName":"Reggie","title":"Engineer"}`)},
+		scim.PatchOperation{Op: "replace", Path: `emails[type eq "work"].value`, Value: json.RawMessage(`"reggie@example.com"`)},
+		scim.PatchOperation{Op: "add", Path: "externalId", Value: json.RawMessage(`"hr-7"`)},
+	), "10.0.0.1", "azure")
+	require.NoError(t, err)
+	assert.False(t, *updated.Active)
+	assert.Equal(t, "Reggie", updated.Name.GivenName)
+	assert.Equal(t, "User", updated.Name.FamilyName)
+	assert.Equal(t, "reggie@example.com", updated.PrimaryEmail())
+	assert.Equal(t, "hr-7", updated.ExternalID)
+
+	var stored models.User
+	require.NoError(t, database.DB.First(&stored, user.ID).Error)
+	assert.False(t, stored.IsActive)
+	assert.Equal(t, "reggie@example.com", stored.Email)
+
+	var deactivations int64
+	database.DB.Model(&models.ActivityLog{}).Where("user_id = ? AND action = ?", user.ID, "user_deactivated").Count(&deactivations)
+	assert.Equal(t, int64(1), deactivations)
+
+	_, err = service.PatchUser(id, patch(scim.PatchOperation{Op: "remove", Path: "userName"}), "10.0.0.1", "azure")
+	assert.Equal(t, scim.ErrorTypeMutability, scimError(t, err).ScimType)
+
+	_, err = service.PatchUser(id, patch(scim.PatchOperation{Op: "replace", Path: "password", Value: json.RawMessage(`"short"`)}), "10.0.0.1", "azure")
+	assert.Equal(t, scim.ErrorTypeInvalidValue, scimError(t, err).ScimType)
+
+	_, err = service.PatchUser("999", patch(scim.PatchOperation{Op: "replace", Path: "active", Value: json.RawMessage(`true`)}), "10.0.0.1", "azure")
+	assert.Equal(t, "404", scimError(t, err).Status)
+}
+
+func TestSCIM_ReplaceAndDeleteUser(t *testing.T) {
+	service, _ := setupSCIMTest(t)
+
+	created, err := service.CreateUser(&scim.User{UserName: "sam", ExternalID: "hr-9", Emails: []scim.MultiValue{{Value: "sam@corp.example"}}}, "10.0.0.1", "okta")
+	require.NoError(t, err)
+
+	active := false
+	replaced, err := service.ReplaceUser(created.ID, &scim.User{
+		UserName: "samuel",
+		Name:     &scim.Name{GivenName: "Samuel", FamilyName: "Lee"},
+		Emails:   []scim.MultiValue{{Value: "samuel@corp.example"}},
+		Active:   &active,
+	}, "10.0.0.1", "okta")
+	require.NoError(t, err)
+	assert.Equal(t, "samuel", replaced.UserName)
+	assert.Empty(t, replaced.ExternalID)
+	assert.False(t, *replaced.Active)
+
+	require.NoError(t, service.DeleteUser(created.ID, "10.0.0.1", "okta"))
+	_, err = service.GetUser(created.ID)
+	assert.Equal(t, "404", scimError(t, err).Status)
+}
+
+func TestSCIM_GroupMembershipMapsToRoles(t *testing.T) {
+	service, user := setupSCIMTest(t)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	group, err := service.CreateGroup(&scim.Group{DisplayName: "Engineering", Members: []scim.MultiValue{{Value: userID}}}, "10.0.0.1", "okta")
+	require.NoError(t, err)
+	require.Len(t, group.Members, 1)
+	assert.Equal(t, userID, group.Members[0].Value)
+
+	var member models.User
+	require.NoError(t, database.DB.Preload("Role").First(&member, user.ID).Error)
+	assert.Equal(t, "Engineering", member.Role.Name)
+
+	list, err := service.ListGroups(`displayName eq "engineering"`, scim.ParsePage("", ""), false)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), list.TotalResults)
+	assert.Empty(t, list.Resources[0].(*scim.Group).Members)
+
+	group, err = service.PatchGroup(group.ID, patch(
+		scim.PatchOperation{Op: "remove", Path: `members[value eq "` + userID + `"]`},
+		scim.PatchOperation{Op: "replace", Path: "displayName", Value: json.RawMessage(`"Platform"`)},
+	), "10.0.0.1", "okta")
+	require.NoError(t, err)
+	assert.Equal(t, "Platform", group.DisplayName)
+	assert.Empty(t, group.Members)
+
+	require.NoError(t, database.DB.Preload("Role").First(&member, user.ID).Error)
+	assert.Equal(t, "User", member.Role.Name)
+
+	_, err = service.PatchGroup(group.ID, patch(scim.PatchOperation{Op: "add", Path: "members", Value: json.RawMessage(`[{"value":"` + userID + `"}]`)}), "10.0.0.1", "okta")
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteGroup(group.ID, "10.0.0.1", "okta"))
+
+	require.NoError(t, database.DB.Preload("Role").First(&member, user.ID).Error)
+	assert.Equal(t, "User", member.Role.Name)
+
+	var synced int64
+	database.DB.Model(&models.ActivityLog{}).Where("user_id = ? AND action = ?", user.ID, "role_synced").Count(&synced)
+	assert.Equal(t, int64(4), synced)
+}
+
+func TestSCIM_UserInSeveralGroups(t *testing.T) {
+	service, user := setupSCIMTest(t)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	members := []scim.MultiValue{{Value: userID}}
+
+	engineering, err := service.CreateGroup(&scim.Group{DisplayName: "Engineering", Members: members}, "10.0.0.1", "okta")
+	require.NoError(t, err)
+	oncall, err := service.CreateGroup(&scim.Group{DisplayName: "On-Call", Members: members}, "10.0.0.1", "okta")
+	require.NoError(t, err)
+
+	scimUser, err := service.GetUser(userID)
+	require.NoError(t, err)
+	require.Len(t, scimUser.Groups, 3)
+	assert.Equal(t, "Engineering", scimUser.Groups[0].Display)
+
+	list, err := service.ListGroups("", scim.ParsePage("", ""), true)
+	require.NoError(t, err)
+	for _, resource := range list.Resources {
+		assert.Len(t, resource.(*scim.Group).Members, 1)
+	}
+
+	_, err = service.PatchGroup(engineering.ID, patch(scim.PatchOperation{Op: "remove", Path: "members"}), "10.0.0.1", "okta")
+	require.NoError(t, err)
+
+	var member models.User
+	require.NoError(t, database.DB.Preload("Role").Preload("Roles").First(&member, user.ID).Error)
+	assert.Equal(t, "User", member.Role.Name)
+	assert.True(t, member.HasRole("On-Call"))
+	assert.False(t, member.HasRole("Engineering"))
+
+	defaultRoleID := strconv.FormatUint(uint64(user.RoleID), 10)
+	_, err = service.PatchGroup(defaultRoleID, patch(scim.PatchOperation{Op: "remove", Path: "members"}), "10.0.0.1", "okta")
+	require.NoError(t, err)
+
+	require.NoError(t, database.DB.Preload("Role").Preload("Roles").First(&member, user.ID).Error)
+	assert.Equal(t, "On-Call", member.Role.Name)
+	assert.Len(t, member.AllRoles(), 1)
+
+	group, err := service.GetGroup(oncall.ID, true)
+	require.NoError(t, err)
+	assert.Len(t, group.Members, 1)
+}
+
+func TestSCIM_GroupRules(t *testing.T) {
+	service, user := setupSCIMTest(t)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	system := models.Role{Name: "Super Admin", IsSystemRole: true}
+	database.DB.Create(&system)
+	systemID := strconv.FormatUint(uint64(system.ID), 10)
+
+	_, err := service.PatchGroup(systemID, patch(scim.PatchOperation{Op: "replace", Path: "displayName", Value: json.RawMessage(`"Root"`)}), "10.0.0.1", "okta")
+	assert.Equal(t, scim.ErrorTypeMutability, scimError(t, err).ScimType)
+	assert.Equal(t, scim.ErrorTypeMutability, scimError(t, service.DeleteGroup(systemID, "10.0.0.1", "okta")).ScimType)
+
+	defaultRoleID := strconv.FormatUint(uint64(user.RoleID), 10)
+	_, err = service.PatchGroup(defaultRoleID, patch(scim.PatchOperation{Op: "remove", Path: "members"}), "10.0.0.1", "okta")
+	assert.Equal(t, scim.ErrorTypeMutability, scimError(t, err).ScimType)
+
+	_, err = service.PatchGroup(defaultRoleID, patch(scim.PatchOperation{Op: "add", Path: "members", Value: json.RawMessage(`[{"value":"999"}]`)}), "10.0.0.1", "okta")
+	assert.Equal(t, scim.ErrorTypeInvalidValue, scimError(t, err).ScimType)
+
+	_, err = service.CreateGroup(&scim.Group{DisplayName: "User"}, "10.0.0.1", "okta")
+	assert.Equal(t, "409", scimError(t, err).Status)
+
+	group, err := service.GetGroup(defaultRoleID, true)
+	require.NoError(t, err)
+	assert.Equal(t, []scim.MultiValue{{Value: userID, Display: user.Username}}, group.Members)
+}
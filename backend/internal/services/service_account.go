@@ -0,0 +1,352 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+)
+
+// ServiceAccountKeyPrefix marks bearer tokens that are service account API
+// keys rather than JWTs.
+const ServiceAccountKeyPrefix = "sak_"
+
+type ServiceAccountService struct {
+	jwtService *utils.JWTService
+}
+
+func NewServiceAccountService(jwtService *utils.JWTService) *ServiceAccountService {
+	return &ServiceAccountService{jwtService: jwtService}
+}
+
+func IsServiceAccountKey(token string) bool {
+	return strings.HasPrefix(token, ServiceAccountKeyPrefix)
+}
+
+func (s *ServiceAccountService) GetServiceAccounts() ([]models.ServiceAccount, error) {
+	var accounts []models.ServiceAccount
+	if err := database.DB.Preload("Role").Order("name").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (s *ServiceAccountService) GetServiceAccount(id uint) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := database.DB.Preload("Role").First(&account, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("service account not found")
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// CreateServiceAccount creates the account together with its client
+// credentials. The client secret is only returned here.
+func (s *ServiceAccountService) CreateServiceAccount(req *models.ServiceAccountInput) (*models.ServiceAccountCredentials, error) {
+	var existing models.ServiceAccount
+	if err := database.DB.Where("name = ?", req.Name).First(&existing).Error; err == nil {
+		return nil, errors.New("service account with this name already exists")
+	}
+
+	var role models.Role
+	if err := database.DB.First(&role, req.RoleID).Error; err != nil {
+		return nil, errors.New("role not found")
+	}
+
+	clientID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	clientSecret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	account := models.ServiceAccount{
+		Name:             req.Name,
+		Description:      req.Description,
+		RoleID:           req.RoleID,
+		IsActive:         true,
+		ClientID:         clientID,
+		ClientSecretHash: utils.HashToken(clientSecret),
+	}
+	if err := database.DB.Create(&account).Error; err != nil {
+		return nil, err
+	}
+	account.Role = role
+
+	return &models.ServiceAccountCredentials{
+		ServiceAccount: &account,
+		ClientID:       clientID,
+		ClientSecret:   clientSecret,
+	}, nil
+}
+
+func (s *ServiceAccountService) UpdateServiceAccount(id uint, req *models.ServiceAccountUpdateInput) (*models.ServiceAccount, error) {
+	account, err := s.GetServiceAccount(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" && req.Name != account.Name {
+		var existing models.ServiceAccount
+		if err := database.DB.Where("name = ? AND id != ?", req.Name, id).First(&existing).Error; err == nil {
+			return nil, errors.New("service account with this name already exists")
+		}
+		account.Name = req.Name
+	}
+
+	if req.Description != "" {
+		account.Description = req.Description
+	}
+
+	if req.RoleID != 0 {
+		var role models.Role
+		if err := database.DB.First(&role, req.RoleID).Error; err != nil {
+			return nil, errors.New("role not found")
+		}
+		account.RoleID = req.RoleID
+		account.Role = role
+	}
+
+	if req.IsActive != nil {
+		account.IsActive = *req.IsActive
+	}
+
+	if err := database.DB.Omit("Role").Save(account).Error; err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// DeleteServiceAccount removes the account and revokes its API keys. Access
+// tokens it was issued stop working because the account no longer loads.
+func (s *ServiceAccountService) DeleteServiceAccount(id uint) error {
+	account, err := s.GetServiceAccount(id)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ServiceAccountAPIKey{}).
+			Where("service_account_id = ? AND revoked_at IS NULL", account.ID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(account).Error
+	})
+}
+
+// RotateClientSecret replaces the client secret and invalidates every access
+// token issued for the old one. The new secret is only returned here.
+func (s *ServiceAccountService) RotateClientSecret(id uint) (*models.ServiceAccountCredentials, error) {
+	account, err := s.GetServiceAccount(id)
+	if err != nil {
+		return nil, err
+	}
+
+	clientSecret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(account).Updates(map[string]interface{}{
+		"client_secret_hash":     utils.HashToken(clientSecret),
+		"credentials_rotated_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.ServiceAccountCredentials{
+		ServiceAccount: account,
+		ClientID:       account.ClientID,
+		ClientSecret:   clientSecret,
+	}, nil
+}
+
+// IssueToken exchanges client credentials for a short-lived access token.
+func (s *ServiceAccountService) IssueToken(req *models.ServiceAccountTokenRequest, ipAddress, userAgent string) (*models.ServiceAccountTokenResponse, error) {
+	var account models.ServiceAccount
+	if err := database.DB.Where("client_id = ?", req.ClientID).First(&account).Error; err != nil {
+		return nil, errors.New("invalid client credentials")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(account.ClientSecretHash), []byte(utils.HashToken(req.ClientSecret))) != 1 {
+		return nil, errors.New("invalid client credentials")
+	}
+
+	if !account.IsActive {
+		return nil, errors.New("service account is deactivated")
+	}
+
+	accessToken, expiresAt, err := s.jwtService.GenerateServiceAccountToken(&account, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	database.DB.Model(&account).Update("last_used_at", now)
+
+	activityLog := models.ActivityLog{
+		ServiceAccountID: &account.ID,
+		Action:           "token_issued",
+		Resource:         "auth",
+		Details:          "Service account access token issued",
+		IPAddress:        ipAddress,
+		UserAgent:        userAgent,
+	}
+	database.DB.Create(&activityLog)
+
+	return &models.ServiceAccountTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// AuthenticateToken resolves the claims of a service account access token to
+// the active account, rejecting tokens issued before the last secret rotation.
+func (s *ServiceAccountService) AuthenticateToken(claims *models.JWTClaims) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := database.DB.Preload("Role").First(&account, claims.ServiceAccountID).Error; err != nil {
+		return nil, errors.New("service account not found")
+	}
+
+	if !account.IsActive {
+		return nil, errors.New("service account is deactivated")
+	}
+
+	if account.CredentialsRotatedAt != nil && claims.IssuedAt.Unix() < account.CredentialsRotatedAt.Unix() {
+		return nil, errors.New("token has been revoked")
+	}
+
+	return &account, nil
+}
+
+// AuthenticateAPIKey resolves an API key to its active account and records
+// where the key was last used from.
+func (s *ServiceAccountService) AuthenticateAPIKey(plain, ip string) (*models.ServiceAccount, error) {
+	var key models.ServiceAccountAPIKey
+	if err := database.DB.Preload("ServiceAccount.Role").
+		Where("key_hash = ?", utils.HashToken(plain)).
+		First(&key).Error; err != nil {
+		return nil, errors.New("invalid API key")
+	}
+
+	if !key.Active() {
+		return nil, errors.New("API key is revoked or expired")
+	}
+
+	account := key.ServiceAccount
+	if account.ID == 0 {
+		return nil, errors.New("service account not found")
+	}
+	if !account.IsActive {
+		return nil, errors.New("service account is deactivated")
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || key.LastUsedAt.Before(now.Add(-tokenTouchInterval)) || key.LastUsedIP != ip {
+		database.DB.Model(&key).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+		database.DB.Model(&account).Update("last_used_at", now)
+	}
+
+	return &account, nil
+}
+
+func (s *ServiceAccountService) GetAPIKeys(accountID uint) ([]models.ServiceAccountAPIKey, error) {
+	var keys []models.ServiceAccountAPIKey
+	if err := database.DB.Where("service_account_id = ?", accountID).
+		Order("created_at DESC").
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateAPIKey issues a new API key. The plain key is only returned here.
+func (s *ServiceAccountService) CreateAPIKey(accountID uint, req *models.ServiceAccountAPIKeyInput) (*models.CreatedServiceAccountAPIKey, error) {
+	if _, err := s.GetServiceAccount(accountID); err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	plain := ServiceAccountKeyPrefix + secret
+
+	key := models.ServiceAccountAPIKey{
+		ServiceAccountID: accountID,
+		Name:             req.Name,
+		KeyHash:          utils.HashToken(plain),
+		Prefix:           plain[:len(ServiceAccountKeyPrefix)+6],
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.Create(&key).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedServiceAccountAPIKey{ServiceAccountAPIKey: &key, Key: plain}, nil
+}
+
+func (s *ServiceAccountService) RevokeAPIKey(accountID, keyID uint) error {
+	var key models.ServiceAccountAPIKey
+	if err := database.DB.Where("id = ? AND service_account_id = ?", keyID, accountID).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("API key not found")
+		}
+		return err
+	}
+
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	return database.DB.Model(&key).Update("revoked_at", time.Now()).Error
+}
+
+func (s *ServiceAccountService) GetActivityLogs(accountID uint, page, limit int) (*models.ActivityLogListResponse, error) {
+	offset := (page - 1) * limit
+
+	var total int64
+	if err := database.DB.Model(&models.ActivityLog{}).Where("service_account_id = ?", accountID).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var activityLogs []models.ActivityLog
+	if err := database.DB.Preload("ServiceAccount").Where("service_account_id = ?", accountID).
+		Order("created_at desc").Offset(offset).Limit(limit).Find(&activityLogs).Error; err != nil {
+		return nil, err
+	}
+
+	activities := make([]models.ActivityLogResponse, len(activityLogs))
+	for i, log := range activityLogs {
+		activities[i] = *log.ToResponse()
+	}
+
+	return &models.ActivityLogListResponse{
+		Activities: activities,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: int(math.Ceil(float64(total) / float64(limit))),
+	}, nil
+}
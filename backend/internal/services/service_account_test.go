@@ -0,0 +1,109 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+)
+
+func setupServiceAccountTest(t *testing.T) (*services.ServiceAccountService, *models.ServiceAccountCredentials) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.ActivityLog{}, &models.ServiceAccount{}, &models.ServiceAccountAPIKey{}))
+	database.DB = db
+
+	readPerm := models.Permission{Name: "users.read", Resource: "users", Action: "read"}
+	db.Create(&readPerm)
+
+	role := models.Role{Name: "Reporter", Description: "Read-only automation"}
+	db.Create(&role)
+	db.Model(&role).Association("Permissions").Append(&readPerm)
+
+	service := services.NewServiceAccountService(utils.NewJWTService(testJWTConfig))
+	credentials, err := service.CreateServiceAccount(&models.ServiceAccountInput{Name: "nightly-report", RoleID: role.ID})
+	require.NoError(t, err)
+
+	return service, credentials
+}
+
+func TestServiceAccount_ClientCredentials(t *testing.T) {
+	service, credentials := setupServiceAccountTest(t)
+	account := credentials.ServiceAccount
+
+	var stored models.ServiceAccount
+	require.NoError(t, database.DB.First(&stored, account.ID).Error)
+	assert.NotEqual(t, credentials.ClientSecret, stored.ClientSecretHash, "only the hash should be stored")
+
+	_, err := service.IssueToken(&models.ServiceAccountTokenRequest{ClientID: credentials.ClientID, ClientSecret: "wrong"}, "10.0.0.1", "cron")
+	assert.Error(t, err)
+
+	token, err := service.IssueToken(&models.ServiceAccountTokenRequest{ClientID: credentials.ClientID, ClientSecret: credentials.ClientSecret}, "10.0.0.1", "cron")
+	require.NoError(t, err)
+
+	claims, err := utils.NewJWTService(testJWTConfig).ValidateServiceAccountToken(token.AccessToken)
+	require.NoError(t, err)
+	authenticated, err := service.AuthenticateToken(claims)
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, authenticated.ID)
+
+	rotated, err := service.RotateClientSecret(account.ID)
+	require.NoError(t, err)
+	_, err = service.IssueToken(&models.ServiceAccountTokenRequest{ClientID: credentials.ClientID, ClientSecret: credentials.ClientSecret}, "10.0.0.1", "cron")
+	assert.Error(t, err, "the old secret stops working after rotation")
+	_, err = service.IssueToken(&models.ServiceAccountTokenRequest{ClientID: credentials.ClientID, ClientSecret: rotated.ClientSecret}, "10.0.0.1", "cron")
+	assert.NoError(t, err)
+
+	inactive := false
+	_, err = service.UpdateServiceAccount(account.ID, &models.ServiceAccountUpdateInput{IsActive: &inactive})
+	require.NoError(t, err)
+	_, err = service.AuthenticateToken(claims)
+	assert.Error(t, err)
+}
+
+func TestServiceAccount_APIKeys(t *testing.T) {
+	service, credentials := setupServiceAccountTest(t)
+	account := credentials.ServiceAccount
+
+	key, err := service.CreateAPIKey(account.ID, &models.ServiceAccountAPIKeyInput{Name: "ci"})
+	require.NoError(t, err)
+	assert.True(t, services.IsServiceAccountKey(key.Key))
+
+	authenticated, err := service.AuthenticateAPIKey(key.Key, "10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, authenticated.ID)
+	assert.Equal(t, "Reporter", authenticated.Role.Name)
+
+	require.NoError(t, service.RevokeAPIKey(account.ID, key.ID))
+	_, err = service.AuthenticateAPIKey(key.Key, "10.0.0.2")
+	assert.Error(t, err)
+}
+
+func TestServiceAccount_PermissionsAndActivity(t *testing.T) {
+	service, credentials := setupServiceAccountTest(t)
+	account := credentials.ServiceAccount
+	rbacService := services.NewRBACService(database.DB)
+
+	allowed, err := rbacService.CheckServiceAccountPermission(account.ID, "users", "read")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = rbacService.CheckServiceAccountPermission(account.ID, "users", "delete")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	_, err = service.IssueToken(&models.ServiceAccountTokenRequest{ClientID: credentials.ClientID, ClientSecret: credentials.ClientSecret}, "10.0.0.1", "cron")
+	require.NoError(t, err)
+
+	activity, err := service.GetActivityLogs(account.ID, 1, 20)
+	require.NoError(t, err)
+	require.Len(t, activity.Activities, 1)
+	assert.Equal(t, "token_issued", activity.Activities[0].Action)
+	require.NotNil(t, activity.Activities[0].ServiceAccount)
+	assert.Equal(t, "nightly-report", activity.Activities[0].ServiceAccount.Name)
+	assert.Zero(t, activity.Activities[0].UserID)
+}
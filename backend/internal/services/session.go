@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// sessionTouchInterval bounds how often an authenticated request writes the
+// session's last-seen time, so busy clients don't cause a write per request.
+const sessionTouchInterval = time.Minute
+
+type SessionService struct{}
+
+func NewSessionService() *SessionService {
+	return &SessionService{}
+}
+
+func (s *SessionService) CreateSession(userID uint, familyID, ipAddress, userAgent string) (*models.Session, error) {
+	now := time.Now()
+	session := models.Session{
+		UserID:          userID,
+		FamilyID:        familyID,
+		IPAddress:       ipAddress,
+		UserAgent:       userAgent,
+		LastSeenAt:      now,
+		AuthenticatedAt: now,
+	}
+
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// GetActiveSessions returns the user's sessions that are neither revoked nor expired.
+func (s *SessionService) GetActiveSessions(userID, currentSessionID uint) ([]models.SessionResponse, error) {
+	var sessions []models.Session
+	if err := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at desc").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = *session.ToResponse(currentSessionID)
+	}
+
+	return responses, nil
+}
+
+func (s *SessionService) IsSessionActive(sessionID uint) bool {
+	var count int64
+	database.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Count(&count)
+	return count > 0
+}
+
+func (s *SessionService) TouchSession(sessionID uint) {
+	now := time.Now()
+	database.DB.Model(&models.Session{}).
+		Where("id = ? AND last_seen_at < ?", sessionID, now.Add(-sessionTouchInterval)).
+		Update("last_seen_at", now)
+}
+
+// RevokeSession revokes one of the user's sessions together with its refresh
+// token family. Access tokens carry the session ID, so they stop working on
+// their next request.
+func (s *SessionService) RevokeSession(userID, sessionID uint) error {
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("session not found")
+		}
+		return err
+	}
+
+	if session.RevokedAt != nil {
+		return nil
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&session).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", session.FamilyID).
+			Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": "logout"}).Error
+	})
+}
+
+func (s *SessionService) RevokeAllSessions(userID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": "logout"}).Error
+	})
+}
+
+func (s *SessionService) CountActiveSessions() (int64, error) {
+	var count int64
+	err := database.DB.Model(&models.Session{}).
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Count(&count).Error
+	return count, err
+}
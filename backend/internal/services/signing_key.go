@@ -0,0 +1,252 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+)
+
+// SigningKeyService keeps the asymmetric JWT signing keys in the database so
+// every instance signs with the same key and publishes the same JWKS. Keys
+// are rotated every KeyRotationInterval; a retired key stays available for
+// verification until every token it could have signed has expired.
+type SigningKeyService struct {
+	algorithm        string
+	rotationInterval time.Duration
+	retention        time.Duration
+
+	mu      sync.RWMutex
+	current *utils.SigningKey
+	keys    map[string]*utils.SigningKey
+
+	// reloadMu serializes the reloads for unknown kids, and loadedAt limits
+	// them to one per unknownKeyReloadInterval.
+	reloadMu sync.Mutex
+	loadedAt time.Time
+}
+
+// unknownKeyReloadInterval is the least time between two reloads caused by
+// unknown kids, so tokens with made-up kids can't query the database on
+// every request.
+const unknownKeyReloadInterval = 5 * time.Second
+
+func NewSigningKeyService(cfg *config.Config) *SigningKeyService {
+	// Every signed token type counts; invitations may be issued for up to
+	// maxInvitationExpiry whatever INVITATION_EXPIRY says.
+	retention := cfg.JWT.AccessTokenExpiry
+	for _, lifetime := range []time.Duration{
+		cfg.JWT.RefreshTokenExpiry,
+		cfg.MFA.PendingTokenExpiry,
+		cfg.Email.TokenExpiry,
+		cfg.Impersonation.TokenExpiry,
+		cfg.Register.InvitationExpiry,
+		maxInvitationExpiry,
+	} {
+		if lifetime > retention {
+			retention = lifetime
+		}
+	}
+
+	return &SigningKeyService{
+		algorithm:        cfg.JWT.Algorithm,
+		rotationInterval: cfg.JWT.KeyRotationInterval,
+		retention:        retention,
+		keys:             make(map[string]*utils.SigningKey),
+	}
+}
+
+// CurrentKey returns the key new tokens are signed with.
+func (s *SigningKeyService) CurrentKey() (*utils.SigningKey, error) {
+	s.mu.RLock()
+	current := s.current
+	s.mu.RUnlock()
+
+	if current == nil {
+		return nil, errors.New("no signing key available")
+	}
+	return current, nil
+}
+
+// Key returns the key with the given kid. Unknown kids trigger a reload so
+// keys rotated in by another instance are picked up, at most once per
+// unknownKeyReloadInterval.
+func (s *SigningKeyService) Key(kid string) (*utils.SigningKey, error) {
+	if key := s.lookup(kid); key != nil {
+		return key, nil
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	// Another request may have reloaded while this one waited.
+	if key := s.lookup(kid); key != nil {
+		return key, nil
+	}
+
+	s.mu.RLock()
+	recent := time.Since(s.loadedAt) < unknownKeyReloadInterval
+	s.mu.RUnlock()
+	if recent {
+		return nil, errors.New("unknown signing key")
+	}
+
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+
+	if key := s.lookup(kid); key != nil {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+func (s *SigningKeyService) lookup(kid string) *utils.SigningKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys[kid]
+}
+
+// Load reads the verifiable keys from the database and makes the newest
+// active key of the configured algorithm the current one.
+func (s *SigningKeyService) Load() error {
+	var stored []models.SigningKey
+	if err := database.DB.
+		Where("verify_until IS NULL OR verify_until > ?", time.Now()).
+		Order("created_at ASC, id ASC").
+		Find(&stored).Error; err != nil {
+		return err
+	}
+
+	keys := make(map[string]*utils.SigningKey, len(stored))
+	var current *utils.SigningKey
+	for _, record := range stored {
+		key, err := utils.ParseSigningKey(record.KID, record.Algorithm, record.PrivateKey, record.PublicKey)
+		if err != nil {
+			log.Printf("Skipping unreadable signing key %s: %v", record.KID, err)
+			continue
+		}
+
+		keys[record.KID] = key
+		if record.RetiredAt == nil && record.Algorithm == s.algorithm {
+			current = key
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.current = current
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Initialize loads the stored keys and creates the first one if there is no
+// active key for the configured algorithm yet.
+func (s *SigningKeyService) Initialize() error {
+	if err := s.Load(); err != nil {
+		return err
+	}
+
+	if _, err := s.CurrentKey(); err != nil {
+		return s.Rotate()
+	}
+	return nil
+}
+
+// Rotate creates a new signing key and retires the previous ones, keeping
+// them verifiable for the retention period.
+func (s *SigningKeyService) Rotate() error {
+	privatePEM, publicPEM, err := utils.GenerateSigningKeyPair(s.algorithm)
+	if err != nil {
+		return err
+	}
+
+	kid, err := utils.GenerateRandomToken(8)
+	if err != nil {
+		return err
+	}
+
+	key := models.SigningKey{
+		KID:        kid,
+		Algorithm:  s.algorithm,
+		PrivateKey: privatePEM,
+		PublicKey:  publicPEM,
+	}
+	if err := database.DB.Create(&key).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	verifyUntil := now.Add(s.retention)
+	if err := database.DB.Model(&models.SigningKey{}).
+		Where("id <> ? AND retired_at IS NULL", key.ID).
+		Updates(map[string]interface{}{"retired_at": now, "verify_until": verifyUntil}).Error; err != nil {
+		return err
+	}
+
+	log.Printf("Rotated JWT signing key, new kid %s", kid)
+	return s.Load()
+}
+
+// RotateIfDue rotates when the current key is older than the rotation
+// interval and deletes keys that are no longer needed for verification. It
+// also reloads the keys, so instances converge on rotations made elsewhere.
+func (s *SigningKeyService) RotateIfDue() error {
+	database.DB.Where("verify_until IS NOT NULL AND verify_until <= ?", time.Now()).Delete(&models.SigningKey{})
+
+	if s.rotationInterval > 0 {
+		var latest models.SigningKey
+		err := database.DB.Where("retired_at IS NULL AND algorithm = ?", s.algorithm).
+			Order("created_at DESC, id DESC").First(&latest).Error
+		if err != nil || time.Since(latest.CreatedAt) >= s.rotationInterval {
+			return s.Rotate()
+		}
+	}
+
+	return s.Load()
+}
+
+// StartRotation checks for due rotations every checkInterval until stop is
+// closed.
+func (s *SigningKeyService) StartRotation(checkInterval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.RotateIfDue(); err != nil {
+				log.Printf("Failed to rotate JWT signing keys: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// JWKS returns the public halves of every key that may still have signed a
+// valid token.
+func (s *SigningKeyService) JWKS() (*models.JWKS, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jwks := &models.JWKS{Keys: []models.JWK{}}
+	for _, key := range s.keys {
+		jwk, err := key.JWK()
+		if err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+
+	return jwks, nil
+}
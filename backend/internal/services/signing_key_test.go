@@ -0,0 +1,150 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/services"
+	"rbac-system/backend/internal/utils"
+)
+
+func TestSigningKeyService_RotationKeepsOldKeysVerifiable(t *testing.T) {
+	for _, algorithm := range []string{"RS256", "ES256", "EdDSA"} {
+		t.Run(algorithm, func(t *testing.T) {
+			_, user := setupAuthTest(t)
+			require.NoError(t, database.DB.AutoMigrate(&models.SigningKey{}))
+
+			cfg := *testJWTConfig
+			cfg.JWT.Algorithm = algorithm
+			cfg.JWT.KeyRotationInterval = time.Hour
+
+			keyService := services.NewSigningKeyService(&cfg)
+			require.NoError(t, keyService.Initialize())
+			jwtService := utils.NewJWTService(&cfg).WithKeyProvider(keyService)
+
+			oldToken, _, err := jwtService.GenerateAccessToken(user, 1, time.Now())
+			require.NoError(t, err)
+			oldKey, err := keyService.CurrentKey()
+			require.NoError(t, err)
+
+			require.NoError(t, keyService.Rotate())
+			newKey, err := keyService.CurrentKey()
+			require.NoError(t, err)
+			assert.NotEqual(t, oldKey.KID, newKey.KID)
+
+			newToken, _, err := jwtService.GenerateAccessToken(user, 1, time.Now())
+			require.NoError(t, err)
+
+			for _, token := range []string{oldToken, newToken} {
+				claims, err := jwtService.ValidateAccessToken(token)
+				require.NoError(t, err)
+				assert.Equal(t, user.ID, claims.UserID)
+			}
+
+			jwks, err := keyService.JWKS()
+			require.NoError(t, err)
+			require.Len(t, jwks.Keys, 2)
+			for _, jwk := range jwks.Keys {
+				assert.Equal(t, algorithm, jwk.Alg)
+				assert.Equal(t, "sig", jwk.Use)
+			}
+
+			// Once the retention period has passed the old key is dropped and
+			// its tokens are rejected.
+			database.DB.Model(&models.SigningKey{}).Where("kid = ?", oldKey.KID).
+				Update("verify_until", time.Now().Add(-time.Minute))
+			require.NoError(t, keyService.RotateIfDue())
+
+			_, err = jwtService.ValidateAccessToken(oldToken)
+			assert.Error(t, err)
+			_, err = jwtService.ValidateAccessToken(newToken)
+			assert.NoError(t, err)
+
+			jwks, err = keyService.JWKS()
+			require.NoError(t, err)
+			assert.Len(t, jwks.Keys, 1)
+		})
+	}
+}
+
+func TestSigningKeyService_RotateIfDue(t *testing.T) {
+	setupAuthTest(t)
+	require.NoError(t, database.DB.AutoMigrate(&models.SigningKey{}))
+
+	cfg := *testJWTConfig
+	cfg.JWT.Algorithm = "ES256"
+	cfg.JWT.KeyRotationInterval = time.Hour
+
+	keyService := services.NewSigningKeyService(&cfg)
+	require.NoError(t, keyService.Initialize())
+	first, err := keyService.CurrentKey()
+	require.NoError(t, err)
+
+	require.NoError(t, keyService.RotateIfDue())
+	current, err := keyService.CurrentKey()
+	require.NoError(t, err)
+	assert.Equal(t, first.KID, current.KID)
+
+	database.DB.Model(&models.SigningKey{}).Where("kid = ?", first.KID).
+		Update("created_at", time.Now().Add(-2*time.Hour))
+	require.NoError(t, keyService.RotateIfDue())
+	current, err = keyService.CurrentKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, first.KID, current.KID)
+
+	// The retired key is kept for the longest token lifetime, here the 30
+	// days an invitation link may last.
+	var retired models.SigningKey
+	require.NoError(t, database.DB.Where("kid = ?", first.KID).First(&retired).Error)
+	require.NotNil(t, retired.VerifyUntil)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), *retired.VerifyUntil, time.Minute)
+}
+
+func TestSigningKeyService_UnknownKidsReloadAtMostOncePerInterval(t *testing.T) {
+	setupAuthTest(t)
+	require.NoError(t, database.DB.AutoMigrate(&models.SigningKey{}))
+
+	cfg := *testJWTConfig
+	cfg.JWT.Algorithm = "ES256"
+
+	keyService := services.NewSigningKeyService(&cfg)
+	require.NoError(t, keyService.Initialize())
+
+	// A key rotated in by another instance right after the load is only
+	// picked up once the reload interval has passed.
+	other := services.NewSigningKeyService(&cfg)
+	require.NoError(t, other.Rotate())
+	rotated, err := other.CurrentKey()
+	require.NoError(t, err)
+
+	_, err = keyService.Key(rotated.KID)
+	assert.Error(t, err)
+	_, err = keyService.Key("made-up")
+	assert.Error(t, err)
+
+	require.NoError(t, keyService.Load())
+	key, err := keyService.Key(rotated.KID)
+	require.NoError(t, err)
+	assert.Equal(t, rotated.KID, key.KID)
+}
+
+func TestJWTService_RejectsHS256TokensWhenAsymmetric(t *testing.T) {
+	_, user := setupAuthTest(t)
+	require.NoError(t, database.DB.AutoMigrate(&models.SigningKey{}))
+
+	hmacToken, _, err := utils.NewJWTService(testJWTConfig).GenerateAccessToken(user, 1, time.Now())
+	require.NoError(t, err)
+
+	cfg := *testJWTConfig
+	cfg.JWT.Algorithm = "RS256"
+	keyService := services.NewSigningKeyService(&cfg)
+	require.NoError(t, keyService.Initialize())
+
+	_, err = utils.NewJWTService(&cfg).WithKeyProvider(keyService).ValidateAccessToken(hmacToken)
+	assert.Error(t, err)
+}
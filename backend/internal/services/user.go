@@ -5,8 +5,10 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/mailer"
 	"rbac-system/backend/internal/models"
 	"rbac-system/backend/internal/utils"
 
@@ -14,19 +16,25 @@ import (
 )
 
 type UserService struct {
-	rbacService *RBACService
+	rbacService     *RBACService
+	emailService    *EmailVerificationService
+	notifier        *mailer.Notifier
+	passwordService *PasswordService
 }
 
-func NewUserService(rbacService *RBACService) *UserService {
+func NewUserService(rbacService *RBACService, emailService *EmailVerificationService, notifier *mailer.Notifier, passwordService *PasswordService) *UserService {
 	return &UserService{
-		rbacService: rbacService,
+		rbacService:     rbacService,
+		emailService:    emailService,
+		notifier:        notifier,
+		passwordService: passwordService,
 	}
 }
 
 func (s *UserService) GetUsers(page, limit int, search, sortBy, sortOrder string) (*models.UserListResponse, error) {
 	offset := (page - 1) * limit
 
-	query := database.DB.Model(&models.User{}).Preload("Role")
+	query := database.DB.Model(&models.User{}).Preload("Role").Preload("Roles")
 
 	if search != "" {
 		searchTerm := "%" + strings.ToLower(search) + "%"
@@ -74,12 +82,15 @@ func (s *UserService) GetUsers(page, limit int, search, sortBy, sortOrder string
 
 func (s *UserService) GetUserByID(id uint) (*models.User, error) {
 	var user models.User
-	if err := database.DB.Preload("Role.Permissions").First(&user, id).Error; err != nil {
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&user, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("user not found")
 		}
 		return nil, err
 	}
+	if err := withInheritedPermissions(&user); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
@@ -89,9 +100,13 @@ func (s *UserService) CreateUser(req *models.UserInput) (*models.User, error) {
 		return nil, errors.New("user with this email or username already exists")
 	}
 
-	var role models.Role
-	if err := database.DB.First(&role, req.RoleID).Error; err != nil {
-		return nil, errors.New("role not found")
+	roles, err := findRoles(append([]uint{req.RoleID}, req.RoleIDs...))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := utils.ValidatePassword(req.Password, req.Username, req.Email); err != nil {
+		return nil, err
 	}
 
 	hashedPassword, err := utils.HashPassword(req.Password)
@@ -99,24 +114,34 @@ func (s *UserService) CreateUser(req *models.UserInput) (*models.User, error) {
 		return nil, err
 	}
 
+	now := time.Now()
 	user := models.User{
-		Email:        req.Email,
-		Username:     req.Username,
-		PasswordHash: hashedPassword,
-		FirstName:    req.FirstName,
-		LastName:     req.LastName,
-		RoleID:       req.RoleID,
-		IsActive:     true,
+		Email:             req.Email,
+		Username:          req.Username,
+		PasswordHash:      hashedPassword,
+		PasswordChangedAt: &now,
+		FirstName:         req.FirstName,
+		LastName:          req.LastName,
+		RoleID:            req.RoleID,
+		Roles:             roles,
+		IsActive:          true,
+	}
+
+	if err := database.DB.Omit("Roles.*").Create(&user).Error; err != nil {
+		return nil, err
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&user, user.ID).Error; err != nil {
 		return nil, err
 	}
-
-	if err := database.DB.Preload("Role.Permissions").First(&user, user.ID).Error; err != nil {
+	if err := withInheritedPermissions(&user); err != nil {
 		return nil, err
 	}
 
+	s.emailService.trySendVerification(&user)
+
+	s.notifier.SendWelcome(&user)
+
 	return &user, nil
 }
 
@@ -129,12 +154,14 @@ func (s *UserService) UpdateUser(id uint, req *models.UserUpdateInput) (*models.
 		return nil, err
 	}
 
+	emailChanged := false
 	if req.Email != "" && req.Email != user.Email {
 		var existingUser models.User
 		if err := database.DB.Where("email = ? AND id != ?", req.Email, id).First(&existingUser).Error; err == nil {
 			return nil, errors.New("user with this email already exists")
 		}
 		user.Email = req.Email
+		emailChanged = true
 	}
 
 	if req.Username != "" && req.Username != user.Username {
@@ -151,28 +178,86 @@ func (s *UserService) UpdateUser(id uint, req *models.UserUpdateInput) (*models.
 	if req.LastName != "" {
 		user.LastName = req.LastName
 	}
+	if req.IsActive != nil {
+		user.IsActive = *req.IsActive
+	}
+
+	var primary *models.Role
 	if req.RoleID != 0 {
 		var role models.Role
 		if err := database.DB.First(&role, req.RoleID).Error; err != nil {
 			return nil, errors.New("role not found")
 		}
-		user.RoleID = req.RoleID
+		primary = &role
 	}
-	if req.IsActive != nil {
-		user.IsActive = *req.IsActive
+
+	var roles []models.Role
+	if req.RoleIDs != nil {
+		found, err := findRoles(req.RoleIDs)
+		if err != nil {
+			return nil, err
+		}
+		roles = found
 	}
 
 	if err := database.DB.Save(&user).Error; err != nil {
 		return nil, err
 	}
 
-	if err := database.DB.Preload("Role.Permissions").First(&user, user.ID).Error; err != nil {
+	if err := s.updateUserRoles(&user, primary, roles, req.RoleIDs != nil); err != nil {
+		return nil, err
+	}
+
+	if emailChanged {
+		if err := s.emailService.ResetVerification(&user); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&user, user.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := withInheritedPermissions(&user); err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
 
+// updateUserRoles applies a role change from UpdateUser. A full set of roles
+// replaces the user's roles, keeping the current primary role when it is
+// among them unless a new primary is given; a primary alone swaps just that.
+func (s *UserService) updateUserRoles(user *models.User, primary *models.Role, roles []models.Role, replace bool) error {
+	if !replace {
+		if primary == nil || primary.ID == user.RoleID {
+			return nil
+		}
+		return replacePrimaryRole(user, primary)
+	}
+
+	if primary == nil {
+		for i := range roles {
+			if roles[i].ID == user.RoleID {
+				primary = &roles[i]
+			}
+		}
+	}
+	if primary == nil && len(roles) > 0 {
+		primary = &roles[0]
+	}
+	if primary == nil {
+		var current models.Role
+		if err := database.DB.First(&current, user.RoleID).Error; err != nil {
+			return err
+		}
+		primary = &current
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return setUserRoles(tx, user, primary, roles)
+	})
+}
+
 func (s *UserService) DeleteUser(id uint) error {
 	var user models.User
 	if err := database.DB.First(&user, id).Error; err != nil {
@@ -199,7 +284,10 @@ func (s *UserService) ActivateUser(id uint) (*models.User, error) {
 		return nil, err
 	}
 
-	if err := database.DB.Preload("Role.Permissions").First(&user, user.ID).Error; err != nil {
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&user, user.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := withInheritedPermissions(&user); err != nil {
 		return nil, err
 	}
 
@@ -220,23 +308,28 @@ func (s *UserService) DeactivateUser(id uint) (*models.User, error) {
 		return nil, err
 	}
 
-	if err := database.DB.Preload("Role.Permissions").First(&user, user.ID).Error; err != nil {
+	if err := database.DB.Preload("Role.Permissions").Preload("Roles.Permissions").First(&user, user.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := withInheritedPermissions(&user); err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
 
+// GetUserActivityLogs lists what the user did, including what was done while
+// someone impersonated them and what they did while impersonating others.
 func (s *UserService) GetUserActivityLogs(userID uint, page, limit int) (*models.ActivityLogListResponse, error) {
 	offset := (page - 1) * limit
 
 	var total int64
-	if err := database.DB.Model(&models.ActivityLog{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+	if err := database.DB.Model(&models.ActivityLog{}).Where("user_id = ? OR impersonator_id = ?", userID, userID).Count(&total).Error; err != nil {
 		return nil, err
 	}
 
 	var activityLogs []models.ActivityLog
-	if err := database.DB.Preload("User").Where("user_id = ?", userID).
+	if err := database.DB.Preload("User").Preload("Impersonator").Where("user_id = ? OR impersonator_id = ?", userID, userID).
 		Order("created_at desc").Offset(offset).Limit(limit).Find(&activityLogs).Error; err != nil {
 		return nil, err
 	}
@@ -293,19 +386,7 @@ func (s *UserService) UpdatePassword(userID uint, req *models.PasswordUpdateInpu
 		return errors.New("current password is incorrect")
 	}
 
-	// Hash new password
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
-	if err != nil {
-		return errors.New("failed to hash new password")
-	}
-
-	// Update password
-	user.PasswordHash = hashedPassword
-	if err := database.DB.Save(&user).Error; err != nil {
-		return err
-	}
-
-	return nil
+	return s.passwordService.SetPassword(&user, req.NewPassword)
 }
 
 func parseIntOrDefault(s string, defaultVal int) int {
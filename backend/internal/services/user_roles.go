@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+
+	"rbac-system/backend/internal/database"
+	"rbac-system/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// A user's roles are their primary role (role_id) together with the roles in
+// user_roles, which normally lists the primary role as well. The helpers
+// below keep both in step.
+
+// usersWithRole narrows a users query to holders of the role, primary or not.
+func usersWithRole(query *gorm.DB, roleID uint) *gorm.DB {
+	return query.Where("users.role_id = ? OR users.id IN (SELECT user_id FROM user_roles WHERE role_id = ?)", roleID, roleID)
+}
+
+// findRoles loads the roles with the IDs, ignoring duplicates.
+func findRoles(ids []uint) ([]models.Role, error) {
+	unique := []uint{}
+	seen := map[uint]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	var roles []models.Role
+	if len(unique) == 0 {
+		return roles, nil
+	}
+	if err := database.DB.Where("id IN ?", unique).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	if len(roles) != len(unique) {
+		return nil, errors.New("role not found")
+	}
+	return roles, nil
+}
+
+// setUserRoles replaces all of the user's roles. primary becomes role_id and
+// is added to roles if it is missing.
+func setUserRoles(tx *gorm.DB, user *models.User, primary *models.Role, roles []models.Role) error {
+	hasPrimary := false
+	for _, role := range roles {
+		hasPrimary = hasPrimary || role.ID == primary.ID
+	}
+	if !hasPrimary {
+		roles = append([]models.Role{*primary}, roles...)
+	}
+
+	if err := tx.Model(user).Omit("Role", "Roles").Update("role_id", primary.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(user).Omit("Roles.*").Association("Roles").Replace(roles); err != nil {
+		return err
+	}
+
+	user.RoleID = primary.ID
+	user.Role = *primary
+	user.Roles = roles
+	return nil
+}
+
+// loadUserRoles reloads the primary role and all roles of the user.
+func loadUserRoles(user *models.User) error {
+	return database.DB.Preload("Role").Preload("Roles").First(user, user.ID).Error
+}
+
+// replacePrimaryRole swaps the user's primary role for role, dropping the old
+// one. Other roles stay as they are.
+func replacePrimaryRole(user *models.User, role *models.Role) error {
+	if err := loadUserRoles(user); err != nil {
+		return err
+	}
+
+	roles := []models.Role{}
+	for _, current := range user.AllRoles() {
+		if current.ID != user.RoleID {
+			roles = append(roles, current)
+		}
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return setUserRoles(tx, user, role, roles)
+	})
+}
+
+// addUserRole gives the user role on top of their roles. With primary it also
+// becomes their primary role; the previous primary role is kept.
+func addUserRole(user *models.User, role *models.Role, primary bool) error {
+	if err := loadUserRoles(user); err != nil {
+		return err
+	}
+
+	roles := user.AllRoles()
+	if !user.HasRole(role.Name) {
+		roles = append(roles, *role)
+	}
+
+	next := &user.Role
+	if primary {
+		next = role
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return setUserRoles(tx, user, next, roles)
+	})
+}
+
+// removeUserRole takes role away from the user. If it was their primary role
+// the next remaining role takes over, or fallback when none is left.
+func removeUserRole(user *models.User, role *models.Role, fallback *models.Role) error {
+	if err := loadUserRoles(user); err != nil {
+		return err
+	}
+
+	roles := []models.Role{}
+	for _, current := range user.AllRoles() {
+		if current.ID != role.ID {
+			roles = append(roles, current)
+		}
+	}
+
+	primary := &user.Role
+	if user.RoleID == role.ID {
+		primary = fallback
+		if len(roles) > 0 {
+			primary = &roles[0]
+		}
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return setUserRoles(tx, user, primary, roles)
+	})
+}
@@ -0,0 +1,138 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/models"
+	"rbac-system/backend/internal/utils"
+)
+
+func newJWTConfig(issuer string, audience ...string) *config.Config {
+	return &config.Config{
+		JWT: config.JWTConfig{
+			Secret:             "shared-secret",
+			RefreshSecret:      "shared-refresh-secret",
+			AccessTokenExpiry:  15 * time.Minute,
+			RefreshTokenExpiry: time.Hour,
+			Issuer:             issuer,
+			Audience:           audience,
+			Leeway:             30 * time.Second,
+		},
+	}
+}
+
+var jwtTestUser = &models.User{ID: 42, Email: "user@example.com", RoleID: 3}
+
+func TestJWTService_RegisteredClaims(t *testing.T) {
+	jwtService := utils.NewJWTService(newJWTConfig("rbac-system", "rbac-system-api", "reports"))
+
+	tokenString, expiresAt, err := jwtService.GenerateAccessToken(jwtTestUser, 7, time.Now())
+	require.NoError(t, err)
+
+	claims := &utils.TokenClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(tokenString, claims)
+	require.NoError(t, err)
+	assert.Equal(t, "rbac-system", claims.Issuer)
+	assert.Equal(t, "42", claims.Subject)
+	assert.Equal(t, jwt.ClaimStrings{"rbac-system-api", "reports"}, claims.Audience)
+	assert.NotNil(t, claims.NotBefore)
+	assert.Equal(t, expiresAt.Unix(), claims.ExpiresAt.Unix())
+
+	validated, err := jwtService.ValidateAccessToken(tokenString)
+	require.NoError(t, err)
+	assert.Equal(t, uint(42), validated.UserID)
+	assert.Equal(t, uint(7), validated.SessionID)
+	assert.Equal(t, "access", validated.Type)
+}
+
+func TestJWTService_RejectsOtherIssuerAndAudience(t *testing.T) {
+	otherApp, _, err := utils.NewJWTService(newJWTConfig("rbac-system", "billing")).GenerateAccessToken(jwtTestUser, 1, time.Now())
+	require.NoError(t, err)
+	otherIssuer, _, err := utils.NewJWTService(newJWTConfig("someone-else", "rbac-system-api")).GenerateAccessToken(jwtTestUser, 1, time.Now())
+	require.NoError(t, err)
+
+	jwtService := utils.NewJWTService(newJWTConfig("rbac-system", "rbac-system-api"))
+
+	_, err = jwtService.ValidateAccessToken(otherApp)
+	assert.Error(t, err)
+	_, err = jwtService.ValidateAccessToken(otherIssuer)
+	assert.Error(t, err)
+}
+
+func TestJWTService_RejectsWrongTokenType(t *testing.T) {
+	jwtService := utils.NewJWTService(newJWTConfig("rbac-system", "rbac-system-api"))
+
+	mfaToken, _, err := jwtService.GenerateMFAToken(jwtTestUser, "verify")
+	require.NoError(t, err)
+
+	_, err = jwtService.ValidateAccessToken(mfaToken)
+	assert.Error(t, err)
+	_, err = jwtService.ValidateMFAToken(mfaToken, "enroll")
+	assert.Error(t, err)
+	_, err = jwtService.ValidateMFAToken(mfaToken, "verify")
+	assert.NoError(t, err)
+}
+
+func TestJWTService_Leeway(t *testing.T) {
+	cfg := newJWTConfig("rbac-system", "rbac-system-api")
+	jwtService := utils.NewJWTService(cfg)
+
+	sign := func(notBefore, expiresAt time.Time) string {
+		claims := &utils.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "rbac-system",
+				Subject:   "42",
+				Audience:  jwt.ClaimStrings{"rbac-system-api"},
+				NotBefore: jwt.NewNumericDate(notBefore),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+			UserID: 42,
+			Type:   "access",
+		}
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.JWT.Secret))
+		require.NoError(t, err)
+		return tokenString
+	}
+
+	now := time.Now()
+
+	// Within the leeway a slightly skewed clock is tolerated...
+	_, err := jwtService.ValidateAccessToken(sign(now.Add(10*time.Second), now.Add(time.Minute)))
+	assert.NoError(t, err)
+	_, err = jwtService.ValidateAccessToken(sign(now.Add(-time.Minute), now.Add(-10*time.Second)))
+	assert.NoError(t, err)
+
+	// ...beyond it the token is not yet or no longer valid.
+	_, err = jwtService.ValidateAccessToken(sign(now.Add(time.Minute), now.Add(2*time.Minute)))
+	assert.Error(t, err)
+	_, err = jwtService.ValidateAccessToken(sign(now.Add(-2*time.Minute), now.Add(-time.Minute)))
+	assert.Error(t, err)
+}
+
+func TestJWTService_ServiceAccountToken(t *testing.T) {
+	jwtService := utils.NewJWTService(newJWTConfig("rbac-system", "rbac-system-api"))
+
+	tokenString, _, err := jwtService.GenerateServiceAccountToken(&models.ServiceAccount{ID: 5, RoleID: 2}, "", "")
+	require.NoError(t, err)
+
+	claims := &utils.TokenClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(tokenString, claims)
+	require.NoError(t, err)
+	assert.Equal(t, "service-account:5", claims.Subject)
+	assert.Zero(t, claims.UserID)
+
+	validated, err := jwtService.ValidateServiceAccountToken(tokenString)
+	require.NoError(t, err)
+	assert.Equal(t, uint(5), validated.ServiceAccountID)
+	assert.Equal(t, uint(2), validated.RoleID)
+
+	_, err = jwtService.ValidateAccessToken(tokenString)
+	assert.Error(t, err, "service account tokens are not user access tokens")
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"rbac-system/backend/internal/config"
+)
+
+var (
+	ErrPasswordMismatch  = errors.New("password does not match")
+	ErrUnknownHashFormat = errors.New("unknown password hash format")
+)
+
+// PasswordHasher produces password hashes for new and changed passwords.
+// Verification does not depend on the configured hasher: every hash carries
+// its algorithm and parameters, so old hashes keep working after the
+// configuration changes and NeedsRehash reports which ones are outdated.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	NeedsRehash(hash string) bool
+}
+
+// BcryptHasher stores hashes in the standard modular crypt format
+// ($2a$<cost>$...), which is also what earlier versions wrote.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedBytes), nil
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher stores hashes as PHC strings:
+// $argon2id$v=19$m=<memory KiB>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type argon2idHash struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	parsed, err := parseArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+
+	return parsed.memory != h.Memory ||
+		parsed.iterations != h.Iterations ||
+		parsed.parallelism != h.Parallelism ||
+		uint32(len(parsed.salt)) != h.SaltLength ||
+		uint32(len(parsed.key)) != h.KeyLength
+}
+
+func parseArgon2idHash(hash string) (*argon2idHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrUnknownHashFormat
+	}
+
+	parsed := &argon2idHash{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &parsed.memory, &parsed.iterations, &parsed.parallelism); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+
+	var err error
+	if parsed.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+	if parsed.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(parsed.key) == 0 {
+		return nil, ErrUnknownHashFormat
+	}
+
+	return parsed, nil
+}
+
+// NewPasswordHasher builds the hasher selected by the configuration.
+func NewPasswordHasher(cfg config.PasswordHashConfig) (PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case "bcrypt":
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return &BcryptHasher{Cost: cfg.BcryptCost}, nil
+	case "argon2id":
+		if cfg.Argon2Memory == 0 || cfg.Argon2Iterations == 0 || cfg.Argon2Parallelism == 0 {
+			return nil, errors.New("argon2id memory, iterations and parallelism must be positive")
+		}
+		return &Argon2idHasher{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+			SaltLength:  16,
+			KeyLength:   32,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", cfg.Algorithm)
+	}
+}
+
+var passwordHasher PasswordHasher = &BcryptHasher{Cost: bcrypt.DefaultCost}
+
+// SetPasswordHasher replaces the hasher used by HashPassword and
+// PasswordNeedsRehash. It is meant to be called once at startup.
+func SetPasswordHasher(hasher PasswordHasher) {
+	passwordHasher = hasher
+}
+
+// PasswordNeedsRehash reports whether the hash was produced with a different
+// algorithm or weaker parameters than the configured hasher.
+func PasswordNeedsRehash(hash string) bool {
+	return passwordHasher.NeedsRehash(hash)
+}
+
+func verifyPassword(password, hash string) error {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		parsed, err := parseArgon2idHash(hash)
+		if err != nil {
+			return err
+		}
+
+		key := argon2.IDKey([]byte(password), parsed.salt, parsed.iterations, parsed.memory, parsed.parallelism, uint32(len(parsed.key)))
+		if subtle.ConstantTimeCompare(key, parsed.key) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(hash, "$2") {
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			return ErrPasswordMismatch
+		}
+		return nil
+	}
+
+	return ErrUnknownHashFormat
+}
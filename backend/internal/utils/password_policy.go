@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"rbac-system/backend/internal/config"
+)
+
+//go:embed data/common_passwords.txt
+var defaultCommonPasswords string
+
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password broke. SendValidationError
+// renders it as structured validation details.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return "password does not meet the policy: " + strings.Join(messages, "; ")
+}
+
+type PasswordPolicy struct {
+	MinLength        int
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	DisallowUserInfo bool
+	commonPasswords  map[string]struct{}
+}
+
+// NewPasswordPolicy builds the policy from configuration. The common-password
+// check uses CommonPasswordFile when set, otherwise the bundled list.
+func NewPasswordPolicy(cfg config.PasswordPolicyConfig) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		MinLength:        cfg.MinLength,
+		MaxLength:        cfg.MaxLength,
+		RequireUppercase: cfg.RequireUppercase,
+		RequireLowercase: cfg.RequireLowercase,
+		RequireDigit:     cfg.RequireDigit,
+		RequireSymbol:    cfg.RequireSymbol,
+		DisallowUserInfo: cfg.DisallowUserInfo,
+	}
+
+	if !cfg.CheckCommonPasswords {
+		return policy, nil
+	}
+
+	list := defaultCommonPasswords
+	if cfg.CommonPasswordFile != "" {
+		content, err := os.ReadFile(cfg.CommonPasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		list = string(content)
+	}
+	policy.commonPasswords = parseCommonPasswords(list)
+
+	return policy, nil
+}
+
+func parseCommonPasswords(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		if password := strings.ToLower(strings.TrimSpace(scanner.Text())); password != "" {
+			passwords[password] = struct{}{}
+		}
+	}
+	return passwords
+}
+
+// Validate checks the password against every rule. username and email are
+// the account's identifiers, which the password may not contain.
+func (p *PasswordPolicy) Validate(password, username, email string) error {
+	var violations []PasswordViolation
+	add := func(code, message string) {
+		violations = append(violations, PasswordViolation{Code: code, Message: message})
+	}
+
+	length := len([]rune(password))
+	if length < p.MinLength {
+		add("too_short", fmt.Sprintf("Password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add("too_long", fmt.Sprintf("Password must be at most %d characters long", p.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUppercase && !hasUpper {
+		add("missing_uppercase", "Password must contain an uppercase letter")
+	}
+	if p.RequireLowercase && !hasLower {
+		add("missing_lowercase", "Password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		add("missing_digit", "Password must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		add("missing_symbol", "Password must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	if p.DisallowUserInfo && containsUserInfo(lowered, username, email) {
+		add("contains_user_info", "Password must not contain your username or email address")
+	}
+
+	if _, common := p.commonPasswords[lowered]; common {
+		add("common_password", "Password is too common")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsUserInfo reports whether the lowercased password contains the
+// username, the full email or its local part. Identifiers shorter than three
+// characters are ignored to avoid rejecting passwords by coincidence.
+func containsUserInfo(password, username, email string) bool {
+	email = strings.ToLower(email)
+	localPart, _, _ := strings.Cut(email, "@")
+
+	for _, identifier := range []string{strings.ToLower(username), email, localPart} {
+		if len(identifier) >= 3 && strings.Contains(password, identifier) {
+			return true
+		}
+	}
+	return false
+}
+
+var passwordPolicy = &PasswordPolicy{
+	MinLength:        8,
+	MaxLength:        100,
+	DisallowUserInfo: true,
+	commonPasswords:  parseCommonPasswords(defaultCommonPasswords),
+}
+
+// SetPasswordPolicy replaces the policy used by ValidatePassword. It is meant
+// to be called once at startup.
+func SetPasswordPolicy(policy *PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+// ValidatePassword checks a new password against the configured policy.
+func ValidatePassword(password, username, email string) error {
+	return passwordPolicy.Validate(password, username, email)
+}
@@ -0,0 +1,64 @@
+package utils_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/utils"
+)
+
+func violationCodes(t *testing.T, err error) []string {
+	var policyErr *utils.PasswordPolicyError
+	require.True(t, errors.As(err, &policyErr), "expected a password policy error, got %v", err)
+
+	codes := make([]string, len(policyErr.Violations))
+	for i, violation := range policyErr.Violations {
+		codes[i] = violation.Code
+	}
+	return codes
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy, err := utils.NewPasswordPolicy(config.PasswordPolicyConfig{
+		MinLength:            10,
+		MaxLength:            64,
+		RequireUppercase:     true,
+		RequireLowercase:     true,
+		RequireDigit:         true,
+		RequireSymbol:        true,
+		DisallowUserInfo:     true,
+		CheckCommonPasswords: true,
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, policy.Validate("Tr0ub4dor&3x", "jdoe", "jane@example.com"))
+
+	assert.ElementsMatch(t,
+		[]string{"too_short", "missing_uppercase", "missing_digit", "missing_symbol"},
+		violationCodes(t, policy.Validate("short", "jdoe", "jane@example.com")))
+
+	assert.Contains(t, violationCodes(t, policy.Validate("My-JDoe-Pass1", "jdoe", "jane@example.com")), "contains_user_info")
+	assert.Contains(t, violationCodes(t, policy.Validate("Jane#Secret12", "jdoe", "jane@example.com")), "contains_user_info")
+	assert.Contains(t, violationCodes(t, policy.Validate("PASSWORD123", "jdoe", "jane@example.com")), "common_password")
+}
+
+func TestPasswordPolicy_CustomCommonPasswordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common.txt")
+	require.NoError(t, os.WriteFile(path, []byte("CorrectHorse\n\n"), 0o644))
+
+	policy, err := utils.NewPasswordPolicy(config.PasswordPolicyConfig{
+		MinLength:            8,
+		CheckCommonPasswords: true,
+		CommonPasswordFile:   path,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"common_password"}, violationCodes(t, policy.Validate("correcthorse", "", "")))
+	assert.NoError(t, policy.Validate("password123", "", ""))
+}
@@ -0,0 +1,76 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rbac-system/backend/internal/config"
+	"rbac-system/backend/internal/utils"
+)
+
+var testArgon2Config = config.PasswordHashConfig{
+	Algorithm:         "argon2id",
+	Argon2Memory:      1024,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+}
+
+func usePasswordHasher(t *testing.T, cfg config.PasswordHashConfig) {
+	hasher, err := utils.NewPasswordHasher(cfg)
+	require.NoError(t, err)
+
+	utils.SetPasswordHasher(hasher)
+	t.Cleanup(func() { utils.SetPasswordHasher(&utils.BcryptHasher{Cost: 10}) })
+}
+
+func TestArgon2idHasher_PHCFormat(t *testing.T) {
+	usePasswordHasher(t, testArgon2Config)
+
+	hash, err := utils.HashPassword("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	assert.NoError(t, utils.CheckPassword("correct horse", hash))
+	assert.ErrorIs(t, utils.CheckPassword("wrong horse", hash), utils.ErrPasswordMismatch)
+	assert.False(t, utils.PasswordNeedsRehash(hash))
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	usePasswordHasher(t, config.PasswordHashConfig{Algorithm: "bcrypt", BcryptCost: 4})
+	bcryptHash, err := utils.HashPassword("secret")
+	require.NoError(t, err)
+	assert.False(t, utils.PasswordNeedsRehash(bcryptHash))
+
+	// A higher cost makes existing hashes outdated, but they still verify.
+	usePasswordHasher(t, config.PasswordHashConfig{Algorithm: "bcrypt", BcryptCost: 5})
+	assert.True(t, utils.PasswordNeedsRehash(bcryptHash))
+	assert.True(t, utils.CheckPasswordHash("secret", bcryptHash))
+
+	// Switching algorithms keeps bcrypt hashes verifiable too.
+	usePasswordHasher(t, testArgon2Config)
+	assert.True(t, utils.PasswordNeedsRehash(bcryptHash))
+	assert.True(t, utils.CheckPasswordHash("secret", bcryptHash))
+
+	stronger := testArgon2Config
+	stronger.Argon2Iterations = 2
+	argonHash, err := utils.HashPassword("secret")
+	require.NoError(t, err)
+	usePasswordHasher(t, stronger)
+	assert.True(t, utils.PasswordNeedsRehash(argonHash))
+	assert.True(t, utils.CheckPasswordHash("secret", argonHash))
+}
+
+func TestNewPasswordHasher_RejectsInvalidConfig(t *testing.T) {
+	_, err := utils.NewPasswordHasher(config.PasswordHashConfig{Algorithm: "md5"})
+	assert.Error(t, err)
+
+	_, err = utils.NewPasswordHasher(config.PasswordHashConfig{Algorithm: "bcrypt", BcryptCost: 99})
+	assert.Error(t, err)
+}
+
+func TestCheckPassword_UnknownFormat(t *testing.T) {
+	assert.ErrorIs(t, utils.CheckPassword("secret", "plaintext"), utils.ErrUnknownHashFormat)
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -42,6 +43,17 @@ func SendSuccess(c *fiber.Ctx, status int, message string, data interface{}) err
 }
 
 func SendValidationError(c *fiber.Ctx, err error) error {
+	var policyErr *PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "validation_failed",
+			Message: "Password does not meet the password policy",
+			Details: map[string]interface{}{
+				"password": policyErr.Violations,
+			},
+		})
+	}
+
 	if validationErrors, ok := err.(validator.ValidationErrors); ok {
 		details := make(map[string]interface{})
 		
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"rbac-system/backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a parsed key pair identified by its kid.
+type SigningKey struct {
+	KID        string
+	Algorithm  string
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+// KeyProvider supplies asymmetric keys to the JWTService. CurrentKey signs new
+// tokens; Key looks up the key named by a token's kid header.
+type KeyProvider interface {
+	CurrentKey() (*SigningKey, error)
+	Key(kid string) (*SigningKey, error)
+}
+
+func (k *SigningKey) Method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// IsAsymmetricAlgorithm reports whether alg is one of the supported
+// asymmetric JWT algorithms.
+func IsAsymmetricAlgorithm(alg string) bool {
+	switch alg {
+	case "RS256", "ES256", "EdDSA":
+		return true
+	}
+	return false
+}
+
+// GenerateSigningKeyPair creates a new key pair for alg and returns both
+// halves PEM encoded (PKCS #8 private key, PKIX public key).
+func GenerateSigningKeyPair(alg string) (string, string, error) {
+	var privateKey crypto.Signer
+	var err error
+
+	switch alg {
+	case "RS256":
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", "", fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", "", err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return "", "", err
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	return string(privatePEM), string(publicPEM), nil
+}
+
+// ParseSigningKey decodes a stored key pair.
+func ParseSigningKey(kid, alg, privatePEM, publicPEM string) (*SigningKey, error) {
+	privateBlock, _ := pem.Decode([]byte(privatePEM))
+	if privateBlock == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(privateBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	publicBlock, _ := pem.Decode([]byte(publicPEM))
+	if publicBlock == nil {
+		return nil, errors.New("invalid public key PEM")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(publicBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{
+		KID:        kid,
+		Algorithm:  alg,
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+	}, nil
+}
+
+// JWK returns the public half of the key in JSON Web Key format.
+func (k *SigningKey) JWK() (models.JWK, error) {
+	jwk := models.JWK{Kid: k.KID, Use: "sig", Alg: k.Algorithm}
+
+	switch publicKey := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = publicKey.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return models.JWK{}, errors.New("unsupported public key type")
+	}
+
+	return jwk, nil
+}
+
+// ParseJWK decodes the public key in a JSON Web Key, such as one published by
+// an external identity provider.
+func ParseJWK(jwk models.JWK) (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		publicKey := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, errors.New("invalid EC key")
+		}
+		return publicKey, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
@@ -0,0 +1,49 @@
+package utils_test
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"rbac-system/backend/internal/utils"
+)
+
+// rfc6238Secret is the SHA-1 seed from the RFC 6238 test vectors.
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateTOTPCode_RFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; the 6-digit codes are their last six digits.
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := utils.GenerateTOTPCode(rfc6238Secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	step, ok := utils.ValidateTOTPCode(rfc6238Secret, "050471", now)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1111111111/30), step)
+
+	_, ok = utils.ValidateTOTPCode(rfc6238Secret, "050471", now.Add(30*time.Second))
+	assert.True(t, ok, "Codes from the previous step should be accepted")
+
+	_, ok = utils.ValidateTOTPCode(rfc6238Secret, "050471", now.Add(2*time.Minute))
+	assert.False(t, ok, "Codes outside the drift window should be rejected")
+
+	_, ok = utils.ValidateTOTPCode(rfc6238Secret, "000000", now)
+	assert.False(t, ok)
+}